@@ -7,12 +7,48 @@ package ossa
 type BasicBlock struct {
 	Instructions []*Value
 	Terminator   *Terminator
+
+	// Name is an optional name for the block, used only for debugging in
+	// the same way as Value.Name.
+	Name string
+
+	// Region is an optional opaque identifier for a frontend-defined region
+	// that the block belongs to, such as a source-level scope, a monitor
+	// region, or a transaction. ossa itself attaches no meaning to it, but
+	// transforms that rearrange the control flow graph must preserve it and
+	// must not merge blocks that belong to different regions.
+	//
+	// If set, the value must be comparable using the == operator. The nil
+	// value represents the absence of any region.
+	Region interface{}
+
+	// NoSpeculate, if set, forbids transforms from executing the block's
+	// instructions speculatively, on paths where they would not otherwise
+	// have run. Hoisting instructions out of the block, or converting
+	// control flow around the block into data flow, are examples of
+	// such speculation.
+	//
+	// This is intended for frontends whose security model depends on
+	// certain operations not being executed speculatively, such as
+	// bounds-checked memory accesses.
+	NoSpeculate bool
+
+	// meta is the block's metadata, as described by MetaHolder.
+	meta metadata
 }
 
 func NewBasicBlock() *BasicBlock {
 	return &BasicBlock{}
 }
 
+// SameRegion returns true if the receiver and the given other block belong to
+// the same region, as defined by the Region field.
+//
+// Transforms that merge blocks together must check this before merging.
+func (b *BasicBlock) SameRegion(other *BasicBlock) bool {
+	return b.Region == other.Region
+}
+
 // AddSuccessors adds the successors of this block to the given set, modifying
 // it in-place.
 func (b *BasicBlock) AddSuccessors(to BasicBlockAdder) {
@@ -40,7 +76,7 @@ func (b *BasicBlock) AddReachable(to BasicBlockSet) {
 			continue
 		}
 		to.Add(block)
-		todo = b.Terminator.AppendSuccessors(todo)
+		todo = block.Terminator.AppendSuccessors(todo)
 	}
 }
 
@@ -0,0 +1,38 @@
+package ossa
+
+import (
+	"testing"
+)
+
+func TestBasicBlockAddReachable(t *testing.T) {
+	a := NewBasicBlock()
+	b := NewBasicBlock()
+	c := NewBasicBlock()
+	d := NewBasicBlock()
+	unreached := NewBasicBlock()
+	a.Terminator = Jump(b)
+	b.Terminator = Branch(ConstLiteral(BoolConst(true)), c, d)
+	c.Terminator = Jump(b)
+	d.Terminator = Return()
+	unreached.Terminator = Jump(a)
+
+	got := make(BasicBlockSet)
+	a.AddReachable(got)
+	for _, block := range []*BasicBlock{a, b, c, d} {
+		if !got.Has(block) {
+			t.Errorf("block %p is not reachable; want reachable", block)
+		}
+	}
+	if got.Has(unreached) {
+		t.Errorf("block %p is reachable; want unreachable", unreached)
+	}
+
+	// Blocks already in the set are assumed to have their descendents
+	// present too, so they are not visited again.
+	got = make(BasicBlockSet)
+	got.Add(b)
+	a.AddReachable(got)
+	if !got.Has(a) || got.Has(c) || got.Has(d) {
+		t.Errorf("AddReachable visited the successors of a block already in the set")
+	}
+}
@@ -8,139 +8,308 @@ package ossa
 // package, but also as a side-effect appends new instructions to the basic
 // block, effectively recording the order of operations.
 //
+// By default a builder appends at the end of its block, but it can instead be
+// given an insertion point elsewhere in the block using methods such as
+// SetInsertBefore. Each instruction inserted in the middle of a block is
+// placed after the previous one, so a sequence of calls produces instructions
+// in the same order as the calls.
+//
 // Once a terminator instruction has been appended, the builder is closed and
-// any further appending calls will panic.
+// any further appending calls will panic until the builder is pointed at a
+// different block using SetBlock, or at an insertion point before the end of
+// the block.
+//
+// A builder tracks its current block mutably, so it must be used by pointer
+// and shared between all of the code that is generating a particular
+// function.
 type Builder struct {
 	block *BasicBlock
+
+	// index is the position in block.Instructions where the next instruction
+	// will be inserted, or -1 to append to the end of the block.
+	index int
+
+	// fn is the function that new blocks are added to, or nil if the builder
+	// is not associated with a function.
+	fn *Function
+
+	// preds records the predecessors of each block, as implied by the
+	// terminators appended by this builder. This is used by the variable
+	// tracking in builder_vars.go.
+	preds map[*BasicBlock][]*BasicBlock
+
+	// vars is the state for the variable tracking in builder_vars.go, which
+	// is allocated only once the first variable is declared.
+	vars *builderVars
+
+	// pos is the source position assigned to new instructions and
+	// terminators, or nil if they should not have a position.
+	pos Pos
 }
 
-// NewBuilder constructs and returns a new builder.
-func NewBuilder(block *BasicBlock) Builder {
-	return Builder{
+// NewBuilder constructs and returns a new builder that appends to the given
+// block.
+//
+// Blocks created with NewBlock on the result are not owned by any function.
+// Use NewFunctionBuilder instead to create blocks that belong to a function.
+func NewBuilder(block *BasicBlock) *Builder {
+	return &Builder{
 		block: block,
+		index: -1,
+	}
+}
+
+// NewFunctionBuilder constructs and returns a new builder that initially
+// appends to the entry block of the given function. Blocks created with
+// NewBlock will be owned by the same function.
+func NewFunctionBuilder(f *Function) *Builder {
+	return &Builder{
+		block: f.Entry(),
+		index: -1,
+		fn:    f,
 	}
 }
 
 // Block returns the block currently associated with the receiver.
-func (b Builder) Block() *BasicBlock {
+func (b *Builder) Block() *BasicBlock {
 	return b.block
 }
 
-// SetBlock points the receiver at a different basic block. All future append
-// operations will therefore apply to the new block.
-func (b Builder) SetBlock(block *BasicBlock) {
+// Function returns the function associated with the receiver, or nil if
+// it was not constructed by NewFunctionBuilder.
+func (b *Builder) Function() *Function {
+	return b.fn
+}
+
+// SetBlock points the receiver at the end of a different basic block. All
+// future append operations will therefore apply to the new block.
+func (b *Builder) SetBlock(block *BasicBlock) {
+	b.block = block
+	b.index = -1
+}
+
+// SetInsertAtStart points the receiver at the start of the given block, so
+// that future instructions will be inserted before any existing instructions.
+func (b *Builder) SetInsertAtStart(block *BasicBlock) {
+	b.block = block
+	b.index = 0
+}
+
+// SetInsertBefore points the receiver at the position immediately before the
+// given instruction in the given block. It panics if the instruction does not
+// belong to the block.
+func (b *Builder) SetInsertBefore(block *BasicBlock, inst *Value) {
+	b.block = block
+	b.index = instructionIndex(block, inst)
+}
+
+// SetInsertAfter points the receiver at the position immediately after the
+// given instruction in the given block. It panics if the instruction does not
+// belong to the block.
+func (b *Builder) SetInsertAfter(block *BasicBlock, inst *Value) {
 	b.block = block
+	b.index = instructionIndex(block, inst) + 1
+}
+
+func instructionIndex(block *BasicBlock, inst *Value) int {
+	for i, v := range block.Instructions {
+		if v == inst {
+			return i
+		}
+	}
+	panic("instruction does not belong to block")
 }
 
-// NewBlock is a helper for allocating a new, empty basic block and wrapping
-// a builder around it.
-func (b Builder) NewBlock() Builder {
-	block := &BasicBlock{}
-	return NewBuilder(block)
+// NewBlock allocates a new, empty basic block and returns it. If the receiver
+// is associated with a function then the new block is owned by that function.
+//
+// NewBlock does not change the receiver's current block. Call SetBlock with
+// the result to begin appending to the new block.
+func (b *Builder) NewBlock() *BasicBlock {
+	if b.fn != nil {
+		return b.fn.NewBlock()
+	}
+	return NewBasicBlock()
 }
 
 // Open returns true if the builder is open to new instructions. That is, if
-// the wrapped block does not yet have a terminator.
-func (b Builder) Open() bool {
-	return b.block.Terminator == nil
+// the wrapped block does not yet have a terminator or if the builder's
+// insertion point is before the end of the block.
+func (b *Builder) Open() bool {
+	return b.block.Terminator == nil || b.index >= 0
+}
+
+// SetPos sets the source position that the builder assigns to the
+// instructions and terminators it appends from now on, unless they already
+// have a position. A nil position stops the builder from assigning
+// positions.
+func (b *Builder) SetPos(pos Pos) {
+	b.pos = pos
+}
+
+// Pos returns the source position most recently set with SetPos.
+func (b *Builder) Pos() Pos {
+	return b.pos
 }
 
-func (b Builder) appendInstruction(v *Value) *Value {
+func (b *Builder) appendInstruction(v *Value) *Value {
 	if !b.Open() {
 		panic("append to closed block")
 	}
-	b.block.Instructions = append(b.block.Instructions, v)
+	if v.pos == nil {
+		v.pos = b.pos
+	}
+	if b.index < 0 {
+		b.block.Instructions = append(b.block.Instructions, v)
+		return v
+	}
+	insts := append(b.block.Instructions, nil)
+	copy(insts[b.index+1:], insts[b.index:])
+	insts[b.index] = v
+	b.block.Instructions = insts
+	b.index++
 	return v
 }
 
-func (b Builder) appendTerminator(t *Terminator) *Terminator {
+func (b *Builder) appendTerminator(t *Terminator) *Terminator {
+	if b.index >= 0 {
+		panic("terminator must be appended at the end of a block")
+	}
 	if !b.Open() {
 		panic("append to closed block")
 	}
+	if t.pos == nil && t != Unreachable {
+		t.pos = b.pos
+	}
 	b.block.Terminator = t
+	b.recordPredecessors(b.block)
 	return t
 }
 
 // AuxLiteral is a convenience alias for the top-level function of the
 // same name. Because literals do not have side-effects, it does not append
 // to the block's instruction list.
-func (b Builder) AuxLiteral(v interface{}) *Value {
+func (b *Builder) AuxLiteral(v interface{}) *Value {
 	return AuxLiteral(v)
 }
 
 // GlobalSym is a convenience alias for the top-level function of the
 // same name. Because symbols do not have side-effects, it does not append
 // to the block's instruction list.
-func (b Builder) GlobalSym() *Value {
+func (b *Builder) GlobalSym() *Value {
 	return GlobalSym()
 }
 
 // LocalSym is a convenience alias for the top-level function of the
 // same name. Because symbols do not have side-effects, it does not append
 // to the block's instruction list.
-func (b Builder) LocalSym() *Value {
+func (b *Builder) LocalSym() *Value {
 	return LocalSym()
 }
 
 // Argument is a convenience alias for the top-level function of the
 // same name. Because symbols do not have side-effects, it does not append
 // to the block's instruction list.
-func (b Builder) Argument() *Value {
+func (b *Builder) Argument() *Value {
 	return Argument()
 }
 
+// Undef is a convenience alias for the top-level function of the
+// same name. Because undefined values do not have side-effects, it does not
+// append to the block's instruction list.
+func (b *Builder) Undef() *Value {
+	return Undef()
+}
+
+// Poison is a convenience alias for the top-level function of the
+// same name. Because poison values do not have side-effects, it does not
+// append to the block's instruction list.
+func (b *Builder) Poison() *Value {
+	return Poison()
+}
+
 // Phi constructs and appends a Phi operation to the underlying block.
-func (b Builder) Phi(candidates ...BasicBlockValue) *Value {
+func (b *Builder) Phi(candidates ...BasicBlockValue) *Value {
 	return b.appendInstruction(Phi(candidates...))
 }
 
 // Load constructs and appends a Load operation to the underlying block.
-func (b Builder) Load(ref *Value) *Value {
+func (b *Builder) Load(ref *Value) *Value {
 	return b.appendInstruction(Load(ref))
 }
 
 // Store constructs and appends a Store operation to the underlying block.
-func (b Builder) Store(val, ref *Value) *Value {
+func (b *Builder) Store(val, ref *Value) *Value {
 	return b.appendInstruction(Store(val, ref))
 }
 
 // Call constructs and appends a Call to the underlying block.
-func (b Builder) Call(callee *Value, args ...*Value) *Value {
+func (b *Builder) Call(callee *Value, args ...*Value) *Value {
 	return b.appendInstruction(Call(callee, args...))
 }
 
+// Select constructs and appends a Select operation to the underlying block.
+func (b *Builder) Select(cond, ifTrue, ifFalse *Value) *Value {
+	return b.appendInstruction(Select(cond, ifTrue, ifFalse))
+}
+
+// LandingPad constructs and appends a LandingPad operation to the underlying
+// block.
+func (b *Builder) LandingPad() *Value {
+	return b.appendInstruction(LandingPad())
+}
+
 // Jump constructs a Jump terminator and uses it to terminate the underlying
 // block, closing the builder.
-func (b Builder) Jump(target *BasicBlock) *Terminator {
+func (b *Builder) Jump(target *BasicBlock) *Terminator {
 	return b.appendTerminator(Jump(target))
 }
 
 // Branch constructs a Branch terminator and uses it to terminate the underlying
 // block, closing the builder.
-func (b Builder) Branch(cond *Value, trueTarget, falseTarget *BasicBlock) *Terminator {
+func (b *Builder) Branch(cond *Value, trueTarget, falseTarget *BasicBlock) *Terminator {
 	return b.appendTerminator(Branch(cond, trueTarget, falseTarget))
 }
 
 // Switch constructs a Switch terminator and uses it to terminate the underlying
 // block, closing the builder.
-func (b Builder) Switch(inp *Value, defTarget *BasicBlock, cases ...BasicBlockValue) *Terminator {
+func (b *Builder) Switch(inp *Value, defTarget *BasicBlock, cases ...BasicBlockValue) *Terminator {
 	return b.appendTerminator(Switch(inp, defTarget, cases...))
 }
 
 // Return constructs a Return terminator and uses it to terminate the underlying
 // block, closing the builder.
-func (b Builder) Return(ret *Value) *Terminator {
-	return b.appendTerminator(Return(ret))
+func (b *Builder) Return(vals ...*Value) *Terminator {
+	return b.appendTerminator(Return(vals...))
 }
 
 // Yield constructs a Yield terminator and uses it to terminate the underlying
 // block, closing the builder.
-func (b Builder) Yield(resume *BasicBlock) *Terminator {
+func (b *Builder) Yield(resume *BasicBlock) *Terminator {
 	return b.appendTerminator(Yield(resume))
 }
 
 // Await constructs a Await terminator and uses it to terminate the underlying
 // block, closing the builder.
-func (b Builder) Await(event *Value, resume *BasicBlock) *Terminator {
+func (b *Builder) Await(event *Value, resume *BasicBlock) *Terminator {
 	return b.appendTerminator(Await(event, resume))
 }
+
+// Invoke constructs and appends a Call of the given callee and arguments, and
+// then uses an Invoke terminator for that call to terminate the underlying
+// block, closing the builder. It returns the call, whose result is available
+// only in the normal target and the blocks it leads to.
+func (b *Builder) Invoke(callee *Value, normal, unwind *BasicBlock, args ...*Value) *Value {
+	if b.index >= 0 {
+		panic("terminator must be appended at the end of a block")
+	}
+	call := b.Call(callee, args...)
+	b.appendTerminator(Invoke(call, normal, unwind))
+	return call
+}
+
+// Resume constructs a Resume terminator and uses it to terminate the
+// underlying block, closing the builder.
+func (b *Builder) Resume(exc *Value) *Terminator {
+	return b.appendTerminator(Resume(exc))
+}
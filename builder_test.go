@@ -0,0 +1,312 @@
+package ossa
+
+import (
+	"testing"
+)
+
+func TestBuilderSetBlock(t *testing.T) {
+	f := NewFunction()
+	b := NewFunctionBuilder(f)
+	entry := b.Block()
+	if entry != f.Entry() {
+		t.Fatalf("builder does not start at the entry block")
+	}
+
+	next := b.NewBlock()
+	if b.Block() != entry {
+		t.Errorf("NewBlock changed the current block")
+	}
+	if !f.HasBlock(next) {
+		t.Errorf("new block is not owned by the function")
+	}
+
+	b.Jump(next)
+	if b.Open() {
+		t.Errorf("builder is still open after appending a terminator")
+	}
+
+	// SetBlock must be visible through the same builder, so that helper
+	// functions that receive the builder can move it to other blocks.
+	moveTo := func(b *Builder, block *BasicBlock) {
+		b.SetBlock(block)
+	}
+	moveTo(b, next)
+	if b.Block() != next {
+		t.Fatalf("SetBlock did not persist")
+	}
+	if !b.Open() {
+		t.Errorf("builder is not open after moving to a new block")
+	}
+	v := b.Load(b.LocalSym())
+	b.Return(v)
+	if len(next.Instructions) != 1 || next.Instructions[0] != v {
+		t.Errorf("instruction was not appended to the new block")
+	}
+	if len(entry.Instructions) != 0 {
+		t.Errorf("instruction was appended to the old block")
+	}
+}
+
+func TestBuilderInvoke(t *testing.T) {
+	f := NewFunction()
+	b := NewFunctionBuilder(f)
+	entry := b.Block()
+	normal := b.NewBlock()
+	unwind := b.NewBlock()
+	callee := b.GlobalSym()
+	arg := b.AuxLiteral(1)
+
+	call := b.Invoke(callee, normal, unwind, arg)
+	if b.Open() {
+		t.Errorf("builder is still open after Invoke")
+	}
+	if call.Op() != OpCall || call.Arg(0) != callee || call.Arg(1) != arg {
+		t.Errorf("Invoke did not construct the expected call")
+	}
+	if len(entry.Instructions) != 1 || entry.Instructions[0] != call {
+		t.Errorf("call was not appended to the block")
+	}
+	term := entry.Terminator
+	if term.Op() != OpInvoke || term.Arg(0).Value != call {
+		t.Errorf("block does not end with an Invoke of the call")
+	}
+	succs := term.AppendSuccessors(nil)
+	if len(succs) != 2 || succs[0] != normal || succs[1] != unwind {
+		t.Errorf("wrong successors for Invoke")
+	}
+
+	b.SetBlock(unwind)
+	exc := b.LandingPad()
+	b.Resume(exc)
+	if got := unwind.Terminator.AppendSuccessors(nil); len(got) != 0 {
+		t.Errorf("Resume has successors")
+	}
+	if exc.Equivalent(LandingPad()) {
+		t.Errorf("distinct landing pads are equivalent")
+	}
+}
+
+func TestBuilderInsertionPoint(t *testing.T) {
+	f := NewFunction()
+	b := NewFunctionBuilder(f)
+	entry := f.Entry()
+
+	sym := b.LocalSym()
+	first := b.Load(sym)
+	last := b.Load(sym)
+	b.Return(last)
+
+	// Even though the block is now closed, we can still hoist instructions
+	// to the top of it or insert them between existing instructions.
+	b.SetInsertAtStart(entry)
+	if !b.Open() {
+		t.Fatalf("builder is closed after SetInsertAtStart")
+	}
+	hoisted1 := b.Store(b.AuxLiteral(1), sym)
+	hoisted2 := b.Store(b.AuxLiteral(2), sym)
+
+	b.SetInsertAfter(entry, first)
+	middle := b.Load(sym)
+
+	b.SetInsertBefore(entry, first)
+	beforeFirst := b.Load(sym)
+
+	want := []*Value{hoisted1, hoisted2, beforeFirst, first, middle, last}
+	got := entry.Instructions
+	if len(got) != len(want) {
+		t.Fatalf("wrong number of instructions %d; want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("wrong instruction at index %d", i)
+		}
+	}
+
+	b.SetBlock(entry)
+	if b.Open() {
+		t.Errorf("builder is open at the end of a terminated block")
+	}
+}
+
+func TestBuilderVariables(t *testing.T) {
+	// This builds the equivalent of the following:
+	//
+	//     x := 0
+	//     y := 1
+	//     for x < limit {
+	//         x = x + y
+	//     }
+	//     if x == 5 {
+	//         y = 2
+	//     }
+	//     return x + y
+	f := NewFunction()
+	limit := f.AddParam()
+	b := NewFunctionBuilder(f)
+	lt := b.AuxLiteral("lt")
+	add := b.AuxLiteral("add")
+	eq := b.AuxLiteral("eq")
+	zero := b.AuxLiteral(0)
+	one := b.AuxLiteral(1)
+	two := b.AuxLiteral(2)
+	five := b.AuxLiteral(5)
+
+	entry := b.Block()
+	x := b.DeclareVar()
+	y := b.DeclareVar()
+	b.WriteVar(x, zero)
+	b.WriteVar(y, one)
+	b.SealBlock(entry)
+	header := b.NewBlock()
+	b.Jump(header)
+
+	// The loop header is not sealed yet because the loop body will also
+	// jump back to it.
+	b.SetBlock(header)
+	body := b.NewBlock()
+	afterLoop := b.NewBlock()
+	cond := b.Call(lt, b.ReadVar(x), limit)
+	b.Branch(cond, body, afterLoop)
+
+	b.SealBlock(body)
+	b.SetBlock(body)
+	b.WriteVar(x, b.Call(add, b.ReadVar(x), b.ReadVar(y)))
+	b.Jump(header)
+	b.SealBlock(header)
+
+	b.SealBlock(afterLoop)
+	b.SetBlock(afterLoop)
+	then := b.NewBlock()
+	join := b.NewBlock()
+	b.Branch(b.Call(eq, b.ReadVar(x), five), then, join)
+	b.SealBlock(then)
+	b.SetBlock(then)
+	b.WriteVar(y, two)
+	b.Jump(join)
+	b.SealBlock(join)
+	b.SetBlock(join)
+	result := b.Call(add, b.ReadVar(x), b.ReadVar(y))
+	b.Return(result)
+
+	// The loop header should have a Phi node for x, since it's modified in
+	// the loop body. Because y was read in the body before the header was
+	// sealed, the header may also have a trivial Phi node for y, which we
+	// don't assert on since it is only a limitation of ReadVar.
+	xPhi := header.Instructions[0]
+	if xPhi.Op() != OpPhi {
+		t.Fatalf("first instruction in loop header is %s; want OpPhi", xPhi.Op())
+	}
+	if got, want := xPhi.NumArgs(), 2; got != want {
+		t.Fatalf("wrong number of candidates for x in loop header %d; want %d", got, want)
+	}
+	if c := xPhi.PhiCandidate(0); c.Block != entry || c.Value != zero {
+		t.Errorf("wrong first candidate for x in loop header")
+	}
+	sum := body.Instructions[0]
+	if c := xPhi.PhiCandidate(1); c.Block != body || c.Value != sum {
+		t.Errorf("wrong second candidate for x in loop header")
+	}
+	for _, inst := range header.Instructions[1:] {
+		if inst.Op() == OpPhi && skipTrivialPhi(inst) == inst {
+			t.Errorf("loop header has a non-trivial Phi node other than for x")
+		}
+	}
+	if skipTrivialPhi(sum.Arg(2)) != one {
+		t.Errorf("wrong value for y in loop body")
+	}
+	if sum.Arg(1) != xPhi {
+		t.Errorf("wrong value for x in loop body")
+	}
+
+	// The join block needs a Phi node for y, but x is the same on both
+	// paths and so it should come directly from the loop header. The
+	// afterLoop block has only one predecessor, so it needs no Phi nodes.
+	if got, want := len(afterLoop.Instructions), 1; got != want {
+		t.Errorf("wrong number of instructions in afterLoop %d; want %d", got, want)
+	}
+	if got, want := len(join.Instructions), 2; got != want {
+		t.Fatalf("wrong number of instructions in join block %d; want %d", got, want)
+	}
+	yJoinPhi := join.Instructions[0]
+	if yJoinPhi.Op() != OpPhi || yJoinPhi.NumArgs() != 2 {
+		t.Fatalf("first instruction in join block is not a Phi with two candidates")
+	}
+	if c := yJoinPhi.PhiCandidate(0); c.Block != afterLoop || skipTrivialPhi(c.Value) != one {
+		t.Errorf("wrong first candidate for y in join block")
+	}
+	if c := yJoinPhi.PhiCandidate(1); c.Block != then || c.Value != two {
+		t.Errorf("wrong second candidate for y in join block")
+	}
+	if result.Arg(1) != xPhi || result.Arg(2) != yJoinPhi {
+		t.Errorf("wrong operands for result")
+	}
+}
+
+// skipTrivialPhi returns the only candidate of the given Phi node other than
+// itself, if it has only one, or returns the given value otherwise.
+func skipTrivialPhi(v *Value) *Value {
+	if v.Op() != OpPhi {
+		return v
+	}
+	var same *Value
+	for i := 0; i < v.NumArgs(); i++ {
+		switch arg := v.Arg(i); {
+		case arg == v || arg == same:
+		case same == nil:
+			same = arg
+		default:
+			return v
+		}
+	}
+	return same
+}
+
+type testPos string
+
+func (p testPos) String() string {
+	return string(p)
+}
+
+func TestBuilderPos(t *testing.T) {
+	f := NewFunction()
+	b := NewFunctionBuilder(f)
+	p := f.AddParam()
+
+	unset := b.Load(p)
+	b.SetPos(testPos("a.src:1"))
+	load := b.Load(p)
+	b.SetPos(testPos("a.src:2"))
+	ret := b.Return(load)
+
+	if got := unset.Pos(); got != nil {
+		t.Errorf("instruction has position %v before SetPos", got)
+	}
+	if got, want := load.Pos(), Pos(testPos("a.src:1")); got != want {
+		t.Errorf("wrong instruction position %v; want %v", got, want)
+	}
+	if got, want := ret.Pos(), Pos(testPos("a.src:2")); got != want {
+		t.Errorf("wrong terminator position %v; want %v", got, want)
+	}
+}
+
+func TestBuilderNewBlockBuilder(t *testing.T) {
+	f := NewFunction()
+	b := NewFunctionBuilder(f)
+	nb := b.NewBlockBuilder()
+	if nb == b {
+		t.Fatalf("NewBlockBuilder returned the receiver")
+	}
+	if b.Block() != f.Entry() {
+		t.Errorf("receiver's block changed")
+	}
+	if !f.HasBlock(nb.Block()) || nb.Block() == f.Entry() {
+		t.Errorf("new builder's block is not a new block in the function")
+	}
+	if nb.Function() != f {
+		t.Errorf("new builder is not associated with the function")
+	}
+	nb.Return()
+	if nb.Block().Terminator == nil || b.Block().Terminator != nil {
+		t.Errorf("new builder appended to the wrong block")
+	}
+}
@@ -0,0 +1,298 @@
+package ossa
+
+// Variable represents a mutable variable in the source language, which
+// a Builder can translate into SSA form automatically as the frontend reads
+// and writes it, inserting Phi nodes where necessary.
+//
+// Variables are created by Builder.DeclareVar and are meaningful only to the
+// builder that created them.
+type Variable struct {
+	// id is here both to help with debugging and to ensure that Variable
+	// is not zero-sized, so that each pointer to a Variable is unique.
+	id int
+}
+
+// builderVars is the state used by a Builder to track variables, using the
+// algorithm from "Simple and Efficient Construction of Static Single
+// Assignment Form" by Braun et al.
+type builderVars struct {
+	count int
+
+	// defs is the current definition of each variable in each block.
+	defs map[*Variable]map[*BasicBlock]*Value
+
+	// sealed is the set of blocks whose predecessors are all known.
+	sealed BasicBlockSet
+
+	// incomplete are the Phi nodes created in blocks that are not yet
+	// sealed, which will have their candidates populated once the block
+	// is sealed.
+	incomplete map[*BasicBlock][]incompletePhi
+
+	// phiBlocks records the block that each of our Phi nodes belongs to.
+	phiBlocks map[*Value]*BasicBlock
+
+	// phiUsers records which of our Phi nodes use each of our other Phi
+	// nodes as a candidate, so that we can update them if a Phi node is
+	// removed.
+	phiUsers map[*Value][]*Value
+
+	// exposed is the set of our Phi nodes that have been returned from
+	// ReadVar, which we therefore cannot remove because the frontend may
+	// have used them as operands in other instructions.
+	exposed ValueSet
+
+	// filling is the set of our Phi nodes whose candidates are currently
+	// being populated, which must not be considered for removal until
+	// they are complete.
+	filling ValueSet
+}
+
+type incompletePhi struct {
+	v   *Variable
+	phi *Value
+}
+
+// DeclareVar creates a new variable that can be used with the receiver's
+// WriteVar and ReadVar methods.
+//
+// Builders determine which definition of a variable is visible in a
+// particular block by analyzing the predecessors of that block, which they
+// learn as terminators are appended. This means that variables work only
+// with blocks whose terminators are all appended using the same builder,
+// and each block must be sealed using SealBlock once all of its
+// predecessors have been terminated.
+func (b *Builder) DeclareVar() *Variable {
+	if b.vars == nil {
+		b.vars = &builderVars{
+			defs:       make(map[*Variable]map[*BasicBlock]*Value),
+			sealed:     make(BasicBlockSet),
+			incomplete: make(map[*BasicBlock][]incompletePhi),
+			phiBlocks:  make(map[*Value]*BasicBlock),
+			phiUsers:   make(map[*Value][]*Value),
+			exposed:    make(ValueSet),
+			filling:    make(ValueSet),
+		}
+	}
+	b.vars.count++
+	v := &Variable{id: b.vars.count}
+	b.vars.defs[v] = make(map[*BasicBlock]*Value)
+	return v
+}
+
+// WriteVar records that the given value is the new definition of the given
+// variable in the receiver's current block.
+func (b *Builder) WriteVar(v *Variable, val *Value) {
+	b.vars.defs[v][b.block] = val
+}
+
+// ReadVar returns the value of the given variable that is visible in the
+// receiver's current block, inserting Phi nodes in the current block or
+// its predecessors as necessary.
+//
+// If the variable has no definition on any path to the current block,
+// ReadVar returns nil.
+//
+// When reading from a block that is not yet sealed, ReadVar may return a Phi
+// node that later turns out to be trivial, because all of its candidates
+// other than itself are the same value. Such Phi nodes are left in place.
+// The algorithm by Braun et al. would remove them and replace all of their
+// uses, but the frontend may also hold a returned value in its own data
+// structures, where the builder cannot find it. A trivial Phi node is still
+// correct, and merely redundant.
+func (b *Builder) ReadVar(v *Variable) *Value {
+	val := b.readVar(v, b.block)
+	if _, isPhi := b.vars.phiBlocks[val]; isPhi {
+		b.vars.exposed.Add(val)
+	}
+	return val
+}
+
+// SealBlock records that all of the predecessors of the given block have been
+// terminated, and so all of the incoming control flow edges are known. Any
+// Phi nodes previously created for variables in that block will have their
+// candidates populated.
+//
+// A block must be sealed only once, and no further terminators may target a
+// sealed block.
+func (b *Builder) SealBlock(block *BasicBlock) {
+	if b.vars == nil {
+		// No variables have been declared, so there's nothing to do.
+		return
+	}
+	if b.vars.sealed.Has(block) {
+		panic("block is already sealed")
+	}
+	for _, inc := range b.vars.incomplete[block] {
+		b.addPhiCandidates(inc.v, inc.phi, block)
+	}
+	delete(b.vars.incomplete, block)
+	b.vars.sealed.Add(block)
+}
+
+// recordPredecessors updates the receiver's predecessor table to reflect the
+// terminator that was just appended to the given block.
+func (b *Builder) recordPredecessors(block *BasicBlock) {
+	if b.preds == nil {
+		b.preds = make(map[*BasicBlock][]*BasicBlock)
+	}
+	// A terminator can produce the same successor more than once, but
+	// we only want to record each edge once.
+	seen := make(BasicBlockSet)
+	block.AddSuccessors(basicBlockAdderFunc(func(succ *BasicBlock) {
+		if seen.Has(succ) {
+			return
+		}
+		seen.Add(succ)
+		if b.vars != nil && b.vars.sealed.Has(succ) {
+			panic("new predecessor for sealed block")
+		}
+		b.preds[succ] = append(b.preds[succ], block)
+	}))
+}
+
+func (b *Builder) readVar(v *Variable, block *BasicBlock) *Value {
+	if val, exists := b.vars.defs[v][block]; exists {
+		return val
+	}
+
+	var val *Value
+	preds := b.preds[block]
+	switch {
+	case !b.vars.sealed.Has(block):
+		val = b.newPhi(block)
+		b.vars.incomplete[block] = append(b.vars.incomplete[block], incompletePhi{v, val})
+	case len(preds) == 0:
+		val = nil // undefined
+	case len(preds) == 1:
+		val = b.readVar(v, preds[0])
+	default:
+		// We must record the new Phi node as the definition before we visit
+		// the predecessors, so that any loops will terminate here.
+		val = b.newPhi(block)
+		b.vars.defs[v][block] = val
+		val = b.addPhiCandidates(v, val, block)
+	}
+	b.vars.defs[v][block] = val
+	return val
+}
+
+// newPhi inserts a new Phi node with no candidates at the start of the given
+// block.
+func (b *Builder) newPhi(block *BasicBlock) *Value {
+	phi := Phi()
+
+	// We'll place our new Phi node after any existing Phi nodes, so that
+	// all of the Phi nodes stay together at the start of the block.
+	idx := 0
+	for idx < len(block.Instructions) && block.Instructions[idx].op == OpPhi {
+		idx++
+	}
+	insts := append(block.Instructions, nil)
+	copy(insts[idx+1:], insts[idx:])
+	insts[idx] = phi
+	block.Instructions = insts
+	if block == b.block && b.index >= idx {
+		// Keep the insertion point pointing at the same instruction.
+		b.index++
+	}
+
+	b.vars.phiBlocks[phi] = block
+	return phi
+}
+
+func (b *Builder) addPhiCandidates(v *Variable, phi *Value, block *BasicBlock) *Value {
+	b.vars.filling.Add(phi)
+	for _, pred := range b.preds[block] {
+		val := b.readVar(v, pred)
+		phi.AddPhiCandidate(BasicBlockValue{Block: pred, Value: val})
+		if _, isPhi := b.vars.phiBlocks[val]; isPhi {
+			b.vars.phiUsers[val] = append(b.vars.phiUsers[val], phi)
+		}
+	}
+	b.vars.filling.Remove(phi)
+	return b.tryRemoveTrivialPhi(phi)
+}
+
+// tryRemoveTrivialPhi checks whether the given Phi node has only one distinct
+// candidate value other than itself. If so, it removes the Phi node and
+// replaces all of our references to it with that value, returning it.
+// Otherwise, it returns the Phi node unchanged.
+func (b *Builder) tryRemoveTrivialPhi(phi *Value) *Value {
+	if b.vars.exposed.Has(phi) {
+		return phi
+	}
+	var same *Value
+	sameSet := false
+	for i := 0; i < phi.NumArgs(); i++ {
+		arg := phi.Arg(i)
+		if (sameSet && arg == same) || arg == phi {
+			continue
+		}
+		if sameSet {
+			return phi // not trivial, because it merges at least two values
+		}
+		same = arg
+		sameSet = true
+	}
+
+	// Remove the Phi node from its block.
+	block := b.vars.phiBlocks[phi]
+	insts := block.Instructions
+	for i, inst := range insts {
+		if inst == phi {
+			copy(insts[i:], insts[i+1:])
+			insts[len(insts)-1] = nil
+			block.Instructions = insts[:len(insts)-1]
+			if block == b.block && b.index > i {
+				b.index--
+			}
+			break
+		}
+	}
+	delete(b.vars.phiBlocks, phi)
+
+	// Replace our references to the Phi node.
+	for _, defs := range b.vars.defs {
+		for defBlock, def := range defs {
+			if def == phi {
+				defs[defBlock] = same
+			}
+		}
+	}
+	users := b.vars.phiUsers[phi]
+	delete(b.vars.phiUsers, phi)
+	for _, user := range users {
+		if user == phi {
+			continue
+		}
+		for i := 0; i < user.NumArgs(); i++ {
+			if user.Arg(i) == phi {
+				user.SetArg(i, same)
+			}
+		}
+		if _, isPhi := b.vars.phiBlocks[same]; isPhi {
+			b.vars.phiUsers[same] = append(b.vars.phiUsers[same], user)
+		}
+	}
+
+	// Removing this Phi node may have made some of its users trivial too,
+	// but we must skip any that are not yet complete.
+	for _, user := range users {
+		userBlock, stillPhi := b.vars.phiBlocks[user]
+		if !stillPhi || user == phi || b.vars.filling.Has(user) || !b.vars.sealed.Has(userBlock) {
+			continue
+		}
+		b.tryRemoveTrivialPhi(user)
+	}
+
+	return same
+}
+
+// basicBlockAdderFunc is an implementation of BasicBlockAdder that calls a
+// function for each added block.
+type basicBlockAdderFunc func(block *BasicBlock)
+
+func (f basicBlockAdderFunc) Add(block *BasicBlock) {
+	f(block)
+}
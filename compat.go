@@ -0,0 +1,27 @@
+package ossa
+
+// This file contains deprecated forms of APIs that have since changed, kept
+// so that existing frontends can migrate to the new forms incrementally
+// rather than all at once. Each delegates to its replacement.
+//
+// The free constructors such as Jump, Branch and Call are not deprecated;
+// Builder delegates to them, and they remain the way to construct values
+// and terminators without appending them to a block.
+
+// NewBlockBuilder allocates a new, empty basic block in the same way as
+// NewBlock, and returns a separate builder that appends to it and adds any
+// further blocks to the same function as the receiver.
+//
+// The returned builder does not share the receiver's variables, predecessor
+// tracking or source position.
+//
+// Deprecated: Builder.NewBlock formerly returned a builder for the new block,
+// as this method does. Use NewBlock and SetBlock to continue using the same
+// builder instead.
+func (b *Builder) NewBlockBuilder() *Builder {
+	return &Builder{
+		block: b.NewBlock(),
+		index: -1,
+		fn:    b.fn,
+	}
+}
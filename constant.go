@@ -0,0 +1,219 @@
+package ossa
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Const is a canonical representation of a literal constant, intended as
+// a common currency for constant values that frontends and shared passes
+// can both understand without needing to agree on any particular Go type
+// for literals.
+//
+// Integers and floats are represented with arbitrary precision, and so a
+// Const carries no notion of bit width or byte order. Any such constraints
+// are the concern of the frontend or backend that produced or consumes the
+// value.
+//
+// The zero value of Const is the nil constant. Const values are immutable,
+// and so may be freely copied and shared.
+type Const struct {
+	kind ConstKind
+	b    bool
+	i    *big.Int
+	f    *big.Float
+}
+
+// ConstKind represents the different kinds of constant that can be
+// represented by Const.
+type ConstKind int
+
+const (
+	ConstNil ConstKind = iota
+	ConstBool
+	ConstInt
+	ConstFloat
+)
+
+// NilConst is the nil constant, which is also the zero value of Const.
+var NilConst Const
+
+// BoolConst returns a boolean constant with the given value.
+func BoolConst(b bool) Const {
+	return Const{
+		kind: ConstBool,
+		b:    b,
+	}
+}
+
+// IntConst returns an integer constant with the given value. The given
+// integer is copied, so the caller may continue to modify it afterwards
+// without affecting the result.
+func IntConst(i *big.Int) Const {
+	return Const{
+		kind: ConstInt,
+		i:    new(big.Int).Set(i),
+	}
+}
+
+// Int64Const is a convenience wrapper around IntConst for integers that fit
+// in an int64.
+func Int64Const(i int64) Const {
+	return Const{
+		kind: ConstInt,
+		i:    big.NewInt(i),
+	}
+}
+
+// FloatConst returns a floating point constant with the given value. The
+// given float is copied, so the caller may continue to modify it afterwards
+// without affecting the result.
+//
+// FloatConst panics if given an infinity, because those cannot be represented
+// in a Const.
+func FloatConst(f *big.Float) Const {
+	if f.IsInf() {
+		panic("FloatConst with infinity")
+	}
+	return Const{
+		kind: ConstFloat,
+		f:    new(big.Float).Copy(f),
+	}
+}
+
+// Float64Const is a convenience wrapper around FloatConst for values that are
+// representable as float64. It panics if given NaN or an infinity.
+func Float64Const(f float64) Const {
+	if math.IsNaN(f) {
+		panic("Float64Const with NaN")
+	}
+	if math.IsInf(f, 0) {
+		panic("Float64Const with infinity")
+	}
+	return Const{
+		kind: ConstFloat,
+		f:    big.NewFloat(f),
+	}
+}
+
+// ConstLiteral constructs a new Value with OpAuxLiteral whose aux value
+// is the given constant.
+func ConstLiteral(c Const) *Value {
+	return AuxLiteral(c)
+}
+
+// Kind returns the kind of the receiving constant.
+func (c Const) Kind() ConstKind {
+	return c.kind
+}
+
+// Bool returns the value of a boolean constant. The second return value is
+// false if the receiver is not a boolean constant.
+func (c Const) Bool() (bool, bool) {
+	if c.kind != ConstBool {
+		return false, false
+	}
+	return c.b, true
+}
+
+// Int returns the value of an integer constant. The second return value is
+// false if the receiver is not an integer constant.
+//
+// The result is a fresh copy that the caller is free to modify.
+func (c Const) Int() (*big.Int, bool) {
+	if c.kind != ConstInt {
+		return nil, false
+	}
+	return new(big.Int).Set(c.i), true
+}
+
+// Float returns the value of a floating point constant. The second return
+// value is false if the receiver is not a floating point constant.
+//
+// The result is a fresh copy that the caller is free to modify.
+func (c Const) Float() (*big.Float, bool) {
+	if c.kind != ConstFloat {
+		return nil, false
+	}
+	return new(big.Float).Copy(c.f), true
+}
+
+// Equal returns true if the receiver and the given other constant are of
+// the same kind and have the same value.
+//
+// Integer and floating point constants are never equal to one another, even
+// if they represent the same number. Floating point zero is not equal to
+// negative zero, because the two can produce different results in
+// arithmetic.
+func (c Const) Equal(other Const) bool {
+	if c.kind != other.kind {
+		return false
+	}
+	switch c.kind {
+	case ConstNil:
+		return true
+	case ConstBool:
+		return c.b == other.b
+	case ConstInt:
+		return c.i.Cmp(other.i) == 0
+	case ConstFloat:
+		return c.f.Cmp(other.f) == 0 && c.f.Signbit() == other.f.Signbit()
+	default:
+		panic(fmt.Sprintf("Equal is missing a case for %s", c.kind))
+	}
+}
+
+// String returns a string representation of the constant, suitable for
+// debugging and for use in textual representations of the IR.
+func (c Const) String() string {
+	switch c.kind {
+	case ConstNil:
+		return "nil"
+	case ConstBool:
+		if c.b {
+			return "true"
+		}
+		return "false"
+	case ConstInt:
+		return c.i.String()
+	case ConstFloat:
+		// We always include a decimal point or exponent so that the result
+		// is distinguishable from an integer constant.
+		s := c.f.Text('g', -1)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	default:
+		panic(fmt.Sprintf("String is missing a case for %s", c.kind))
+	}
+}
+
+// String returns a name for the receiving constant kind.
+func (k ConstKind) String() string {
+	switch k {
+	case ConstNil:
+		return "nil"
+	case ConstBool:
+		return "bool"
+	case ConstInt:
+		return "int"
+	case ConstFloat:
+		return "float"
+	default:
+		return fmt.Sprintf("ConstKind(%d)", int(k))
+	}
+}
+
+// Const returns the canonical constant represented by the receiver, if it
+// is an OpAuxLiteral value whose aux value is a Const. The second return
+// value is false for any other value.
+func (v *Value) Const() (Const, bool) {
+	if v.op != OpAuxLiteral {
+		return NilConst, false
+	}
+	c, ok := v.aux.(Const)
+	return c, ok
+}
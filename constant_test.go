@@ -0,0 +1,139 @@
+package ossa
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestConstAccessors(t *testing.T) {
+	if got := NilConst.Kind(); got != ConstNil {
+		t.Errorf("wrong kind for NilConst %s", got)
+	}
+	if got := (Const{}).Kind(); got != ConstNil {
+		t.Errorf("wrong kind for zero Const %s", got)
+	}
+
+	b := BoolConst(true)
+	if got, ok := b.Bool(); !ok || !got {
+		t.Errorf("wrong bool %t, %t", got, ok)
+	}
+	if _, ok := b.Int(); ok {
+		t.Errorf("bool constant has an int value")
+	}
+
+	// IntConst copies its argument, and Int returns a fresh copy.
+	n := big.NewInt(40)
+	i := IntConst(n)
+	n.SetInt64(0)
+	got, ok := i.Int()
+	if !ok || got.Int64() != 40 {
+		t.Errorf("wrong int %s, %t", got, ok)
+	}
+	got.SetInt64(1)
+	if again, _ := i.Int(); again.Int64() != 40 {
+		t.Errorf("modifying the result of Int changed the constant")
+	}
+	if _, ok := i.Float(); ok {
+		t.Errorf("int constant has a float value")
+	}
+
+	x := big.NewFloat(1.5)
+	fc := FloatConst(x)
+	x.SetInt64(0)
+	if got, ok := fc.Float(); !ok || got.Cmp(big.NewFloat(1.5)) != 0 {
+		t.Errorf("wrong float %s, %t", got, ok)
+	}
+	if _, ok := fc.Bool(); ok {
+		t.Errorf("float constant has a bool value")
+	}
+}
+
+func TestConstString(t *testing.T) {
+	tests := []struct {
+		c    Const
+		want string
+	}{
+		{NilConst, "nil"},
+		{BoolConst(false), "false"},
+		{Int64Const(-12), "-12"},
+		{Float64Const(2), "2.0"},
+		{Float64Const(0.25), "0.25"},
+		{Float64Const(1e100), "1e+100"},
+		{Float64Const(math.Copysign(0, -1)), "-0.0"},
+	}
+	for _, test := range tests {
+		if got := test.c.String(); got != test.want {
+			t.Errorf("wrong string %q; want %q", got, test.want)
+		}
+	}
+}
+
+func TestConstInvalidFloats(t *testing.T) {
+	tests := map[string]func(){
+		"NaN":               func() { Float64Const(math.NaN()) },
+		"infinity":          func() { Float64Const(math.Inf(1)) },
+		"negative infinity": func() { Float64Const(math.Inf(-1)) },
+		"big infinity":      func() { FloatConst(new(big.Float).SetInf(false)) },
+	}
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("did not panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
+
+func TestValueConst(t *testing.T) {
+	if c, ok := ConstLiteral(Int64Const(3)).Const(); !ok || !c.Equal(Int64Const(3)) {
+		t.Errorf("wrong constant %s, %t", c, ok)
+	}
+	if _, ok := AuxLiteral(3).Const(); ok {
+		t.Errorf("AuxLiteral of an int has a Const")
+	}
+	if _, ok := Load(ConstLiteral(NilConst)).Const(); ok {
+		t.Errorf("Load has a Const")
+	}
+}
+
+func TestConstEqual(t *testing.T) {
+	tests := map[string]struct {
+		a, b Const
+		want bool
+	}{
+		"nil":             {NilConst, NilConst, true},
+		"bool":            {BoolConst(true), BoolConst(true), true},
+		"different bools": {BoolConst(true), BoolConst(false), false},
+		"int":             {Int64Const(2), Int64Const(2), true},
+		"different ints":  {Int64Const(2), Int64Const(3), false},
+		"float":           {Float64Const(0.5), Float64Const(0.5), true},
+		"int and float":   {Int64Const(1), Float64Const(1), false},
+		"nil and false":   {NilConst, BoolConst(false), false},
+		"zeros":           {Float64Const(0), Float64Const(math.Copysign(0, -1)), false},
+		"negative zeros":  {Float64Const(math.Copysign(0, -1)), Float64Const(math.Copysign(0, -1)), true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.a.Equal(test.b); got != test.want {
+				t.Errorf("%s.Equal(%s) = %t; want %t", test.a, test.b, got, test.want)
+			}
+			if got := test.b.Equal(test.a); got != test.want {
+				t.Errorf("%s.Equal(%s) = %t; want %t", test.b, test.a, got, test.want)
+			}
+
+			// Literals of equal constants must be Equivalent and so must
+			// have the same StructuralHash.
+			a, b := ConstLiteral(test.a), ConstLiteral(test.b)
+			if got := a.Equivalent(b); got != test.want {
+				t.Errorf("literals Equivalent = %t; want %t", got, test.want)
+			}
+			if test.want && a.StructuralHash() != b.StructuralHash() {
+				t.Errorf("equivalent literals have different hashes")
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package ossa
+
+// Function is a container for the basic blocks that make up a single
+// function, along with the argument values that represent its parameters.
+//
+// A function always has an entry block, which is the block that control
+// passes to when the function is called. The other blocks in the function
+// are usually reachable from the entry block, but the function retains
+// ownership of all blocks added to it regardless of whether they are
+// reachable, so that its blocks can be enumerated without walking the
+// control flow graph.
+type Function struct {
+	// Params are the values that represent the function's parameters. Each
+	// element is a value constructed by Argument. Use AddParam to append a
+	// new parameter.
+	Params []*Value
+
+	// blocks is the set of blocks owned by this function in the order they
+	// were added. blocks[0] is always the entry block.
+	blocks []*BasicBlock
+
+	// owned is the same set of blocks as in "blocks", for fast membership
+	// tests.
+	owned BasicBlockSet
+}
+
+// NewFunction constructs and returns a new function with an empty entry block
+// and no parameters.
+func NewFunction() *Function {
+	entry := NewBasicBlock()
+	return &Function{
+		blocks: []*BasicBlock{entry},
+		owned:  NewBasicBlockSet(entry),
+	}
+}
+
+// Entry returns the entry block of the receiving function.
+func (f *Function) Entry() *BasicBlock {
+	return f.blocks[0]
+}
+
+// AddParam constructs a new argument value, appends it to the function's
+// parameters, and returns it.
+func (f *Function) AddParam() *Value {
+	v := Argument()
+	f.Params = append(f.Params, v)
+	return v
+}
+
+// NewBlock allocates a new, empty basic block owned by the receiving function
+// and returns it.
+func (f *Function) NewBlock() *BasicBlock {
+	b := NewBasicBlock()
+	f.AddBlock(b)
+	return b
+}
+
+// AddBlock transfers ownership of an existing block to the receiving function.
+// It is a no-op if the function already owns the block.
+//
+// A block must not be owned by more than one function at a time.
+func (f *Function) AddBlock(block *BasicBlock) {
+	if f.HasBlock(block) {
+		return
+	}
+	f.blocks = append(f.blocks, block)
+	f.owned.Add(block)
+}
+
+// HasBlock returns true if the given block is owned by the receiving function.
+func (f *Function) HasBlock(block *BasicBlock) bool {
+	return f.owned.Has(block)
+}
+
+// RemoveBlock releases the given block from the receiving function. It is a
+// no-op if the block is not owned by the function.
+//
+// The caller must ensure that the block is no longer referenced by any of
+// the function's other blocks. It is not possible to remove the entry block.
+func (f *Function) RemoveBlock(block *BasicBlock) {
+	if block == f.blocks[0] {
+		panic("can't remove entry block")
+	}
+	if !f.owned.Has(block) {
+		return
+	}
+	f.owned.Remove(block)
+	for i, b := range f.blocks {
+		if b == block {
+			copy(f.blocks[i:], f.blocks[i+1:])
+			f.blocks[len(f.blocks)-1] = nil
+			f.blocks = f.blocks[:len(f.blocks)-1]
+			return
+		}
+	}
+}
+
+// BlockCount returns the number of blocks owned by the receiving function.
+func (f *Function) BlockCount() int {
+	return len(f.blocks)
+}
+
+// AppendBlocks appends to the given slice all of the blocks owned by the
+// receiving function and returns the new slice.
+//
+// The blocks are appended in the order they were added to the function, with
+// the entry block always first. This order is deterministic, and so it is
+// suitable for producing stable output such as printed IR.
+func (f *Function) AppendBlocks(to []*BasicBlock) []*BasicBlock {
+	return append(to, f.blocks...)
+}
+
+// AddBlocksTo adds to the given adder all of the blocks owned by the receiving
+// function, in the same order as AppendBlocks.
+func (f *Function) AddBlocksTo(to BasicBlockAdder) {
+	for _, b := range f.blocks {
+		to.Add(b)
+	}
+}
@@ -0,0 +1,72 @@
+package ossa
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFunction(t *testing.T) {
+	f := NewFunction()
+	entry := f.Entry()
+	if entry == nil {
+		t.Fatalf("new function has no entry block")
+	}
+	if got := f.BlockCount(); got != 1 {
+		t.Errorf("new function has %d blocks; want 1", got)
+	}
+	if len(f.Params) != 0 {
+		t.Errorf("new function has parameters")
+	}
+
+	p0 := f.AddParam()
+	p1 := f.AddParam()
+	if p0.Op() != OpArgument || p1.Op() != OpArgument || p0 == p1 {
+		t.Errorf("AddParam did not return distinct arguments")
+	}
+	if got, want := f.Params, []*Value{p0, p1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong parameters %v; want %v", got, want)
+	}
+
+	a := f.NewBlock()
+	b := NewBasicBlock()
+	if f.HasBlock(b) {
+		t.Errorf("function owns a block before it is added")
+	}
+	f.AddBlock(b)
+	f.AddBlock(b) // no-op, since the block is already owned
+	c := f.NewBlock()
+	if got, want := f.AppendBlocks(nil), []*BasicBlock{entry, a, b, c}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong blocks %v; want %v", got, want)
+	}
+
+	set := make(BasicBlockSet)
+	f.AddBlocksTo(set)
+	if got := len(set); got != 4 || !set.Has(entry) || !set.Has(c) {
+		t.Errorf("AddBlocksTo added the wrong blocks %v", set)
+	}
+
+	f.RemoveBlock(b)
+	f.RemoveBlock(b)               // no-op, since the block is no longer owned
+	f.RemoveBlock(NewBasicBlock()) // no-op, since the block was never owned
+	if f.HasBlock(b) {
+		t.Errorf("function still owns a removed block")
+	}
+	if got, want := f.AppendBlocks(nil), []*BasicBlock{entry, a, c}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong blocks after removal %v; want %v", got, want)
+	}
+	if got, want := f.BlockCount(), 3; got != want {
+		t.Errorf("wrong block count %d; want %d", got, want)
+	}
+	if f.Entry() != entry {
+		t.Errorf("entry block changed")
+	}
+
+	t.Run("remove entry", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("RemoveBlock did not panic")
+			}
+		}()
+		f.RemoveBlock(entry)
+	})
+}
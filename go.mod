@@ -1,3 +1,5 @@
 module github.com/alamatic/ossa
 
+go 1.18
+
 require github.com/google/go-cmp v0.2.0
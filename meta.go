@@ -0,0 +1,154 @@
+package ossa
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// metadata is the storage for the metadata of a value, terminator or basic
+// block, which is allocated only once the first entry is set.
+type metadata map[interface{}]interface{}
+
+func (m *metadata) get(key interface{}) interface{} {
+	return (*m)[key]
+}
+
+func (m metadata) copy() metadata {
+	if len(m) == 0 {
+		return nil
+	}
+	ret := make(metadata, len(m))
+	for key, val := range m {
+		ret[key] = val
+	}
+	return ret
+}
+
+func (m *metadata) set(key, val interface{}) {
+	if key == nil || !reflect.TypeOf(key).Comparable() {
+		panic(fmt.Sprintf("metadata key of non-comparable type %T", key))
+	}
+	if val == nil {
+		delete(*m, key)
+		return
+	}
+	if *m == nil {
+		*m = make(metadata)
+	}
+	(*m)[key] = val
+}
+
+// MetaHolder is the interface implemented by the types that can carry
+// metadata: *Value, *Terminator and *BasicBlock.
+//
+// Metadata is a set of auxiliary facts keyed by arbitrary comparable values,
+// such as inlining hints or profile counts. ossa itself attaches no meaning
+// to metadata. Transforms preserve the metadata of the values, terminators
+// and blocks they move, but do not generally copy it to any that they
+// create, except for copies made using Clone. MetaKey provides type-safe
+// access to a particular kind of entry.
+type MetaHolder interface {
+	// Meta returns the metadata entry with the given key, or nil if there
+	// is no such entry.
+	Meta(key interface{}) interface{}
+
+	// SetMeta sets the metadata entry with the given key, replacing any
+	// existing entry. A nil value removes the entry. It panics if the key
+	// is not comparable using the == operator.
+	SetMeta(key, val interface{})
+}
+
+var (
+	_ MetaHolder = (*Value)(nil)
+	_ MetaHolder = (*Terminator)(nil)
+	_ MetaHolder = (*BasicBlock)(nil)
+)
+
+func (v *Value) Meta(key interface{}) interface{} {
+	return v.meta.get(key)
+}
+
+func (v *Value) SetMeta(key, val interface{}) {
+	v.meta.set(key, val)
+}
+
+func (t *Terminator) Meta(key interface{}) interface{} {
+	return t.meta.get(key)
+}
+
+// SetMeta implements MetaHolder. It panics if the receiver is the shared
+// Unreachable terminator.
+func (t *Terminator) SetMeta(key, val interface{}) {
+	if t == Unreachable {
+		panic("can't modify the Unreachable terminator")
+	}
+	t.meta.set(key, val)
+}
+
+func (b *BasicBlock) Meta(key interface{}) interface{} {
+	return b.meta.get(key)
+}
+
+func (b *BasicBlock) SetMeta(key, val interface{}) {
+	b.meta.set(key, val)
+}
+
+// MergeMeta copies to the receiver each of the metadata entries of the given
+// other block whose key the receiver doesn't already have an entry for. It is
+// intended for transforms that merge one block into another, so that the
+// resulting block keeps the metadata of both.
+func (b *BasicBlock) MergeMeta(other *BasicBlock) {
+	for key, val := range other.meta {
+		if b.meta.get(key) == nil {
+			b.meta.set(key, val)
+		}
+	}
+}
+
+// MetaKey is a metadata key whose entries always have values of type T. Each
+// key created with NewMetaKey is distinct from all others, even if they have
+// the same name, so a package can define its own keys without conflicting
+// with keys defined elsewhere.
+//
+// For example, a frontend might attach the names of source variables to
+// values:
+//
+//	var varNameKey = ossa.NewMetaKey[string]("varName")
+//
+//	varNameKey.Set(v, "count")
+//	if name, ok := varNameKey.Get(v); ok {
+//	    // ...
+//	}
+type MetaKey[T any] struct {
+	name string
+}
+
+// NewMetaKey creates a new metadata key for values of type T. The name is
+// used only for debugging.
+func NewMetaKey[T any](name string) *MetaKey[T] {
+	return &MetaKey[T]{name: name}
+}
+
+// Get returns the entry for the receiving key from the given holder. The
+// second return value is false if the holder has no such entry.
+func (k *MetaKey[T]) Get(h MetaHolder) (T, bool) {
+	ret, ok := h.Meta(k).(T)
+	return ret, ok
+}
+
+// Set sets the entry for the receiving key on the given holder, replacing any
+// existing entry.
+func (k *MetaKey[T]) Set(h MetaHolder, val T) {
+	h.SetMeta(k, val)
+}
+
+// Delete removes the entry for the receiving key from the given holder, if
+// present.
+func (k *MetaKey[T]) Delete(h MetaHolder) {
+	h.SetMeta(k, nil)
+}
+
+// String returns the name of the key.
+func (k *MetaKey[T]) String() string {
+	return k.name
+}
@@ -0,0 +1,70 @@
+package ossa
+
+import (
+	"testing"
+)
+
+func TestMeta(t *testing.T) {
+	weightsKey := NewMetaKey[[]float64]("weights")
+	hintKey := NewMetaKey[string]("hint")
+	otherHintKey := NewMetaKey[string]("hint")
+
+	block := NewBasicBlock()
+	v := Load(Argument())
+	term := Branch(v, block, block)
+
+	if _, ok := hintKey.Get(v); ok {
+		t.Errorf("new value has metadata")
+	}
+
+	hintKey.Set(v, "inline")
+	weightsKey.Set(term, []float64{0.9, 0.1})
+	block.SetMeta("untyped", 5)
+
+	if got, ok := hintKey.Get(v); !ok || got != "inline" {
+		t.Errorf("wrong hint %q, %t", got, ok)
+	}
+	if _, ok := otherHintKey.Get(v); ok {
+		t.Errorf("keys with the same name are not distinct")
+	}
+	if got, ok := weightsKey.Get(term); !ok || len(got) != 2 || got[0] != 0.9 {
+		t.Errorf("wrong weights %v, %t", got, ok)
+	}
+	if got := block.Meta("untyped"); got != 5 {
+		t.Errorf("wrong untyped metadata %v", got)
+	}
+
+	hintKey.Delete(v)
+	if _, ok := hintKey.Get(v); ok {
+		t.Errorf("metadata remains after Delete")
+	}
+
+	t.Run("MergeMeta", func(t *testing.T) {
+		other := NewBasicBlock()
+		other.SetMeta("untyped", 6)
+		other.SetMeta("extra", true)
+		block.MergeMeta(other)
+		if got := block.Meta("untyped"); got != 5 {
+			t.Errorf("existing entry was replaced with %v", got)
+		}
+		if got := block.Meta("extra"); got != true {
+			t.Errorf("wrong merged entry %v", got)
+		}
+	})
+	t.Run("non-comparable key", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("SetMeta did not panic")
+			}
+		}()
+		v.SetMeta([]int{1}, true)
+	})
+	t.Run("Unreachable", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("SetMeta did not panic")
+			}
+		}()
+		hintKey.Set(Unreachable, "never")
+	})
+}
@@ -0,0 +1,180 @@
+package ossa
+
+import (
+	"fmt"
+)
+
+// Module is a container for a whole program or library, grouping together
+// named global symbols and the functions that define some of them.
+//
+// Each name in a module is associated with a global symbol value, as
+// constructed by GlobalSym. A function defined in the module is also
+// identified by its symbol, so that Call instructions can refer to it by
+// using that symbol as the callee.
+//
+// Iteration over the names in a module is in the order they were first
+// declared, so it is deterministic for a given sequence of calls.
+//
+// A module also has a constant pool, which holds large literal data such as
+// strings and byte arrays outside of the values that use it. See Intern.
+type Module struct {
+	names []string
+	syms  map[string]*Value
+	funcs map[*Value]*Function
+
+	// symNames is the inverse of syms.
+	symNames map[*Value]string
+
+	// pool is the data of each constant pool entry, and poolIndex is its
+	// inverse.
+	pool      []string
+	poolIndex map[string]int
+}
+
+// NewModule constructs and returns a new, empty module.
+func NewModule() *Module {
+	return &Module{
+		syms:      make(map[string]*Value),
+		funcs:     make(map[*Value]*Function),
+		symNames:  make(map[*Value]string),
+		poolIndex: make(map[string]int),
+	}
+}
+
+// DeclareGlobal returns the global symbol associated with the given name,
+// first constructing a new symbol if the name is not yet declared.
+func (m *Module) DeclareGlobal(name string) *Value {
+	if sym, exists := m.syms[name]; exists {
+		return sym
+	}
+	sym := GlobalSym()
+	m.names = append(m.names, name)
+	m.syms[name] = sym
+	m.symNames[sym] = name
+	return sym
+}
+
+// DefineFunction declares the given name, if it is not already declared,
+// and associates a new function with its symbol. The new function is
+// returned.
+//
+// It panics if a function is already defined with the given name.
+func (m *Module) DefineFunction(name string) *Function {
+	sym := m.DeclareGlobal(name)
+	if _, exists := m.funcs[sym]; exists {
+		panic(fmt.Sprintf("duplicate definition of function %q", name))
+	}
+	f := NewFunction()
+	m.funcs[sym] = f
+	return f
+}
+
+// Global returns the global symbol declared with the given name, or nil if
+// there is no such declaration.
+func (m *Module) Global(name string) *Value {
+	return m.syms[name]
+}
+
+// GlobalName returns the name that the given global symbol was declared
+// with. The second return value is false if the symbol does not belong
+// to the receiving module.
+func (m *Module) GlobalName(sym *Value) (string, bool) {
+	name, ok := m.symNames[sym]
+	return name, ok
+}
+
+// Function returns the function defined with the given name, or nil if
+// there is no such function.
+func (m *Module) Function(name string) *Function {
+	sym, exists := m.syms[name]
+	if !exists {
+		return nil
+	}
+	return m.funcs[sym]
+}
+
+// FunctionForSym returns the function associated with the given global
+// symbol, or nil if the symbol does not belong to the receiving module or
+// does not have a function defined.
+func (m *Module) FunctionForSym(sym *Value) *Function {
+	return m.funcs[sym]
+}
+
+// AppendGlobalNames appends to the given slice the names of all of the global
+// symbols declared in the receiving module, including the names of functions,
+// in the order they were declared. It returns the new slice.
+func (m *Module) AppendGlobalNames(to []string) []string {
+	return append(to, m.names...)
+}
+
+// AppendFunctionNames appends to the given slice the names of all of the
+// functions defined in the receiving module, in the order their names were
+// declared. It returns the new slice.
+func (m *Module) AppendFunctionNames(to []string) []string {
+	for _, name := range m.names {
+		if _, isFunc := m.funcs[m.syms[name]]; isFunc {
+			to = append(to, name)
+		}
+	}
+	return to
+}
+
+// PoolRef refers to an entry in a module's constant pool, as returned by
+// Module.Intern. A PoolRef is usually used as the aux value of an AuxLiteral,
+// so that the literal's data is stored only once in the module however many
+// values use it, and so that literals with the same data have equal aux
+// values that are cheap to compare.
+//
+// A PoolRef is meaningful only for the module that returned it. The zero
+// value refers to the first entry of a module's pool, if it has one.
+type PoolRef struct {
+	index int
+}
+
+// Index returns the position of the referenced entry in its module's pool,
+// which is the number of entries that were added before it.
+func (r PoolRef) Index() int {
+	return r.index
+}
+
+// Intern adds the given data to the receiving module's constant pool and
+// returns a reference to its entry. If the pool already has an entry with
+// the same data then Intern returns a reference to that entry instead.
+//
+// Pool entries are strings of bytes, so byte arrays can be interned by
+// converting them to string. Entries are never removed.
+func (m *Module) Intern(data string) PoolRef {
+	if i, exists := m.poolIndex[data]; exists {
+		return PoolRef{i}
+	}
+	i := len(m.pool)
+	m.pool = append(m.pool, data)
+	m.poolIndex[data] = i
+	return PoolRef{i}
+}
+
+// PoolLiteral is a convenience wrapper that interns the given data and
+// returns a new AuxLiteral value whose aux value refers to it.
+func (m *Module) PoolLiteral(data string) *Value {
+	return AuxLiteral(m.Intern(data))
+}
+
+// PoolData returns the data of the given entry of the receiving module's
+// constant pool. The second return value is false if there is no such
+// entry.
+func (m *Module) PoolData(ref PoolRef) (string, bool) {
+	if ref.index < 0 || ref.index >= len(m.pool) {
+		return "", false
+	}
+	return m.pool[ref.index], true
+}
+
+// AppendPoolRefs appends to the given slice a reference to each entry in the
+// receiving module's constant pool, in the order they were added, and returns
+// the new slice.
+func (m *Module) AppendPoolRefs(to []PoolRef) []PoolRef {
+	for i := range m.pool {
+		to = append(to, PoolRef{i})
+	}
+	return to
+}
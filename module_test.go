@@ -0,0 +1,112 @@
+package ossa
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModule(t *testing.T) {
+	m := NewModule()
+	if got := m.AppendGlobalNames(nil); len(got) != 0 {
+		t.Errorf("new module has globals %v", got)
+	}
+
+	data := m.DeclareGlobal("data")
+	if data.Op() != OpGlobalSym {
+		t.Errorf("global symbol has wrong op %s", data.Op())
+	}
+	if again := m.DeclareGlobal("data"); again != data {
+		t.Errorf("redeclaring a global produced a new symbol")
+	}
+	mainFn := m.DefineFunction("main")
+	m.DeclareGlobal("helper")
+	helperFn := m.DefineFunction("helper")
+	if mainFn == helperFn {
+		t.Errorf("DefineFunction returned the same function twice")
+	}
+
+	if got := m.Global("data"); got != data {
+		t.Errorf("wrong symbol for data")
+	}
+	if got := m.Global("missing"); got != nil {
+		t.Errorf("undeclared name has a symbol")
+	}
+	if name, ok := m.GlobalName(data); !ok || name != "data" {
+		t.Errorf("wrong name for data %q, %t", name, ok)
+	}
+	if _, ok := m.GlobalName(GlobalSym()); ok {
+		t.Errorf("foreign symbol has a name")
+	}
+
+	if got := m.Function("main"); got != mainFn {
+		t.Errorf("wrong function for main")
+	}
+	if got := m.Function("data"); got != nil {
+		t.Errorf("data has a function")
+	}
+	if got := m.Function("missing"); got != nil {
+		t.Errorf("undeclared name has a function")
+	}
+	if got := m.FunctionForSym(m.Global("helper")); got != helperFn {
+		t.Errorf("wrong function for helper's symbol")
+	}
+	if got := m.FunctionForSym(data); got != nil {
+		t.Errorf("data's symbol has a function")
+	}
+	if got := m.FunctionForSym(GlobalSym()); got != nil {
+		t.Errorf("foreign symbol has a function")
+	}
+
+	// Names are listed in the order they were first declared.
+	if got, want := m.AppendGlobalNames(nil), []string{"data", "main", "helper"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong global names %v; want %v", got, want)
+	}
+	if got, want := m.AppendFunctionNames([]string{"x"}), []string{"x", "main", "helper"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong function names %v; want %v", got, want)
+	}
+
+	t.Run("duplicate function", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("DefineFunction did not panic")
+			}
+		}()
+		m.DefineFunction("main")
+	})
+}
+
+func TestModulePool(t *testing.T) {
+	m := NewModule()
+	if got := m.AppendPoolRefs(nil); len(got) != 0 {
+		t.Errorf("new module has pool entries %v", got)
+	}
+
+	hello := m.Intern("hello")
+	bytes := m.Intern(string([]byte{0, 1, 2}))
+	if again := m.Intern("hello"); again != hello {
+		t.Errorf("interning the same data produced a new entry")
+	}
+	if hello == bytes {
+		t.Errorf("interning different data produced the same entry")
+	}
+	if got, want := bytes.Index(), 1; got != want {
+		t.Errorf("wrong index %d; want %d", got, want)
+	}
+	if data, ok := m.PoolData(hello); !ok || data != "hello" {
+		t.Errorf("wrong data for hello %q, %t", data, ok)
+	}
+	if _, ok := NewModule().PoolData(hello); ok {
+		t.Errorf("empty module has data for hello")
+	}
+	if got, want := m.AppendPoolRefs(nil), []PoolRef{hello, bytes}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong pool entries %v; want %v", got, want)
+	}
+
+	lit := m.PoolLiteral("hello")
+	if ref, ok := AuxAs[PoolRef](lit); !ok || ref != hello {
+		t.Errorf("wrong aux value for pool literal %#v", lit.Aux())
+	}
+	if !lit.Equivalent(AuxLiteral(hello)) {
+		t.Errorf("pool literals with the same data are not equivalent")
+	}
+}
@@ -0,0 +1,75 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// AliasResult describes whether two memory references might refer to the
+// same memory, as decided by an AliasAnalysis.
+type AliasResult int
+
+const (
+	// NoAlias means that the two references never refer to the same
+	// memory.
+	NoAlias AliasResult = iota
+
+	// MayAlias means that the two references might refer to the same
+	// memory, or that it isn't known whether they do.
+	MayAlias
+
+	// MustAlias means that the two references always refer to the same
+	// memory.
+	MustAlias
+)
+
+// AliasAnalysis is the interface implemented by types that can decide
+// whether two memory references, such as the reference operands of Load and
+// Store instructions, might refer to the same memory.
+//
+// An implementation that knows nothing about two references must return
+// MayAlias for them. Frontends that know more about their language's memory
+// model, such as which references have distinct types that can never
+// overlap, can implement this interface to make memory analyses more
+// precise. BasicAliasAnalysis is a conservative implementation that knows
+// only what ossa itself can guarantee.
+type AliasAnalysis interface {
+	MayAlias(a, b *ossa.Value) AliasResult
+}
+
+// BasicAliasAnalysis is an AliasAnalysis that distinguishes references only
+// by their roots:
+//
+//   - A reference always aliases itself.
+//   - Two different symbols, whether local or global, never alias.
+//   - A local symbol never aliases an argument, because the symbol's memory
+//     did not exist yet when the argument was passed.
+//
+// All other pairs of references may alias.
+type BasicAliasAnalysis struct{}
+
+var _ AliasAnalysis = BasicAliasAnalysis{}
+
+func (BasicAliasAnalysis) MayAlias(a, b *ossa.Value) AliasResult {
+	if a == nil || b == nil {
+		return MayAlias
+	}
+	if a == b {
+		return MustAlias
+	}
+	aOp, bOp := a.Op(), b.Op()
+	switch {
+	case isSym(a) && isSym(b):
+		return NoAlias
+	case aOp == ossa.OpLocalSym && bOp == ossa.OpArgument:
+		return NoAlias
+	case aOp == ossa.OpArgument && bOp == ossa.OpLocalSym:
+		return NoAlias
+	default:
+		return MayAlias
+	}
+}
+
+func isSym(v *ossa.Value) bool {
+	op := v.Op()
+	return op == ossa.OpGlobalSym || op == ossa.OpLocalSym
+}
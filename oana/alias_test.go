@@ -0,0 +1,35 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestBasicAliasAnalysis(t *testing.T) {
+	local1, local2 := ossa.LocalSym(), ossa.LocalSym()
+	global := ossa.GlobalSym()
+	arg1, arg2 := ossa.Argument(), ossa.Argument()
+	loaded := ossa.Load(arg1)
+
+	tests := []struct {
+		name string
+		a, b *ossa.Value
+		want AliasResult
+	}{
+		{"same local", local1, local1, MustAlias},
+		{"same argument", arg1, arg1, MustAlias},
+		{"different locals", local1, local2, NoAlias},
+		{"local and global", local1, global, NoAlias},
+		{"local and argument", local1, arg1, NoAlias},
+		{"argument and local", arg1, local1, NoAlias},
+		{"global and argument", global, arg1, MayAlias},
+		{"different arguments", arg1, arg2, MayAlias},
+		{"local and loaded", local1, loaded, MayAlias},
+	}
+	for _, test := range tests {
+		if got := (BasicAliasAnalysis{}).MayAlias(test.a, test.b); got != test.want {
+			t.Errorf("%s: got %d; want %d", test.name, got, test.want)
+		}
+	}
+}
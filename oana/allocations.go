@@ -0,0 +1,48 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// AllocationTable is a map from each allocation call in a function to
+// whether the address of the object it allocates escapes. An
+// AllocationTable can be constructed by calling FindAllocations.
+type AllocationTable map[*ossa.Value]Escape
+
+// FindAllocations finds the Call instructions in the blocks reachable from
+// the given entry block whose callee the given registry recognizes as an
+// intrinsic with the IntrinsicAllocates effect, and determines whether the
+// address of each allocated object escapes.
+//
+// The address of an allocated object escapes under the same rules that
+// FindEscapes uses for local symbols: it may flow through Phi and Select
+// instructions, and only the reference operand of a Load or Store is a
+// non-escaping use. An Invoke of the allocation call is not a use of the
+// address, so an allocation that may raise an exception can still be found
+// not to escape. An object whose address does not escape is accessed only
+// by the function's own Loads and Stores and is unreachable once the
+// function returns, so it could be allocated in the function's activation
+// instead.
+//
+// The given module is used to recognize callees by name, and may be nil as
+// described for IntrinsicRegistry.Recognize.
+func FindAllocations(m *ossa.Module, entry *ossa.BasicBlock, r *IntrinsicRegistry) AllocationTable {
+	uses := BuildUses(entry)
+	ret := make(AllocationTable)
+	ossa.WalkValuesRPO(entry, ossa.VisitorFuncs{
+		Value: func(v *ossa.Value) bool {
+			if in := r.RecognizeCall(m, v); in != nil && in.Effects.Has(IntrinsicAllocates) {
+				ret[v] = findEscape(v, uses)
+			}
+			return true
+		},
+	})
+	return ret
+}
+
+// Escapes returns true if the address of the object allocated by the given
+// call escapes. Calls that are not in the table are assumed to escape.
+func (t AllocationTable) Escapes(call *ossa.Value) bool {
+	e, ok := t[call]
+	return !ok || e.Reason != NoEscape
+}
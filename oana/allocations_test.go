@@ -0,0 +1,66 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindAllocations(t *testing.T) {
+	m := ossa.NewModule()
+	f := m.DefineFunction("f")
+	alloc := m.DeclareGlobal("alloc")
+	other := m.DeclareGlobal("other")
+	r := NewIntrinsicRegistry()
+	r.RegisterName("alloc", &Intrinsic{Name: "alloc", Effects: IntrinsicAllocates})
+
+	b := ossa.NewFunctionBuilder(f)
+	one := b.AuxLiteral(1)
+	local := b.Call(alloc)
+	b.Store(one, local)
+	b.Load(local)
+	passed := b.Call(alloc)
+	b.Call(other, passed)
+	returned := b.Call(alloc)
+	merged := b.Select(f.AddParam(), local, returned)
+	notAlloc := b.Call(other)
+	b.Return(merged)
+
+	got := FindAllocations(m, f.Entry(), r)
+	if got, want := len(got), 3; got != want {
+		t.Errorf("wrong number of allocations %d; want %d", got, want)
+	}
+	if _, ok := got[notAlloc]; ok {
+		t.Errorf("call to other function is an allocation")
+	}
+
+	// local escapes only through the Select that might also produce the
+	// returned allocation.
+	if got, want := got[local].Reason, EscapeReturn; got != want {
+		t.Errorf("wrong reason for local %d; want %d", got, want)
+	}
+	if got, want := got[passed].Reason, EscapeCall; got != want {
+		t.Errorf("wrong reason for passed %d; want %d", got, want)
+	}
+
+	f.Entry().Terminator = ossa.Return()
+	got = FindAllocations(m, f.Entry(), r)
+	if got.Escapes(local) {
+		t.Errorf("local escapes once nothing returns it")
+	}
+	if !got.Escapes(passed) || !got.Escapes(notAlloc) {
+		t.Errorf("passed and notAlloc should escape")
+	}
+
+	// Invoking the allocation call does not use the allocated address.
+	normal := f.NewBlock()
+	normal.Terminator = ossa.Return()
+	tail := b.NewBlock()
+	f.Entry().Terminator = ossa.Jump(tail)
+	b.SetBlock(tail)
+	invoked := b.Invoke(alloc, normal, normal)
+	got = FindAllocations(m, f.Entry(), r)
+	if got.Escapes(invoked) {
+		t.Errorf("invoked escapes through the Invoke of its call")
+	}
+}
@@ -0,0 +1,111 @@
+package oana
+
+import (
+	"math/bits"
+)
+
+// BitSet is a set of small non-negative integers, represented as a bit
+// vector. BitSet is used by bit-vector data flow analyses to represent sets
+// of numbered facts, such as values or definitions, using word-level
+// operations.
+//
+// All of the sets combined in a single operation must have been created with
+// the same size.
+type BitSet []uint64
+
+// NewBitSet returns an empty set that can contain integers in the range
+// [0, size).
+func NewBitSet(size int) BitSet {
+	return make(BitSet, (size+63)/64)
+}
+
+// NewFullBitSet returns a set that contains all of the integers in the range
+// [0, size).
+func NewFullBitSet(size int) BitSet {
+	s := NewBitSet(size)
+	for i := range s {
+		s[i] = ^uint64(0)
+	}
+	if rem := size % 64; rem != 0 {
+		s[len(s)-1] = (uint64(1) << uint(rem)) - 1
+	}
+	return s
+}
+
+// Has returns true only if the given integer is in the set.
+func (s BitSet) Has(i int) bool {
+	return s[i/64]&(uint64(1)<<uint(i%64)) != 0
+}
+
+// Add inserts the given integer into the set.
+func (s BitSet) Add(i int) {
+	s[i/64] |= uint64(1) << uint(i%64)
+}
+
+// Remove removes the given integer from the set.
+func (s BitSet) Remove(i int) {
+	s[i/64] &^= uint64(1) << uint(i%64)
+}
+
+// Len returns the number of integers in the set.
+func (s BitSet) Len() int {
+	n := 0
+	for _, w := range s {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// Copy returns a new set with the same members as the receiver.
+func (s BitSet) Copy() BitSet {
+	ret := make(BitSet, len(s))
+	copy(ret, s)
+	return ret
+}
+
+// Equal returns true if the receiver and the given set have the same members.
+func (s BitSet) Equal(other BitSet) bool {
+	for i, w := range s {
+		if other[i] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// UnionWith adds all of the members of the given set to the receiver,
+// in-place.
+func (s BitSet) UnionWith(other BitSet) {
+	for i, w := range other {
+		s[i] |= w
+	}
+}
+
+// IntersectWith removes from the receiver all members that are not also in
+// the given set, in-place.
+func (s BitSet) IntersectWith(other BitSet) {
+	for i, w := range other {
+		s[i] &= w
+	}
+}
+
+// DifferenceWith removes from the receiver all members that are in the given
+// set, in-place.
+func (s BitSet) DifferenceWith(other BitSet) {
+	for i, w := range other {
+		s[i] &^= w
+	}
+}
+
+// AppendMembers appends to the given slice all of the members of the set in
+// ascending order, and returns the new slice.
+func (s BitSet) AppendMembers(to []int) []int {
+	for i, w := range s {
+		for w != 0 {
+			b := bits.TrailingZeros64(w)
+			to = append(to, i*64+b)
+			w &^= uint64(1) << uint(b)
+		}
+	}
+	return to
+}
@@ -0,0 +1,145 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// BlockFrequencies is a map from each basic block to its estimated execution
+// frequency, relative to a start block that runs once. A BlockFrequencies can
+// be constructed by calling FindBlockFrequencies, and can be used with
+// CostTable.Dynamic to estimate the dynamic cost of a function.
+type BlockFrequencies map[*ossa.BasicBlock]float64
+
+// maxLoopScale is the largest number of iterations that FindBlockFrequencies
+// assumes for any loop, which applies in particular to loops that seem to
+// never exit.
+const maxLoopScale = 1024
+
+// FindBlockFrequencies estimates how often each block reachable from the
+// given start block runs for each time the start block is entered.
+//
+// The probability of each edge leaving a block is given by the weights of
+// its terminator, as set using ossa.Terminator.SetWeights. If a terminator
+// has no weights, or all of its weights are zero, then each of its edges is
+// assumed to be equally likely.
+//
+// The frequency of a block is the sum of the frequencies of its predecessors,
+// each scaled by the probability of the edge to it. For the head of a natural
+// loop, that sum is further scaled by the expected number of iterations of
+// the loop, which is 1/(1-p) where p is the probability of returning to the
+// head through one of the loop's back edges after entering it. Loops that
+// share a head are treated as a single loop. Edges that re-enter cycles
+// without passing through their head, which occur only in irreducible control
+// flow, are ignored, so the result is less precise for such graphs.
+//
+// The caller must provide the result of calling FindPredecessors with the
+// same start block, without any modification to the graph in the mean time,
+// or the result is undefined.
+func FindBlockFrequencies(start *ossa.BasicBlock, preds PredecessorsTable) BlockFrequencies {
+	rpo := ossa.AppendBlocksRPO(start, nil)
+	loops := FindLoopInfo(start, preds)
+	isBackEdge := func(from, to *ossa.BasicBlock) bool {
+		loop := loops.Loop(to)
+		if loop == nil || loop.Head != to {
+			return false
+		}
+		for _, tail := range loop.Tails {
+			if tail == from {
+				return true
+			}
+		}
+		return false
+	}
+
+	// propagate calculates the frequencies of the blocks in the given set,
+	// or of all reachable blocks if the set is nil, given the frequency of
+	// the given head block and ignoring back edges. Blocks are visited in
+	// reverse postorder so that each block's forward predecessors are
+	// visited before it.
+	scales := make(map[*ossa.BasicBlock]float64, len(loops.Loops))
+	propagate := func(head *ossa.BasicBlock, headFreq float64, within ossa.BasicBlockSet) BlockFrequencies {
+		ret := BlockFrequencies{head: headFreq}
+		for _, block := range rpo {
+			if block == head || (within != nil && !within.Has(block)) {
+				continue
+			}
+			var freq float64
+			for pred := range preds[block] {
+				if (within != nil && !within.Has(pred)) || isBackEdge(pred, block) {
+					continue
+				}
+				freq += ret[pred] * edgeProbability(pred, block)
+			}
+			if scale, ok := scales[block]; ok {
+				freq *= scale
+			}
+			ret[block] = freq
+		}
+		return ret
+	}
+
+	// We calculate the scale of each loop before the scales of any loops
+	// that contain it, which appear earlier in loops.Loops.
+	for i := len(loops.Loops) - 1; i >= 0; i-- {
+		loop := loops.Loops[i]
+		freqs := propagate(loop.Head, 1, loop.Body)
+		var cyclic float64
+		for _, tail := range loop.Tails {
+			cyclic += freqs[tail] * edgeProbability(tail, loop.Head)
+		}
+		scale := float64(maxLoopScale)
+		if cyclic < 1-1.0/maxLoopScale {
+			scale = 1 / (1 - cyclic)
+		}
+		scales[loop.Head] = scale
+	}
+
+	startFreq := 1.0
+	if scale, ok := scales[start]; ok {
+		startFreq = scale
+	}
+	return propagate(start, startFreq, nil)
+}
+
+// Edge returns the estimated frequency of the edge from one block to another,
+// which is the frequency of the first block scaled by the probability that
+// it passes control to the second.
+func (f BlockFrequencies) Edge(from, to *ossa.BasicBlock) float64 {
+	freq := f[from]
+	if freq == 0 || from.Terminator == nil {
+		return 0
+	}
+	return freq * edgeProbability(from, to)
+}
+
+// edgeProbability returns the probability that control passes from one
+// block to another, according to the weights of the first block's
+// terminator.
+func edgeProbability(from, to *ossa.BasicBlock) float64 {
+	succs := from.Terminator.AppendSuccessors(nil)
+	weights := from.Terminator.Weights()
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		weights = nil // all edges are equally likely
+	}
+
+	var matched float64
+	total = 0
+	for i, succ := range succs {
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		total += w
+		if succ == to {
+			matched += w
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return matched / total
+}
@@ -0,0 +1,79 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindBlockFrequencies(t *testing.T) {
+	entry := &ossa.BasicBlock{}
+	left := &ossa.BasicBlock{}
+	right := &ossa.BasicBlock{}
+	outerHead := &ossa.BasicBlock{}
+	innerHead := &ossa.BasicBlock{}
+	latch := &ossa.BasicBlock{}
+	exit := &ossa.BasicBlock{}
+	cond := ossa.AuxLiteral(nil)
+
+	entry.Terminator = ossa.Branch(cond, left, right)
+	left.Terminator = ossa.Jump(outerHead)
+	right.Terminator = ossa.Jump(outerHead)
+	outerHead.Terminator = ossa.Branch(cond, innerHead, exit)
+	outerHead.Terminator.SetWeights(3, 1)
+	innerHead.Terminator = ossa.Branch(cond, innerHead, latch)
+	latch.Terminator = ossa.Jump(outerHead)
+	exit.Terminator = ossa.Return()
+
+	names := map[*ossa.BasicBlock]string{
+		entry:     "entry",
+		left:      "left",
+		right:     "right",
+		outerHead: "outerHead",
+		innerHead: "innerHead",
+		latch:     "latch",
+		exit:      "exit",
+	}
+
+	// The inner loop is expected to run twice each time it is entered, and
+	// is entered on three quarters of the iterations of the outer loop, so
+	// the outer loop is expected to run four times.
+	got := FindBlockFrequencies(entry, FindPredecessors(entry))
+	want := BlockFrequencies{
+		entry:     1,
+		left:      0.5,
+		right:     0.5,
+		outerHead: 4,
+		innerHead: 6,
+		latch:     3,
+		exit:      1,
+	}
+	for block, name := range names {
+		if got, want := got[block], want[block]; got != want {
+			t.Errorf("%s has wrong frequency %g; want %g", name, got, want)
+		}
+	}
+	if got, want := len(got), len(want); got != want {
+		t.Errorf("wrong number of blocks %d; want %d", got, want)
+	}
+	if got, want := got.Edge(outerHead, innerHead), 3.0; got != want {
+		t.Errorf("outerHead->innerHead has wrong frequency %g; want %g", got, want)
+	}
+	if got, want := got.Edge(outerHead, latch), 0.0; got != want {
+		t.Errorf("outerHead->latch has wrong frequency %g; want %g", got, want)
+	}
+
+	// Zero weights are treated as if there were no weights at all.
+	entry.Terminator.SetWeights(0, 0)
+	if got := FindBlockFrequencies(entry, FindPredecessors(entry)); got[left] != 0.5 {
+		t.Errorf("left has wrong frequency %g with zero weights; want 0.5", got[left])
+	}
+
+	// A loop that never exits is assumed to run maxLoopScale times.
+	spin := &ossa.BasicBlock{}
+	spin.Terminator = ossa.Jump(spin)
+	got = FindBlockFrequencies(spin, FindPredecessors(spin))
+	if got, want := got[spin], float64(maxLoopScale); got != want {
+		t.Errorf("spin has wrong frequency %g; want %g", got, want)
+	}
+}
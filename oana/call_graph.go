@@ -0,0 +1,185 @@
+package oana
+
+import (
+	"sort"
+
+	"github.com/alamatic/ossa"
+)
+
+// CallGraph describes which functions of a module may call which others, as
+// calculated by BuildCallGraph.
+type CallGraph struct {
+	// Functions is all of the functions defined in the module, in the order
+	// they were declared.
+	Functions []*ossa.Function
+
+	// Callees maps each function to the functions it may call, each listed
+	// once in the order of the first call to it.
+	Callees map[*ossa.Function][]*ossa.Function
+
+	// Callers maps each function to the functions that may call it, in the
+	// order of Functions.
+	Callers map[*ossa.Function][]*ossa.Function
+
+	// Unresolved maps each function to the OpCall instructions in it whose
+	// callee could not be determined. Such a call might call any function
+	// whose symbol has escaped, so analyses that need a complete call graph
+	// must treat it conservatively.
+	Unresolved map[*ossa.Function][]*ossa.Value
+
+	// Components is the strongly-connected components of the graph, with
+	// each component appearing after all of the components it calls into.
+	// The functions in each component appear in the order of Functions.
+	Components [][]*ossa.Function
+
+	component map[*ossa.Function]int
+}
+
+// BuildCallGraph constructs the call graph of the given module.
+//
+// The callee of each OpCall instruction that is the symbol of a function
+// defined in the module is a direct call of that function. Calls to global
+// symbols without a function definition are calls to functions outside of
+// the module, which are assumed not to call back into it, and so appear
+// nowhere in the graph.
+//
+// Any other callee is an indirect call, for which the given resolve function
+// is called to find the functions it may call. If resolve is nil or returns
+// false as its second result, the call is recorded in the Unresolved field
+// instead.
+func BuildCallGraph(m *ossa.Module, resolve func(call *ossa.Value) (callees []*ossa.Function, ok bool)) *CallGraph {
+	g := &CallGraph{
+		Callees:    make(map[*ossa.Function][]*ossa.Function),
+		Callers:    make(map[*ossa.Function][]*ossa.Function),
+		Unresolved: make(map[*ossa.Function][]*ossa.Value),
+	}
+	for _, name := range m.AppendFunctionNames(nil) {
+		g.Functions = append(g.Functions, m.Function(name))
+	}
+
+	for _, f := range g.Functions {
+		seen := make(map[*ossa.Function]bool)
+		addCallee := func(callee *ossa.Function) {
+			if !seen[callee] {
+				seen[callee] = true
+				g.Callees[f] = append(g.Callees[f], callee)
+			}
+		}
+		ossa.WalkValues(f, ossa.VisitorFuncs{
+			Value: func(v *ossa.Value) bool {
+				if v.Op() != ossa.OpCall {
+					return true
+				}
+				callee := v.Arg(0)
+				if target := m.FunctionForSym(callee); target != nil {
+					addCallee(target)
+					return true
+				}
+				if _, global := m.GlobalName(callee); global {
+					return true // external function
+				}
+				if resolve != nil {
+					if targets, ok := resolve(v); ok {
+						for _, target := range targets {
+							addCallee(target)
+						}
+						return true
+					}
+				}
+				g.Unresolved[f] = append(g.Unresolved[f], v)
+				return true
+			},
+		})
+	}
+	for _, f := range g.Functions {
+		for _, callee := range g.Callees[f] {
+			g.Callers[callee] = append(g.Callers[callee], f)
+		}
+	}
+
+	g.Components = functionComponents(g.Functions, g.Callees)
+	g.component = make(map[*ossa.Function]int, len(g.Functions))
+	for i, component := range g.Components {
+		for _, f := range component {
+			g.component[f] = i
+		}
+	}
+	return g
+}
+
+// Recursive returns true if the given function may call itself, either
+// directly or via other functions. Unresolved calls are not considered.
+func (g *CallGraph) Recursive(f *ossa.Function) bool {
+	i, ok := g.component[f]
+	if !ok {
+		return false
+	}
+	if len(g.Components[i]) > 1 {
+		return true
+	}
+	for _, callee := range g.Callees[f] {
+		if callee == f {
+			return true
+		}
+	}
+	return false
+}
+
+// functionComponents finds the strongly-connected components of the graph
+// whose nodes are the given functions and whose edges are given by deps,
+// using Tarjan's algorithm. Each component appears after all of the
+// components it has edges to, and the functions in each component appear in
+// the same order as in funcs. Edges to functions not in funcs are ignored.
+func functionComponents(funcs []*ossa.Function, deps map[*ossa.Function][]*ossa.Function) [][]*ossa.Function {
+	var ret [][]*ossa.Function
+	index := make(map[*ossa.Function]int, len(funcs))
+	lowlink := make(map[*ossa.Function]int, len(funcs))
+	onStack := make(map[*ossa.Function]bool, len(funcs))
+	order := make(map[*ossa.Function]int, len(funcs))
+	for i, f := range funcs {
+		order[f] = i
+	}
+	var stack []*ossa.Function
+	var visit func(f *ossa.Function)
+	visit = func(f *ossa.Function) {
+		index[f] = len(index)
+		lowlink[f] = index[f]
+		stack = append(stack, f)
+		onStack[f] = true
+		for _, dep := range deps[f] {
+			if _, known := order[dep]; !known {
+				continue
+			}
+			if _, visited := index[dep]; !visited {
+				visit(dep)
+				if lowlink[dep] < lowlink[f] {
+					lowlink[f] = lowlink[dep]
+				}
+			} else if onStack[dep] && index[dep] < lowlink[f] {
+				lowlink[f] = index[dep]
+			}
+		}
+		if lowlink[f] != index[f] {
+			return
+		}
+		start := len(stack) - 1
+		for stack[start] != f {
+			start--
+		}
+		component := append([]*ossa.Function(nil), stack[start:]...)
+		for _, member := range component {
+			onStack[member] = false
+		}
+		stack = stack[:start]
+		sort.Slice(component, func(i, j int) bool {
+			return order[component[i]] < order[component[j]]
+		})
+		ret = append(ret, component)
+	}
+	for _, f := range funcs {
+		if _, visited := index[f]; !visited {
+			visit(f)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,104 @@
+package oana
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestBuildCallGraph(t *testing.T) {
+	m := ossa.NewModule()
+	external := m.DeclareGlobal("external")
+	main := m.DefineFunction("main")
+	even := m.DefineFunction("even")
+	odd := m.DefineFunction("odd")
+	fact := m.DefineFunction("fact")
+	leaf := m.DefineFunction("leaf")
+
+	b := ossa.NewFunctionBuilder(main)
+	b.Call(m.Global("even"))
+	b.Call(m.Global("fact"))
+	b.Call(m.Global("even"))
+	b.Call(external)
+	resolved := b.Call(b.Load(m.Global("leaf")))
+	unresolved := b.Call(b.Load(external))
+	b.Return()
+
+	b = ossa.NewFunctionBuilder(even)
+	b.Call(m.Global("odd"))
+	b.Return()
+
+	b = ossa.NewFunctionBuilder(odd)
+	b.Call(m.Global("even"))
+	b.Call(m.Global("leaf"))
+	b.Return()
+
+	b = ossa.NewFunctionBuilder(fact)
+	b.Call(m.Global("fact"))
+	b.Return()
+
+	b = ossa.NewFunctionBuilder(leaf)
+	b.Return()
+
+	g := BuildCallGraph(m, func(call *ossa.Value) ([]*ossa.Function, bool) {
+		if call == resolved {
+			return []*ossa.Function{leaf}, true
+		}
+		return nil, false
+	})
+
+	names := map[*ossa.Function]string{
+		main: "main",
+		even: "even",
+		odd:  "odd",
+		fact: "fact",
+		leaf: "leaf",
+	}
+	nameAll := func(funcs []*ossa.Function) []string {
+		var ret []string
+		for _, f := range funcs {
+			ret = append(ret, names[f])
+		}
+		return ret
+	}
+
+	for f, want := range map[*ossa.Function][]string{
+		main: {"even", "fact", "leaf"},
+		even: {"odd"},
+		odd:  {"even", "leaf"},
+		fact: {"fact"},
+		leaf: nil,
+	} {
+		if got := nameAll(g.Callees[f]); !reflect.DeepEqual(got, want) {
+			t.Errorf("wrong callees for %s %q; want %q", names[f], got, want)
+		}
+	}
+	if got, want := nameAll(g.Callers[leaf]), []string{"main", "odd"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong callers for leaf %q; want %q", got, want)
+	}
+	if got, want := g.Unresolved[main], []*ossa.Value{unresolved}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong unresolved calls %#v; want %#v", got, want)
+	}
+
+	var gotComponents [][]string
+	for _, component := range g.Components {
+		gotComponents = append(gotComponents, nameAll(component))
+	}
+	wantComponents := [][]string{{"leaf"}, {"even", "odd"}, {"fact"}, {"main"}}
+	if !reflect.DeepEqual(gotComponents, wantComponents) {
+		t.Errorf("wrong components\ngot:  %q\nwant: %q", gotComponents, wantComponents)
+	}
+
+	for f, want := range map[*ossa.Function]bool{
+		main: false,
+		even: true,
+		odd:  true,
+		fact: true,
+		leaf: false,
+	} {
+		if got := g.Recursive(f); got != want {
+			t.Errorf("%s recursive is %t; want %t", names[f], got, want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// ConstantMemoryTable is a map from global symbols to facts about their
+// memory, for those symbols whose memory is never written outside of
+// initialization. A ConstantMemoryTable can be constructed by calling
+// FindConstantMemory.
+type ConstantMemoryTable map[*ossa.Value]ConstantMemory
+
+// ConstantMemory describes the contents of the memory of a symbol that is
+// constant after initialization.
+type ConstantMemory struct {
+	// Store is the only Store instruction that writes the symbol's memory,
+	// or nil if it is written by more than one Store or by none at all.
+	Store *ossa.Value
+
+	// Value is the value that every Load of the symbol produces, or nil if
+	// that value is not known.
+	//
+	// Value is known only if Store is not nil, the value it stores is a
+	// literal or global symbol that can be used in any function, the Store
+	// runs whenever its initialization function returns, and there are no
+	// Loads of the symbol in initialization functions, which might
+	// otherwise run before the Store.
+	//
+	// The Store runs whenever its function returns if its block
+	// post-dominates the function's entry block, meaning that every path
+	// from the entry block that leaves the function passes through it. A
+	// path leaves the function at any terminator other than Jump, Branch,
+	// Switch, Invoke and Unreachable.
+	Value *ossa.Value
+}
+
+// FindConstantMemory calculates which of the global symbols in the given
+// module have memory that is not written outside of the functions for which
+// the given init function returns true. If init is nil then no functions are
+// initialization functions, and so only symbols that are never written at
+// all are constant.
+//
+// The analysis assumes that the module contains all of the code that can
+// refer to its global symbols. A symbol is considered only if every use of
+// it in the module is as the reference operand of a Load or Store, since a
+// symbol used in any other way might have its memory written indirectly.
+// Symbols that have functions defined are never included.
+func FindConstantMemory(m *ossa.Module, init func(f *ossa.Function) bool) ConstantMemoryTable {
+	type symInfo struct {
+		escapes   bool
+		written   bool // written outside of initialization
+		stores    []*ossa.Value
+		initLoads bool
+	}
+	storeFuncs := make(map[*ossa.Value]*ossa.Function)
+	storeBlocks := make(map[*ossa.Value]*ossa.BasicBlock)
+	syms := make(map[*ossa.Value]*symInfo)
+	for _, name := range m.AppendGlobalNames(nil) {
+		if m.Function(name) == nil {
+			syms[m.Global(name)] = &symInfo{}
+		}
+	}
+
+	for _, name := range m.AppendFunctionNames(nil) {
+		f := m.Function(name)
+		isInit := init != nil && init(f)
+		var block *ossa.BasicBlock
+		ossa.WalkValues(f, ossa.VisitorFuncs{
+			Enter: func(b *ossa.BasicBlock) bool {
+				block = b
+				return true
+			},
+			Value: func(v *ossa.Value) bool {
+				for i := 0; i < v.NumArgs(); i++ {
+					info, ok := syms[v.Arg(i)]
+					if !ok {
+						continue
+					}
+					switch {
+					case v.Op() == ossa.OpLoad && i == 0:
+						if isInit {
+							info.initLoads = true
+						}
+					case v.Op() == ossa.OpStore && i == 1:
+						info.stores = append(info.stores, v)
+						storeFuncs[v] = f
+						storeBlocks[v] = block
+						if !isInit {
+							info.written = true
+						}
+					default:
+						info.escapes = true
+					}
+				}
+				return true
+			},
+			Terminator: func(t *ossa.Terminator) bool {
+				for i := 0; i < t.NumArgs(); i++ {
+					if info, ok := syms[t.Arg(i).Value]; ok {
+						info.escapes = true
+					}
+				}
+				return true
+			},
+		})
+	}
+
+	ret := make(ConstantMemoryTable)
+	for sym, info := range syms {
+		if info.escapes || info.written {
+			continue
+		}
+		var fact ConstantMemory
+		if len(info.stores) == 1 {
+			fact.Store = info.stores[0]
+			val := fact.Store.Arg(0)
+			if val != nil && !info.initLoads && isFunctionIndependent(val) && postDominatesEntry(storeFuncs[fact.Store], storeBlocks[fact.Store]) {
+				fact.Value = val
+			}
+		}
+		ret[sym] = fact
+	}
+	return ret
+}
+
+// FoldLoad returns the value that the given Load instruction produces, if
+// it reads from a symbol in the table whose value is known. Otherwise it
+// returns nil.
+func (t ConstantMemoryTable) FoldLoad(load *ossa.Value) *ossa.Value {
+	if load.Op() != ossa.OpLoad {
+		return nil
+	}
+	return t[load.Arg(0)].Value
+}
+
+// isFunctionIndependent returns true if the given value does not belong to
+// any particular function, and so can be used as an operand in any function.
+func isFunctionIndependent(v *ossa.Value) bool {
+	switch v.Op() {
+	case ossa.OpGlobalSym, ossa.OpAuxLiteral, ossa.OpUndef, ossa.OpPoison:
+		return true
+	default:
+		return false
+	}
+}
+
+// postDominatesEntry returns true if every path from the entry block of the
+// given function that leaves the function passes through the given block, as
+// described for ConstantMemory.Value.
+func postDominatesEntry(f *ossa.Function, block *ossa.BasicBlock) bool {
+	seen := make(ossa.BasicBlockSet)
+	stack := []*ossa.BasicBlock{f.Entry()}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == block || seen.Has(current) {
+			continue
+		}
+		seen.Add(current)
+		t := current.Terminator
+		if t == nil {
+			return false
+		}
+		switch t.Op() {
+		case ossa.OpJump, ossa.OpBranch, ossa.OpSwitch, ossa.OpInvoke, ossa.OpUnreachable:
+			stack = t.AppendSuccessors(stack)
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindConstantMemory(t *testing.T) {
+	m := ossa.NewModule()
+	known := m.DeclareGlobal("known")
+	unwritten := m.DeclareGlobal("unwritten")
+	mutable := m.DeclareGlobal("mutable")
+	escaped := m.DeclareGlobal("escaped")
+	twice := m.DeclareGlobal("twice")
+	readInInit := m.DeclareGlobal("readInInit")
+	conditional := m.DeclareGlobal("conditional")
+	joined := m.DeclareGlobal("joined")
+	one := ossa.AuxLiteral(1)
+	two := ossa.AuxLiteral(2)
+
+	initFn := m.DefineFunction("init")
+	b := ossa.NewFunctionBuilder(initFn)
+	knownStore := b.Store(one, known)
+	b.Store(one, twice)
+	b.Store(two, twice)
+	readInInitStore := b.Store(one, readInInit)
+	b.Load(readInInit)
+	b.Return()
+
+	// The second initialization function stores to "conditional" only on
+	// one path, so its value might never be written, but stores to
+	// "joined" on every path that returns.
+	initFn2 := m.DefineFunction("init2")
+	b = ossa.NewFunctionBuilder(initFn2)
+	then, done := b.NewBlock(), b.NewBlock()
+	b.Branch(initFn2.AddParam(), then, done)
+	b.SetBlock(then)
+	conditionalStore := b.Store(one, conditional)
+	b.Jump(done)
+	b.SetBlock(done)
+	joinedStore := b.Store(two, joined)
+	b.Return()
+
+	mainFn := m.DefineFunction("main")
+	b = ossa.NewFunctionBuilder(mainFn)
+	load := b.Load(known)
+	b.Load(unwritten)
+	b.Store(load, mutable)
+	b.Call(m.Global("init"), escaped)
+	b.Return(load)
+
+	got := FindConstantMemory(m, func(f *ossa.Function) bool {
+		return f == initFn || f == initFn2
+	})
+	want := ConstantMemoryTable{
+		known:       {Store: knownStore, Value: one},
+		unwritten:   {},
+		twice:       {},
+		readInInit:  {Store: readInInitStore},
+		conditional: {Store: conditionalStore},
+		joined:      {Store: joinedStore, Value: two},
+	}
+	names := map[*ossa.Value]string{
+		known:       "known",
+		unwritten:   "unwritten",
+		mutable:     "mutable",
+		escaped:     "escaped",
+		twice:       "twice",
+		readInInit:  "readInInit",
+		conditional: "conditional",
+		joined:      "joined",
+	}
+	for sym, name := range names {
+		gotFact, gotOk := got[sym]
+		wantFact, wantOk := want[sym]
+		switch {
+		case gotOk != wantOk:
+			t.Errorf("%s: constant is %t; want %t", name, gotOk, wantOk)
+		case gotFact != wantFact:
+			t.Errorf("%s: wrong fact %#v; want %#v", name, gotFact, wantFact)
+		}
+	}
+	if got, want := len(got), len(want); got != want {
+		t.Errorf("wrong number of constant symbols %d; want %d", got, want)
+	}
+
+	if got := got.FoldLoad(load); got != one {
+		t.Errorf("wrong folded load %#v; want %#v", got, one)
+	}
+
+	// Without any initialization functions, only the symbol that is never
+	// written is constant.
+	got = FindConstantMemory(m, nil)
+	if _, ok := got[unwritten]; !ok || len(got) != 1 {
+		t.Errorf("wrong result without init functions: %#v", got)
+	}
+}
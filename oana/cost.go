@@ -0,0 +1,93 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// CostModel is the interface implemented by types that can estimate the
+// cost of executing individual instructions and terminators. Cost models are
+// usually provided by a backend, since only the backend knows how each
+// operation will ultimately be implemented.
+//
+// Costs are unitless, and are meaningful only in comparison to other costs
+// produced by the same model.
+type CostModel interface {
+	// ValueCost returns the estimated cost of executing the given
+	// instruction once.
+	ValueCost(v *ossa.Value) float64
+
+	// TerminatorCost returns the estimated cost of executing the given
+	// terminator once.
+	TerminatorCost(t *ossa.Terminator) float64
+}
+
+// OpCostModel is a simple implementation of CostModel that assigns a fixed
+// weight to each operation, with an optional callback to refine the cost of
+// calls based on their callee.
+type OpCostModel struct {
+	// Weights gives the cost of each operation. Any operation not present
+	// in the map has cost zero.
+	Weights map[ossa.Op]float64
+
+	// CallCost, if set, is called for each OpCall instruction, with the
+	// callee value as its argument. Its result is added to the weight of
+	// OpCall from Weights.
+	CallCost func(callee *ossa.Value) float64
+}
+
+var _ CostModel = (*OpCostModel)(nil)
+
+func (m *OpCostModel) ValueCost(v *ossa.Value) float64 {
+	cost := m.Weights[v.Op()]
+	if v.Op() == ossa.OpCall && m.CallCost != nil {
+		cost += m.CallCost(v.Arg(0))
+	}
+	return cost
+}
+
+func (m *OpCostModel) TerminatorCost(t *ossa.Terminator) float64 {
+	return m.Weights[t.Op()]
+}
+
+// CostTable is a map from each basic block to the estimated cost of running
+// it once. A CostTable can be constructed by calling EstimateCost.
+type CostTable map[*ossa.BasicBlock]float64
+
+// EstimateCost uses the given cost model to estimate the cost of running each
+// of the blocks in the given function once.
+func EstimateCost(f *ossa.Function, model CostModel) CostTable {
+	ret := make(CostTable, f.BlockCount())
+	for _, block := range f.AppendBlocks(nil) {
+		var cost float64
+		for _, v := range block.Instructions {
+			cost += model.ValueCost(v)
+		}
+		if block.Terminator != nil {
+			cost += model.TerminatorCost(block.Terminator)
+		}
+		ret[block] = cost
+	}
+	return ret
+}
+
+// Static returns the estimated static cost of the function the table was
+// built from, which is the sum of the costs of all of its blocks.
+func (t CostTable) Static() float64 {
+	var total float64
+	for _, cost := range t {
+		total += cost
+	}
+	return total
+}
+
+// Dynamic returns the estimated dynamic cost of the function the table was
+// built from, weighting the cost of each block by the given relative
+// execution frequencies, such as those calculated by FindBlockFrequencies.
+// Blocks not present in freqs are assumed to never execute.
+func (t CostTable) Dynamic(freqs map[*ossa.BasicBlock]float64) float64 {
+	var total float64
+	for block, cost := range t {
+		total += cost * freqs[block]
+	}
+	return total
+}
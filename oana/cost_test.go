@@ -0,0 +1,72 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestEstimateCost(t *testing.T) {
+	f := ossa.NewFunction()
+	entry := f.Entry()
+	loopHeader := f.NewBlock()
+	loopBody := f.NewBlock()
+	exit := f.NewBlock()
+
+	cheap := ossa.GlobalSym()
+	expensive := ossa.GlobalSym()
+	ref := ossa.LocalSym()
+
+	entry.Terminator = ossa.Jump(loopHeader)
+	cond := ossa.Load(ref)
+	loopHeader.Instructions = []*ossa.Value{cond}
+	loopHeader.Terminator = ossa.Branch(cond, loopBody, exit)
+	loopBody.Instructions = []*ossa.Value{
+		ossa.Call(cheap),
+		ossa.Call(expensive),
+		ossa.Store(cond, ref),
+	}
+	loopBody.Terminator = ossa.Jump(loopHeader)
+	exit.Terminator = ossa.Return()
+
+	model := &OpCostModel{
+		Weights: map[ossa.Op]float64{
+			ossa.OpLoad:   2,
+			ossa.OpStore:  3,
+			ossa.OpCall:   1,
+			ossa.OpBranch: 1,
+		},
+		CallCost: func(callee *ossa.Value) float64 {
+			if callee == expensive {
+				return 10
+			}
+			return 0
+		},
+	}
+	costs := EstimateCost(f, model)
+
+	want := CostTable{
+		entry:      0,
+		loopHeader: 3,  // Load + Branch
+		loopBody:   15, // Call + (Call + 10) + Store
+		exit:       0,
+	}
+	for block, wantCost := range want {
+		if got := costs[block]; got != wantCost {
+			t.Errorf("wrong cost %v for block; want %v", got, wantCost)
+		}
+	}
+	if got, want := costs.Static(), 18.0; got != want {
+		t.Errorf("wrong static cost %v; want %v", got, want)
+	}
+
+	freqs := map[*ossa.BasicBlock]float64{
+		entry:      1,
+		loopHeader: 11,
+		loopBody:   10,
+		exit:       1,
+	}
+	if got, want := costs.Dynamic(freqs), 183.0; got != want {
+		t.Errorf("wrong dynamic cost %v; want %v", got, want)
+	}
+}
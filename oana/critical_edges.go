@@ -0,0 +1,43 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// FindCriticalEdges finds the critical edges in the control flow graph
+// reachable from the given start block, appending each one to the given
+// slice "to", which may be nil, and returning the new slice.
+//
+// A critical edge is one whose source block has more than one distinct
+// successor and whose target block has more than one distinct predecessor.
+// Such an edge cannot have code placed on it without affecting other paths,
+// so it must be split by inserting a new block before code can be placed
+// there. Multiple terminator arguments with the same source and target are
+// reported as a single edge.
+//
+// The edges are ordered by their source blocks in reverse postorder, and
+// then in the order of the successors of each source block.
+//
+// The caller must provide the result of calling FindPredecessors with the
+// same start block, without any modification to the graph in the mean time,
+// or the result is undefined.
+func FindCriticalEdges(start *ossa.BasicBlock, preds PredecessorsTable, to []Edge) []Edge {
+	var succs []*ossa.BasicBlock
+	for _, block := range ossa.AppendBlocksRPO(start, nil) {
+		succs = block.Terminator.AppendSuccessors(succs[:0])
+		unique := ossa.NewBasicBlockSet(succs...)
+		if len(unique) < 2 {
+			continue
+		}
+		for _, succ := range succs {
+			if !unique.Has(succ) {
+				continue // already reported
+			}
+			unique.Remove(succ)
+			if len(preds[succ]) > 1 {
+				to = append(to, Edge{From: block, To: succ})
+			}
+		}
+	}
+	return to
+}
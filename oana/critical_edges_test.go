@@ -0,0 +1,30 @@
+package oana
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindCriticalEdges(t *testing.T) {
+	entry := &ossa.BasicBlock{}
+	left := &ossa.BasicBlock{}
+	join := &ossa.BasicBlock{}
+	exit := &ossa.BasicBlock{}
+	cond := ossa.AuxLiteral(nil)
+
+	entry.Terminator = ossa.Branch(cond, left, join)
+	left.Terminator = ossa.Switch(cond, join, ossa.BasicBlockValue{Value: cond, Block: join}, ossa.BasicBlockValue{Value: cond, Block: exit})
+	join.Terminator = ossa.Jump(exit)
+	exit.Terminator = ossa.Return()
+
+	// The two arguments of the switch that target join are reported as a
+	// single edge. The edge from join to exit is not critical because join
+	// has only one successor.
+	got := FindCriticalEdges(entry, FindPredecessors(entry), nil)
+	want := []Edge{{entry, join}, {left, join}, {left, exit}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong critical edges\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
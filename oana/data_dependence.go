@@ -0,0 +1,180 @@
+package oana
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/alamatic/ossa"
+)
+
+// DependenceKind describes why one value depends on another in a
+// DataDependenceGraph.
+type DependenceKind int
+
+const (
+	// OperandDependence means that the dependent value uses the other as an
+	// operand, or as the value of a Phi candidate.
+	OperandDependence DependenceKind = iota
+
+	// MemoryDependence means that the dependent value is a Load that may
+	// read memory written by the other, which is a Store or some other
+	// instruction that writes memory, such as a Call.
+	MemoryDependence
+)
+
+// Dependence is an edge in a DataDependenceGraph, meaning that the value To
+// depends on the value From.
+type Dependence struct {
+	From, To *ossa.Value
+	Kind     DependenceKind
+
+	// Carried is true if From does not come before To in the graph's Values,
+	// which means that the dependence is on a value from an earlier
+	// iteration of a loop, reaching To through a back edge.
+	Carried bool
+}
+
+// DataDependenceGraph describes which values depend on which others in a
+// control flow graph. A DataDependenceGraph can be constructed by calling
+// BuildDataDependenceGraph.
+//
+// The dependences that are not carried form a directed acyclic graph, whose
+// topological orders are the orders in which the values could be computed
+// within a single iteration of any loops containing them.
+type DataDependenceGraph struct {
+	// Values is the values of the graph. The values that are not
+	// instructions, such as arguments, symbols and literals, come first in
+	// the order of their first use, followed by the instructions in the
+	// reverse postorder of their blocks and then in their order within each
+	// block.
+	Values []*ossa.Value
+
+	// Dependences is the edges of the graph, ordered by the position of To
+	// in Values, then with operand dependences in operand order before
+	// memory dependences in the order of their From values.
+	Dependences []Dependence
+}
+
+// BuildDataDependenceGraph constructs the data dependence graph of the
+// instructions in the graph entered at the given start block.
+//
+// Memory dependences are those found by FindReachingStores with the given
+// alias analysis, or BasicAliasAnalysis if it is nil. Only the dependences
+// of loads on earlier writes are included, and not those that only order
+// writes with respect to other memory accesses, such as a Store's dependence
+// on an earlier Load of the same memory. Operands of terminators are not
+// included, since terminators are not values.
+//
+// The caller must provide the result of calling FindPredecessors with the
+// same start block, without any modification to the graph in the mean time,
+// or the result is undefined.
+func BuildDataDependenceGraph(start *ossa.BasicBlock, preds PredecessorsTable, aa AliasAnalysis) *DataDependenceGraph {
+	blocks := ossa.AppendBlocksRPO(start, nil)
+	var insts []*ossa.Value
+	isInst := make(ossa.ValueSet)
+	for _, block := range blocks {
+		for _, inst := range block.Instructions {
+			insts = append(insts, inst)
+			isInst.Add(inst)
+		}
+	}
+
+	ret := &DataDependenceGraph{}
+	index := make(map[*ossa.Value]int)
+	for _, inst := range insts {
+		ossa.WalkOperands(inst, func(arg *ossa.Value) (*ossa.Value, bool) {
+			if _, seen := index[arg]; !seen && !isInst.Has(arg) {
+				index[arg] = len(ret.Values)
+				ret.Values = append(ret.Values, arg)
+			}
+			return arg, true
+		})
+	}
+	for _, inst := range insts {
+		index[inst] = len(ret.Values)
+		ret.Values = append(ret.Values, inst)
+	}
+
+	reaching := FindReachingStores(start, preds, aa)
+	add := func(from, to *ossa.Value, kind DependenceKind) {
+		ret.Dependences = append(ret.Dependences, Dependence{
+			From:    from,
+			To:      to,
+			Kind:    kind,
+			Carried: index[from] >= index[to],
+		})
+	}
+	for _, inst := range insts {
+		ossa.WalkOperands(inst, func(arg *ossa.Value) (*ossa.Value, bool) {
+			add(arg, inst, OperandDependence)
+			return arg, true
+		})
+		if inst.Op() != ossa.OpLoad {
+			continue
+		}
+		var writes []*ossa.Value
+		for write := range reaching[inst] {
+			writes = append(writes, write)
+		}
+		sort.Slice(writes, func(i, j int) bool {
+			return index[writes[i]] < index[writes[j]]
+		})
+		for _, write := range writes {
+			add(write, inst, MemoryDependence)
+		}
+	}
+	return ret
+}
+
+// WriteDOT writes the receiving graph to the given writer in the DOT
+// language used by Graphviz, with an edge from each value to the values
+// that depend on it.
+//
+// Each value is labelled with its operation, its debug name if it has one,
+// and the aux value of a literal. Memory dependences are drawn dashed and
+// carried dependences are drawn in gray, without affecting the layout.
+func (g *DataDependenceGraph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	index := make(map[*ossa.Value]int, len(g.Values))
+	fmt.Fprintln(bw, "digraph {")
+	for i, v := range g.Values {
+		index[v] = i
+		label := v.Op().Name()
+		if name := v.Name(); name != "" {
+			label = name + " = " + label
+		}
+		if v.Op() == ossa.OpAuxLiteral {
+			if k, ok := v.Const(); ok {
+				label += " " + k.String()
+			} else {
+				label += fmt.Sprintf(" %#v", v.Aux())
+			}
+		}
+		fmt.Fprintf(bw, "\tv%d [label=%q];\n", i, label)
+	}
+	for _, dep := range g.Dependences {
+		var attrs []string
+		if dep.Kind == MemoryDependence {
+			attrs = append(attrs, "style=dashed")
+		}
+		if dep.Carried {
+			attrs = append(attrs, "color=gray", "constraint=false")
+		}
+		fmt.Fprintf(bw, "\tv%d -> v%d", index[dep.From], index[dep.To])
+		if len(attrs) > 0 {
+			fmt.Fprint(bw, " [")
+			for i, attr := range attrs {
+				if i > 0 {
+					fmt.Fprint(bw, ", ")
+				}
+				fmt.Fprint(bw, attr)
+			}
+			fmt.Fprint(bw, "]")
+		}
+		fmt.Fprintln(bw, ";")
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
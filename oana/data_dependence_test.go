@@ -0,0 +1,101 @@
+package oana
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestBuildDataDependenceGraph(t *testing.T) {
+	f := ossa.NewFunction()
+	p := f.AddParam()
+	b := ossa.NewFunctionBuilder(f)
+	one := b.AuxLiteral(1)
+	x := b.LocalSym()
+	loop := b.NewBlock()
+	exit := b.NewBlock()
+
+	s0 := b.Store(one, x)
+	b.Jump(loop)
+
+	b.SetBlock(loop)
+	i := b.Phi()
+	v := b.Load(x)
+	n := b.Call(p, i, v)
+	s1 := b.Store(n, x)
+	b.Branch(n, loop, exit)
+	i.AddPhiCandidate(ossa.BasicBlockValue{Block: f.Entry(), Value: one})
+	i.AddPhiCandidate(ossa.BasicBlockValue{Block: loop, Value: n})
+
+	b.SetBlock(exit)
+	r := b.Load(x)
+	b.Return(r)
+
+	i.SetName("i")
+	n.SetName("n")
+
+	// The Call may write to any memory, so the Loads depend on it too.
+	g := BuildDataDependenceGraph(f.Entry(), FindPredecessors(f.Entry()), nil)
+	if got, want := g.Values, []*ossa.Value{one, x, p, s0, i, v, n, s1, r}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong values\ngot:  %v\nwant: %v", got, want)
+	}
+	want := []Dependence{
+		{From: one, To: s0},
+		{From: x, To: s0},
+		{From: one, To: i},
+		{From: n, To: i, Carried: true},
+		{From: x, To: v},
+		{From: s0, To: v, Kind: MemoryDependence},
+		{From: n, To: v, Kind: MemoryDependence, Carried: true},
+		{From: s1, To: v, Kind: MemoryDependence, Carried: true},
+		{From: p, To: n},
+		{From: i, To: n},
+		{From: v, To: n},
+		{From: n, To: s1},
+		{From: x, To: s1},
+		{From: x, To: r},
+		{From: n, To: r, Kind: MemoryDependence},
+		{From: s1, To: r, Kind: MemoryDependence},
+	}
+	if got := g.Dependences; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong dependences\ngot:  %v\nwant: %v", got, want)
+	}
+
+	var buf strings.Builder
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	wantDOT := `digraph {
+	v0 [label="AuxLiteral 1"];
+	v1 [label="LocalSym"];
+	v2 [label="Argument"];
+	v3 [label="Store"];
+	v4 [label="i = Phi"];
+	v5 [label="Load"];
+	v6 [label="n = Call"];
+	v7 [label="Store"];
+	v8 [label="Load"];
+	v0 -> v3;
+	v1 -> v3;
+	v0 -> v4;
+	v6 -> v4 [color=gray, constraint=false];
+	v1 -> v5;
+	v3 -> v5 [style=dashed];
+	v6 -> v5 [style=dashed, color=gray, constraint=false];
+	v7 -> v5 [style=dashed, color=gray, constraint=false];
+	v2 -> v6;
+	v4 -> v6;
+	v5 -> v6;
+	v6 -> v7;
+	v1 -> v7;
+	v1 -> v8;
+	v6 -> v8 [style=dashed];
+	v7 -> v8 [style=dashed];
+}
+`
+	if got := buf.String(); got != wantDOT {
+		t.Errorf("wrong DOT\ngot:\n%s\nwant:\n%s", got, wantDOT)
+	}
+}
@@ -0,0 +1,78 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// ImmediateDominatorsTable is a map from each basic block to its immediate
+// dominator, which is the strict dominator that is dominated by all of the
+// block's other strict dominators. The start block has no immediate dominator
+// and so maps to nil.
+//
+// An ImmediateDominatorsTable can be constructed by calling
+// FindImmediateDominators.
+type ImmediateDominatorsTable map[*ossa.BasicBlock]*ossa.BasicBlock
+
+// FindImmediateDominators derives the immediate dominator of each block from
+// the given dominators table, which must be the result of calling
+// FindDominators.
+func FindImmediateDominators(doms DominatorsTable) ImmediateDominatorsTable {
+	ret := make(ImmediateDominatorsTable, len(doms))
+	for block, blockDoms := range doms {
+		// The strict dominators of a block form a chain, so the immediate
+		// dominator is the one that is itself dominated by all of the others,
+		// which is the one with exactly one fewer dominator than our block.
+		var idom *ossa.BasicBlock
+		for d := range blockDoms {
+			if d != block && len(doms[d]) == len(blockDoms)-1 {
+				idom = d
+				break
+			}
+		}
+		ret[block] = idom
+	}
+	return ret
+}
+
+// DominanceFrontiersTable is a map from each basic block to its dominance
+// frontier, which is the set of blocks where the block's dominance ends: each
+// block in the frontier has a predecessor that the block dominates, but
+// the block does not strictly dominate the frontier block itself.
+//
+// A DominanceFrontiersTable can be constructed by calling
+// FindDominanceFrontiers.
+type DominanceFrontiersTable map[*ossa.BasicBlock]ossa.BasicBlockSet
+
+// FindDominanceFrontiers calculates the dominance frontier of each block
+// in the given immediate dominators table.
+//
+// The caller must provide the results of calling FindPredecessors and
+// FindImmediateDominators for the same start block, without any modification
+// to the graph in the mean time, or the result is undefined.
+func FindDominanceFrontiers(preds PredecessorsTable, idoms ImmediateDominatorsTable) DominanceFrontiersTable {
+	ret := make(DominanceFrontiersTable, len(idoms))
+	for block := range idoms {
+		ret[block] = make(ossa.BasicBlockSet)
+	}
+
+	// This is the algorithm from "A Simple, Fast Dominance Algorithm" by
+	// Cooper, Harvey and Kennedy: a block with multiple predecessors is in
+	// the frontier of each of its predecessors and of their dominators, up
+	// to but not including the block's own immediate dominator.
+	//
+	// The start block has an implied additional predecessor representing
+	// entry into the graph, so it is a join point if it has any predecessors
+	// at all.
+	for block, blockPreds := range preds {
+		idom := idoms[block]
+		if len(blockPreds) < 2 && (idom != nil || len(blockPreds) == 0) {
+			continue
+		}
+		for pred := range blockPreds {
+			for runner := pred; runner != nil && runner != idom; runner = idoms[runner] {
+				ret[runner].Add(block)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,84 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindDominanceFrontiers(t *testing.T) {
+	entry := &ossa.BasicBlock{}
+	loopHeader := &ossa.BasicBlock{}
+	ifTrue := &ossa.BasicBlock{}
+	ifFalse := &ossa.BasicBlock{}
+	loopTail := &ossa.BasicBlock{}
+	exit := &ossa.BasicBlock{}
+
+	entry.Terminator = ossa.Jump(loopHeader)
+	loopHeader.Terminator = ossa.Branch(ossa.AuxLiteral(nil), ifTrue, exit)
+	ifTrue.Terminator = ossa.Branch(ossa.AuxLiteral(nil), loopTail, ifFalse)
+	ifFalse.Terminator = ossa.Jump(loopTail)
+	loopTail.Terminator = ossa.Jump(loopHeader)
+	exit.Terminator = ossa.Return(ossa.AuxLiteral(nil))
+
+	preds := FindPredecessors(entry)
+	doms := FindDominators(entry, preds)
+	idoms := FindImmediateDominators(doms)
+	dfs := FindDominanceFrontiers(preds, idoms)
+
+	// We care about the identities of these blocks rather than their contents,
+	// so to make test results easier to understand we'll give each block a
+	// name and compare by those names.
+	names := map[*ossa.BasicBlock]string{
+		entry:      "entry",
+		loopHeader: "loopHeader",
+		ifTrue:     "ifTrue",
+		ifFalse:    "ifFalse",
+		loopTail:   "loopTail",
+		exit:       "exit",
+	}
+
+	wantIdoms := ImmediateDominatorsTable{
+		entry:      nil,
+		loopHeader: entry,
+		ifTrue:     loopHeader,
+		ifFalse:    ifTrue,
+		loopTail:   ifTrue,
+		exit:       loopHeader,
+	}
+	for block, want := range wantIdoms {
+		if got := idoms[block]; got != want {
+			t.Errorf("wrong immediate dominator for %q: %q; want %q", names[block], names[got], names[want])
+		}
+	}
+	if len(idoms) != len(wantIdoms) {
+		t.Errorf("wrong number of immediate dominator entries %d; want %d", len(idoms), len(wantIdoms))
+	}
+
+	want := DominanceFrontiersTable{
+		entry:      ossa.NewBasicBlockSet(),
+		loopHeader: ossa.NewBasicBlockSet(loopHeader),
+		ifTrue:     ossa.NewBasicBlockSet(loopHeader),
+		ifFalse:    ossa.NewBasicBlockSet(loopTail),
+		loopTail:   ossa.NewBasicBlockSet(loopHeader),
+		exit:       ossa.NewBasicBlockSet(),
+	}
+	for wantB, wantFBs := range want {
+		gotFBs := dfs[wantB]
+		for wantFB := range wantFBs {
+			if !gotFBs.Has(wantFB) {
+				t.Errorf("%q should be in the frontier of %q", names[wantFB], names[wantB])
+			}
+		}
+		for gotFB := range gotFBs {
+			if !wantFBs.Has(gotFB) {
+				t.Errorf("%q should not be in the frontier of %q", names[gotFB], names[wantB])
+			}
+		}
+	}
+	for gotB := range dfs {
+		if _, exists := want[gotB]; !exists {
+			t.Errorf("%q should not be in the result", names[gotB])
+		}
+	}
+}
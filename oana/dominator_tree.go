@@ -0,0 +1,175 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// DominatorTree represents the immediate dominator relationships between the
+// blocks reachable from a particular start block, as a tree rooted at that
+// start block.
+//
+// Unlike DominatorsTable, a DominatorTree uses memory proportional to the
+// number of blocks and can answer dominance queries in constant time. A
+// DominatorTree can be constructed by calling FindDominatorTree.
+type DominatorTree struct {
+	start    *ossa.BasicBlock
+	idoms    map[*ossa.BasicBlock]*ossa.BasicBlock
+	children map[*ossa.BasicBlock][]*ossa.BasicBlock
+
+	// pre and post are the preorder and postorder numbers of each block in
+	// a depth-first traversal of the tree. Block a dominates block b if and
+	// only if b's interval is nested within a's.
+	pre, post map[*ossa.BasicBlock]int
+
+	// preorder is the blocks in the order of the preorder numbering.
+	preorder []*ossa.BasicBlock
+}
+
+// FindDominatorTree calculates the dominator tree for the given start block
+// and all blocks reachable from it, using the algorithm from "A Simple, Fast
+// Dominance Algorithm" by Cooper, Harvey and Kennedy.
+//
+// Calculating the tree requires a table of predecessors provided by the
+// caller. This must be the result of calling FindPredecessors with the same
+// start block and no subsequent modifications to the graph beneath it, or
+// the results of this function are undefined.
+func FindDominatorTree(start *ossa.BasicBlock, preds PredecessorsTable) *DominatorTree {
+	rpo := ossa.AppendBlocksRPO(start, nil)
+	rpoIdx := make(map[*ossa.BasicBlock]int, len(rpo))
+	for i, block := range rpo {
+		rpoIdx[block] = i
+	}
+
+	// During the main loop, the start block is its own immediate dominator
+	// so that the intersection walk always terminates. We'll remove that
+	// self-reference once we're done.
+	idoms := make(map[*ossa.BasicBlock]*ossa.BasicBlock, len(rpo))
+	idoms[start] = start
+	intersect := func(a, b *ossa.BasicBlock) *ossa.BasicBlock {
+		for a != b {
+			for rpoIdx[a] > rpoIdx[b] {
+				a = idoms[a]
+			}
+			for rpoIdx[b] > rpoIdx[a] {
+				b = idoms[b]
+			}
+		}
+		return a
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, block := range rpo[1:] {
+			var newIdom *ossa.BasicBlock
+			for pred := range preds[block] {
+				if _, processed := idoms[pred]; !processed {
+					continue
+				}
+				if newIdom == nil {
+					newIdom = pred
+				} else {
+					newIdom = intersect(pred, newIdom)
+				}
+			}
+			if idoms[block] != newIdom {
+				idoms[block] = newIdom
+				changed = true
+			}
+		}
+	}
+	idoms[start] = nil
+
+	// Children are recorded in reverse postorder so that the preorder
+	// traversal of the tree is deterministic.
+	children := make(map[*ossa.BasicBlock][]*ossa.BasicBlock)
+	for _, block := range rpo[1:] {
+		idom := idoms[block]
+		children[idom] = append(children[idom], block)
+	}
+
+	t := &DominatorTree{
+		start:    start,
+		idoms:    idoms,
+		children: children,
+		pre:      make(map[*ossa.BasicBlock]int, len(rpo)),
+		post:     make(map[*ossa.BasicBlock]int, len(rpo)),
+		preorder: make([]*ossa.BasicBlock, 0, len(rpo)),
+	}
+	type frame struct {
+		block *ossa.BasicBlock
+		next  int // index of the next child to visit
+	}
+	stack := []frame{{block: start}}
+	t.pre[start] = 0
+	t.preorder = append(t.preorder, start)
+	postNum := 0
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		kids := children[top.block]
+		if top.next < len(kids) {
+			child := kids[top.next]
+			top.next++
+			t.pre[child] = len(t.preorder)
+			t.preorder = append(t.preorder, child)
+			stack = append(stack, frame{block: child})
+			continue
+		}
+		t.post[top.block] = postNum
+		postNum++
+		stack = stack[:len(stack)-1]
+	}
+
+	return t
+}
+
+// Start returns the start block that the tree was built from, which is the
+// root of the tree.
+func (t *DominatorTree) Start() *ossa.BasicBlock {
+	return t.start
+}
+
+// Has returns true if the given block is in the tree, which is true only for
+// blocks that are reachable from the start block.
+func (t *DominatorTree) Has(block *ossa.BasicBlock) bool {
+	_, ok := t.pre[block]
+	return ok
+}
+
+// IDom returns the immediate dominator of the given block, or nil if the
+// block is the start block or is not in the tree.
+func (t *DominatorTree) IDom(block *ossa.BasicBlock) *ossa.BasicBlock {
+	return t.idoms[block]
+}
+
+// Dominates returns true if block a dominates block b. Every block in the tree
+// dominates itself. Blocks that are not in the tree neither dominate nor are
+// dominated by any block.
+func (t *DominatorTree) Dominates(a, b *ossa.BasicBlock) bool {
+	aPre, aOk := t.pre[a]
+	bPre, bOk := t.pre[b]
+	if !aOk || !bOk {
+		return false
+	}
+	return aPre <= bPre && t.post[b] <= t.post[a]
+}
+
+// StrictlyDominates returns true if block a dominates block b and the two
+// blocks are not the same.
+func (t *DominatorTree) StrictlyDominates(a, b *ossa.BasicBlock) bool {
+	return a != b && t.Dominates(a, b)
+}
+
+// AppendChildren appends to the given slice the blocks that the given block
+// immediately dominates, and returns the new slice.
+func (t *DominatorTree) AppendChildren(block *ossa.BasicBlock, to []*ossa.BasicBlock) []*ossa.BasicBlock {
+	return append(to, t.children[block]...)
+}
+
+// AppendPreorder appends to the given slice all of the blocks in the tree in
+// preorder, so that each block appears before all of the blocks it dominates,
+// and returns the new slice.
+//
+// The ordering is consistent for a particular graph, but is not otherwise
+// part of the contract of this method.
+func (t *DominatorTree) AppendPreorder(to []*ossa.BasicBlock) []*ossa.BasicBlock {
+	return append(to, t.preorder...)
+}
@@ -0,0 +1,86 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindDominatorTree(t *testing.T) {
+	entry := &ossa.BasicBlock{}
+	loopHeader := &ossa.BasicBlock{}
+	ifTrue := &ossa.BasicBlock{}
+	ifFalse := &ossa.BasicBlock{}
+	loopTail := &ossa.BasicBlock{}
+	exit := &ossa.BasicBlock{}
+	unreachable := &ossa.BasicBlock{}
+
+	entry.Terminator = ossa.Jump(loopHeader)
+	loopHeader.Terminator = ossa.Branch(ossa.AuxLiteral(nil), ifTrue, exit)
+	ifTrue.Terminator = ossa.Branch(ossa.AuxLiteral(nil), loopTail, ifFalse)
+	ifFalse.Terminator = ossa.Jump(loopTail)
+	loopTail.Terminator = ossa.Jump(loopHeader)
+	exit.Terminator = ossa.Return(ossa.AuxLiteral(nil))
+	unreachable.Terminator = ossa.Jump(exit)
+
+	preds := FindPredecessors(entry)
+	tree := FindDominatorTree(entry, preds)
+
+	// We care about the identities of these blocks rather than their contents,
+	// so to make test results easier to understand we'll give each block a
+	// name and compare by those names.
+	names := map[*ossa.BasicBlock]string{
+		entry:       "entry",
+		loopHeader:  "loopHeader",
+		ifTrue:      "ifTrue",
+		ifFalse:     "ifFalse",
+		loopTail:    "loopTail",
+		exit:        "exit",
+		unreachable: "unreachable",
+	}
+
+	wantIdoms := map[*ossa.BasicBlock]*ossa.BasicBlock{
+		entry:       nil,
+		loopHeader:  entry,
+		ifTrue:      loopHeader,
+		ifFalse:     ifTrue,
+		loopTail:    ifTrue,
+		exit:        loopHeader,
+		unreachable: nil,
+	}
+	for block, want := range wantIdoms {
+		if got := tree.IDom(block); got != want {
+			t.Errorf("wrong immediate dominator for %q: %q; want %q", names[block], names[got], names[want])
+		}
+	}
+
+	// The result of Dominates must agree with FindDominators for every
+	// pair of blocks.
+	doms := FindDominators(entry, preds)
+	for a := range names {
+		for b := range names {
+			want := doms[b].Has(a)
+			if got := tree.Dominates(a, b); got != want {
+				t.Errorf("wrong result for Dominates(%q, %q): %t; want %t", names[a], names[b], got, want)
+			}
+			if got, want := tree.StrictlyDominates(a, b), want && a != b; got != want {
+				t.Errorf("wrong result for StrictlyDominates(%q, %q): %t; want %t", names[a], names[b], got, want)
+			}
+		}
+	}
+
+	if tree.Has(unreachable) {
+		t.Errorf("unreachable block is in the tree")
+	}
+
+	var got []string
+	for _, block := range tree.AppendPreorder(nil) {
+		got = append(got, names[block])
+	}
+	want := []string{"entry", "loopHeader", "exit", "ifTrue", "ifFalse", "loopTail"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("wrong preorder\ngot: %#v\nwant: %#v", got, want)
+	}
+}
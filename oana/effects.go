@@ -0,0 +1,166 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// Effects summarizes the effects that calling a function might have, as
+// calculated by FindEffects. Each value includes all of the effects of the
+// values before it, so the effects of two calls can be combined by taking
+// the greater of the two.
+type Effects int
+
+const (
+	// Pure means that calling the function has no effect other than
+	// producing its result, and that the result depends only on its
+	// arguments. Two calls to the same pure function with the same
+	// arguments produce the same result.
+	Pure Effects = iota
+
+	// ReadOnly means that calling the function might read memory that
+	// outlives the call, but does not write it or have any other effect.
+	ReadOnly
+
+	// MayWrite means that calling the function might write memory that
+	// outlives the call, or might have some other side-effect.
+	MayWrite
+)
+
+// EffectsTable is a map from each function in a module to a summary of its
+// effects. An EffectsTable can be constructed by calling FindEffects.
+type EffectsTable map[*ossa.Function]Effects
+
+// FindEffects calculates the effects of each function in the given call
+// graph, which must have been built from the given module.
+//
+// The effects of a function are the combination of the effects of its own
+// instructions and terminators and those of all of the functions it may
+// call, calculated for each strongly-connected component of the graph until
+// they stop changing. Loads and Stores whose reference is a local symbol
+// that does not escape, as decided by FindEscapes, access only memory that
+// does not outlive the call and so have no effect. Other instructions and
+// terminators are classified using Op.HasSideEffects, Op.ReadsMemory and
+// Op.WritesMemory, with Yield, Await and Resume terminators considered to
+// have side-effects, since each of them passes control somewhere other than
+// back to the caller with a result. An Invoke's unwind target is always a
+// block in the same function, so an exception leaves through an Invoke only
+// by reaching a Resume.
+//
+// Calls to global symbols that have no function defined in the module have
+// the effects returned by the given external function, or MayWrite if it is
+// nil. Unresolved calls in the graph have the effects MayWrite.
+func FindEffects(m *ossa.Module, g *CallGraph, external func(callee *ossa.Value) Effects) EffectsTable {
+	ret := make(EffectsTable, len(g.Functions))
+	for _, f := range g.Functions {
+		ret[f] = localEffects(m, f, external)
+		if len(g.Unresolved[f]) != 0 {
+			ret[f] = MayWrite
+		}
+	}
+
+	for _, component := range g.Components {
+		for changed := true; changed; {
+			changed = false
+			for _, f := range component {
+				for _, callee := range g.Callees[f] {
+					if ret[callee] > ret[f] {
+						ret[f] = ret[callee]
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// CallEffects returns the effects of the given Call instruction, which are
+// those of its callee if the callee is the symbol of a function in the table,
+// or MayWrite otherwise.
+func (t EffectsTable) CallEffects(m *ossa.Module, call *ossa.Value) Effects {
+	if call.Op() != ossa.OpCall {
+		return MayWrite
+	}
+	if f := m.FunctionForSym(call.Arg(0)); f != nil {
+		if effects, ok := t[f]; ok {
+			return effects
+		}
+	}
+	return MayWrite
+}
+
+// PureCalls returns a function that reports whether a given instruction is a
+// Call of a pure function, suitable for passing to transforms that accept
+// a callback to identify pure instructions.
+func (t EffectsTable) PureCalls(m *ossa.Module) func(v *ossa.Value) bool {
+	return func(v *ossa.Value) bool {
+		return v.Op() == ossa.OpCall && t.CallEffects(m, v) == Pure
+	}
+}
+
+// localEffects returns the effects of the given function's own instructions
+// and terminators, excluding those of the functions it calls.
+func localEffects(m *ossa.Module, f *ossa.Function, external func(callee *ossa.Value) Effects) Effects {
+	escapes := FindEscapes(f.Entry())
+	isPrivate := func(ref *ossa.Value) bool {
+		return ref != nil && ref.Op() == ossa.OpLocalSym && !escapes.Escapes(ref)
+	}
+
+	ret := Pure
+	add := func(effects Effects) {
+		if effects > ret {
+			ret = effects
+		}
+	}
+	classify := func(op ossa.Op) {
+		switch {
+		case op.WritesMemory():
+			add(MayWrite)
+		case op.HasSideEffects() && !op.Terminator():
+			add(MayWrite)
+		case op.ReadsMemory():
+			add(ReadOnly)
+		}
+	}
+	ossa.WalkValues(f, ossa.VisitorFuncs{
+		Value: func(v *ossa.Value) bool {
+			switch v.Op() {
+			case ossa.OpLoad:
+				if !isPrivate(v.Arg(0)) {
+					add(ReadOnly)
+				}
+			case ossa.OpStore:
+				if !isPrivate(v.Arg(1)) {
+					add(MayWrite)
+				}
+			case ossa.OpCall:
+				callee := v.Arg(0)
+				if m.FunctionForSym(callee) != nil {
+					break // accounted for by the call graph
+				}
+				if _, global := m.GlobalName(callee); global {
+					if external != nil {
+						add(external(callee))
+					} else {
+						add(MayWrite)
+					}
+				}
+				// Any other callee is an indirect call, which the call
+				// graph either resolved or recorded as unresolved.
+			default:
+				classify(v.Op())
+			}
+			return true
+		},
+		Terminator: func(t *ossa.Terminator) bool {
+			switch t.Op() {
+			case ossa.OpYield, ossa.OpAwait, ossa.OpResume:
+				add(MayWrite)
+			default:
+				classify(t.Op())
+			}
+			return true
+		},
+	})
+	return ret
+}
@@ -0,0 +1,120 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindEffects(t *testing.T) {
+	m := ossa.NewModule()
+	global := m.DeclareGlobal("global")
+	sqrt := m.DeclareGlobal("sqrt")
+	one := ossa.AuxLiteral(1)
+
+	define := func(name string, build func(b *ossa.Builder)) *ossa.Function {
+		f := m.DefineFunction(name)
+		b := ossa.NewFunctionBuilder(f)
+		build(b)
+		b.Return()
+		return f
+	}
+
+	leaf := define("leaf", func(b *ossa.Builder) {
+		tmp := b.LocalSym()
+		b.Store(one, tmp)
+		b.Load(tmp)
+		b.Call(sqrt, one)
+	})
+	reader := define("reader", func(b *ossa.Builder) {
+		b.Load(global)
+	})
+	writer := define("writer", func(b *ossa.Builder) {
+		b.Store(one, global)
+	})
+	caller := define("caller", func(b *ossa.Builder) {
+		b.Call(m.Global("leaf"))
+		b.Call(m.Global("reader"))
+	})
+	// rec1 and rec2 call each other, so each has the effects of writer.
+	m.DeclareGlobal("rec2")
+	rec1 := define("rec1", func(b *ossa.Builder) {
+		b.Call(m.Global("rec2"))
+	})
+	rec2 := define("rec2", func(b *ossa.Builder) {
+		b.Call(m.Global("rec1"))
+		b.Call(m.Global("writer"))
+	})
+	indirect := define("indirect", func(b *ossa.Builder) {
+		b.Call(b.Load(global))
+	})
+	escaped := define("escaped", func(b *ossa.Builder) {
+		tmp := b.LocalSym()
+		b.Store(one, tmp)
+		b.Call(m.Global("leaf"), tmp)
+	})
+	// A function that can raise an exception to its caller has an effect
+	// even if it does nothing else, whether it raises it itself or lets
+	// one from a pure callee continue unwinding.
+	thrower := m.DefineFunction("thrower")
+	ossa.NewFunctionBuilder(thrower).Resume(thrower.AddParam())
+	rethrower := m.DefineFunction("rethrower")
+	{
+		b := ossa.NewFunctionBuilder(rethrower)
+		normal, unwind := b.NewBlock(), b.NewBlock()
+		b.Invoke(m.Global("leaf"), normal, unwind)
+		b.SetBlock(normal)
+		b.Return()
+		b.SetBlock(unwind)
+		b.Resume(b.LandingPad())
+	}
+
+	g := BuildCallGraph(m, nil)
+	got := FindEffects(m, g, func(callee *ossa.Value) Effects {
+		if callee == sqrt {
+			return Pure
+		}
+		return MayWrite
+	})
+
+	names := map[*ossa.Function]string{
+		leaf:      "leaf",
+		reader:    "reader",
+		writer:    "writer",
+		caller:    "caller",
+		rec1:      "rec1",
+		rec2:      "rec2",
+		indirect:  "indirect",
+		escaped:   "escaped",
+		thrower:   "thrower",
+		rethrower: "rethrower",
+	}
+	want := EffectsTable{
+		leaf:      Pure,
+		reader:    ReadOnly,
+		writer:    MayWrite,
+		caller:    ReadOnly,
+		rec1:      MayWrite,
+		rec2:      MayWrite,
+		indirect:  MayWrite,
+		escaped:   MayWrite,
+		thrower:   MayWrite,
+		rethrower: MayWrite,
+	}
+	for f, want := range want {
+		if got := got[f]; got != want {
+			t.Errorf("%s has wrong effects %d; want %d", names[f], got, want)
+		}
+	}
+
+	pure := got.PureCalls(m)
+	if !pure(ossa.Call(m.Global("leaf"))) {
+		t.Errorf("call to leaf is not pure; want pure")
+	}
+	if pure(ossa.Call(m.Global("reader"))) {
+		t.Errorf("call to reader is pure; want not pure")
+	}
+	if pure(ossa.Call(sqrt)) {
+		t.Errorf("call to external function is pure; want not pure")
+	}
+}
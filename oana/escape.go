@@ -0,0 +1,129 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// EscapeReason describes why the address of a local symbol escapes, as
+// decided by FindEscapes.
+type EscapeReason int
+
+const (
+	// NoEscape means that the symbol's address does not escape: its memory
+	// is accessed only by Load and Store instructions in the function that
+	// refer to it directly or through Phi and Select instructions.
+	NoEscape EscapeReason = iota
+
+	// EscapeCall means that the address is an operand of a Call, and so
+	// the callee might retain it or access the memory.
+	EscapeCall
+
+	// EscapeStore means that the address is the value operand of a Store,
+	// and so might later be loaded from memory elsewhere.
+	EscapeStore
+
+	// EscapeReturn means that the address is returned from the function.
+	EscapeReturn
+
+	// EscapeOther means that the address is used in some other way whose
+	// effect is not known, such as as an operand of a registered operation
+	// or a terminator other than Return.
+	EscapeOther
+)
+
+// Escape describes whether the address of a particular local symbol escapes.
+type Escape struct {
+	Reason EscapeReason
+
+	// Use is the first use found through which the address escapes, which
+	// might be a use of a Phi or Select instruction that has the symbol as
+	// one of its candidates or operands. It is the zero value if Reason is
+	// NoEscape.
+	Use Use
+}
+
+// EscapeTable is a map from each local symbol used in a function to whether
+// its address escapes. An EscapeTable can be constructed by calling
+// FindEscapes.
+type EscapeTable map[*ossa.Value]Escape
+
+// FindEscapes determines whether the address of each local symbol used in
+// the blocks reachable from the given entry block escapes, which consumers
+// can use to decide whether the symbol's memory must outlive the function's
+// activation or might be accessed by code other than the function's own
+// Load and Store instructions.
+//
+// The address of a symbol flows through any Phi instruction that has it as
+// a candidate and any Select instruction that has it as one of its two
+// possible results, and escapes if the result of such an instruction
+// escapes. Only the reference operand of a Load or Store is a non-escaping
+// use; any other use escapes.
+//
+// A symbol that does not escape might still be unsuitable for
+// PromoteLocalSyms, which also requires that the symbol is referred to
+// directly rather than through Phi or Select instructions.
+func FindEscapes(entry *ossa.BasicBlock) EscapeTable {
+	uses := BuildUses(entry)
+	ret := make(EscapeTable)
+	for sym := range uses {
+		if sym.Op() != ossa.OpLocalSym {
+			continue
+		}
+		ret[sym] = findEscape(sym, uses)
+	}
+	return ret
+}
+
+// Escapes returns true if the address of the given symbol escapes. Symbols
+// that are not in the table are assumed to escape.
+func (t EscapeTable) Escapes(sym *ossa.Value) bool {
+	e, ok := t[sym]
+	return !ok || e.Reason != NoEscape
+}
+
+// findEscape follows the uses of the given symbol, and of any Phi and Select
+// instructions its address flows through, to find the first use through
+// which it escapes.
+func findEscape(sym *ossa.Value, uses UsesTable) Escape {
+	seen := make(ossa.ValueSet)
+	seen.Add(sym)
+	queue := []*ossa.Value{sym}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, u := range uses[v] {
+			reason := NoEscape
+			if u.Value == nil {
+				switch u.Terminator().Op() {
+				case ossa.OpInvoke:
+					// An Invoke's operand is the call it makes, rather than
+					// a use of the call's result.
+				case ossa.OpReturn:
+					reason = EscapeReturn
+				default:
+					reason = EscapeOther
+				}
+			} else {
+				switch op := u.Value.Op(); {
+				case op == ossa.OpLoad && u.Index == 0:
+				case op == ossa.OpStore && u.Index == 1:
+				case op == ossa.OpStore:
+					reason = EscapeStore
+				case op == ossa.OpCall:
+					reason = EscapeCall
+				case op == ossa.OpPhi || (op == ossa.OpSelect && u.Index != 0):
+					if !seen.Has(u.Value) {
+						seen.Add(u.Value)
+						queue = append(queue, u.Value)
+					}
+				default:
+					reason = EscapeOther
+				}
+			}
+			if reason != NoEscape {
+				return Escape{Reason: reason, Use: u}
+			}
+		}
+	}
+	return Escape{}
+}
@@ -0,0 +1,65 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindEscapes(t *testing.T) {
+	f := ossa.NewFunction()
+	cond := f.AddParam()
+	b := ossa.NewFunctionBuilder(f)
+	callee := b.GlobalSym()
+	global := b.GlobalSym()
+	one := b.AuxLiteral(1)
+
+	private := b.LocalSym()
+	called := b.LocalSym()
+	stored := b.LocalSym()
+	returned := b.LocalSym()
+	viaSelect := b.LocalSym()
+	other := b.LocalSym()
+
+	b.Store(one, private)
+	b.Load(private)
+	call := b.Call(callee, called)
+	store := b.Store(stored, global)
+	sel := b.Select(cond, viaSelect, other)
+	b.Load(sel)
+	escapingCall := b.Call(callee, sel)
+	b.Return(returned)
+
+	names := map[*ossa.Value]string{
+		private:   "private",
+		called:    "called",
+		stored:    "stored",
+		returned:  "returned",
+		viaSelect: "viaSelect",
+		other:     "other",
+	}
+	want := map[*ossa.Value]Escape{
+		private:   {},
+		called:    {Reason: EscapeCall, Use: Use{Block: f.Entry(), Value: call, Index: 1}},
+		stored:    {Reason: EscapeStore, Use: Use{Block: f.Entry(), Value: store, Index: 0}},
+		returned:  {Reason: EscapeReturn, Use: Use{Block: f.Entry(), Index: 0}},
+		viaSelect: {Reason: EscapeCall, Use: Use{Block: f.Entry(), Value: escapingCall, Index: 1}},
+		other:     {Reason: EscapeCall, Use: Use{Block: f.Entry(), Value: escapingCall, Index: 1}},
+	}
+
+	got := FindEscapes(f.Entry())
+	if len(got) != len(want) {
+		t.Errorf("wrong number of symbols %d; want %d", len(got), len(want))
+	}
+	for sym, want := range want {
+		if got := got[sym]; got != want {
+			t.Errorf("wrong result for %s\ngot:  %#v\nwant: %#v", names[sym], got, want)
+		}
+	}
+	if got.Escapes(private) {
+		t.Errorf("private escapes; want not")
+	}
+	if !got.Escapes(called) {
+		t.Errorf("called does not escape; want it to")
+	}
+}
@@ -0,0 +1,50 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// ExceptionSpec is implemented by frontends to describe which calls can
+// raise exceptions and which blocks have a handler for them, for use with
+// FindUnprotectedCalls.
+type ExceptionSpec interface {
+	// MayThrow returns true if the given Call instruction might raise an
+	// exception rather than returning normally.
+	MayThrow(call *ossa.Value) bool
+
+	// Protected returns true if the given block belongs to a protected
+	// region, such as the body of a try statement, where any exception
+	// raised must be delivered to a handler in the same function. A
+	// frontend would usually decide this using the block's Region.
+	Protected(block *ossa.BasicBlock) bool
+}
+
+// FindUnprotectedCalls returns the Call instructions in the given function
+// that may throw, according to the given spec, but that would skip the
+// function's handler if they did. The calls are returned in the order
+// visited by WalkValues.
+//
+// A Call is unprotected if it belongs to a protected block but is not the
+// call of the Invoke terminator that ends that block. A plain Call that
+// raises an exception unwinds out of the function entirely, so an
+// unprotected call usually means that the frontend forgot to give it an
+// unwind edge, which would otherwise be noticed only when the exception is
+// raised at runtime.
+func FindUnprotectedCalls(f *ossa.Function, spec ExceptionSpec) []*ossa.Value {
+	var ret []*ossa.Value
+	for _, block := range f.AppendBlocks(nil) {
+		if !spec.Protected(block) {
+			continue
+		}
+		var invoked *ossa.Value
+		if t := block.Terminator; t != nil && t.Op() == ossa.OpInvoke {
+			invoked = t.Arg(0).Value
+		}
+		for _, inst := range block.Instructions {
+			if inst.Op() == ossa.OpCall && inst != invoked && spec.MayThrow(inst) {
+				ret = append(ret, inst)
+			}
+		}
+	}
+	return ret
+}
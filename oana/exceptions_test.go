@@ -0,0 +1,56 @@
+package oana
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+// testExceptionSpec treats every call as throwing except calls to "safe",
+// and blocks whose Region is "try" as protected.
+type testExceptionSpec struct {
+	safe *ossa.Value
+}
+
+func (s testExceptionSpec) MayThrow(call *ossa.Value) bool {
+	return call.Arg(0) != s.safe
+}
+
+func (s testExceptionSpec) Protected(block *ossa.BasicBlock) bool {
+	return block.Region == "try"
+}
+
+func TestFindUnprotectedCalls(t *testing.T) {
+	m := ossa.NewModule()
+	f := m.DefineFunction("f")
+	risky := m.DeclareGlobal("risky")
+	safe := m.DeclareGlobal("safe")
+
+	b := ossa.NewFunctionBuilder(f)
+	body := b.NewBlock()
+	body.Region = "try"
+	handler := b.NewBlock()
+	after := b.NewBlock()
+	after.Region = "try"
+	b.Call(risky) // not in the protected region
+	b.Jump(body)
+
+	b.SetBlock(body)
+	forgotten := b.Call(risky)
+	b.Call(safe)
+	b.Invoke(risky, after, handler)
+
+	b.SetBlock(handler)
+	b.LandingPad()
+	b.Return()
+
+	b.SetBlock(after)
+	last := b.Call(risky)
+	b.Return()
+
+	got := FindUnprotectedCalls(f, testExceptionSpec{safe: safe})
+	if want := []*ossa.Value{forgotten, last}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong unprotected calls %v; want %v", got, want)
+	}
+}
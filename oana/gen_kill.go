@@ -0,0 +1,220 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// ValueIndex assigns a distinct small integer to each of a set of values, so
+// that sets of those values can be represented as a BitSet.
+type ValueIndex struct {
+	values []*ossa.Value
+	index  map[*ossa.Value]int
+}
+
+// NewValueIndex returns an empty value index.
+func NewValueIndex() *ValueIndex {
+	return &ValueIndex{
+		index: make(map[*ossa.Value]int),
+	}
+}
+
+// Add assigns the next available number to the given value and returns it,
+// or returns the value's existing number if it was already added.
+func (x *ValueIndex) Add(v *ossa.Value) int {
+	if i, exists := x.index[v]; exists {
+		return i
+	}
+	i := len(x.values)
+	x.values = append(x.values, v)
+	x.index[v] = i
+	return i
+}
+
+// Index returns the number assigned to the given value. The second return
+// value is false if the value has not been added.
+func (x *ValueIndex) Index(v *ossa.Value) (int, bool) {
+	i, ok := x.index[v]
+	return i, ok
+}
+
+// Value returns the value that was assigned the given number.
+func (x *ValueIndex) Value(i int) *ossa.Value {
+	return x.values[i]
+}
+
+// Len returns the number of values in the index, which is also the size to
+// use for bit sets of those values.
+func (x *ValueIndex) Len() int {
+	return len(x.values)
+}
+
+// AddMembersTo adds to the given value set all of the values whose numbers
+// are members of the given bit set.
+func (x *ValueIndex) AddMembersTo(bs BitSet, to ossa.ValueSet) {
+	for _, i := range bs.AppendMembers(nil) {
+		to.Add(x.values[i])
+	}
+}
+
+// DataFlowDirection specifies which way facts flow in a data flow analysis.
+type DataFlowDirection int
+
+const (
+	// Forward analyses propagate facts from predecessors to successors.
+	Forward DataFlowDirection = iota
+
+	// Backward analyses propagate facts from successors to predecessors.
+	Backward
+)
+
+// MeetOperator specifies how a gen/kill analysis combines the facts arriving
+// at a block from multiple neighbors.
+type MeetOperator int
+
+const (
+	// Union is the meet operator for "may" analyses, where a fact holds if
+	// it holds along any path.
+	Union MeetOperator = iota
+
+	// Intersection is the meet operator for "must" analyses, where a fact
+	// holds only if it holds along all paths.
+	Intersection
+)
+
+// GenKillProblem describes a classic bit-vector data flow problem, where each
+// block generates some facts and kills others, to be solved by
+// SolveGenKill.
+type GenKillProblem struct {
+	Direction DataFlowDirection
+	Meet      MeetOperator
+
+	// Size is the number of distinct facts, and thus the size of all of the
+	// bit sets used in the problem.
+	Size int
+
+	// Gen and Kill give the facts generated and killed by each block. A
+	// block that is absent from either map generates or kills nothing.
+	Gen, Kill map[*ossa.BasicBlock]BitSet
+
+	// Boundary is the set of facts that hold on entry to the start block,
+	// for forward problems, or on exit from blocks with no successors, for
+	// backward problems. A nil Boundary is the empty set.
+	Boundary BitSet
+}
+
+// GenKillResult is the solution to a GenKillProblem, giving the facts that
+// hold on entry to and exit from each block.
+//
+// For a forward problem, In is the meet of the Out sets of a block's
+// predecessors and Out is derived from In using the block's gen and kill
+// sets. For a backward problem the roles are reversed.
+type GenKillResult struct {
+	In, Out map[*ossa.BasicBlock]BitSet
+}
+
+// SolveGenKill solves the given gen/kill problem for the control flow graph
+// entered at the given start block, iterating until a fixpoint is reached.
+//
+// Solving the problem requires a table of predecessors provided by the
+// caller. This must be the result of calling FindPredecessors with the same
+// start block and no subsequent modifications to the graph beneath it, or
+// the results of this function are undefined.
+func SolveGenKill(start *ossa.BasicBlock, preds PredecessorsTable, problem *GenKillProblem) GenKillResult {
+	order := ossa.AppendBlocksRPO(start, nil)
+	if problem.Direction == Backward {
+		// Visiting blocks in postorder means we'll usually visit successors
+		// before their predecessors.
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+
+	boundary := problem.Boundary
+	if boundary == nil {
+		boundary = NewBitSet(problem.Size)
+	}
+	initial := func() BitSet {
+		if problem.Meet == Intersection {
+			return NewFullBitSet(problem.Size)
+		}
+		return NewBitSet(problem.Size)
+	}
+
+	ret := GenKillResult{
+		In:  make(map[*ossa.BasicBlock]BitSet, len(order)),
+		Out: make(map[*ossa.BasicBlock]BitSet, len(order)),
+	}
+	// "before" is the set on the side facts flow in from, and "after"
+	// is the side they flow out of, so that the rest of the algorithm can be
+	// the same for both directions.
+	before, after := ret.In, ret.Out
+	if problem.Direction == Backward {
+		before, after = ret.Out, ret.In
+	}
+	for _, block := range order {
+		before[block] = initial()
+		after[block] = initial()
+	}
+
+	empty := NewBitSet(problem.Size)
+	gen := func(block *ossa.BasicBlock) BitSet {
+		if s, ok := problem.Gen[block]; ok {
+			return s
+		}
+		return empty
+	}
+	kill := func(block *ossa.BasicBlock) BitSet {
+		if s, ok := problem.Kill[block]; ok {
+			return s
+		}
+		return empty
+	}
+
+	var neighbors []*ossa.BasicBlock
+	for changed := true; changed; {
+		changed = false
+		for _, block := range order {
+			neighbors = neighbors[:0]
+			if problem.Direction == Forward {
+				neighbors = preds[block].AppendBlocks(neighbors)
+			} else {
+				neighbors = block.Terminator.AppendSuccessors(neighbors)
+			}
+
+			in := before[block]
+			first := true
+			meet := func(s BitSet) {
+				if first {
+					copy(in, s)
+					first = false
+					return
+				}
+				if problem.Meet == Intersection {
+					in.IntersectWith(s)
+				} else {
+					in.UnionWith(s)
+				}
+			}
+			isBoundary := (problem.Direction == Forward && block == start) ||
+				(problem.Direction == Backward && len(neighbors) == 0)
+			if isBoundary {
+				meet(boundary)
+			}
+			for _, n := range neighbors {
+				if s, ok := after[n]; ok {
+					meet(s)
+				}
+			}
+
+			out := in.Copy()
+			out.DifferenceWith(kill(block))
+			out.UnionWith(gen(block))
+			if !out.Equal(after[block]) {
+				after[block] = out
+				changed = true
+			}
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,166 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestSolveGenKill(t *testing.T) {
+	entry := &ossa.BasicBlock{}
+	loopHeader := &ossa.BasicBlock{}
+	loopBody := &ossa.BasicBlock{}
+	exit := &ossa.BasicBlock{}
+
+	entry.Terminator = ossa.Jump(loopHeader)
+	loopHeader.Terminator = ossa.Branch(
+		ossa.AuxLiteral(nil),
+		loopBody,
+		exit,
+	)
+	loopBody.Terminator = ossa.Jump(loopHeader)
+	exit.Terminator = ossa.Return(ossa.AuxLiteral(nil))
+
+	preds := FindPredecessors(entry)
+
+	bitSet := func(members ...int) BitSet {
+		s := NewBitSet(70) // more than one word, to exercise the word handling
+		for _, m := range members {
+			s.Add(m)
+		}
+		return s
+	}
+	members := func(s BitSet) []int {
+		return s.AppendMembers(nil)
+	}
+
+	t.Run("forward union", func(t *testing.T) {
+		// This is shaped like reaching definitions: entry defines fact 0,
+		// the loop body redefines it as fact 65, and the exit defines 1.
+		result := SolveGenKill(entry, preds, &GenKillProblem{
+			Direction: Forward,
+			Meet:      Union,
+			Size:      70,
+			Gen: map[*ossa.BasicBlock]BitSet{
+				entry:    bitSet(0),
+				loopBody: bitSet(65),
+				exit:     bitSet(1),
+			},
+			Kill: map[*ossa.BasicBlock]BitSet{
+				entry:    bitSet(65),
+				loopBody: bitSet(0),
+			},
+		})
+
+		want := map[*ossa.BasicBlock][]int{
+			entry:      nil,
+			loopHeader: {0, 65},
+			loopBody:   {0, 65},
+			exit:       {0, 65},
+		}
+		for block, want := range want {
+			if got := members(result.In[block]); !cmp.Equal(got, want) {
+				t.Errorf("wrong In set\ngot:  %#v\nwant: %#v", got, want)
+			}
+		}
+		if got, want := members(result.Out[exit]), []int{0, 1, 65}; !cmp.Equal(got, want) {
+			t.Errorf("wrong Out set for exit\ngot:  %#v\nwant: %#v", got, want)
+		}
+	})
+
+	t.Run("forward intersection", func(t *testing.T) {
+		// This is shaped like available expressions: fact 2 is available
+		// from the entry but the loop body kills it, so it's not available
+		// in the loop header.
+		result := SolveGenKill(entry, preds, &GenKillProblem{
+			Direction: Forward,
+			Meet:      Intersection,
+			Size:      70,
+			Gen: map[*ossa.BasicBlock]BitSet{
+				entry:      bitSet(2, 3),
+				loopHeader: bitSet(4),
+			},
+			Kill: map[*ossa.BasicBlock]BitSet{
+				loopBody: bitSet(2),
+			},
+		})
+
+		want := map[*ossa.BasicBlock][]int{
+			entry:      nil,
+			loopHeader: {3},
+			loopBody:   {3, 4},
+			exit:       {3, 4},
+		}
+		for block, want := range want {
+			if got := members(result.In[block]); !cmp.Equal(got, want) {
+				t.Errorf("wrong In set\ngot:  %#v\nwant: %#v", got, want)
+			}
+		}
+	})
+
+	t.Run("backward union", func(t *testing.T) {
+		// This is shaped like liveness: the loop header uses fact 5 and the
+		// exit uses fact 6, while the entry block defines both.
+		result := SolveGenKill(entry, preds, &GenKillProblem{
+			Direction: Backward,
+			Meet:      Union,
+			Size:      70,
+			Gen: map[*ossa.BasicBlock]BitSet{
+				loopHeader: bitSet(5),
+				exit:       bitSet(6),
+			},
+			Kill: map[*ossa.BasicBlock]BitSet{
+				entry: bitSet(5, 6),
+			},
+			Boundary: bitSet(69),
+		})
+
+		wantIn := map[*ossa.BasicBlock][]int{
+			entry:      {69},
+			loopHeader: {5, 6, 69},
+			loopBody:   {5, 6, 69},
+			exit:       {6, 69},
+		}
+		for block, want := range wantIn {
+			if got := members(result.In[block]); !cmp.Equal(got, want) {
+				t.Errorf("wrong In set\ngot:  %#v\nwant: %#v", got, want)
+			}
+		}
+		if got, want := members(result.Out[entry]), []int{5, 6, 69}; !cmp.Equal(got, want) {
+			t.Errorf("wrong Out set for entry\ngot:  %#v\nwant: %#v", got, want)
+		}
+	})
+}
+
+func TestBitSet(t *testing.T) {
+	full := NewFullBitSet(65)
+	if got, want := full.Len(), 65; got != want {
+		t.Errorf("wrong length for full set %d; want %d", got, want)
+	}
+	s := NewBitSet(65)
+	s.Add(64)
+	s.Add(3)
+	s.Add(3)
+	if !s.Has(64) || !s.Has(3) || s.Has(4) {
+		t.Errorf("wrong membership after Add")
+	}
+	s.Remove(3)
+	if s.Has(3) {
+		t.Errorf("still has 3 after Remove")
+	}
+	other := full.Copy()
+	other.DifferenceWith(s)
+	if other.Has(64) || other.Len() != 64 {
+		t.Errorf("wrong result for DifferenceWith")
+	}
+	other.IntersectWith(s)
+	if other.Len() != 0 {
+		t.Errorf("wrong result for IntersectWith")
+	}
+	other.UnionWith(s)
+	if !other.Equal(s) {
+		t.Errorf("wrong result for UnionWith")
+	}
+}
@@ -0,0 +1,141 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// InitOrder describes an order in which to run the initialization functions
+// of a module, as calculated by FindInitOrder.
+type InitOrder struct {
+	// Functions is all of the initialization functions, ordered so that
+	// each one comes after the functions that store to the global symbols it
+	// loads from, except where those dependencies form a cycle.
+	Functions []*ossa.Function
+
+	// Cycles is each group of initialization functions that depend on each
+	// other cyclically, in the order the groups appear in Functions. The
+	// functions in each group are adjacent in Functions, and appear in the
+	// order they were declared in the module.
+	//
+	// There is no correct order for the functions in a cycle, so a runtime
+	// that requires a deterministic initialization order should probably
+	// treat any cycle as an error.
+	Cycles [][]*ossa.Function
+}
+
+// FindInitOrder calculates the order in which the initialization functions
+// of the given module must run, which are the functions for which the given
+// init function returns true.
+//
+// One initialization function depends on another if it loads from a global
+// symbol that the other stores to. Loads and stores in functions called
+// directly by an initialization function, identified by a callee that is the
+// symbol of another function in the module, count as loads and stores of
+// the caller. Indirect calls and calls to functions outside of the module
+// are assumed to not access any global symbols, so frontends whose
+// initializers can make such calls must account for them separately.
+//
+// The order is deterministic for a particular module. Functions that do not
+// depend on each other appear in the order they were declared in the module.
+func FindInitOrder(m *ossa.Module, init func(f *ossa.Function) bool) *InitOrder {
+	var funcs []*ossa.Function
+	for _, name := range m.AppendFunctionNames(nil) {
+		funcs = append(funcs, m.Function(name))
+	}
+
+	// First we find the global symbols that each function loads and stores
+	// directly, and which functions it calls.
+	loads := make(map[*ossa.Function]ossa.ValueSet, len(funcs))
+	stores := make(map[*ossa.Function]ossa.ValueSet, len(funcs))
+	calls := make(map[*ossa.Function][]*ossa.Function, len(funcs))
+	isGlobal := func(v *ossa.Value) bool {
+		_, ok := m.GlobalName(v)
+		return ok
+	}
+	for _, f := range funcs {
+		fLoads, fStores := make(ossa.ValueSet), make(ossa.ValueSet)
+		ossa.WalkValues(f, ossa.VisitorFuncs{
+			Value: func(v *ossa.Value) bool {
+				switch v.Op() {
+				case ossa.OpLoad:
+					if ref := v.Arg(0); ref != nil && isGlobal(ref) {
+						fLoads.Add(ref)
+					}
+				case ossa.OpStore:
+					if ref := v.Arg(1); ref != nil && isGlobal(ref) {
+						fStores.Add(ref)
+					}
+				case ossa.OpCall:
+					if callee := v.Arg(0); callee != nil {
+						if target := m.FunctionForSym(callee); target != nil {
+							calls[f] = append(calls[f], target)
+						}
+					}
+				}
+				return true
+			},
+		})
+		loads[f], stores[f] = fLoads, fStores
+	}
+
+	// Then we propagate the accesses of each callee to its callers until
+	// nothing changes, which accounts for recursion.
+	for changed := true; changed; {
+		changed = false
+		for _, f := range funcs {
+			for _, callee := range calls[f] {
+				changed = addAll(loads[f], loads[callee]) || changed
+				changed = addAll(stores[f], stores[callee]) || changed
+			}
+		}
+	}
+
+	var inits []*ossa.Function
+	for _, f := range funcs {
+		if init(f) {
+			inits = append(inits, f)
+		}
+	}
+	deps := make(map[*ossa.Function][]*ossa.Function, len(inits))
+	for _, f := range inits {
+		for _, other := range inits {
+			if other != f && intersects(loads[f], stores[other]) {
+				deps[f] = append(deps[f], other)
+			}
+		}
+	}
+
+	// Finally we find the strongly-connected components of the dependency
+	// graph, which are produced only after all of the components they
+	// depend on.
+	ret := &InitOrder{}
+	for _, component := range functionComponents(inits, deps) {
+		ret.Functions = append(ret.Functions, component...)
+		if len(component) > 1 {
+			ret.Cycles = append(ret.Cycles, component)
+		}
+	}
+	return ret
+}
+
+// addAll adds all of the members of from to the set to, returning true if
+// that added any new members.
+func addAll(to, from ossa.ValueSet) bool {
+	added := false
+	for v := range from {
+		if !to.Has(v) {
+			to.Add(v)
+			added = true
+		}
+	}
+	return added
+}
+
+func intersects(a, b ossa.ValueSet) bool {
+	for v := range a {
+		if b.Has(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindInitOrder(t *testing.T) {
+	m := ossa.NewModule()
+	a := m.DeclareGlobal("a")
+	b := m.DeclareGlobal("b")
+	c := m.DeclareGlobal("c")
+	d := m.DeclareGlobal("d")
+	one := ossa.AuxLiteral(1)
+
+	define := func(name string, build func(b *ossa.Builder)) *ossa.Function {
+		f := m.DefineFunction(name)
+		b := ossa.NewFunctionBuilder(f)
+		build(b)
+		b.Return()
+		return f
+	}
+
+	// initB is declared before initA but depends on it, via a helper
+	// function that loads a.
+	define("getA", func(bld *ossa.Builder) {
+		bld.Load(a)
+	})
+	initB := define("initB", func(bld *ossa.Builder) {
+		bld.Store(bld.Call(m.Global("getA")), b)
+	})
+	initA := define("initA", func(bld *ossa.Builder) {
+		bld.Store(one, a)
+	})
+	// initC and initD depend on each other.
+	initC := define("initC", func(bld *ossa.Builder) {
+		bld.Store(bld.Load(d), c)
+	})
+	initD := define("initD", func(bld *ossa.Builder) {
+		bld.Store(bld.Load(c), d)
+	})
+	initE := define("initE", func(bld *ossa.Builder) {
+		bld.Load(b)
+		bld.Load(c)
+	})
+
+	names := map[*ossa.Function]string{
+		initA: "initA",
+		initB: "initB",
+		initC: "initC",
+		initD: "initD",
+		initE: "initE",
+	}
+	got := FindInitOrder(m, func(f *ossa.Function) bool {
+		_, ok := names[f]
+		return ok
+	})
+
+	wantFuncs := []*ossa.Function{initA, initB, initC, initD, initE}
+	if len(got.Functions) != len(wantFuncs) {
+		t.Fatalf("wrong number of functions %d; want %d", len(got.Functions), len(wantFuncs))
+	}
+	for i, f := range wantFuncs {
+		if got.Functions[i] != f {
+			t.Errorf("wrong function at position %d: got %s, want %s", i, names[got.Functions[i]], names[f])
+		}
+	}
+	if len(got.Cycles) != 1 {
+		t.Fatalf("wrong number of cycles %d; want 1", len(got.Cycles))
+	}
+	if cycle := got.Cycles[0]; len(cycle) != 2 || cycle[0] != initC || cycle[1] != initD {
+		t.Errorf("wrong cycle %v", cycle)
+	}
+}
@@ -0,0 +1,209 @@
+package oana
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/alamatic/ossa"
+)
+
+// Intrinsic describes a well-known operation that may be the callee of a
+// Call instruction, such as a memory copy, a math function, or an
+// allocation.
+//
+// Different frontends tend to represent the same fundamental operations in
+// different ways, so an IntrinsicRegistry allows each frontend to map its
+// own callees to shared descriptors that generic passes can understand.
+type Intrinsic struct {
+	// Name is a human-readable name for the intrinsic, used only for
+	// debugging.
+	Name string
+
+	// Effects describes how a call to the intrinsic may interact with the
+	// rest of the program, aside from producing its result.
+	Effects IntrinsicEffects
+
+	// Fold, if set, is called with the arguments of a call whose arguments
+	// are all canonical constants. It returns the constant result of the
+	// call, or false as its second return value if the call cannot be
+	// folded.
+	Fold func(args []ossa.Const) (ossa.Const, bool)
+
+	// Cost is the estimated cost of a call to the intrinsic, in the same
+	// units as whatever CostModel it is used with.
+	Cost float64
+}
+
+// Pure returns true if the intrinsic has no effects, and so a call to it
+// can be removed if its result is unused, or merged with another call that
+// has the same arguments.
+func (in *Intrinsic) Pure() bool {
+	return in.Effects == 0
+}
+
+// IntrinsicEffects is a set of flags describing the effects of calling an
+// intrinsic. The zero value represents no effects at all.
+type IntrinsicEffects uint
+
+const (
+	// IntrinsicReadsMemory indicates that the intrinsic may read memory
+	// that is reachable from its arguments.
+	IntrinsicReadsMemory IntrinsicEffects = 1 << iota
+
+	// IntrinsicWritesMemory indicates that the intrinsic may write memory
+	// that is reachable from its arguments.
+	IntrinsicWritesMemory
+
+	// IntrinsicAllocates indicates that the intrinsic allocates a new
+	// memory object, whose address is its result.
+	IntrinsicAllocates
+
+	// IntrinsicMayFail indicates that the intrinsic may not return
+	// normally, such as by aborting the program.
+	IntrinsicMayFail
+)
+
+// Has returns true if the receiver includes all of the given effects.
+func (e IntrinsicEffects) Has(effects IntrinsicEffects) bool {
+	return e&effects == effects
+}
+
+// IntrinsicRegistry maps callees to intrinsic descriptors.
+//
+// Callees can be recognized either by the name of a global symbol in a
+// module, or by the aux value of an AuxLiteral callee, which a frontend
+// might use to represent fundamental operations of its language.
+//
+// The zero value of IntrinsicRegistry is not usable. Use
+// NewIntrinsicRegistry to create one.
+type IntrinsicRegistry struct {
+	byName map[string]*Intrinsic
+	byAux  map[interface{}]*Intrinsic
+}
+
+// NewIntrinsicRegistry constructs and returns a new, empty registry.
+func NewIntrinsicRegistry() *IntrinsicRegistry {
+	return &IntrinsicRegistry{
+		byName: make(map[string]*Intrinsic),
+		byAux:  make(map[interface{}]*Intrinsic),
+	}
+}
+
+// RegisterName associates the given intrinsic with calls to the global
+// symbol of the given name.
+//
+// It panics if an intrinsic is already registered for the given name.
+func (r *IntrinsicRegistry) RegisterName(name string, in *Intrinsic) {
+	if _, exists := r.byName[name]; exists {
+		panic(fmt.Sprintf("duplicate intrinsic for name %q", name))
+	}
+	r.byName[name] = in
+}
+
+// RegisterAux associates the given intrinsic with calls to AuxLiteral
+// callees whose aux value is equal to the given key.
+//
+// Keys are compared using the Go == operator, so the key must be of a
+// comparable type. It panics if the key is not comparable or if an
+// intrinsic is already registered for the given key.
+func (r *IntrinsicRegistry) RegisterAux(key interface{}, in *Intrinsic) {
+	if !isComparable(key) {
+		panic(fmt.Sprintf("intrinsic key of non-comparable type %T", key))
+	}
+	if _, exists := r.byAux[key]; exists {
+		panic(fmt.Sprintf("duplicate intrinsic for key %#v", key))
+	}
+	r.byAux[key] = in
+}
+
+// Recognize returns the intrinsic associated with the given callee, or nil
+// if the callee is not recognized.
+//
+// The given module is used to find the names of global symbols. It may be
+// nil, in which case only AuxLiteral callees can be recognized.
+func (r *IntrinsicRegistry) Recognize(m *ossa.Module, callee *ossa.Value) *Intrinsic {
+	if callee == nil {
+		return nil
+	}
+	switch callee.Op() {
+	case ossa.OpGlobalSym:
+		if m == nil {
+			return nil
+		}
+		name, ok := m.GlobalName(callee)
+		if !ok {
+			return nil
+		}
+		return r.byName[name]
+	case ossa.OpAuxLiteral:
+		return r.lookupAux(callee.Aux())
+	default:
+		return nil
+	}
+}
+
+// RecognizeCall is like Recognize but takes a Call instruction, returning
+// nil if the given value is not a Call or if its callee is not recognized.
+func (r *IntrinsicRegistry) RecognizeCall(m *ossa.Module, call *ossa.Value) *Intrinsic {
+	if call.Op() != ossa.OpCall {
+		return nil
+	}
+	return r.Recognize(m, call.Arg(0))
+}
+
+// FoldCall attempts to calculate the constant result of the given Call
+// instruction, which is possible only if its callee is a recognized
+// intrinsic with a Fold function and all of its arguments are canonical
+// constants. The second return value is false if the call cannot be folded.
+func (r *IntrinsicRegistry) FoldCall(m *ossa.Module, call *ossa.Value) (ossa.Const, bool) {
+	in := r.RecognizeCall(m, call)
+	if in == nil || in.Fold == nil {
+		return ossa.NilConst, false
+	}
+	args := make([]ossa.Const, call.NumArgs()-1)
+	for i := range args {
+		arg := call.Arg(i + 1)
+		if arg == nil {
+			return ossa.NilConst, false
+		}
+		c, ok := arg.Const()
+		if !ok {
+			return ossa.NilConst, false
+		}
+		args[i] = c
+	}
+	return in.Fold(args)
+}
+
+// CallCost returns a function suitable for use as the CallCost field of an
+// OpCostModel, which returns the cost of any recognized intrinsic and zero
+// for any other callee.
+func (r *IntrinsicRegistry) CallCost(m *ossa.Module) func(callee *ossa.Value) float64 {
+	return func(callee *ossa.Value) float64 {
+		if in := r.Recognize(m, callee); in != nil {
+			return in.Cost
+		}
+		return 0
+	}
+}
+
+// lookupAux returns the intrinsic registered for the given aux value, or nil
+// if there is none or the value cannot be used as a key.
+func (r *IntrinsicRegistry) lookupAux(key interface{}) (in *Intrinsic) {
+	if !isComparable(key) {
+		return nil
+	}
+	// A comparable type can still hold values that are not comparable, such
+	// as a struct with an interface field containing a slice, and then the
+	// map lookup panics.
+	defer func() {
+		if recover() != nil {
+			in = nil
+		}
+	}()
+	return r.byAux[key]
+}
+
+func isComparable(v interface{}) bool {
+	return v == nil || reflect.TypeOf(v).Comparable()
+}
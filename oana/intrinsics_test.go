@@ -0,0 +1,104 @@
+package oana
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestIntrinsicRegistry(t *testing.T) {
+	type opKey string
+
+	memcpy := &Intrinsic{
+		Name:    "memcpy",
+		Effects: IntrinsicReadsMemory | IntrinsicWritesMemory,
+		Cost:    10,
+	}
+	add := &Intrinsic{
+		Name: "add",
+		Fold: func(args []ossa.Const) (ossa.Const, bool) {
+			if len(args) != 2 {
+				return ossa.NilConst, false
+			}
+			a, ok := args[0].Int()
+			if !ok {
+				return ossa.NilConst, false
+			}
+			b, ok := args[1].Int()
+			if !ok {
+				return ossa.NilConst, false
+			}
+			return ossa.IntConst(new(big.Int).Add(a, b)), true
+		},
+		Cost: 1,
+	}
+
+	r := NewIntrinsicRegistry()
+	r.RegisterName("memcpy", memcpy)
+	r.RegisterAux(opKey("add"), add)
+
+	m := ossa.NewModule()
+	memcpySym := m.DeclareGlobal("memcpy")
+	otherSym := m.DeclareGlobal("other")
+	addCallee := ossa.AuxLiteral(opKey("add"))
+
+	if got, want := r.Recognize(m, memcpySym), memcpy; got != want {
+		t.Errorf("wrong result for memcpy symbol %#v; want %#v", got, want)
+	}
+	if got := r.Recognize(m, otherSym); got != nil {
+		t.Errorf("unexpected result for other symbol %#v", got)
+	}
+	if got := r.Recognize(nil, memcpySym); got != nil {
+		t.Errorf("unexpected result for memcpy symbol without module %#v", got)
+	}
+	if got, want := r.Recognize(nil, addCallee), add; got != want {
+		t.Errorf("wrong result for add callee %#v; want %#v", got, want)
+	}
+	if got := r.Recognize(nil, ossa.AuxLiteral([]int{1})); got != nil {
+		t.Errorf("unexpected result for non-comparable aux %#v", got)
+	}
+	if got := r.Recognize(nil, ossa.AuxLiteral(struct{ v interface{} }{[]int{1}})); got != nil {
+		t.Errorf("unexpected result for aux holding non-comparable value %#v", got)
+	}
+
+	if memcpy.Pure() || !add.Pure() {
+		t.Errorf("wrong purity")
+	}
+	if !memcpy.Effects.Has(IntrinsicWritesMemory) || memcpy.Effects.Has(IntrinsicAllocates) {
+		t.Errorf("wrong effects for memcpy")
+	}
+
+	t.Run("FoldCall", func(t *testing.T) {
+		call := ossa.Call(addCallee, ossa.ConstLiteral(ossa.Int64Const(2)), ossa.ConstLiteral(ossa.Int64Const(3)))
+		got, ok := r.FoldCall(m, call)
+		if !ok {
+			t.Fatalf("call was not folded")
+		}
+		if want := ossa.Int64Const(5); !got.Equal(want) {
+			t.Errorf("wrong result %s; want %s", got, want)
+		}
+
+		notConst := ossa.Call(addCallee, ossa.ConstLiteral(ossa.Int64Const(2)), ossa.Argument())
+		if _, ok := r.FoldCall(m, notConst); ok {
+			t.Errorf("call with non-constant argument was folded")
+		}
+		noFold := ossa.Call(memcpySym, ossa.ConstLiteral(ossa.Int64Const(2)))
+		if _, ok := r.FoldCall(m, noFold); ok {
+			t.Errorf("call to intrinsic without Fold was folded")
+		}
+	})
+
+	t.Run("CallCost", func(t *testing.T) {
+		model := &OpCostModel{
+			Weights:  map[ossa.Op]float64{ossa.OpCall: 2},
+			CallCost: r.CallCost(m),
+		}
+		if got, want := model.ValueCost(ossa.Call(memcpySym)), 12.0; got != want {
+			t.Errorf("wrong cost for memcpy call %v; want %v", got, want)
+		}
+		if got, want := model.ValueCost(ossa.Call(otherSym)), 2.0; got != want {
+			t.Errorf("wrong cost for other call %v; want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// LivenessTable is a map from each basic block to the values that are live
+// on entry to and exit from that block. A LivenessTable can be constructed
+// by calling FindLiveness.
+type LivenessTable map[*ossa.BasicBlock]BlockLiveness
+
+// BlockLiveness describes the values that are live at the boundaries of a
+// single basic block.
+//
+// A value is live at a particular point if it is defined on some path to
+// that point and used on some path from it without being redefined. Since
+// the analysis is of SSA form, a value can be redefined only by a Phi node
+// in a loop.
+type BlockLiveness struct {
+	// In is the set of values that are live on entry to the block. This
+	// does not include the results of the block's own Phi nodes, because
+	// they are defined at the start of the block.
+	In ossa.ValueSet
+
+	// Out is the set of values that are live on exit from the block. This
+	// includes any values that the Phi nodes of the block's successors
+	// select when control arrives from this block.
+	Out ossa.ValueSet
+}
+
+// FindLiveness calculates the values that are live at the start and end of
+// each block in the given function that is reachable from its entry block.
+//
+// Only the function's parameters and the instructions in its blocks are
+// considered to be values for the purposes of this analysis. Other operands,
+// such as literals and symbols, are not defined by the function and so are
+// never considered live. Parameters are defined at the start of the entry
+// block, and so are never live on entry to it.
+//
+// A Phi node's candidate is considered to be used at the end of its
+// corresponding predecessor, rather than at the start of the Phi node's own
+// block, and so it is live on exit from that predecessor but not
+// necessarily live on entry to the Phi node's block.
+func FindLiveness(f *ossa.Function) LivenessTable {
+	entry := f.Entry()
+	preds := FindPredecessors(entry)
+	reachable := func(block *ossa.BasicBlock) bool {
+		_, ok := preds[block]
+		return ok || block == entry
+	}
+
+	var blocks []*ossa.BasicBlock
+	index := NewValueIndex()
+	for _, param := range f.Params {
+		index.Add(param)
+	}
+	for _, block := range f.AppendBlocks(nil) {
+		if !reachable(block) {
+			continue
+		}
+		blocks = append(blocks, block)
+		for _, v := range block.Instructions {
+			index.Add(v)
+		}
+	}
+
+	size := index.Len()
+	gen := make(map[*ossa.BasicBlock]BitSet, len(blocks))
+	kill := make(map[*ossa.BasicBlock]BitSet, len(blocks))
+	for _, block := range blocks {
+		gen[block] = NewBitSet(size)
+		kill[block] = NewBitSet(size)
+	}
+
+	// A value is used "upward-exposed" if it's used before any definition
+	// in the same block, in which case it's live on entry to the block.
+	use := func(block *ossa.BasicBlock, v *ossa.Value) {
+		if v == nil {
+			return
+		}
+		if i, ok := index.Index(v); ok && !kill[block].Has(i) {
+			gen[block].Add(i)
+		}
+	}
+	define := func(block *ossa.BasicBlock, v *ossa.Value) {
+		i, _ := index.Index(v)
+		kill[block].Add(i)
+	}
+
+	for _, param := range f.Params {
+		define(entry, param)
+	}
+	for _, block := range blocks {
+		for _, v := range block.Instructions {
+			if v.Op() != ossa.OpPhi {
+				for i := 0; i < v.NumArgs(); i++ {
+					use(block, v.Arg(i))
+				}
+			}
+			define(block, v)
+		}
+		if t := block.Terminator; t != nil {
+			for i := 0; i < t.NumArgs(); i++ {
+				use(block, t.Arg(i).Value)
+			}
+		}
+	}
+
+	// Phi candidates are used at the end of their predecessors, so we can
+	// handle them only once we know all of the definitions in those blocks.
+	phiUses := make(map[*ossa.BasicBlock]BitSet)
+	for _, block := range blocks {
+		for _, v := range block.Instructions {
+			if v.Op() != ossa.OpPhi {
+				continue
+			}
+			for i := 0; i < v.NumArgs(); i++ {
+				c := v.PhiCandidate(i)
+				if !reachable(c.Block) || c.Value == nil {
+					continue
+				}
+				idx, ok := index.Index(c.Value)
+				if !ok {
+					continue
+				}
+				use(c.Block, c.Value)
+				if phiUses[c.Block] == nil {
+					phiUses[c.Block] = NewBitSet(size)
+				}
+				phiUses[c.Block].Add(idx)
+			}
+		}
+	}
+
+	result := SolveGenKill(entry, preds, &GenKillProblem{
+		Direction: Backward,
+		Meet:      Union,
+		Size:      size,
+		Gen:       gen,
+		Kill:      kill,
+	})
+
+	ret := make(LivenessTable, len(blocks))
+	for _, block := range blocks {
+		in := make(ossa.ValueSet)
+		out := make(ossa.ValueSet)
+		index.AddMembersTo(result.In[block], in)
+		index.AddMembersTo(result.Out[block], out)
+		if uses, ok := phiUses[block]; ok {
+			index.AddMembersTo(uses, out)
+		}
+		ret[block] = BlockLiveness{
+			In:  in,
+			Out: out,
+		}
+	}
+	return ret
+}
@@ -0,0 +1,84 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindLiveness(t *testing.T) {
+	f := ossa.NewFunction()
+	p := f.AddParam()
+	callee := ossa.GlobalSym()
+
+	b := ossa.NewFunctionBuilder(f)
+	entry := f.Entry()
+	header := b.NewBlock()
+	body := b.NewBlock()
+	exit := b.NewBlock()
+	unreachable := b.NewBlock()
+
+	x := b.Call(callee, p)
+	b.Jump(header)
+
+	b.SetBlock(header)
+	i := b.Phi(ossa.BasicBlockValue{Block: entry, Value: x})
+	b.Branch(i, body, exit)
+
+	b.SetBlock(body)
+	y := b.Call(callee, i)
+	b.Jump(header)
+	i.AddPhiCandidate(ossa.BasicBlockValue{Block: body, Value: y})
+
+	b.SetBlock(exit)
+	b.Return(p)
+
+	b.SetBlock(unreachable)
+	b.Return(x)
+
+	names := map[*ossa.Value]string{
+		p: "p",
+		x: "x",
+		i: "i",
+		y: "y",
+	}
+	blockNames := map[*ossa.BasicBlock]string{
+		entry:  "entry",
+		header: "header",
+		body:   "body",
+		exit:   "exit",
+	}
+	live := FindLiveness(f)
+
+	type sets struct {
+		In, Out []*ossa.Value
+	}
+	want := map[*ossa.BasicBlock]sets{
+		entry:  {In: nil, Out: []*ossa.Value{p, x}},
+		header: {In: []*ossa.Value{p}, Out: []*ossa.Value{p, i}},
+		body:   {In: []*ossa.Value{p, i}, Out: []*ossa.Value{p, y}},
+		exit:   {In: []*ossa.Value{p}, Out: nil},
+	}
+	check := func(block *ossa.BasicBlock, which string, got ossa.ValueSet, want []*ossa.Value) {
+		if len(got) != len(want) {
+			t.Errorf("%s has %d values live %s; want %d", blockNames[block], len(got), which, len(want))
+		}
+		for _, v := range want {
+			if !got.Has(v) {
+				t.Errorf("%s should be live %s %s", names[v], which, blockNames[block])
+			}
+		}
+	}
+	for block, want := range want {
+		got, ok := live[block]
+		if !ok {
+			t.Errorf("no liveness for %s", blockNames[block])
+			continue
+		}
+		check(block, "into", got.In, want.In)
+		check(block, "out of", got.Out, want.Out)
+	}
+	if _, ok := live[unreachable]; ok {
+		t.Errorf("unreachable block should not be in the table")
+	}
+}
@@ -0,0 +1,173 @@
+package oana
+
+import (
+	"sort"
+
+	"github.com/alamatic/ossa"
+)
+
+// Edge is an edge in a control flow graph, from a block to one of its
+// successors.
+type Edge struct {
+	From, To *ossa.BasicBlock
+}
+
+// Loop is a loop in a control flow graph, as described by LoopInfo. Unlike
+// NaturalLoop, a Loop represents all of the natural loops that share a
+// particular head.
+type Loop struct {
+	// Head is the block that all of the loop's back edges lead to, which
+	// dominates every block in the loop.
+	Head *ossa.BasicBlock
+
+	// Tails is the source of each of the loop's back edges, in reverse
+	// postorder.
+	Tails []*ossa.BasicBlock
+
+	// Blocks is all of the blocks in the loop, including those in any
+	// nested loops, in reverse postorder. Body is the same blocks as a set.
+	Blocks []*ossa.BasicBlock
+	Body   ossa.BasicBlockSet
+
+	// Parent is the innermost loop that contains this one, or nil if this
+	// is an outermost loop. Children is the loops whose parent is this one.
+	Parent   *Loop
+	Children []*Loop
+
+	// Depth is the number of loops that contain the head of this loop,
+	// including this loop itself, so it is 1 for an outermost loop.
+	Depth int
+}
+
+// LoopInfo describes the loops in a control flow graph and how they are
+// nested. A LoopInfo can be constructed by calling FindLoopInfo.
+type LoopInfo struct {
+	// Loops is all of the loops in the graph, ordered so that each loop
+	// appears before any loops nested inside it.
+	Loops []*Loop
+
+	innermost map[*ossa.BasicBlock]*Loop
+}
+
+// FindLoopInfo finds the loops in the control flow graph reachable from the
+// given start block, by merging together the natural loops that share a
+// head and then arranging them into a tree by which loops contain the heads
+// of others.
+//
+// The caller must provide the result of calling FindPredecessors with the
+// same start block, without any modification to the graph in the mean time,
+// or the result is undefined.
+func FindLoopInfo(start *ossa.BasicBlock, preds PredecessorsTable) *LoopInfo {
+	rpo := ossa.AppendBlocksRPO(start, nil)
+	order := make(map[*ossa.BasicBlock]int, len(rpo))
+	for i, block := range rpo {
+		order[block] = i
+	}
+
+	doms := FindDominators(start, preds)
+	loops := make(map[*ossa.BasicBlock]*Loop)
+	ret := &LoopInfo{
+		innermost: make(map[*ossa.BasicBlock]*Loop),
+	}
+	for _, natural := range FindNaturalLoops(doms, nil) {
+		body := natural.FindBody(preds)
+		loop, exists := loops[natural.Head]
+		if !exists {
+			loop = &Loop{
+				Head: natural.Head,
+				Body: body,
+			}
+			loops[natural.Head] = loop
+			ret.Loops = append(ret.Loops, loop)
+		} else {
+			for block := range body {
+				loop.Body.Add(block)
+			}
+		}
+		loop.Tails = append(loop.Tails, natural.Tail)
+	}
+
+	// The head of a loop precedes all of the other blocks in the loop in
+	// reverse postorder, so sorting by head puts outer loops first.
+	sort.Slice(ret.Loops, func(i, j int) bool {
+		return order[ret.Loops[i].Head] < order[ret.Loops[j].Head]
+	})
+	for _, loop := range ret.Loops {
+		sort.Slice(loop.Tails, func(i, j int) bool {
+			return order[loop.Tails[i]] < order[loop.Tails[j]]
+		})
+		for _, block := range rpo {
+			if loop.Body.Has(block) {
+				loop.Blocks = append(loop.Blocks, block)
+			}
+		}
+
+		// Because outer loops come first, the innermost loop already
+		// recorded for our head, if any, is our parent.
+		if parent := ret.innermost[loop.Head]; parent != nil {
+			loop.Parent = parent
+			parent.Children = append(parent.Children, loop)
+		}
+		loop.Depth = 1
+		if loop.Parent != nil {
+			loop.Depth = loop.Parent.Depth + 1
+		}
+		for block := range loop.Body {
+			ret.innermost[block] = loop
+		}
+	}
+	return ret
+}
+
+// Loop returns the innermost loop that contains the given block, or nil if
+// the block is not in any loop.
+func (li *LoopInfo) Loop(block *ossa.BasicBlock) *Loop {
+	return li.innermost[block]
+}
+
+// Depth returns the number of loops that contain the given block, which is
+// zero if the block is not in any loop.
+func (li *LoopInfo) Depth(block *ossa.BasicBlock) int {
+	if loop := li.innermost[block]; loop != nil {
+		return loop.Depth
+	}
+	return 0
+}
+
+// Contains returns true if the given block is in the receiving loop,
+// including in any loops nested inside it.
+func (l *Loop) Contains(block *ossa.BasicBlock) bool {
+	return l.Body.Has(block)
+}
+
+// AppendExitingEdges appends to the given slice each edge from a block in
+// the receiving loop to a block outside of it, and returns the new slice.
+// The edges are ordered by their source blocks in reverse postorder, and
+// then in the order of the successors of each source block.
+func (l *Loop) AppendExitingEdges(to []Edge) []Edge {
+	var succs []*ossa.BasicBlock
+	for _, block := range l.Blocks {
+		succs = block.Terminator.AppendSuccessors(succs[:0])
+		for _, succ := range succs {
+			if !l.Body.Has(succ) {
+				to = append(to, Edge{From: block, To: succ})
+			}
+		}
+	}
+	return to
+}
+
+// AppendExits appends to the given slice each block outside of the
+// receiving loop that is a successor of a block inside it, in the order they
+// are first reached by the edges from AppendExitingEdges, and returns the
+// new slice.
+func (l *Loop) AppendExits(to []*ossa.BasicBlock) []*ossa.BasicBlock {
+	seen := make(ossa.BasicBlockSet)
+	for _, edge := range l.AppendExitingEdges(nil) {
+		if !seen.Has(edge.To) {
+			seen.Add(edge.To)
+			to = append(to, edge.To)
+		}
+	}
+	return to
+}
@@ -0,0 +1,106 @@
+package oana
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindLoopInfo(t *testing.T) {
+	entry := &ossa.BasicBlock{}
+	outerHead := &ossa.BasicBlock{}
+	innerHead := &ossa.BasicBlock{}
+	latch := &ossa.BasicBlock{}
+	cont := &ossa.BasicBlock{}
+	early := &ossa.BasicBlock{}
+	exit := &ossa.BasicBlock{}
+	cond := ossa.AuxLiteral(nil)
+
+	entry.Terminator = ossa.Jump(outerHead)
+	outerHead.Terminator = ossa.Branch(cond, innerHead, exit)
+	innerHead.Terminator = ossa.Branch(cond, innerHead, latch)
+	latch.Terminator = ossa.Branch(cond, outerHead, cont)
+	cont.Terminator = ossa.Branch(cond, outerHead, early)
+	early.Terminator = ossa.Return()
+	exit.Terminator = ossa.Return()
+
+	names := map[*ossa.BasicBlock]string{
+		entry:     "entry",
+		outerHead: "outerHead",
+		innerHead: "innerHead",
+		latch:     "latch",
+		cont:      "cont",
+		early:     "early",
+		exit:      "exit",
+	}
+	nameAll := func(blocks []*ossa.BasicBlock) []string {
+		var ret []string
+		for _, block := range blocks {
+			ret = append(ret, names[block])
+		}
+		return ret
+	}
+
+	info := FindLoopInfo(entry, FindPredecessors(entry))
+	if got, want := len(info.Loops), 2; got != want {
+		t.Fatalf("wrong number of loops %d; want %d", got, want)
+	}
+	outer, inner := info.Loops[0], info.Loops[1]
+
+	if got, want := names[outer.Head], "outerHead"; got != want {
+		t.Errorf("outer loop has wrong head %q; want %q", got, want)
+	}
+	if got, want := nameAll(outer.Tails), []string{"latch", "cont"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outer loop has wrong tails %q; want %q", got, want)
+	}
+	if got, want := nameAll(outer.Blocks), []string{"outerHead", "innerHead", "latch", "cont"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outer loop has wrong blocks %q; want %q", got, want)
+	}
+	if outer.Parent != nil || outer.Depth != 1 {
+		t.Errorf("outer loop has parent %p and depth %d; want no parent and depth 1", outer.Parent, outer.Depth)
+	}
+	if got, want := outer.Children, []*Loop{inner}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outer loop has wrong children %#v; want %#v", got, want)
+	}
+	if got, want := outer.AppendExitingEdges(nil), []Edge{{outerHead, exit}, {cont, early}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outer loop has wrong exiting edges %#v; want %#v", got, want)
+	}
+	if got, want := nameAll(outer.AppendExits(nil)), []string{"exit", "early"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outer loop has wrong exits %q; want %q", got, want)
+	}
+
+	if got, want := names[inner.Head], "innerHead"; got != want {
+		t.Errorf("inner loop has wrong head %q; want %q", got, want)
+	}
+	if got, want := nameAll(inner.Blocks), []string{"innerHead"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("inner loop has wrong blocks %q; want %q", got, want)
+	}
+	if inner.Parent != outer || inner.Depth != 2 {
+		t.Errorf("inner loop has wrong parent or depth %d; want outer loop and depth 2", inner.Depth)
+	}
+	if got, want := nameAll(inner.AppendExits(nil)), []string{"latch"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("inner loop has wrong exits %q; want %q", got, want)
+	}
+
+	for block, want := range map[*ossa.BasicBlock]*Loop{
+		entry:     nil,
+		outerHead: outer,
+		innerHead: inner,
+		latch:     outer,
+		exit:      nil,
+	} {
+		if got := info.Loop(block); got != want {
+			t.Errorf("%s is in the wrong loop", names[block])
+		}
+	}
+	for block, want := range map[*ossa.BasicBlock]int{
+		entry:     0,
+		innerHead: 2,
+		cont:      1,
+	} {
+		if got := info.Depth(block); got != want {
+			t.Errorf("%s has wrong depth %d; want %d", names[block], got, want)
+		}
+	}
+}
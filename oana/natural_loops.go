@@ -15,7 +15,8 @@ type NaturalLoop struct {
 
 // FindNaturalLoops uses the given dominators table to detect any natural
 // loops, appending each one found to the given slice "to" which
-// may be nil.
+// may be nil. Each back edge produces a separate loop, so loops may share a
+// head; FindLoopInfo instead merges such loops and describes their nesting.
 //
 // The caller must provide the result of calling FindDominators with some
 // start block, without any modification to the graph in the mean time, or
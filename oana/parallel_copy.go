@@ -0,0 +1,120 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// Copy represents a copy from one location to another. The meaning of a
+// location is decided by the caller: it might be a value, a register, a
+// stack slot, etc.
+type Copy[L comparable] struct {
+	Dst, Src L
+}
+
+// SequentializeCopies converts a parallel copy, in which all of the given
+// copies conceptually happen simultaneously, into an equivalent sequence of
+// copies that can be performed one at a time, and returns that sequence.
+//
+// Each destination must appear at most once in the given copies. A copy whose
+// source and destination are the same is redundant and is omitted from the
+// result.
+//
+// Where the copies form a cycle, such as when swapping two locations, the
+// cycle can be broken only by copying one of the locations to a temporary
+// location first. In that case, SequentializeCopies calls newTemp to obtain
+// a temporary location, which it will then use for all cycles. If there are
+// no cycles then newTemp is not called.
+//
+// This is the algorithm from "Revisiting Out-of-SSA Translation for
+// Correctness, Code Quality, and Efficiency" by Boissinot et al. It emits the
+// minimum number of copies: one per non-redundant copy given, plus one
+// additional copy for each cycle.
+func SequentializeCopies[L comparable](copies []Copy[L], newTemp func() L) []Copy[L] {
+	ret := make([]Copy[L], 0, len(copies))
+
+	// loc tracks where the original value of each source location currently
+	// lives, while pred is the source location for each destination.
+	loc := make(map[L]L, len(copies))
+	pred := make(map[L]L, len(copies))
+	var ready, todo []L
+	for _, c := range copies {
+		if c.Dst == c.Src {
+			continue
+		}
+		loc[c.Src] = c.Src
+		pred[c.Dst] = c.Src
+		todo = append(todo, c.Dst)
+	}
+	for _, c := range copies {
+		if c.Dst == c.Src {
+			continue
+		}
+		// A destination that is not also a source can be written
+		// immediately, because no other copy depends on its value.
+		if _, isSrc := loc[c.Dst]; !isSrc {
+			ready = append(ready, c.Dst)
+		}
+	}
+
+	var temp L
+	haveTemp := false
+	written := make(map[L]struct{}, len(copies))
+	for len(todo) > 0 {
+		for len(ready) > 0 {
+			b := ready[len(ready)-1]
+			ready = ready[:len(ready)-1]
+			a := pred[b]
+			c := loc[a]
+			ret = append(ret, Copy[L]{Dst: b, Src: c})
+			written[b] = struct{}{}
+			loc[a] = b
+			// If a still held its own original value then we've just saved
+			// that value into b, and so a is now free to be overwritten.
+			if _, aIsDst := pred[a]; a == c && aIsDst {
+				ready = append(ready, a)
+			}
+		}
+
+		b := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if _, done := written[b]; !done {
+			// The copy into b hasn't happened yet even though no other
+			// copies are ready, so b must be part of a cycle. We'll move b's value to a temporary location to break
+			// the cycle.
+			if !haveTemp {
+				temp = newTemp()
+				haveTemp = true
+			}
+			ret = append(ret, Copy[L]{Dst: temp, Src: b})
+			loc[b] = temp
+			ready = append(ready, b)
+		}
+	}
+
+	return ret
+}
+
+// FindPhiCopies returns the parallel copy that is implied by the Phi nodes at
+// the start of the given block when control arrives from the given predecessor
+// block. Each copy has a Phi node as its destination and the corresponding
+// candidate value as its source.
+//
+// Out-of-SSA translation can implement the Phi nodes by performing these
+// copies at the end of the predecessor block, after sequentializing them
+// using SequentializeCopies.
+func FindPhiCopies(block, pred *ossa.BasicBlock) []Copy[*ossa.Value] {
+	var ret []Copy[*ossa.Value]
+	for _, inst := range block.Instructions {
+		if inst.Op() != ossa.OpPhi {
+			break
+		}
+		for i := 0; i < inst.NumArgs(); i++ {
+			c := inst.PhiCandidate(i)
+			if c.Block == pred {
+				ret = append(ret, Copy[*ossa.Value]{Dst: inst, Src: c.Value})
+				break
+			}
+		}
+	}
+	return ret
+}
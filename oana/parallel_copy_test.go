@@ -0,0 +1,143 @@
+package oana
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestSequentializeCopies(t *testing.T) {
+	tests := map[string]struct {
+		copies    []Copy[string]
+		wantTemps int
+	}{
+		"empty": {
+			nil,
+			0,
+		},
+		"redundant": {
+			[]Copy[string]{{"a", "a"}},
+			0,
+		},
+		"chain": {
+			[]Copy[string]{{"b", "a"}, {"c", "b"}, {"d", "c"}},
+			0,
+		},
+		"fan out": {
+			[]Copy[string]{{"b", "a"}, {"c", "a"}, {"a", "d"}},
+			0,
+		},
+		"swap": {
+			[]Copy[string]{{"a", "b"}, {"b", "a"}},
+			1,
+		},
+		"rotate with tail": {
+			[]Copy[string]{{"a", "b"}, {"b", "c"}, {"c", "a"}, {"d", "a"}},
+			0,
+		},
+		"two cycles": {
+			[]Copy[string]{{"a", "b"}, {"b", "a"}, {"c", "d"}, {"d", "e"}, {"e", "c"}},
+			1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			temps := 0
+			seq := SequentializeCopies(test.copies, func() string {
+				temps++
+				return fmt.Sprintf("tmp%d", temps)
+			})
+
+			if temps != test.wantTemps {
+				t.Errorf("wrong number of temporaries %d; want %d", temps, test.wantTemps)
+			}
+
+			// We'll simulate both the parallel copy and the sequence on
+			// a state where each location initially holds its own name,
+			// and then compare the results.
+			want := make(map[string]string)
+			got := make(map[string]string)
+			for _, c := range test.copies {
+				want[c.Dst] = c.Dst
+				want[c.Src] = c.Src
+				got[c.Dst] = c.Dst
+				got[c.Src] = c.Src
+			}
+			for _, c := range test.copies {
+				want[c.Dst] = c.Src
+			}
+			for _, c := range seq {
+				got[c.Dst] = got[c.Src]
+			}
+			for loc, wantVal := range want {
+				if gotVal := got[loc]; gotVal != wantVal {
+					t.Errorf("location %q has value %q; want %q\nsequence: %v", loc, gotVal, wantVal, seq)
+				}
+			}
+
+			nonRedundant := 0
+			for _, c := range test.copies {
+				if c.Dst != c.Src {
+					nonRedundant++
+				}
+			}
+			cycles := 0
+			for _, c := range seq {
+				if c.Dst == "tmp1" {
+					cycles++
+				}
+			}
+			if got, want := len(seq), nonRedundant+cycles; got != want {
+				t.Errorf("wrong number of copies %d; want %d\nsequence: %v", got, want, seq)
+			}
+		})
+	}
+}
+
+func TestFindPhiCopies(t *testing.T) {
+	entry := &ossa.BasicBlock{}
+	loop := &ossa.BasicBlock{}
+	a := ossa.AuxLiteral("a")
+	b := ossa.AuxLiteral("b")
+
+	// The two Phi nodes swap their values on each iteration.
+	phiA := ossa.Phi(ossa.BasicBlockValue{Block: entry, Value: a})
+	phiB := ossa.Phi(ossa.BasicBlockValue{Block: entry, Value: b})
+	phiA.AddPhiCandidate(ossa.BasicBlockValue{Block: loop, Value: phiB})
+	phiB.AddPhiCandidate(ossa.BasicBlockValue{Block: loop, Value: phiA})
+	loop.Instructions = []*ossa.Value{phiA, phiB}
+
+	got := FindPhiCopies(loop, loop)
+	want := []Copy[*ossa.Value]{
+		{Dst: phiA, Src: phiB},
+		{Dst: phiB, Src: phiA},
+	}
+	if !equalValueCopies(got, want) {
+		t.Errorf("wrong copies from loop")
+	}
+
+	got = FindPhiCopies(loop, entry)
+	want = []Copy[*ossa.Value]{
+		{Dst: phiA, Src: a},
+		{Dst: phiB, Src: b},
+	}
+	if !equalValueCopies(got, want) {
+		t.Errorf("wrong copies from entry")
+	}
+}
+
+// equalValueCopies compares copies by the identity of their values, since
+// cmp.Equal cannot inspect the unexported fields of ossa.Value.
+func equalValueCopies(a, b []Copy[*ossa.Value]) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package oana
+
+import (
+	"sort"
+
+	"github.com/alamatic/ossa"
+)
+
+// PCRange describes a range of program counters in compiled code that a
+// backend generated from a particular block, and possibly from a particular
+// instruction in that block.
+type PCRange struct {
+	// Start and End are the bounds of the range. Start is inclusive and End
+	// is exclusive.
+	Start, End uint64
+
+	// Block is the block that the code was generated from.
+	Block *ossa.BasicBlock
+
+	// Value is the instruction that the code was generated from, or nil if
+	// the code was generated for the block's terminator or cannot be
+	// attributed to any single instruction.
+	Value *ossa.Value
+}
+
+// PCTable maps program counters in compiled code back to the blocks and
+// instructions they were generated from. A PCTable can be constructed by
+// calling BuildPCTable with ranges provided by a backend.
+type PCTable []PCRange
+
+// BuildPCTable constructs a PCTable from the given ranges, which may be given
+// in any order but must not overlap.
+func BuildPCTable(ranges []PCRange) PCTable {
+	ret := append(PCTable(nil), ranges...)
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Start < ret[j].Start
+	})
+	return ret
+}
+
+// Lookup returns the range containing the given program counter, or false if
+// there is no such range.
+func (t PCTable) Lookup(pc uint64) (PCRange, bool) {
+	i := sort.Search(len(t), func(i int) bool {
+		return t[i].End > pc
+	})
+	if i == len(t) || t[i].Start > pc {
+		return PCRange{}, false
+	}
+	return t[i], true
+}
+
+// Profile is the result of attributing the samples taken by a sampling
+// profiler to the blocks and instructions they were taken in. A Profile can
+// be constructed by calling AttributeSamples.
+type Profile struct {
+	// Blocks is the number of samples taken in each block, including those
+	// attributed to its instructions.
+	Blocks map[*ossa.BasicBlock]uint64
+
+	// Values is the number of samples taken in each instruction.
+	Values map[*ossa.Value]uint64
+
+	// Unattributed is the number of samples whose program counters were not
+	// in any range of the table.
+	Unattributed uint64
+}
+
+// AttributeSamples uses the given table to find the block and instruction
+// that each of the given sampled program counters belongs to, and counts the
+// samples taken in each.
+//
+// Sample counts measure how much time was spent in each block, rather than
+// how often it ran, and so a block that is expensive to run collects more
+// samples than a cheaper block that runs equally often. ExecutionCounts can
+// estimate the latter.
+func AttributeSamples(table PCTable, pcs []uint64) *Profile {
+	ret := &Profile{
+		Blocks: make(map[*ossa.BasicBlock]uint64),
+		Values: make(map[*ossa.Value]uint64),
+	}
+	for _, pc := range pcs {
+		r, ok := table.Lookup(pc)
+		if !ok {
+			ret.Unattributed++
+			continue
+		}
+		ret.Blocks[r.Block]++
+		if r.Value != nil {
+			ret.Values[r.Value]++
+		}
+	}
+	return ret
+}
+
+// ExecutionCounts estimates how many times each block ran while the
+// receiving profile was being collected, relative to the other blocks, by
+// dividing its number of samples by its cost in the given table. Blocks
+// with no cost are assumed to cost the same as a block of cost one.
+//
+// The result is meaningful only for blocks that collected enough samples;
+// blocks that ran rarely may collect none at all.
+func (p *Profile) ExecutionCounts(costs CostTable) map[*ossa.BasicBlock]float64 {
+	ret := make(map[*ossa.BasicBlock]float64, len(p.Blocks))
+	for block, samples := range p.Blocks {
+		cost := costs[block]
+		if cost <= 0 {
+			cost = 1
+		}
+		ret[block] = float64(samples) / cost
+	}
+	return ret
+}
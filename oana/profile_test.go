@@ -0,0 +1,72 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestAttributeSamples(t *testing.T) {
+	f := ossa.NewFunction()
+	entry := f.Entry()
+	loop := f.NewBlock()
+	exit := f.NewBlock()
+
+	callee := ossa.GlobalSym()
+	cheap := ossa.Call(callee)
+	expensive := ossa.Call(callee)
+	entry.Instructions = []*ossa.Value{cheap}
+	entry.Terminator = ossa.Jump(loop)
+	loop.Instructions = []*ossa.Value{expensive}
+	loop.Terminator = ossa.Branch(expensive, loop, exit)
+	exit.Terminator = ossa.Return()
+
+	// The ranges are given out of order, and leave gaps at 0x18 and from
+	// 0x30 onwards.
+	table := BuildPCTable([]PCRange{
+		{Start: 0x20, End: 0x28, Block: loop, Value: expensive},
+		{Start: 0x00, End: 0x10, Block: entry, Value: cheap},
+		{Start: 0x28, End: 0x2c, Block: loop},
+		{Start: 0x10, End: 0x18, Block: entry},
+		{Start: 0x2c, End: 0x30, Block: exit},
+	})
+	if r, ok := table.Lookup(0x24); !ok || r.Value != expensive {
+		t.Errorf("wrong range for 0x24: %#v, %t", r, ok)
+	}
+	if r, ok := table.Lookup(0x18); ok {
+		t.Errorf("unexpected range for 0x18: %#v", r)
+	}
+
+	pcs := []uint64{
+		0x00, 0x0c, 0x14,
+		0x20, 0x24, 0x24, 0x20, 0x28, 0x2a, 0x24, 0x20, 0x20, 0x24,
+		0x2c,
+		0x18, 0x40,
+	}
+	got := AttributeSamples(table, pcs)
+	wantBlocks := map[*ossa.BasicBlock]uint64{entry: 3, loop: 10, exit: 1}
+	wantValues := map[*ossa.Value]uint64{cheap: 2, expensive: 8}
+	for block, want := range wantBlocks {
+		if got := got.Blocks[block]; got != want {
+			t.Errorf("wrong number of samples %d in block; want %d", got, want)
+		}
+	}
+	for v, want := range wantValues {
+		if got := got.Values[v]; got != want {
+			t.Errorf("wrong number of samples %d in value; want %d", got, want)
+		}
+	}
+	if got, want := got.Unattributed, uint64(2); got != want {
+		t.Errorf("wrong number of unattributed samples %d; want %d", got, want)
+	}
+
+	// The loop block costs five times as much as the others, so its ten
+	// samples mean that it ran only twice as often as the entry block.
+	counts := got.ExecutionCounts(CostTable{entry: 1, loop: 5})
+	wantCounts := map[*ossa.BasicBlock]float64{entry: 3, loop: 2, exit: 1}
+	for block, want := range wantCounts {
+		if got := counts[block]; got != want {
+			t.Errorf("wrong execution count %g for block; want %g", got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// ReachingStoresTable is a map from each Load instruction to the set of
+// instructions that may have written the memory it reads. A
+// ReachingStoresTable can be constructed by calling FindReachingStores.
+//
+// The set for each load contains the Store instructions that may reach it,
+// along with any Call instructions that may reach it, since a call may
+// write to any memory. An empty set means that the memory is not written
+// on any path to the load.
+type ReachingStoresTable map[*ossa.Value]ossa.ValueSet
+
+// FindReachingStores calculates which Store and Call instructions may reach
+// each Load instruction in the graph entered at the given start block.
+//
+// A store reaches a load if there is a path from the store to the load along
+// which the stored memory is not definitely overwritten. Memory references
+// are compared using the given alias analysis, or BasicAliasAnalysis if it
+// is nil: a store kills earlier stores only if their references must alias,
+// and a store does not reach a load if their references do not alias. Calls
+// never kill earlier stores, because they might not write to memory at all.
+//
+// The caller must provide the result of calling FindPredecessors with the
+// same start block, without any modification to the graph in the mean time,
+// or the result is undefined.
+func FindReachingStores(start *ossa.BasicBlock, preds PredecessorsTable, aa AliasAnalysis) ReachingStoresTable {
+	if aa == nil {
+		aa = BasicAliasAnalysis{}
+	}
+	blocks := ossa.AppendBlocksRPO(start, nil)
+
+	index := NewValueIndex()
+	storesByRef := make(map[*ossa.Value][]int)
+	killsByRef := make(map[*ossa.Value][]int)
+	for _, block := range blocks {
+		for _, inst := range block.Instructions {
+			switch inst.Op() {
+			case ossa.OpStore:
+				i := index.Add(inst)
+				ref := inst.Arg(1)
+				storesByRef[ref] = append(storesByRef[ref], i)
+			default:
+				if inst.Op().WritesMemory() {
+					index.Add(inst)
+				}
+			}
+		}
+	}
+	size := index.Len()
+
+	// A store kills the earlier stores to any reference that must alias
+	// its own, which always includes stores to the same reference.
+	var refs []*ossa.Value
+	for _, block := range blocks {
+		for _, inst := range block.Instructions {
+			if inst.Op() != ossa.OpStore {
+				continue
+			}
+			if ref := inst.Arg(1); len(killsByRef[ref]) == 0 {
+				refs = append(refs, ref)
+				killsByRef[ref] = append([]int(nil), storesByRef[ref]...)
+			}
+		}
+	}
+	for _, a := range refs {
+		for _, b := range refs {
+			if a != b && aa.MayAlias(a, b) == MustAlias {
+				killsByRef[a] = append(killsByRef[a], storesByRef[b]...)
+			}
+		}
+	}
+
+	// transfer updates the given set of reaching instructions to reflect
+	// the effect of the given instruction.
+	transfer := func(s BitSet, inst *ossa.Value) {
+		i, ok := index.Index(inst)
+		if !ok {
+			return
+		}
+		if inst.Op() == ossa.OpStore {
+			for _, other := range killsByRef[inst.Arg(1)] {
+				s.Remove(other)
+			}
+		}
+		s.Add(i)
+	}
+
+	gen := make(map[*ossa.BasicBlock]BitSet, len(blocks))
+	kill := make(map[*ossa.BasicBlock]BitSet, len(blocks))
+	for _, block := range blocks {
+		g := NewBitSet(size)
+		k := NewBitSet(size)
+		for _, inst := range block.Instructions {
+			transfer(g, inst)
+			if inst.Op() == ossa.OpStore {
+				for _, other := range killsByRef[inst.Arg(1)] {
+					k.Add(other)
+				}
+			}
+		}
+		// A store that is generated by the block must not also be killed
+		// by it, or it would be removed from the block's result.
+		k.DifferenceWith(g)
+		gen[block] = g
+		kill[block] = k
+	}
+
+	result := SolveGenKill(start, preds, &GenKillProblem{
+		Direction: Forward,
+		Meet:      Union,
+		Size:      size,
+		Gen:       gen,
+		Kill:      kill,
+	})
+
+	ret := make(ReachingStoresTable)
+	for _, block := range blocks {
+		current := result.In[block].Copy()
+		for _, inst := range block.Instructions {
+			if inst.Op() != ossa.OpLoad {
+				transfer(current, inst)
+				continue
+			}
+			ref := inst.Arg(0)
+			reaching := make(ossa.ValueSet)
+			for _, i := range current.AppendMembers(nil) {
+				def := index.Value(i)
+				if def.Op() == ossa.OpStore && aa.MayAlias(def.Arg(1), ref) == NoAlias {
+					continue
+				}
+				reaching.Add(def)
+			}
+			ret[inst] = reaching
+		}
+	}
+	return ret
+}
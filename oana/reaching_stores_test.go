@@ -0,0 +1,125 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindReachingStores(t *testing.T) {
+	f := ossa.NewFunction()
+	ptr := f.AddParam()
+	b := ossa.NewFunctionBuilder(f)
+	x := b.LocalSym()
+	y := b.LocalSym()
+	one := b.AuxLiteral(1)
+	two := b.AuxLiteral(2)
+	callee := b.GlobalSym()
+
+	entry := f.Entry()
+	then := b.NewBlock()
+	join := b.NewBlock()
+
+	s1 := b.Store(one, x)
+	call := b.Call(callee)
+	b.Branch(call, then, join)
+
+	b.SetBlock(then)
+	b.Store(one, x) // overwritten by the next store
+	s2 := b.Store(two, x)
+	s3 := b.Store(two, y)
+	s4 := b.Store(two, ptr)
+	b.Jump(join)
+
+	b.SetBlock(join)
+	loadX := b.Load(x)
+	loadY := b.Load(y)
+	s5 := b.Store(one, x)
+	loadX2 := b.Load(x)
+	loadPtr := b.Load(ptr)
+	b.Return(loadX)
+
+	names := map[*ossa.Value]string{
+		s1:      "s1",
+		s2:      "s2",
+		s3:      "s3",
+		s4:      "s4",
+		s5:      "s5",
+		call:    "call",
+		loadX:   "loadX",
+		loadY:   "loadY",
+		loadX2:  "loadX2",
+		loadPtr: "loadPtr",
+	}
+
+	preds := FindPredecessors(entry)
+	symbolsOnly := aliasFunc(func(a, b *ossa.Value) AliasResult {
+		if a != b && isSym(a) && isSym(b) {
+			return NoAlias
+		}
+		return MayAlias
+	})
+	ptrIsX := aliasFunc(func(a, b *ossa.Value) AliasResult {
+		if (a == x && b == ptr) || (a == ptr && b == x) {
+			return MustAlias
+		}
+		return BasicAliasAnalysis{}.MayAlias(a, b)
+	})
+	tests := map[string]struct {
+		aa   AliasAnalysis
+		want map[*ossa.Value][]*ossa.Value
+	}{
+		"symbols only": {
+			symbolsOnly,
+			map[*ossa.Value][]*ossa.Value{
+				loadX:   {s1, s2, s4, call},
+				loadY:   {s3, s4, call},
+				loadX2:  {s5, s4, call},
+				loadPtr: {s3, s4, s5, call},
+			},
+		},
+		"basic": {
+			nil,
+			map[*ossa.Value][]*ossa.Value{
+				loadX:   {s1, s2, call},
+				loadY:   {s3, call},
+				loadX2:  {s5, call},
+				loadPtr: {s4, call},
+			},
+		},
+		"must alias": {
+			ptrIsX,
+			map[*ossa.Value][]*ossa.Value{
+				loadX:   {s1, s4, call},
+				loadY:   {s3, call},
+				loadX2:  {s5, call},
+				loadPtr: {s5, call},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := FindReachingStores(entry, preds, test.aa)
+			if len(got) != len(test.want) {
+				t.Errorf("wrong number of loads %d; want %d", len(got), len(test.want))
+			}
+			for load, want := range test.want {
+				reaching := got[load]
+				if len(reaching) != len(want) {
+					t.Errorf("%s has %d reaching stores; want %d", names[load], len(reaching), len(want))
+				}
+				for _, store := range want {
+					if !reaching.Has(store) {
+						t.Errorf("%s should reach %s", names[store], names[load])
+					}
+				}
+			}
+		})
+	}
+}
+
+type aliasFunc func(a, b *ossa.Value) AliasResult
+
+func (f aliasFunc) MayAlias(a, b *ossa.Value) AliasResult {
+	return f(a, b)
+}
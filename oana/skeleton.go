@@ -0,0 +1,160 @@
+package oana
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/alamatic/ossa"
+)
+
+// SkeletonNode is a node in a Skeleton, representing a straight-line chain
+// of blocks in the control flow graph it was built from.
+type SkeletonNode struct {
+	// Blocks is the blocks represented by the node, in the order control
+	// passes through them. Only the first block can have predecessors
+	// outside of the node, and only the last block can have successors
+	// outside of it.
+	Blocks []*ossa.BasicBlock
+
+	// Calls is the Call instructions in the node's blocks, in order. The
+	// other instructions are not represented in the skeleton.
+	Calls []*ossa.Value
+
+	// Succs is the nodes that the last block of the node passes control to,
+	// without duplicates, in the order of the block's successors.
+	Succs []*SkeletonNode
+
+	// LoopHead is true if the first block of the node is the head of a
+	// loop, as described by LoopInfo.
+	LoopHead bool
+}
+
+// Branches returns true if the receiving node has more than one successor.
+func (n *SkeletonNode) Branches() bool {
+	return len(n.Succs) > 1
+}
+
+// Skeleton is a smaller view of a control flow graph that keeps only its
+// loop heads, branches and calls, for tools such as visualizers and reports
+// that must remain usable for functions with thousands of blocks. A Skeleton
+// can be constructed by calling BuildSkeleton.
+type Skeleton struct {
+	// Nodes is all of the nodes of the skeleton, in the reverse postorder of
+	// their first blocks, so the node for the start block is first.
+	Nodes []*SkeletonNode
+
+	nodes map[*ossa.BasicBlock]*SkeletonNode
+}
+
+// BuildSkeleton constructs the skeleton of the control flow graph reachable
+// from the given start block, by collapsing each straight-line chain of
+// blocks into a single node.
+//
+// A block joins the node of its predecessor if it is that predecessor's
+// only successor, the predecessor is its only predecessor, and it is
+// neither the start block nor the head of a loop. Each remaining block
+// begins a new node.
+//
+// The caller must provide the result of calling FindPredecessors with the
+// same start block, without any modification to the graph in the mean time,
+// or the result is undefined.
+func BuildSkeleton(start *ossa.BasicBlock, preds PredecessorsTable) *Skeleton {
+	loops := FindLoopInfo(start, preds)
+	isLoopHead := func(block *ossa.BasicBlock) bool {
+		loop := loops.Loop(block)
+		return loop != nil && loop.Head == block
+	}
+	uniqueSuccs := func(block *ossa.BasicBlock) []*ossa.BasicBlock {
+		if block.Terminator == nil {
+			return nil
+		}
+		var ret []*ossa.BasicBlock
+		seen := make(ossa.BasicBlockSet)
+		for _, succ := range block.Terminator.AppendSuccessors(nil) {
+			if !seen.Has(succ) {
+				seen.Add(succ)
+				ret = append(ret, succ)
+			}
+		}
+		return ret
+	}
+
+	ret := &Skeleton{
+		nodes: make(map[*ossa.BasicBlock]*SkeletonNode),
+	}
+	for _, block := range ossa.AppendBlocksRPO(start, nil) {
+		if ret.nodes[block] != nil {
+			continue
+		}
+		node := &SkeletonNode{LoopHead: isLoopHead(block)}
+		ret.Nodes = append(ret.Nodes, node)
+		for {
+			ret.nodes[block] = node
+			node.Blocks = append(node.Blocks, block)
+			for _, inst := range block.Instructions {
+				if inst.Op() == ossa.OpCall {
+					node.Calls = append(node.Calls, inst)
+				}
+			}
+			succs := uniqueSuccs(block)
+			if len(succs) != 1 {
+				break
+			}
+			next := succs[0]
+			if next == start || len(preds[next]) != 1 || isLoopHead(next) || ret.nodes[next] != nil {
+				break
+			}
+			block = next
+		}
+	}
+
+	for _, node := range ret.Nodes {
+		last := node.Blocks[len(node.Blocks)-1]
+		seen := make(map[*SkeletonNode]bool)
+		for _, succ := range uniqueSuccs(last) {
+			if succNode := ret.nodes[succ]; !seen[succNode] {
+				seen[succNode] = true
+				node.Succs = append(node.Succs, succNode)
+			}
+		}
+	}
+	return ret
+}
+
+// Node returns the node that represents the given block, or nil if the block
+// is not in the graph the receiver was built from.
+func (s *Skeleton) Node(block *ossa.BasicBlock) *SkeletonNode {
+	return s.nodes[block]
+}
+
+// WriteDOT writes the receiving skeleton to the given writer in the DOT
+// language used by Graphviz.
+//
+// Each node is labelled with the name of its first block, if it has one, and
+// with the number of blocks and calls it represents. Loop heads are drawn
+// with a double outline.
+func (s *Skeleton) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	index := make(map[*SkeletonNode]int, len(s.Nodes))
+	fmt.Fprintln(bw, "digraph {")
+	for i, node := range s.Nodes {
+		index[node] = i
+		label := fmt.Sprintf("%d blocks\n%d calls", len(node.Blocks), len(node.Calls))
+		if name := node.Blocks[0].Name; name != "" {
+			label = name + "\n" + label
+		}
+		fmt.Fprintf(bw, "\tn%d [label=%q", i, label)
+		if node.LoopHead {
+			fmt.Fprint(bw, ", peripheries=2")
+		}
+		fmt.Fprintln(bw, "];")
+	}
+	for _, node := range s.Nodes {
+		for _, succ := range node.Succs {
+			fmt.Fprintf(bw, "\tn%d -> n%d;\n", index[node], index[succ])
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
@@ -0,0 +1,110 @@
+package oana
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestBuildSkeleton(t *testing.T) {
+	f := ossa.NewFunction()
+	entry := f.Entry()
+	pre := f.NewBlock()
+	head := f.NewBlock()
+	body := f.NewBlock()
+	latch := f.NewBlock()
+	exit := f.NewBlock()
+	head.Name = "loop"
+
+	callee := ossa.GlobalSym()
+	cond := f.AddParam()
+	setup := ossa.Call(callee)
+	work := ossa.Call(callee)
+	entry.Instructions = []*ossa.Value{setup}
+	entry.Terminator = ossa.Jump(pre)
+	pre.Terminator = ossa.Jump(head)
+	head.Terminator = ossa.Branch(cond, body, exit)
+	body.Instructions = []*ossa.Value{ossa.Load(cond), work}
+	body.Terminator = ossa.Jump(latch)
+	latch.Terminator = ossa.Jump(head)
+	exit.Terminator = ossa.Return()
+
+	s := BuildSkeleton(entry, FindPredecessors(entry))
+	if got, want := len(s.Nodes), 4; got != want {
+		t.Fatalf("wrong number of nodes %d; want %d", got, want)
+	}
+	start, loop, inner, end := s.Node(entry), s.Node(head), s.Node(body), s.Node(exit)
+	if s.Nodes[0] != start {
+		t.Errorf("first node does not represent the start block")
+	}
+	if s.Node(pre) != start || s.Node(latch) != inner {
+		t.Errorf("straight-line chains were not collapsed")
+	}
+	if s.Node(ossa.NewBasicBlock()) != nil {
+		t.Errorf("unrelated block has a node")
+	}
+
+	tests := []struct {
+		name     string
+		node     *SkeletonNode
+		blocks   []*ossa.BasicBlock
+		calls    []*ossa.Value
+		succs    []*SkeletonNode
+		loopHead bool
+	}{
+		{"start", start, []*ossa.BasicBlock{entry, pre}, []*ossa.Value{setup}, []*SkeletonNode{loop}, false},
+		{"loop", loop, []*ossa.BasicBlock{head}, nil, []*SkeletonNode{inner, end}, true},
+		{"inner", inner, []*ossa.BasicBlock{body, latch}, []*ossa.Value{work}, []*SkeletonNode{loop}, false},
+		{"end", end, []*ossa.BasicBlock{exit}, nil, nil, false},
+	}
+	for _, test := range tests {
+		n := test.node
+		if !sameElems(n.Blocks, test.blocks) {
+			t.Errorf("%s node has wrong blocks", test.name)
+		}
+		if !sameElems(n.Calls, test.calls) {
+			t.Errorf("%s node has wrong calls", test.name)
+		}
+		if !sameElems(n.Succs, test.succs) {
+			t.Errorf("%s node has wrong successors", test.name)
+		}
+		if n.LoopHead != test.loopHead {
+			t.Errorf("%s node has LoopHead %t; want %t", test.name, n.LoopHead, test.loopHead)
+		}
+	}
+	if !loop.Branches() || start.Branches() {
+		t.Errorf("wrong result from Branches")
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteDOT(&buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	wantDOT := `digraph {
+	n0 [label="2 blocks\n1 calls"];
+	n1 [label="loop\n1 blocks\n0 calls", peripheries=2];
+	n2 [label="1 blocks\n0 calls"];
+	n3 [label="2 blocks\n1 calls"];
+	n0 -> n1;
+	n1 -> n3;
+	n1 -> n2;
+	n3 -> n1;
+}
+`
+	if got := buf.String(); got != wantDOT {
+		t.Errorf("wrong DOT output\ngot:\n%s\nwant:\n%s", got, wantDOT)
+	}
+}
+
+func sameElems[T comparable](got, want []T) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// SuspensionTable is a map from each basic block that ends with a Yield or
+// Await terminator to the values that are live across that suspension point.
+// A SuspensionTable can be constructed by calling FindSuspensions.
+type SuspensionTable map[*ossa.BasicBlock]ossa.ValueSet
+
+// FindSuspensions finds the values that are live across each suspension point
+// in the given function that is reachable from its entry block, which are
+// the values a coroutine runtime must preserve while the function is
+// suspended there.
+//
+// A value is live across a suspension point if it is live on exit from the
+// suspending block, as decided by FindLiveness. That includes any values
+// selected by the resume block's Phi nodes for the edge from the suspending
+// block, but not values such as an Await event that are used only by the
+// terminator itself. Parameters are included where they are live, so
+// runtimes that keep the parameters elsewhere should exclude them.
+func FindSuspensions(f *ossa.Function) SuspensionTable {
+	ret := make(SuspensionTable)
+	for block, live := range FindLiveness(f) {
+		if t := block.Terminator; t != nil && (t.Op() == ossa.OpYield || t.Op() == ossa.OpAwait) {
+			ret[block] = live.Out
+		}
+	}
+	return ret
+}
+
+// Values returns all of the values that are live across at least one
+// suspension point in the table, which is the set of values that a coroutine
+// frame shared between all of the suspension points must have room for.
+func (t SuspensionTable) Values() ossa.ValueSet {
+	ret := make(ossa.ValueSet)
+	for _, live := range t {
+		for v := range live {
+			ret.Add(v)
+		}
+	}
+	return ret
+}
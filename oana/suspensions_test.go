@@ -0,0 +1,71 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestFindSuspensions(t *testing.T) {
+	f := ossa.NewFunction()
+	p := f.AddParam()
+	callee := ossa.GlobalSym()
+
+	b := ossa.NewFunctionBuilder(f)
+	entry := f.Entry()
+	first := b.NewBlock()
+	second := b.NewBlock()
+	unreachable := b.NewBlock()
+
+	x := b.Call(callee)
+	ev := b.Call(callee)
+	b.Await(ev, first)
+
+	b.SetBlock(first)
+	y := b.Call(callee, x)
+	b.Yield(second)
+
+	b.SetBlock(second)
+	z := b.Phi(ossa.BasicBlockValue{Block: first, Value: y})
+	b.Return(b.Call(callee, z, p))
+
+	b.SetBlock(unreachable)
+	b.Yield(second)
+	z.AddPhiCandidate(ossa.BasicBlockValue{Block: unreachable, Value: x})
+
+	names := map[*ossa.Value]string{
+		p:  "p",
+		x:  "x",
+		ev: "ev",
+		y:  "y",
+	}
+	blockNames := map[*ossa.BasicBlock]string{
+		entry: "entry",
+		first: "first",
+	}
+	want := map[*ossa.BasicBlock][]*ossa.Value{
+		entry: {p, x},
+		first: {p, y},
+	}
+
+	got := FindSuspensions(f)
+	if len(got) != len(want) {
+		t.Errorf("wrong number of suspension points %d; want %d", len(got), len(want))
+	}
+	for block, want := range want {
+		live := got[block]
+		if len(live) != len(want) {
+			t.Errorf("%s has %d values live across it; want %d", blockNames[block], len(live), len(want))
+		}
+		for _, v := range want {
+			if !live.Has(v) {
+				t.Errorf("%s should be live across %s", names[v], blockNames[block])
+			}
+		}
+	}
+
+	all := got.Values()
+	if len(all) != 3 || !all.Has(p) || !all.Has(x) || !all.Has(y) {
+		t.Errorf("wrong values across all suspension points %v; want p, x and y", all)
+	}
+}
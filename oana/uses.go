@@ -0,0 +1,99 @@
+package oana
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// Use describes a single use of a value as an operand of an instruction or a
+// terminator.
+type Use struct {
+	// Block is the block containing the user.
+	Block *ossa.BasicBlock
+
+	// Value is the instruction using the value, or nil if the user is the
+	// block's terminator.
+	Value *ossa.Value
+
+	// Index is the index of the operand within the user, as would be passed
+	// to the Arg method of Value or Terminator. For Phi nodes this is the
+	// index of the candidate.
+	Index int
+}
+
+// Terminator returns the terminator using the value, or nil if the user is
+// an instruction.
+func (u Use) Terminator() *ossa.Terminator {
+	if u.Value != nil {
+		return nil
+	}
+	return u.Block.Terminator
+}
+
+// Replace changes the operand described by the receiver to the given new
+// value.
+func (u Use) Replace(v *ossa.Value) {
+	if u.Value != nil {
+		u.Value.SetArg(u.Index, v)
+		return
+	}
+	t := u.Block.Terminator
+	arg := t.Arg(u.Index)
+	arg.Value = v
+	t.SetArg(u.Index, arg)
+}
+
+// UsesTable is a map from each value to all of its uses in a control flow
+// graph. A UsesTable can be constructed by calling BuildUses.
+//
+// Values with no uses are not present in the table.
+type UsesTable map[*ossa.Value][]Use
+
+// BuildUses finds the uses of all values that are operands of the
+// instructions and terminators in blocks reachable from the given entry
+// block.
+//
+// The uses of each value are in the reverse postorder of their blocks, and
+// then in the order they appear within each block. The table is not updated
+// automatically when the graph is modified, so any modification that adds or
+// removes uses may make it inaccurate.
+func BuildUses(entry *ossa.BasicBlock) UsesTable {
+	ret := make(UsesTable)
+	for _, block := range ossa.AppendBlocksRPO(entry, nil) {
+		for _, inst := range block.Instructions {
+			for i := 0; i < inst.NumArgs(); i++ {
+				if arg := inst.Arg(i); arg != nil {
+					ret[arg] = append(ret[arg], Use{Block: block, Value: inst, Index: i})
+				}
+			}
+		}
+		if t := block.Terminator; t != nil {
+			for i := 0; i < t.NumArgs(); i++ {
+				if arg := t.Arg(i).Value; arg != nil {
+					ret[arg] = append(ret[arg], Use{Block: block, Index: i})
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// HasUses returns true if the given value has at least one use.
+func (t UsesTable) HasUses(v *ossa.Value) bool {
+	return len(t[v]) != 0
+}
+
+// ReplaceAllUses changes all of the uses of the given old value to refer to
+// the given replacement value instead, and updates the table to match.
+func (t UsesTable) ReplaceAllUses(old, repl *ossa.Value) {
+	if old == repl {
+		return
+	}
+	uses := t[old]
+	for _, u := range uses {
+		u.Replace(repl)
+	}
+	delete(t, old)
+	if repl != nil {
+		t[repl] = append(t[repl], uses...)
+	}
+}
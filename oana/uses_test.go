@@ -0,0 +1,97 @@
+package oana
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestBuildUses(t *testing.T) {
+	f := ossa.NewFunction()
+	p := f.AddParam()
+	b := ossa.NewFunctionBuilder(f)
+	callee := b.GlobalSym()
+
+	entry := f.Entry()
+	loop := b.NewBlock()
+	exit := b.NewBlock()
+	unreachable := b.NewBlock()
+
+	x := b.Call(callee, p, p)
+	b.Jump(loop)
+
+	b.SetBlock(loop)
+	phi := b.Phi(ossa.BasicBlockValue{Block: entry, Value: x})
+	y := b.Call(callee, phi)
+	phi.AddPhiCandidate(ossa.BasicBlockValue{Block: loop, Value: y})
+	b.Branch(y, loop, exit)
+
+	b.SetBlock(exit)
+	b.Return(x)
+
+	b.SetBlock(unreachable)
+	b.Return(y)
+
+	uses := BuildUses(entry)
+
+	want := map[*ossa.Value][]Use{
+		callee: {
+			{Block: entry, Value: x, Index: 0},
+			{Block: loop, Value: y, Index: 0},
+		},
+		p: {
+			{Block: entry, Value: x, Index: 1},
+			{Block: entry, Value: x, Index: 2},
+		},
+		x: {
+			{Block: loop, Value: phi, Index: 0},
+			{Block: exit, Index: 0},
+		},
+		phi: {
+			{Block: loop, Value: y, Index: 1},
+		},
+		y: {
+			{Block: loop, Value: phi, Index: 1},
+			{Block: loop, Index: 0},
+		},
+	}
+	if len(uses) != len(want) {
+		t.Errorf("wrong number of used values %d; want %d", len(uses), len(want))
+	}
+	for v, want := range want {
+		got := uses[v]
+		if len(got) != len(want) {
+			t.Errorf("wrong uses %#v; want %#v", got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("wrong use %d %#v; want %#v", i, got[i], want[i])
+			}
+		}
+	}
+
+	if got, want := uses[y][1].Terminator(), loop.Terminator; got != want {
+		t.Errorf("wrong terminator for use")
+	}
+	if uses[y][0].Terminator() != nil {
+		t.Errorf("instruction use has terminator")
+	}
+
+	// Replacing x with a new value should update both the phi and the
+	// return terminator.
+	z := ossa.AuxLiteral(nil)
+	uses.ReplaceAllUses(x, z)
+	if got := phi.Arg(0); got != z {
+		t.Errorf("phi candidate not replaced")
+	}
+	if got := exit.Terminator.Arg(0).Value; got != z {
+		t.Errorf("return value not replaced")
+	}
+	if uses.HasUses(x) {
+		t.Errorf("x still has uses")
+	}
+	if got, want := len(uses[z]), 2; got != want {
+		t.Errorf("z has %d uses; want %d", got, want)
+	}
+}
@@ -9,12 +9,16 @@ const (
 	OpLocalSym
 	OpArgument
 	OpAuxLiteral
+	OpUndef
+	OpPoison
 	OpPhi
 
 	OpLoad
 	OpStore
 
 	OpCall
+	OpSelect
+	OpLandingPad
 
 	// we also have some internal-only operations used to deal with CFG-related
 	// concerns. These are not visible to callers.
@@ -30,6 +34,8 @@ const (
 	OpReturn
 	OpYield
 	OpAwait
+	OpInvoke
+	OpResume
 	OpUnreachable
 
 	opEndTerminators
@@ -38,23 +44,29 @@ const (
 //go:generate stringer -type Op
 
 // Valid returns true if the receiving op is valid, which is to say it is one
-// of the constant values defined in this package. The zero value of
-// Op is not valid.
+// of the constant values defined in this package or an operation registered
+// with RegisterOp. The zero value of Op is not valid.
 func (o Op) Valid() bool {
 	// This excludes opInvalid, opEndValues and opEndTerminators, along with
-	// any values greater than opEndTerminators that are not defined yet.
+	// any values greater than opEndTerminators that are not registered.
 	return o.Value() || o.Terminator()
 }
 
 // Value returns true if the receiving op belongs to the set of operations
 // used with Values, as opposed to Terminators.
 func (o Op) Value() bool {
+	if reg, ok := o.registered(); ok {
+		return !reg.spec.Terminator
+	}
 	return o > opInvalid && o < opEndValues
 }
 
 // Terminator returns true if the receiving op belongs to the set of operations
 // used with Terminators, as opposed to Values.
 func (o Op) Terminator() bool {
+	if reg, ok := o.registered(); ok {
+		return reg.spec.Terminator
+	}
 	return o > opEndValues && o < opEndTerminators
 }
 
@@ -71,3 +83,56 @@ func (o Op) assertTerminator() {
 		panic("operation is not suitable for terminator")
 	}
 }
+
+// HasSideEffects returns true if an operation of the receiving kind may have
+// an effect other than producing its result, such as writing to memory or
+// transferring control, and so must not be removed even if its result is
+// unused.
+//
+// OpCall is conservatively assumed to have side-effects, since the effects
+// of a call depend on its callee. Callers that know more about a particular
+// callee can make their own decision for calls to it.
+//
+// For a registered operation, the result is taken from its OpSpec.
+func (o Op) HasSideEffects() bool {
+	if reg, ok := o.registered(); ok {
+		return reg.spec.HasSideEffects || reg.spec.Terminator
+	}
+	switch o {
+	case OpStore, OpCall:
+		return true
+	default:
+		// All terminators have the effect of transferring control.
+		return o.Terminator()
+	}
+}
+
+// ReadsMemory returns true if an operation of the receiving kind may read
+// from memory, and so may produce a different result if memory is written
+// between two executions of it. OpCall is conservatively assumed to read
+// memory.
+func (o Op) ReadsMemory() bool {
+	if reg, ok := o.registered(); ok {
+		return reg.spec.ReadsMemory
+	}
+	switch o {
+	case OpLoad, OpCall:
+		return true
+	default:
+		return false
+	}
+}
+
+// WritesMemory returns true if an operation of the receiving kind may write
+// to memory. OpCall is conservatively assumed to write memory.
+func (o Op) WritesMemory() bool {
+	if reg, ok := o.registered(); ok {
+		return reg.spec.WritesMemory
+	}
+	switch o {
+	case OpStore, OpCall:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,185 @@
+package ossa
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// OpSpec describes an operation registered by a frontend using RegisterOp.
+type OpSpec struct {
+	// Terminator is true if the operation is for use with terminators, as
+	// opposed to values.
+	Terminator bool
+
+	// Arity is the number of arguments that a value or terminator with the
+	// operation must have, or -1 if any number of arguments is allowed.
+	//
+	// For a value, each argument is an operand value. For a terminator, each
+	// argument is a BasicBlockValue whose Block, if not nil, is a successor
+	// and whose Value, if not nil, is an operand.
+	Arity int
+
+	// HasSideEffects, ReadsMemory and WritesMemory are returned by the
+	// methods of the same names on Op.
+	HasSideEffects bool
+	ReadsMemory    bool
+	WritesMemory   bool
+}
+
+type registeredOp struct {
+	name string
+	spec OpSpec
+}
+
+var opRegistry struct {
+	sync.RWMutex
+	ops    []registeredOp
+	byName map[string]Op
+}
+
+// opFirstRegistered is the Op value assigned to the first operation
+// registered with RegisterOp. Subsequent registrations are numbered
+// sequentially.
+const opFirstRegistered = opEndTerminators + 1
+
+// RegisterOp registers a new operation with the given name and
+// specification, returning its Op value. This allows frontends to represent
+// primitives of their language directly, rather than encoding them as calls
+// to AuxLiteral callees.
+//
+// Values with registered operations are constructed using NewValue, and
+// terminators using NewTerminator. Registered operations never carry an aux
+// value.
+//
+// The name must be a valid identifier that is not the name of a built-in
+// operation or another registered operation, or RegisterOp will panic. The
+// Op value assigned to a registered operation depends on the order of
+// registration, so it should be stored in a variable rather than persisted.
+//
+// Operations are usually registered during program initialization, though
+// it is safe to call RegisterOp concurrently with other uses of Op.
+func RegisterOp(name string, spec OpSpec) Op {
+	if !isOpName(name) {
+		panic(fmt.Sprintf("invalid operation name %q", name))
+	}
+	if spec.Arity < -1 {
+		panic(fmt.Sprintf("invalid arity %d for operation %q", spec.Arity, name))
+	}
+	for o := opInvalid + 1; o < opEndTerminators; o++ {
+		if (o.Value() || o.Terminator()) && o.Name() == name {
+			panic(fmt.Sprintf("operation name %q conflicts with a built-in operation", name))
+		}
+	}
+
+	opRegistry.Lock()
+	defer opRegistry.Unlock()
+	if _, exists := opRegistry.byName[name]; exists {
+		panic(fmt.Sprintf("duplicate registration of operation %q", name))
+	}
+	if opRegistry.byName == nil {
+		opRegistry.byName = make(map[string]Op)
+	}
+	op := opFirstRegistered + Op(len(opRegistry.ops))
+	opRegistry.ops = append(opRegistry.ops, registeredOp{name, spec})
+	opRegistry.byName[name] = op
+	return op
+}
+
+// LookupOp returns the registered operation with the given name. The second
+// return value is false if there is no such operation. Built-in operations
+// are not included.
+func LookupOp(name string) (Op, bool) {
+	opRegistry.RLock()
+	defer opRegistry.RUnlock()
+	op, ok := opRegistry.byName[name]
+	return op, ok
+}
+
+// Spec returns the specification of the receiver, if it is a registered
+// operation. The second return value is false for built-in operations.
+func (o Op) Spec() (OpSpec, bool) {
+	reg, ok := o.registered()
+	return reg.spec, ok
+}
+
+// Name returns the name of the receiving operation, which for built-in
+// operations is the name of its constant without the "Op" prefix, and for
+// registered operations is the name given to RegisterOp.
+func (o Op) Name() string {
+	if reg, ok := o.registered(); ok {
+		return reg.name
+	}
+	return strings.TrimPrefix(o.String(), "Op")
+}
+
+func (o Op) registered() (registeredOp, bool) {
+	if o < opFirstRegistered {
+		return registeredOp{}, false
+	}
+	opRegistry.RLock()
+	defer opRegistry.RUnlock()
+	i := int(o - opFirstRegistered)
+	if i >= len(opRegistry.ops) {
+		return registeredOp{}, false
+	}
+	return opRegistry.ops[i], true
+}
+
+// NewValue constructs a new value with the given registered operation and
+// operands.
+//
+// It panics if the operation is not a registered value operation, or if
+// the number of operands does not match its arity.
+func NewValue(op Op, args ...*Value) *Value {
+	spec, ok := op.Spec()
+	if !ok || spec.Terminator {
+		panic(fmt.Sprintf("NewValue with %s, which is not a registered value operation", op.Name()))
+	}
+	if spec.Arity >= 0 && len(args) != spec.Arity {
+		panic(fmt.Sprintf("%s requires %d operands, but got %d", op.Name(), spec.Arity, len(args)))
+	}
+	v := &Value{
+		op: op,
+	}
+	aa := v.bufForArgs(len(args))
+	v.args = append(aa, args...)
+	return v
+}
+
+// NewTerminator constructs a new terminator with the given registered
+// operation and arguments.
+//
+// It panics if the operation is not a registered terminator operation, or
+// if the number of arguments does not match its arity.
+func NewTerminator(op Op, args ...BasicBlockValue) *Terminator {
+	spec, ok := op.Spec()
+	if !ok || !spec.Terminator {
+		panic(fmt.Sprintf("NewTerminator with %s, which is not a registered terminator operation", op.Name()))
+	}
+	if spec.Arity >= 0 && len(args) != spec.Arity {
+		panic(fmt.Sprintf("%s requires %d arguments, but got %d", op.Name(), spec.Arity, len(args)))
+	}
+	t := &Terminator{
+		op: op,
+	}
+	aa := t.bufForArgs(len(args))
+	t.args = append(aa, args...)
+	return t
+}
+
+func isOpName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_':
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
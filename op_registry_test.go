@@ -0,0 +1,74 @@
+package ossa
+
+import (
+	"testing"
+)
+
+func TestRegisterOp(t *testing.T) {
+	add := RegisterOp("TestRegisterAdd", OpSpec{Arity: 2})
+	store := RegisterOp("TestRegisterStore", OpSpec{
+		Arity:          -1,
+		HasSideEffects: true,
+		WritesMemory:   true,
+	})
+	trap := RegisterOp("TestRegisterTrap", OpSpec{Terminator: true, Arity: 1})
+
+	if !add.Valid() || !add.Value() || add.Terminator() {
+		t.Errorf("wrong classification for value op")
+	}
+	if !trap.Valid() || trap.Value() || !trap.Terminator() {
+		t.Errorf("wrong classification for terminator op")
+	}
+	if (add + trap).Valid() {
+		t.Errorf("unregistered op is valid")
+	}
+	if got, want := add.Name(), "TestRegisterAdd"; got != want {
+		t.Errorf("wrong name %q; want %q", got, want)
+	}
+	if got, want := OpJump.Name(), "Jump"; got != want {
+		t.Errorf("wrong name for built-in op %q; want %q", got, want)
+	}
+	if op, ok := LookupOp("TestRegisterStore"); !ok || op != store {
+		t.Errorf("LookupOp did not find registered op")
+	}
+	if _, ok := LookupOp("Jump"); ok {
+		t.Errorf("LookupOp found built-in op")
+	}
+
+	if add.HasSideEffects() || add.ReadsMemory() || add.WritesMemory() {
+		t.Errorf("wrong effects for add")
+	}
+	if !store.HasSideEffects() || store.ReadsMemory() || !store.WritesMemory() {
+		t.Errorf("wrong effects for store")
+	}
+	if !trap.HasSideEffects() {
+		t.Errorf("terminator has no side-effects")
+	}
+
+	v := NewValue(add, Argument(), Argument())
+	if got, want := v.NumArgs(), 2; got != want {
+		t.Errorf("wrong number of args %d; want %d", got, want)
+	}
+	target := NewBasicBlock()
+	term := NewTerminator(trap, BasicBlockValue{Value: v, Block: target})
+	succs := term.AppendSuccessors(nil)
+	if len(succs) != 1 || succs[0] != target {
+		t.Errorf("wrong successors %#v", succs)
+	}
+
+	mustPanic := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s did not panic", name)
+			}
+		}()
+		fn()
+	}
+	mustPanic("duplicate name", func() { RegisterOp("TestRegisterAdd", OpSpec{}) })
+	mustPanic("built-in name", func() { RegisterOp("Call", OpSpec{}) })
+	mustPanic("invalid name", func() { RegisterOp("not valid", OpSpec{}) })
+	mustPanic("wrong arity", func() { NewValue(add, v) })
+	mustPanic("terminator as value", func() { NewValue(trap, v) })
+	mustPanic("built-in op", func() { NewValue(OpLoad, v) })
+}
@@ -4,9 +4,9 @@ package ossa
 
 import "strconv"
 
-const _Op_name = "opInvalidOpGlobalSymOpLocalSymOpArgumentOpAuxLiteralOpPhiOpLoadOpStoreOpCallopBasicBlockopEndValuesOpJumpOpBranchOpSwitchOpReturnOpYieldOpAwaitOpUnreachableopEndTerminators"
+const _Op_name = "opInvalidOpGlobalSymOpLocalSymOpArgumentOpAuxLiteralOpUndefOpPoisonOpPhiOpLoadOpStoreOpCallOpSelectOpLandingPadopBasicBlockopEndValuesOpJumpOpBranchOpSwitchOpReturnOpYieldOpAwaitOpInvokeOpResumeOpUnreachableopEndTerminators"
 
-var _Op_index = [...]uint8{0, 9, 20, 30, 40, 52, 57, 63, 70, 76, 88, 99, 105, 113, 121, 129, 136, 143, 156, 172}
+var _Op_index = [...]uint8{0, 9, 20, 30, 40, 52, 59, 67, 72, 78, 85, 91, 99, 111, 123, 134, 140, 148, 156, 164, 171, 178, 186, 194, 207, 223}
 
 func (i Op) String() string {
 	if i < 0 || i >= Op(len(_Op_index)-1) {
@@ -0,0 +1,261 @@
+package ossatest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/otext"
+)
+
+// AssertCFGEquivalent reports a test failure if the given functions are not
+// the same up to renaming of their blocks and values, describing the first
+// difference found along with both functions in the otext syntax.
+//
+// Unlike comparing the otext representations of the functions, this does
+// not depend on the order that blocks were added to the functions or on the
+// names of values and blocks, and so it suits tests of transforms whose
+// output is constructed in an order that is not part of their contract.
+//
+// The functions are matched by walking their control flow graphs together
+// from their entry blocks, pairing successors and instructions in order.
+// Paired blocks must have the same number of instructions and paired
+// terminators must have the same operation and operands. Paired values must
+// have the same operation and type, equal aux values and paired operands.
+// Values that have no operands, such as literals, match any value that is
+// Equivalent. Arguments are paired by their position in the function's
+// parameters, while other values whose identity is significant, such as
+// symbols and landing pads, may be paired with any value of the same
+// operation but only one. The instructions of blocks that are not reachable
+// from the entry block are not compared, but the functions must have the
+// same number of blocks.
+//
+// Metadata, source positions, debug names and terminator weights are not
+// compared.
+func AssertCFGEquivalent(t testing.TB, got, want *ossa.Function) {
+	t.Helper()
+	if diff := diffCFG(got, want); diff != "" {
+		t.Errorf(
+			"functions are not equivalent: %s\ngot:\n%s\nwant:\n%s",
+			diff, otext.SprintFunction(got), otext.SprintFunction(want),
+		)
+	}
+}
+
+// diffCFG returns a description of the first difference found between the
+// given functions by AssertCFGEquivalent, or an empty string if they are
+// equivalent.
+func diffCFG(got, want *ossa.Function) string {
+	m := &cfgMatcher{
+		blocks:    make(map[*ossa.BasicBlock]*ossa.BasicBlock),
+		revBlocks: make(map[*ossa.BasicBlock]*ossa.BasicBlock),
+		values:    make(map[*ossa.Value]*ossa.Value),
+		revValues: make(map[*ossa.Value]*ossa.Value),
+		gotIdx:    blockIndices(got),
+		wantIdx:   blockIndices(want),
+	}
+	if len(got.Params) != len(want.Params) {
+		return fmt.Sprintf("got %d parameters, want %d", len(got.Params), len(want.Params))
+	}
+	for i, p := range got.Params {
+		m.pairValue(p, want.Params[i])
+	}
+	if err := m.matchBlock(got.Entry(), want.Entry()); err != nil {
+		return err.Error()
+	}
+	for len(m.todo) > 0 {
+		g := m.todo[0]
+		m.todo = m.todo[1:]
+		if err := m.compareBlocks(g, m.blocks[g]); err != nil {
+			return err.Error()
+		}
+	}
+	if got.BlockCount() != want.BlockCount() {
+		return fmt.Sprintf("got %d blocks, want %d", got.BlockCount(), want.BlockCount())
+	}
+	return ""
+}
+
+// cfgMatcher is the state of a comparison by diffCFG. Each of the maps of
+// pairs is accompanied by its reverse, to ensure that each value or block
+// is paired with only one other.
+type cfgMatcher struct {
+	blocks, revBlocks map[*ossa.BasicBlock]*ossa.BasicBlock
+	values, revValues map[*ossa.Value]*ossa.Value
+
+	// gotIdx and wantIdx are the positions of the blocks in each function,
+	// which name them in messages as the otext printer does.
+	gotIdx, wantIdx map[*ossa.BasicBlock]int
+
+	// todo are the blocks of the got function that have been paired but
+	// whose contents have not yet been compared.
+	todo []*ossa.BasicBlock
+}
+
+func blockIndices(f *ossa.Function) map[*ossa.BasicBlock]int {
+	ret := make(map[*ossa.BasicBlock]int)
+	for i, block := range f.AppendBlocks(nil) {
+		ret[block] = i
+	}
+	return ret
+}
+
+func (m *cfgMatcher) matchBlock(g, w *ossa.BasicBlock) error {
+	if g == nil || w == nil {
+		if g != w {
+			return fmt.Errorf("got block %v, want %v", m.blockName(g, m.gotIdx), m.blockName(w, m.wantIdx))
+		}
+		return nil
+	}
+	pg, gPaired := m.blocks[g]
+	_, wPaired := m.revBlocks[w]
+	switch {
+	case gPaired && wPaired && pg == w:
+		return nil
+	case gPaired || wPaired:
+		return fmt.Errorf("got block %s, want %s", m.blockName(g, m.gotIdx), m.blockName(w, m.wantIdx))
+	}
+	m.blocks[g] = w
+	m.revBlocks[w] = g
+	m.todo = append(m.todo, g)
+	return nil
+}
+
+func (m *cfgMatcher) blockName(block *ossa.BasicBlock, idx map[*ossa.BasicBlock]int) string {
+	if block == nil {
+		return "<nil>"
+	}
+	if i, ok := idx[block]; ok {
+		return fmt.Sprintf("b%d", i)
+	}
+	return "outside of the function"
+}
+
+func (m *cfgMatcher) compareBlocks(g, w *ossa.BasicBlock) error {
+	where := fmt.Sprintf("block %s (want %s)", m.blockName(g, m.gotIdx), m.blockName(w, m.wantIdx))
+	if len(g.Instructions) != len(w.Instructions) {
+		return fmt.Errorf("%s: got %d instructions, want %d", where, len(g.Instructions), len(w.Instructions))
+	}
+	for i, inst := range g.Instructions {
+		if err := m.matchValue(inst, w.Instructions[i]); err != nil {
+			return fmt.Errorf("%s: instruction %d: %s", where, i, err)
+		}
+	}
+
+	gt, wt := g.Terminator, w.Terminator
+	switch {
+	case gt == nil && wt == nil:
+		return nil
+	case gt == nil || wt == nil:
+		return fmt.Errorf("%s: got terminator %v, want %v", where, terminatorOp(gt), terminatorOp(wt))
+	case gt.Op() != wt.Op():
+		return fmt.Errorf("%s: got terminator %s, want %s", where, gt.Op().Name(), wt.Op().Name())
+	case gt.NumArgs() != wt.NumArgs():
+		return fmt.Errorf("%s: got %d terminator operands, want %d", where, gt.NumArgs(), wt.NumArgs())
+	}
+	for i := 0; i < gt.NumArgs(); i++ {
+		ga, wa := gt.Arg(i), wt.Arg(i)
+		if err := m.matchOperand(ga.Value, wa.Value); err != nil {
+			return fmt.Errorf("%s: terminator operand %d: %s", where, i, err)
+		}
+		if err := m.matchBlock(ga.Block, wa.Block); err != nil {
+			return fmt.Errorf("%s: terminator operand %d: %s", where, i, err)
+		}
+	}
+	return nil
+}
+
+func terminatorOp(t *ossa.Terminator) string {
+	if t == nil {
+		return "<nil>"
+	}
+	return t.Op().Name()
+}
+
+// matchValue pairs the given values if they are compatible, or returns an
+// error describing why they are not.
+func (m *cfgMatcher) matchValue(g, w *ossa.Value) error {
+	pg, gPaired := m.values[g]
+	_, wPaired := m.revValues[w]
+	switch {
+	case gPaired && wPaired && pg == w:
+		return nil
+	case g.Op() != w.Op():
+		return fmt.Errorf("got %s, want %s", g.Op().Name(), w.Op().Name())
+	case gPaired || wPaired:
+		return fmt.Errorf("got %s corresponding to a different value", g.Op().Name())
+	case g.NumArgs() != w.NumArgs():
+		return fmt.Errorf("got %d operands, want %d", g.NumArgs(), w.NumArgs())
+	case !ossa.TypesEqual(g.Type(), w.Type()):
+		return fmt.Errorf("got type %v, want %v", g.Type(), w.Type())
+	}
+	switch g.Op() {
+	case ossa.OpArgument:
+		// Arguments are paired in advance by position, so an argument
+		// that is not yet paired does not belong to its function.
+		return fmt.Errorf("got an argument that is not a parameter")
+	case ossa.OpGlobalSym, ossa.OpLocalSym, ossa.OpLandingPad:
+		m.pairValue(g, w)
+		return nil
+	}
+	if g.NumArgs() == 0 {
+		// These are not paired, so that equal literals need not be
+		// shared in the same way by both functions.
+		if !g.Equivalent(w) {
+			return fmt.Errorf("got %s %s, want %s", g.Op().Name(), auxString(g), auxString(w))
+		}
+		return nil
+	}
+	// Equivalent compares the aux values of literals in the same way as it
+	// compares the aux values of other values.
+	if !ossa.AuxLiteral(g.Aux()).Equivalent(ossa.AuxLiteral(w.Aux())) {
+		return fmt.Errorf("got aux value %s, want %s", auxString(g), auxString(w))
+	}
+
+	// The values are paired before their operands are compared, since a Phi
+	// node can depend on itself.
+	m.pairValue(g, w)
+	if g.Op() == ossa.OpPhi {
+		for i := 0; i < g.NumArgs(); i++ {
+			gc, wc := g.PhiCandidate(i), w.PhiCandidate(i)
+			if err := m.matchBlock(gc.Block, wc.Block); err != nil {
+				return fmt.Errorf("candidate %d: %s", i, err)
+			}
+			if err := m.matchOperand(gc.Value, wc.Value); err != nil {
+				return fmt.Errorf("candidate %d: %s", i, err)
+			}
+		}
+		return nil
+	}
+	for i := 0; i < g.NumArgs(); i++ {
+		if err := m.matchOperand(g.Arg(i), w.Arg(i)); err != nil {
+			return fmt.Errorf("operand %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// matchOperand is like matchValue but also allows both values to be nil.
+func (m *cfgMatcher) matchOperand(g, w *ossa.Value) error {
+	switch {
+	case g == nil && w == nil:
+		return nil
+	case g == nil:
+		return fmt.Errorf("got no value, want %s", w.Op().Name())
+	case w == nil:
+		return fmt.Errorf("got %s, want no value", g.Op().Name())
+	}
+	return m.matchValue(g, w)
+}
+
+func auxString(v *ossa.Value) string {
+	if k, ok := v.Const(); ok {
+		return k.String()
+	}
+	return fmt.Sprintf("%#v", v.Aux())
+}
+
+func (m *cfgMatcher) pairValue(g, w *ossa.Value) {
+	m.values[g] = w
+	m.revValues[w] = g
+}
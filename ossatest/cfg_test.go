@@ -0,0 +1,197 @@
+package ossatest
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestAssertCFGEquivalent(t *testing.T) {
+	// The blocks are in a different order, the values have different names
+	// and an equal literal is not shared, but the functions are otherwise
+	// the same.
+	got, err := otext.ParseFunction([]byte(`func(p, q) {
+    one = AuxLiteral 1
+    uno = AuxLiteral 1
+entry:
+    Branch p, loop, exit
+exit:
+    r = Phi [entry: uno] [loop: x]
+    Return r
+loop:
+    i = Phi [entry: one] [loop: x]
+    x = Call q, i
+    Branch x, loop, exit
+}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want, err := otext.ParseFunction([]byte(`func(a, b) {
+    c = AuxLiteral 1
+b0:
+    Branch a, b1, b2
+b1:
+    v = Phi [b0: c] [b1: w]
+    w = Call b, v
+    Branch w, b1, b2
+b2:
+    y = Phi [b0: c] [b1: w]
+    Return y
+}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	AssertCFGEquivalent(t, got, want)
+}
+
+func TestAssertCFGEquivalentDifferent(t *testing.T) {
+	want := `func(p, q) {
+    one = AuxLiteral 1
+entry:
+    x = Call q, one, p
+    Branch x, left, right
+left:
+    Jump right
+right:
+    r = Phi [entry: one] [left: x]
+    Return r
+}
+`
+	tests := map[string]struct {
+		got  string
+		diff string
+	}{
+		"parameters": {
+			`func(p) {
+entry:
+    Return
+}
+`,
+			"got 1 parameters, want 2",
+		},
+		"operation": {
+			`func(p, q) {
+    one = AuxLiteral 1
+entry:
+    x = Load q
+    Branch x, left, right
+left:
+    Jump right
+right:
+    r = Phi [entry: one] [left: x]
+    Return r
+}
+`,
+			"block b0 (want b0): instruction 0: got Load, want Call",
+		},
+		"literal": {
+			`func(p, q) {
+    one = AuxLiteral 2
+entry:
+    x = Call q, one, p
+    Branch x, left, right
+left:
+    Jump right
+right:
+    r = Phi [entry: one] [left: x]
+    Return r
+}
+`,
+			"block b0 (want b0): instruction 0: operand 1: got AuxLiteral 2, want 1",
+		},
+		"operand order": {
+			`func(p, q) {
+    one = AuxLiteral 1
+entry:
+    x = Call q, p, one
+    Branch x, left, right
+left:
+    Jump right
+right:
+    r = Phi [entry: one] [left: x]
+    Return r
+}
+`,
+			"block b0 (want b0): instruction 0: operand 1: got Argument, want AuxLiteral",
+		},
+		"different argument": {
+			`func(p, q) {
+    one = AuxLiteral 1
+entry:
+    x = Call p, one, p
+    Branch x, left, right
+left:
+    Jump right
+right:
+    r = Phi [entry: one] [left: x]
+    Return r
+}
+`,
+			"block b0 (want b0): instruction 0: operand 0: got Argument corresponding to a different value",
+		},
+		"swapped targets": {
+			`func(p, q) {
+    one = AuxLiteral 1
+entry:
+    x = Call q, one, p
+    Branch x, right, left
+left:
+    Jump right
+right:
+    r = Phi [entry: one] [left: x]
+    Return r
+}
+`,
+			"block b2 (want b1): got 1 instructions, want 0",
+		},
+		"Phi candidate": {
+			`func(p, q) {
+    one = AuxLiteral 1
+entry:
+    x = Call q, one, p
+    Branch x, left, right
+left:
+    Jump right
+right:
+    r = Phi [entry: one] [left: one]
+    Return r
+}
+`,
+			"block b2 (want b2): instruction 0: candidate 1: got AuxLiteral, want Call",
+		},
+		"unreachable block": {
+			`func(p, q) {
+    one = AuxLiteral 1
+entry:
+    x = Call q, one, p
+    Branch x, left, right
+left:
+    Jump right
+right:
+    r = Phi [entry: one] [left: x]
+    Return r
+dead:
+    Return
+}
+`,
+			"got 4 blocks, want 3",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := otext.ParseFunction([]byte(test.got))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			want, err := otext.ParseFunction([]byte(want))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := diffCFG(got, want); diff != test.diff {
+				t.Errorf("wrong difference\ngot:  %s\nwant: %s", diff, test.diff)
+			}
+		})
+	}
+}
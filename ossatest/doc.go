@@ -0,0 +1,3 @@
+// Package ossatest is a utility package for ossa that contains helpers for
+// tests of code that constructs or transforms functions.
+package ossatest
@@ -0,0 +1,4 @@
+// Package otext is a utility package for ossa that converts functions and
+// modules to and from a human-readable textual representation, for use in
+// debugging and in test fixtures.
+package otext
@@ -0,0 +1,176 @@
+package otext
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+type tokenType int
+
+const (
+	tokenInvalid tokenType = iota
+	tokenNewline
+	tokenIdent
+	tokenGlobal
+	tokenString
+	tokenNumber
+	tokenPunct
+	tokenEOF
+)
+
+type token struct {
+	typ  tokenType
+	text string // for tokenGlobal and tokenString, the unquoted value
+	pos  pos
+
+	// local is true for a tokenIdent written with a "%" prefix, as in
+	// PrintConfig.LLVMSyntax. The prefix is not included in text, but a
+	// prefixed identifier is never a keyword.
+	local bool
+}
+
+type pos struct {
+	Line, Column int
+}
+
+func (p pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// lex splits the given source into tokens, always ending with a tokenEOF.
+//
+// Consecutive newlines and comments are collapsed into a single tokenNewline,
+// so that the parser doesn't need to deal with blank lines. Comments begin
+// with either "//" or ";".
+func lex(src []byte) ([]token, error) {
+	var toks []token
+	line, lineOff := 1, 0
+	i := 0
+
+	emit := func(typ tokenType, text string, p pos) {
+		if typ == tokenNewline && (len(toks) == 0 || toks[len(toks)-1].typ == tokenNewline) {
+			return
+		}
+		toks = append(toks, token{typ: typ, text: text, pos: p})
+	}
+
+	for i < len(src) {
+		p := pos{line, utf8.RuneCount(src[lineOff:i]) + 1}
+		c := src[i]
+		switch {
+		case c == '\n':
+			emit(tokenNewline, "\n", p)
+			i++
+			line++
+			lineOff = i
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == ';' || (c == '/' && i+1 < len(src) && src[i+1] == '/'):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentContinue(src[i]) {
+				i++
+			}
+			emit(tokenIdent, string(src[start:i]), p)
+		case c == '%':
+			i++
+			start := i
+			for i < len(src) && isIdentContinue(src[i]) {
+				i++
+			}
+			if start == i || !isIdentStart(src[start]) {
+				return nil, fmt.Errorf("%s: expected name after %%", p)
+			}
+			toks = append(toks, token{typ: tokenIdent, text: string(src[start:i]), pos: p, local: true})
+		case c == '@':
+			i++
+			if i < len(src) && src[i] == '"' {
+				s, n, err := lexString(src[i:], p)
+				if err != nil {
+					return nil, err
+				}
+				i += n
+				emit(tokenGlobal, s, p)
+			} else {
+				start := i
+				for i < len(src) && isIdentContinue(src[i]) {
+					i++
+				}
+				if start == i {
+					return nil, fmt.Errorf("%s: expected global name after @", p)
+				}
+				emit(tokenGlobal, string(src[start:i]), p)
+			}
+		case c == '"':
+			s, n, err := lexString(src[i:], p)
+			if err != nil {
+				return nil, err
+			}
+			i += n
+			emit(tokenString, s, p)
+		case c == '-' || (c >= '0' && c <= '9'):
+			start := i
+			i++
+			for i < len(src) {
+				c := src[i]
+				if (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' {
+					i++
+					continue
+				}
+				if (c == '+' || c == '-') && (src[i-1] == 'e' || src[i-1] == 'E') {
+					i++
+					continue
+				}
+				break
+			}
+			emit(tokenNumber, string(src[start:i]), p)
+		case c == '(' || c == ')' || c == '{' || c == '}' || c == '[' || c == ']' || c == ':' || c == ',' || c == '=':
+			i++
+			emit(tokenPunct, string(c), p)
+		default:
+			r, _ := utf8.DecodeRune(src[i:])
+			return nil, fmt.Errorf("%s: unexpected character %q", p, r)
+		}
+	}
+	end := pos{line, utf8.RuneCount(src[lineOff:]) + 1}
+	emit(tokenNewline, "\n", end)
+	toks = append(toks, token{typ: tokenEOF, pos: end})
+	return toks, nil
+}
+
+func lexString(src []byte, p pos) (string, int, error) {
+	// src[0] is the opening quote
+	i := 1
+	for i < len(src) {
+		switch src[i] {
+		case '\\':
+			i += 2
+			continue
+		case '\n':
+			return "", 0, fmt.Errorf("%s: unterminated string", p)
+		case '"':
+			s, err := strconv.Unquote(string(src[:i+1]))
+			if err != nil {
+				return "", 0, fmt.Errorf("%s: invalid string: %s", p, err)
+			}
+			return s, i + 1, nil
+		}
+		i++
+	}
+	return "", 0, fmt.Errorf("%s: unterminated string", p)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentContinue(c byte) bool {
+	return isIdentStart(c) || c == '.' || (c >= '0' && c <= '9')
+}
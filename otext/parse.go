@@ -0,0 +1,1006 @@
+package otext
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/alamatic/ossa"
+)
+
+// ParseFunction parses the textual representation of a single function, in
+// the form produced by FprintFunction, and returns the function it describes.
+//
+// The names of values and block labels in the source need not follow the
+// v0, b0 naming scheme used by the printer; any identifier is accepted.
+// Only labels in the form the printer uses for named values and blocks, such
+// as "v3.count", give a name to the value or block they label.
+// The first block in the source becomes the function's entry block, and the
+// remaining blocks are owned by the function in the order they appear.
+//
+// The source may also be in the syntax produced by PrintConfig.LLVMSyntax,
+// or mix the two syntaxes together.
+//
+// The source must not refer to any global symbols by name, because a
+// function alone has no global namespace. Use ParseModule for sources that
+// need global symbols.
+func ParseFunction(src []byte) (*ossa.Function, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	p.skipNewlines()
+	if !p.peekKeyword("func") && !p.peekKeyword("define") {
+		return nil, fmt.Errorf("%s: expected function", p.peek().pos)
+	}
+	p.next()
+	f := ossa.NewFunction()
+	if err := p.parseFunctionRest(f); err != nil {
+		return nil, err
+	}
+	if tok := p.next(); tok.typ != tokenEOF {
+		return nil, fmt.Errorf("%s: unexpected content after function", tok.pos)
+	}
+	return f, nil
+}
+
+// ParseModule parses the textual representation of a module, in the form
+// produced by FprintModule, and returns the module it describes.
+//
+// Global symbols are declared in the order they first appear as either a
+// global declaration or a function definition, and may be referred to from
+// within any function in the module, regardless of where they are declared.
+//
+// Constant pool entries must be numbered consecutively from zero in the
+// order they appear, and must not repeat the data of an earlier entry, so
+// that each number is the index of the entry in the resulting module's pool.
+// They too may be referred to before they are declared.
+func ParseModule(src []byte) (*ossa.Module, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		toks: toks,
+		mod:  ossa.NewModule(),
+	}
+
+	// We'll declare all of the global names before we parse any function
+	// bodies, so that functions can refer to globals that are declared
+	// after them.
+	funcs := make(map[string]*ossa.Function)
+	depth := 0
+	for i, tok := range toks {
+		switch {
+		case depth == 0 && tok.typ == tokenIdent && tok.text == "pool" && i+3 < len(toks) && toks[i+3].typ == tokenString:
+			ref := p.mod.Intern(toks[i+3].text)
+			if ref.Index() < len(p.pool) {
+				return nil, fmt.Errorf("%s: duplicate data for pool entry %s", toks[i+1].pos, toks[i+1].text)
+			}
+			if want := strconv.Itoa(ref.Index()); toks[i+1].text != want {
+				return nil, fmt.Errorf("%s: pool entry should be numbered %s", toks[i+1].pos, want)
+			}
+			p.pool = append(p.pool, ref)
+		case tok.typ == tokenPunct && tok.text == "{":
+			depth++
+		case tok.typ == tokenPunct && tok.text == "}":
+			depth--
+		case depth == 0 && tok.typ == tokenIdent && i+1 < len(toks) && toks[i+1].typ == tokenGlobal:
+			name := toks[i+1].text
+			switch tok.text {
+			case "global":
+				p.mod.DeclareGlobal(name)
+			case "func", "define":
+				if _, exists := funcs[name]; exists {
+					return nil, fmt.Errorf("%s: duplicate definition of function @%s", tok.pos, name)
+				}
+				funcs[name] = p.mod.DefineFunction(name)
+			}
+		}
+	}
+
+	for {
+		p.skipNewlines()
+		tok := p.next()
+		if tok.typ == tokenEOF {
+			break
+		}
+		if tok.typ != tokenIdent {
+			return nil, fmt.Errorf("%s: expected global declaration or function", tok.pos)
+		}
+		switch tok.text {
+		case "global":
+			if _, err := p.expect(tokenGlobal, ""); err != nil {
+				return nil, err
+			}
+			if err := p.expectNewline(); err != nil {
+				return nil, err
+			}
+		case "pool":
+			if _, err := p.expect(tokenNumber, ""); err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokenPunct, "="); err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokenString, ""); err != nil {
+				return nil, err
+			}
+			if err := p.expectNewline(); err != nil {
+				return nil, err
+			}
+		case "func", "define":
+			nameTok, err := p.expect(tokenGlobal, "")
+			if err != nil {
+				return nil, err
+			}
+			if err := p.parseFunctionRest(funcs[nameTok.text]); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%s: expected global declaration or function", tok.pos)
+		}
+	}
+	return p.mod, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+
+	// mod is the module whose globals may be referred to, or nil if global
+	// references are not allowed.
+	mod *ossa.Module
+
+	// pool is the module's constant pool entries, in the order they were
+	// declared.
+	pool []ossa.PoolRef
+}
+
+// funcParser holds the state for parsing a single function body.
+type funcParser struct {
+	*parser
+	f       *ossa.Function
+	values  map[string]*ossa.Value
+	blocks  map[string]*ossa.BasicBlock
+	defined map[string]bool
+
+	// fixups are functions to run once all values and blocks have been
+	// declared, to resolve references that may appear before the
+	// corresponding declarations.
+	fixups []func() error
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.toks[p.pos]
+	if tok.typ != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) skipNewlines() {
+	for p.peek().typ == tokenNewline {
+		p.next()
+	}
+}
+
+// peekAt returns the token the given number of tokens after the next one,
+// or the final tokenEOF if there are not that many tokens.
+func (p *parser) peekAt(n int) token {
+	if p.pos+n >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.pos+n]
+}
+
+func (p *parser) peekPunct(text string) bool {
+	tok := p.peek()
+	return tok.typ == tokenPunct && tok.text == text
+}
+
+// peekKeyword returns true if the next token is the given keyword, which is
+// an identifier written without a "%" prefix.
+func (p *parser) peekKeyword(text string) bool {
+	tok := p.peek()
+	return tok.typ == tokenIdent && !tok.local && tok.text == text
+}
+
+// acceptKeyword consumes the next token and returns true if it is the given
+// keyword, or otherwise leaves it in place and returns false.
+func (p *parser) acceptKeyword(text string) bool {
+	if !p.peekKeyword(text) {
+		return false
+	}
+	p.next()
+	return true
+}
+
+// expect consumes the next token and returns an error if it is not of the
+// given type. If text is not empty then the token must also have that text.
+func (p *parser) expect(typ tokenType, text string) (token, error) {
+	tok := p.next()
+	if tok.typ != typ || (text != "" && tok.text != text) {
+		return tok, fmt.Errorf("%s: expected %s", tok.pos, describeToken(typ, text))
+	}
+	return tok, nil
+}
+
+func (p *parser) expectNewline() error {
+	_, err := p.expect(tokenNewline, "")
+	return err
+}
+
+func describeToken(typ tokenType, text string) string {
+	switch {
+	case text != "":
+		return fmt.Sprintf("%q", text)
+	case typ == tokenNewline:
+		return "end of line"
+	case typ == tokenIdent:
+		return "identifier"
+	case typ == tokenGlobal:
+		return "global name"
+	default:
+		return "token"
+	}
+}
+
+// parseFunctionRest parses the remainder of a function after its "func"
+// keyword and optional name, populating the given function.
+func (p *parser) parseFunctionRest(f *ossa.Function) error {
+	fp := &funcParser{
+		parser:  p,
+		f:       f,
+		values:  make(map[string]*ossa.Value),
+		blocks:  make(map[string]*ossa.BasicBlock),
+		defined: make(map[string]bool),
+	}
+
+	if _, err := p.expect(tokenPunct, "("); err != nil {
+		return err
+	}
+	for !p.peekPunct(")") {
+		if len(f.Params) > 0 {
+			if _, err := p.expect(tokenPunct, ","); err != nil {
+				return err
+			}
+		}
+		tok, err := p.expect(tokenIdent, "")
+		if err != nil {
+			return err
+		}
+		if err := fp.declareValue(tok, f.AddParam()); err != nil {
+			return err
+		}
+	}
+	p.next() // the closing paren
+	if _, err := p.expect(tokenPunct, "{"); err != nil {
+		return err
+	}
+	if err := p.expectNewline(); err != nil {
+		return err
+	}
+
+	var block *ossa.BasicBlock
+	for {
+		tok := p.next()
+		switch {
+		case tok.typ == tokenPunct && tok.text == "}":
+			if block == nil {
+				return fmt.Errorf("%s: function must have at least one block", tok.pos)
+			}
+			if err := p.expectNewline(); err != nil {
+				return err
+			}
+			for _, fixup := range fp.fixups {
+				if err := fixup(); err != nil {
+					return err
+				}
+			}
+			for label := range fp.blocks {
+				if !fp.defined[label] {
+					return fmt.Errorf("reference to undefined block %s", label)
+				}
+			}
+			return nil
+		case tok.typ == tokenIdent && p.peekPunct(":"):
+			p.next() // the colon
+			if fp.defined[tok.text] {
+				return fmt.Errorf("%s: duplicate definition of block %s", tok.pos, tok.text)
+			}
+			if block == nil {
+				block = f.Entry()
+				fp.blocks[tok.text] = block
+			} else {
+				block = fp.block(tok.text)
+				f.AddBlock(block)
+			}
+			fp.defined[tok.text] = true
+			block.Name = labelName(tok.text, "b")
+			if err := p.expectNewline(); err != nil {
+				return err
+			}
+		case tok.typ == tokenIdent && p.peekPunct("="):
+			p.next() // the equals
+			v, err := fp.parseValue()
+			if err != nil {
+				return err
+			}
+			if err := fp.declareValue(tok, v); err != nil {
+				return err
+			}
+			if block != nil {
+				if block.Terminator != nil {
+					return fmt.Errorf("%s: instruction after terminator", tok.pos)
+				}
+				block.Instructions = append(block.Instructions, v)
+			}
+		case tok.typ == tokenIdent:
+			if block == nil {
+				return fmt.Errorf("%s: terminator outside of block", tok.pos)
+			}
+			if block.Terminator != nil {
+				return fmt.Errorf("%s: block already has a terminator", tok.pos)
+			}
+			if err := fp.parseTerminator(tok, block); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: expected block label, instruction, or terminator", tok.pos)
+		}
+	}
+}
+
+func (fp *funcParser) declareValue(tok token, v *ossa.Value) error {
+	if _, exists := fp.values[tok.text]; exists {
+		return fmt.Errorf("%s: duplicate definition of value %s", tok.pos, tok.text)
+	}
+	fp.values[tok.text] = v
+	v.SetName(labelName(tok.text, "v"))
+	return nil
+}
+
+// labelName returns the name included in the given label if it has the form
+// that the printer generates for named values or blocks, which is the given
+// prefix followed by a number, a dot, and then the name. Otherwise it
+// returns an empty string, because arbitrary labels in handwritten source
+// are not names.
+func labelName(label, prefix string) string {
+	rest := strings.TrimPrefix(label, prefix)
+	if len(rest) == len(label) {
+		return ""
+	}
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || digits+1 >= len(rest) || rest[digits] != '.' {
+		return ""
+	}
+	return rest[digits+1:]
+}
+
+// block returns the block with the given label, creating it if necessary.
+func (fp *funcParser) block(label string) *ossa.BasicBlock {
+	if b, exists := fp.blocks[label]; exists {
+		return b
+	}
+	b := ossa.NewBasicBlock()
+	fp.blocks[label] = b
+	return b
+}
+
+func (fp *funcParser) parseBlockRef() (*ossa.BasicBlock, error) {
+	// In LLVM syntax, block references are preceded by "label". A block
+	// that is itself named "label" is never followed by another identifier.
+	if fp.peekKeyword("label") && fp.peekAt(1).typ == tokenIdent {
+		fp.next()
+	}
+	tok, err := fp.expect(tokenIdent, "")
+	if err != nil {
+		return nil, err
+	}
+	return fp.block(tok.text), nil
+}
+
+// parseTarget parses a reference to a target of the terminator of the given
+// block, along with any Phi candidates for that edge that are written after
+// it in the form produced by PrintConfig.EdgePhis.
+func (fp *funcParser) parseTarget(from *ossa.BasicBlock) (*ossa.BasicBlock, error) {
+	target, err := fp.parseBlockRef()
+	if err != nil || !fp.peekPunct("(") {
+		return target, err
+	}
+	fp.next()
+	for {
+		phiTok, err := fp.expect(tokenIdent, "")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fp.expect(tokenPunct, "="); err != nil {
+			return nil, err
+		}
+		ref, err := fp.parseOperandRef()
+		if err != nil {
+			return nil, err
+		}
+		fp.fixups = append(fp.fixups, func() error {
+			phi := fp.values[phiTok.text]
+			if phi == nil || phi.Op() != ossa.OpPhi || !hasInstruction(target, phi) {
+				return fmt.Errorf("%s: %s is not a Phi node in the target block", phiTok.pos, phiTok.text)
+			}
+			v, err := fp.resolve(ref)
+			if err != nil {
+				return err
+			}
+			phi.AddPhiCandidate(ossa.BasicBlockValue{Block: from, Value: v})
+			return nil
+		})
+		if !fp.peekPunct(",") {
+			break
+		}
+		fp.next()
+	}
+	if _, err := fp.expect(tokenPunct, ")"); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+func hasInstruction(block *ossa.BasicBlock, v *ossa.Value) bool {
+	for _, inst := range block.Instructions {
+		if inst == v {
+			return true
+		}
+	}
+	return false
+}
+
+// operandRef is a reference to a value that may not have been declared yet.
+type operandRef struct {
+	tok token
+}
+
+func (fp *funcParser) parseOperandRef() (operandRef, error) {
+	tok := fp.next()
+	switch tok.typ {
+	case tokenIdent:
+		return operandRef{tok}, nil
+	case tokenGlobal:
+		if fp.mod == nil {
+			return operandRef{}, fmt.Errorf("%s: global references are allowed only in modules", tok.pos)
+		}
+		if fp.mod.Global(tok.text) == nil {
+			return operandRef{}, fmt.Errorf("%s: reference to undeclared global @%s", tok.pos, tok.text)
+		}
+		return operandRef{tok}, nil
+	default:
+		return operandRef{}, fmt.Errorf("%s: expected value", tok.pos)
+	}
+}
+
+// resolve returns the value that the given reference refers to. It must be
+// called only from a fixup, once all values have been declared.
+func (fp *funcParser) resolve(ref operandRef) (*ossa.Value, error) {
+	if ref.tok.typ == tokenGlobal {
+		return fp.mod.Global(ref.tok.text), nil
+	}
+	if ref.tok.text == "void" {
+		return nil, nil
+	}
+	v, exists := fp.values[ref.tok.text]
+	if !exists {
+		return nil, fmt.Errorf("%s: reference to undefined value %s", ref.tok.pos, ref.tok.text)
+	}
+	return v, nil
+}
+
+// parseOperandList parses a comma-separated list of operands up to the end of
+// the current line.
+func (fp *funcParser) parseOperandList() ([]operandRef, error) {
+	var refs []operandRef
+	for fp.peek().typ != tokenNewline {
+		if len(refs) > 0 {
+			if _, err := fp.expect(tokenPunct, ","); err != nil {
+				return nil, err
+			}
+		}
+		ref, err := fp.parseOperandRef()
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// parseCallOperands parses the callee and arguments of a Call up to the end
+// of the current line, written either as a single comma-separated list or,
+// in LLVM syntax, with the arguments in parentheses after the callee.
+func (fp *funcParser) parseCallOperands() ([]operandRef, error) {
+	if next := fp.peekAt(1); fp.peek().typ == tokenNewline || next.typ != tokenPunct || next.text != "(" {
+		return fp.parseOperandList()
+	}
+	callee, err := fp.parseOperandRef()
+	if err != nil {
+		return nil, err
+	}
+	fp.next() // the opening paren
+	refs := []operandRef{callee}
+	for !fp.peekPunct(")") {
+		if len(refs) > 1 {
+			if _, err := fp.expect(tokenPunct, ","); err != nil {
+				return nil, err
+			}
+		}
+		ref, err := fp.parseOperandRef()
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	fp.next() // the closing paren
+	return refs, nil
+}
+
+// setArgsLater registers a fixup to populate the arguments of the given value
+// from the given references.
+func (fp *funcParser) setArgsLater(v *ossa.Value, refs []operandRef) {
+	fp.fixups = append(fp.fixups, func() error {
+		for i, ref := range refs {
+			arg, err := fp.resolve(ref)
+			if err != nil {
+				return err
+			}
+			v.SetArg(i, arg)
+		}
+		return nil
+	})
+}
+
+func (fp *funcParser) parseValue() (*ossa.Value, error) {
+	opTok, err := fp.expect(tokenIdent, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var v *ossa.Value
+	switch name := canonicalOpName(opTok); name {
+	case "GlobalSym":
+		v = ossa.GlobalSym()
+	case "LocalSym":
+		v = ossa.LocalSym()
+	case "Argument":
+		v = ossa.Argument()
+	case "Undef":
+		v = ossa.Undef()
+	case "Poison":
+		v = ossa.Poison()
+	case "LandingPad":
+		v = ossa.LandingPad()
+	case "AuxLiteral":
+		aux, err := fp.parseAux()
+		if err != nil {
+			return nil, err
+		}
+		v = ossa.AuxLiteral(aux)
+	case "Phi":
+		var cands []ossa.BasicBlockValue
+		var refs []operandRef
+		for fp.peekPunct("[") {
+			fp.next()
+			block, ref, err := fp.parsePhiCandidate()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := fp.expect(tokenPunct, "]"); err != nil {
+				return nil, err
+			}
+			cands = append(cands, ossa.BasicBlockValue{Block: block})
+			refs = append(refs, ref)
+			if fp.peekPunct(",") && fp.peekAt(1).typ == tokenPunct && fp.peekAt(1).text == "[" {
+				fp.next() // LLVM syntax separates candidates with commas
+			}
+		}
+		v = ossa.Phi(cands...)
+		fp.setArgsLater(v, refs)
+	case "Load", "Store", "Call", "Select":
+		var refs []operandRef
+		var err error
+		if name == "Call" {
+			refs, err = fp.parseCallOperands()
+		} else {
+			refs, err = fp.parseOperandList()
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case name == "Load" && len(refs) == 1:
+			v = ossa.Load(nil)
+		case name == "Store" && len(refs) == 2:
+			v = ossa.Store(nil, nil)
+		case name == "Select" && len(refs) == 3:
+			v = ossa.Select(nil, nil, nil)
+		case name == "Call" && len(refs) >= 1:
+			v = ossa.Call(nil, make([]*ossa.Value, len(refs)-1)...)
+		default:
+			return nil, fmt.Errorf("%s: wrong number of operands for %s", opTok.pos, opTok.text)
+		}
+		fp.setArgsLater(v, refs)
+	default:
+		op, registered := ossa.LookupOp(opTok.text)
+		if !registered || !op.Value() {
+			return nil, fmt.Errorf("%s: unsupported value operation %q", opTok.pos, opTok.text)
+		}
+		refs, err := fp.parseOperandList()
+		if err != nil {
+			return nil, err
+		}
+		if spec, _ := op.Spec(); spec.Arity >= 0 && len(refs) != spec.Arity {
+			return nil, fmt.Errorf("%s: wrong number of operands for %s", opTok.pos, opTok.text)
+		}
+		v = ossa.NewValue(op, make([]*ossa.Value, len(refs))...)
+		fp.setArgsLater(v, refs)
+	}
+
+	if err := fp.expectNewline(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// parsePhiCandidate parses the content of the brackets around a Phi
+// candidate, which is a block and a value separated by a colon or, in LLVM
+// syntax, a value and a block separated by a comma.
+func (fp *funcParser) parsePhiCandidate() (*ossa.BasicBlock, operandRef, error) {
+	if next := fp.peekAt(1); next.typ == tokenPunct && next.text == "," {
+		ref, err := fp.parseOperandRef()
+		if err != nil {
+			return nil, operandRef{}, err
+		}
+		fp.next() // the comma
+		block, err := fp.parseBlockRef()
+		return block, ref, err
+	}
+	block, err := fp.parseBlockRef()
+	if err != nil {
+		return nil, operandRef{}, err
+	}
+	if _, err := fp.expect(tokenPunct, ":"); err != nil {
+		return nil, operandRef{}, err
+	}
+	ref, err := fp.parseOperandRef()
+	return block, ref, err
+}
+
+func (fp *funcParser) parseAux() (interface{}, error) {
+	tok := fp.next()
+	switch tok.typ {
+	case tokenString:
+		return tok.text, nil
+	case tokenIdent:
+		switch tok.text {
+		case "nil":
+			return ossa.NilConst, nil
+		case "true":
+			return ossa.BoolConst(true), nil
+		case "false":
+			return ossa.BoolConst(false), nil
+		case "pool":
+			if fp.mod == nil {
+				return nil, fmt.Errorf("%s: pool references are allowed only in modules", tok.pos)
+			}
+			numTok, err := fp.expect(tokenNumber, "")
+			if err != nil {
+				return nil, err
+			}
+			i, err := strconv.Atoi(numTok.text)
+			if err != nil || i < 0 || i >= len(fp.pool) {
+				return nil, fmt.Errorf("%s: reference to undeclared pool entry %s", numTok.pos, numTok.text)
+			}
+			return fp.pool[i], nil
+		}
+	case tokenNumber:
+		if !strings.ContainsAny(tok.text, ".eE") {
+			i, ok := new(big.Int).SetString(tok.text, 10)
+			if !ok {
+				return nil, fmt.Errorf("%s: invalid integer %q", tok.pos, tok.text)
+			}
+			return ossa.IntConst(i), nil
+		}
+		f, _, err := new(big.Float).SetPrec(53).Parse(tok.text, 10)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid number %q: %s", tok.pos, tok.text, err)
+		}
+		return ossa.FloatConst(f), nil
+	}
+	return nil, fmt.Errorf("%s: expected literal value", tok.pos)
+}
+
+func (fp *funcParser) parseTerminator(opTok token, block *ossa.BasicBlock) error {
+	// Terminators may refer to values declared later in the source, so we
+	// construct them only once all of the values are declared.
+	var build func() (*ossa.Terminator, error)
+
+	name := canonicalOpName(opTok)
+	if name == "br" {
+		// LLVM syntax writes both Jump and Branch as "br", but only a
+		// Branch has a condition before its first target.
+		name = "Branch"
+		if fp.peekKeyword("label") {
+			name = "Jump"
+		}
+	}
+	switch name {
+	case "Jump", "Yield":
+		target, err := fp.parseTarget(block)
+		if err != nil {
+			return err
+		}
+		build = func() (*ossa.Terminator, error) {
+			if name == "Jump" {
+				return ossa.Jump(target), nil
+			}
+			return ossa.Yield(target), nil
+		}
+	case "Branch", "Invoke":
+		ref, err := fp.parseOperandRef()
+		if err != nil {
+			return err
+		}
+		// LLVM syntax introduces the targets of an Invoke with keywords.
+		if name != "Invoke" || !fp.acceptKeyword("to") {
+			if _, err := fp.expect(tokenPunct, ","); err != nil {
+				return err
+			}
+		}
+		trueTarget, err := fp.parseTarget(block)
+		if err != nil {
+			return err
+		}
+		if name != "Invoke" || !fp.acceptKeyword("unwind") {
+			if _, err := fp.expect(tokenPunct, ","); err != nil {
+				return err
+			}
+		}
+		falseTarget, err := fp.parseTarget(block)
+		if err != nil {
+			return err
+		}
+		build = func() (*ossa.Terminator, error) {
+			cond, err := fp.resolve(ref)
+			if err != nil {
+				return nil, err
+			}
+			if name == "Invoke" {
+				insts := block.Instructions
+				if cond == nil || cond.Op() != ossa.OpCall || len(insts) == 0 || insts[len(insts)-1] != cond {
+					return nil, fmt.Errorf("%s: Invoke operand must be the Call immediately before it", opTok.pos)
+				}
+				return ossa.Invoke(cond, trueTarget, falseTarget), nil
+			}
+			return ossa.Branch(cond, trueTarget, falseTarget), nil
+		}
+	case "Switch":
+		ref, err := fp.parseOperandRef()
+		if err != nil {
+			return err
+		}
+		if _, err := fp.expect(tokenPunct, ","); err != nil {
+			return err
+		}
+		defTarget, err := fp.parseTarget(block)
+		if err != nil {
+			return err
+		}
+		var caseRefs []operandRef
+		var caseTargets []*ossa.BasicBlock
+		for fp.peekPunct("[") {
+			fp.next()
+			// LLVM syntax writes all of the cases in one pair of brackets,
+			// with a comma between each value and its target.
+			llvm := fp.peekAt(1).typ == tokenPunct && fp.peekAt(1).text == ","
+			for {
+				caseRef, err := fp.parseOperandRef()
+				if err != nil {
+					return err
+				}
+				sep := ":"
+				if llvm {
+					sep = ","
+				}
+				if _, err := fp.expect(tokenPunct, sep); err != nil {
+					return err
+				}
+				target, err := fp.parseTarget(block)
+				if err != nil {
+					return err
+				}
+				caseRefs = append(caseRefs, caseRef)
+				caseTargets = append(caseTargets, target)
+				if !llvm || fp.peekPunct("]") {
+					break
+				}
+			}
+			if _, err := fp.expect(tokenPunct, "]"); err != nil {
+				return err
+			}
+		}
+		build = func() (*ossa.Terminator, error) {
+			inp, err := fp.resolve(ref)
+			if err != nil {
+				return nil, err
+			}
+			cases := make([]ossa.BasicBlockValue, len(caseRefs))
+			for i, caseRef := range caseRefs {
+				v, err := fp.resolve(caseRef)
+				if err != nil {
+					return nil, err
+				}
+				cases[i] = ossa.BasicBlockValue{Block: caseTargets[i], Value: v}
+			}
+			return ossa.Switch(inp, defTarget, cases...), nil
+		}
+	case "Return":
+		refs, err := fp.parseOperandList()
+		if err != nil {
+			return err
+		}
+		build = func() (*ossa.Terminator, error) {
+			vals := make([]*ossa.Value, len(refs))
+			for i, ref := range refs {
+				v, err := fp.resolve(ref)
+				if err != nil {
+					return nil, err
+				}
+				if v == nil && len(refs) > 1 {
+					return nil, fmt.Errorf("%s: Return values must not be void", opTok.pos)
+				}
+				vals[i] = v
+			}
+			return ossa.Return(vals...), nil
+		}
+	case "Resume":
+		ref, err := fp.parseOperandRef()
+		if err != nil {
+			return err
+		}
+		build = func() (*ossa.Terminator, error) {
+			exc, err := fp.resolve(ref)
+			if err != nil {
+				return nil, err
+			}
+			return ossa.Resume(exc), nil
+		}
+	case "Await":
+		ref, err := fp.parseOperandRef()
+		if err != nil {
+			return err
+		}
+		if _, err := fp.expect(tokenPunct, ","); err != nil {
+			return err
+		}
+		resume, err := fp.parseTarget(block)
+		if err != nil {
+			return err
+		}
+		build = func() (*ossa.Terminator, error) {
+			event, err := fp.resolve(ref)
+			if err != nil {
+				return nil, err
+			}
+			return ossa.Await(event, resume), nil
+		}
+	case "Unreachable":
+		build = func() (*ossa.Terminator, error) {
+			return ossa.Unreachable, nil
+		}
+	default:
+		op, registered := ossa.LookupOp(opTok.text)
+		if !registered || !op.Terminator() {
+			return fmt.Errorf("%s: unsupported terminator operation %q", opTok.pos, opTok.text)
+		}
+		// Each argument of a registered terminator is written as either
+		// [value] or [value: block].
+		var refs []operandRef
+		var targets []*ossa.BasicBlock
+		for fp.peekPunct("[") {
+			fp.next()
+			ref, err := fp.parseOperandRef()
+			if err != nil {
+				return err
+			}
+			var target *ossa.BasicBlock
+			if fp.peekPunct(":") {
+				fp.next()
+				target, err = fp.parseTarget(block)
+				if err != nil {
+					return err
+				}
+			}
+			if _, err := fp.expect(tokenPunct, "]"); err != nil {
+				return err
+			}
+			refs = append(refs, ref)
+			targets = append(targets, target)
+		}
+		if spec, _ := op.Spec(); spec.Arity >= 0 && len(refs) != spec.Arity {
+			return fmt.Errorf("%s: wrong number of arguments for %s", opTok.pos, opTok.text)
+		}
+		build = func() (*ossa.Terminator, error) {
+			args := make([]ossa.BasicBlockValue, len(refs))
+			for i, ref := range refs {
+				v, err := fp.resolve(ref)
+				if err != nil {
+					return nil, err
+				}
+				args[i] = ossa.BasicBlockValue{Value: v, Block: targets[i]}
+			}
+			return ossa.NewTerminator(op, args...), nil
+		}
+	}
+
+	if err := fp.expectNewline(); err != nil {
+		return err
+	}
+
+	// We need to mark the block as terminated immediately so that we can
+	// detect instructions appearing after the terminator, so we'll use
+	// Unreachable as a placeholder until the fixup runs.
+	block.Terminator = ossa.Unreachable
+	fp.fixups = append(fp.fixups, func() error {
+		t, err := build()
+		if err != nil {
+			return err
+		}
+		block.Terminator = t
+		return nil
+	})
+	return nil
+}
+
+// llvmOpNames maps the names that PrintConfig.LLVMSyntax writes for built-in
+// operations to their names in the default syntax. Jump and Branch are both
+// written as "br", which parseTerminator handles itself.
+var llvmOpNames = map[string]string{
+	"globalsym":   "GlobalSym",
+	"localsym":    "LocalSym",
+	"argument":    "Argument",
+	"auxliteral":  "AuxLiteral",
+	"undef":       "Undef",
+	"poison":      "Poison",
+	"phi":         "Phi",
+	"load":        "Load",
+	"store":       "Store",
+	"call":        "Call",
+	"select":      "Select",
+	"landingpad":  "LandingPad",
+	"switch":      "Switch",
+	"ret":         "Return",
+	"yield":       "Yield",
+	"await":       "Await",
+	"invoke":      "Invoke",
+	"resume":      "Resume",
+	"unreachable": "Unreachable",
+}
+
+// canonicalOpName returns the default syntax name of the operation written
+// as the given token, translating the names written by
+// PrintConfig.LLVMSyntax unless a registered operation has the same name.
+func canonicalOpName(tok token) string {
+	if _, registered := ossa.LookupOp(tok.text); registered {
+		return tok.text
+	}
+	if name, ok := llvmOpNames[tok.text]; ok {
+		return name
+	}
+	return tok.text
+}
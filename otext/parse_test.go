@@ -0,0 +1,255 @@
+package otext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestParseFunction(t *testing.T) {
+	// This uses arbitrary names for values and blocks, and declares them in
+	// an unusual order, so the printed result should be the same function
+	// but in canonical form.
+	src := `
+// Counts up to the given limit.
+func(limit) {
+    one = AuxLiteral 1
+    zero = AuxLiteral 0
+    lt = AuxLiteral "lt"
+    add = AuxLiteral "add"
+entry:
+    Jump header
+header:
+    cur = Phi [entry: zero] [body: next]
+    cond = Call lt, cur, limit
+    Branch cond, body, exit
+exit:
+    Return cur
+body:
+    next = Call add, cur, one
+    Jump header
+}
+`
+	f, err := ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := SprintFunction(f)
+	want := `func(v0) {
+    v1 = AuxLiteral 0
+    v2 = AuxLiteral "lt"
+    v3 = AuxLiteral "add"
+    v4 = AuxLiteral 1
+b0:
+    Jump b1
+b1:
+    v5 = Phi [b0: v1] [b3: v7]
+    v6 = Call v2, v5, v0
+    Branch v6, b3, b2
+b2:
+    Return v5
+b3:
+    v7 = Call v3, v5, v4
+    Jump b1
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseModuleRoundTrip(t *testing.T) {
+	src := `pool 0 = "hello, world"
+pool 1 = "\x00\x01\xff"
+global @counter
+global @"not an identifier"
+
+func @caller(v0) {
+    v1 = AuxLiteral -1.5e-07
+    v2 = AuxLiteral pool 1
+    v3 = AuxLiteral true
+b0:
+    v4 = Call @callee, v1, v2, @"not an identifier"
+    Switch v0, b1 [v3: b2]
+b1:
+    v5 = Load @counter
+    Await v5, b2
+b2:
+    v6 = Phi [b0: v4] [b1: v5]
+    v7 = Store v6, @counter
+    Yield b3
+b3:
+    Unreachable
+}
+
+func @callee(v0, v1, v2) {
+    v3 = Undef
+    v4 = Poison
+b0:
+    v5 = Select v0, v3, v4
+    Return v5
+}
+
+func @swap(v0, v1) {
+b0:
+    Return v1, v0
+}
+
+func @thrower() {
+b0:
+    v0 = Call @callee
+    Invoke v0, b1, b2
+b1:
+    Return v0
+b2:
+    v1 = LandingPad
+    Resume v1
+}
+`
+	m, err := ParseModule([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := SprintModule(m)
+	if got != src {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, src)
+	}
+}
+
+func TestParseRegisteredOps(t *testing.T) {
+	ossa.RegisterOp("TestParseAdd", ossa.OpSpec{Arity: 2})
+	ossa.RegisterOp("TestParseCheck", ossa.OpSpec{Terminator: true, Arity: -1})
+
+	src := `func(v0, v1) {
+b0:
+    v2 = TestParseAdd v0, v1
+    TestParseCheck [v2: b1] [v1] [void: b2]
+b1:
+    Return v2
+b2:
+    Unreachable
+}
+`
+	f, err := ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := SprintFunction(f)
+	if got != src {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, src)
+	}
+	if got, want := len(f.Entry().Terminator.AppendSuccessors(nil)), 2; got != want {
+		t.Errorf("wrong number of successors %d; want %d", got, want)
+	}
+
+	_, err = ParseFunction([]byte("func(v0) {\nb0:\n    v1 = TestParseAdd v0\n    Return\n}\n"))
+	if err == nil || !strings.Contains(err.Error(), "3:10: wrong number of operands for TestParseAdd") {
+		t.Errorf("wrong error for bad arity: %v", err)
+	}
+}
+
+func TestParseFunctionErrors(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want string
+	}{
+		"undefined value": {
+			"func() {\nb0:\n    Return v1\n}\n",
+			"3:12: reference to undefined value v1",
+		},
+		"undefined block": {
+			"func() {\nb0:\n    Jump b1\n}\n",
+			"reference to undefined block b1",
+		},
+		"duplicate value": {
+			"func(v0) {\n    v0 = LocalSym\nb0:\n    Return\n}\n",
+			"2:5: duplicate definition of value v0",
+		},
+		"instruction after terminator": {
+			"func() {\nb0:\n    Return\n    v0 = Load v0\n}\n",
+			"4:5: instruction after terminator",
+		},
+		"global in function": {
+			"func() {\nb0:\n    Return @foo\n}\n",
+			"3:12: global references are allowed only in modules",
+		},
+		"wrong operand count": {
+			"func(v0) {\nb0:\n    v1 = Load v0, v0\n    Return\n}\n",
+			"3:10: wrong number of operands for Load",
+		},
+		"unparseable literal": {
+			"func() {\n    v0 = AuxLiteral <5>\nb0:\n    Return\n}\n",
+			"2:21: unexpected character '<'",
+		},
+		"no blocks": {
+			"func() {\n}\n",
+			"2:1: function must have at least one block",
+		},
+		"Invoke without a Call": {
+			"func(v0) {\nb0:\n    v1 = Load v0\n    Invoke v1, b1, b1\nb1:\n    Return\n}\n",
+			"4:5: Invoke operand must be the Call immediately before it",
+		},
+		"Invoke with instructions after the Call": {
+			"func(v0) {\nb0:\n    v1 = Call v0\n    v2 = Load v0\n    Invoke v1, b1, b1\nb1:\n    Return\n}\n",
+			"5:5: Invoke operand must be the Call immediately before it",
+		},
+		"void among Return values": {
+			"func(v0) {\nb0:\n    Return v0, void\n}\n",
+			"3:5: Return values must not be void",
+		},
+		"pool in function": {
+			"func() {\n    v0 = AuxLiteral pool 0\nb0:\n    Return\n}\n",
+			"2:21: pool references are allowed only in modules",
+		},
+		"edge assignment to non-Phi": {
+			"func(v0) {\nb0:\n    Jump b1 (v0 = v0)\nb1:\n    Return\n}\n",
+			"3:14: v0 is not a Phi node in the target block",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseFunction([]byte(test.src))
+			if err == nil {
+				t.Fatalf("unexpected success; want error %q", test.want)
+			}
+			if got := err.Error(); !strings.Contains(got, test.want) {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseModulePoolErrors(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want string
+	}{
+		"out of order": {
+			"pool 1 = \"a\"\n",
+			"1:6: pool entry should be numbered 0",
+		},
+		"duplicate data": {
+			"pool 0 = \"a\"\npool 1 = \"a\"\n",
+			"2:6: duplicate data for pool entry 1",
+		},
+		"undeclared entry": {
+			"pool 0 = \"a\"\n\nfunc @f() {\n    v0 = AuxLiteral pool 1\nb0:\n    Return\n}\n",
+			"4:26: reference to undeclared pool entry 1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseModule([]byte(test.src))
+			if err == nil {
+				t.Fatalf("unexpected success; want error %q", test.want)
+			}
+			if got := err.Error(); !strings.Contains(got, test.want) {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,635 @@
+package otext
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/alamatic/ossa"
+)
+
+// FprintFunction writes a textual representation of the given function to
+// the given writer.
+//
+// Blocks are labelled b0, b1, etc in the order they are owned by the
+// function, and so the entry block is always b0. Values are named v0, v1,
+// etc, starting with the function's parameters, then any operand values that
+// are not instructions in any of the function's blocks (such as literals and
+// symbols), and finally the instructions of each block in order. The result
+// is therefore deterministic for a given function.
+//
+// A block or value that has a name is labelled with its name after its
+// generated label and a dot, such as "b1.loop" or "v3.count", so that the
+// labels remain unique even if names are not.
+//
+// FprintFunction uses the default configuration. Use a PrintConfig to
+// select other options.
+func FprintFunction(w io.Writer, f *ossa.Function) error {
+	return PrintConfig{}.FprintFunction(w, f)
+}
+
+// FprintModule writes a textual representation of the given module to the
+// given writer.
+//
+// Global symbols are referred to by their names, prefixed with "@". Each
+// function is printed in the same way as for FprintFunction, except that
+// references to the module's global symbols use their names.
+//
+// The entries of the module's constant pool are written first, each as a
+// line such as "pool 0 = \"hello\"", and an AuxLiteral whose aux value is
+// an ossa.PoolRef is written using the index of its entry, as in
+// "AuxLiteral pool 0".
+func FprintModule(w io.Writer, m *ossa.Module) error {
+	return PrintConfig{}.FprintModule(w, m)
+}
+
+// SprintFunction is like FprintFunction but returns the result as a string.
+func SprintFunction(f *ossa.Function) string {
+	return PrintConfig{}.SprintFunction(f)
+}
+
+// SprintModule is like FprintModule but returns the result as a string.
+func SprintModule(m *ossa.Module) string {
+	return PrintConfig{}.SprintModule(m)
+}
+
+// PrintConfig controls optional aspects of the textual representation
+// produced by the printer. The zero value is the default configuration used
+// by the package-level printing functions.
+//
+// The parser accepts the output of every configuration, so fixtures can be
+// written in whichever form is most readable for a particular test.
+type PrintConfig struct {
+	// Verbose adds a comment after each block label listing the block's
+	// predecessors, in the order they are owned by the function.
+	Verbose bool
+
+	// EdgePhis writes the candidates of each Phi node on the terminators
+	// of its predecessors rather than on the Phi node itself, so that the
+	// values flowing along each edge are visible where the edge begins.
+	// A terminator target is then followed by a parenthesized list of
+	// assignments, such as "Jump b2 (v5 = v3)".
+	//
+	// A candidate whose block does not have a terminator that targets the
+	// Phi node's block is still written on the Phi node, so that no
+	// information is lost. When parsed, the candidates written on
+	// terminators are added after any written on the Phi node, in the order
+	// the terminators appear in the source.
+	EdgePhis bool
+
+	// LLVMSyntax writes functions in a syntax resembling LLVM's textual IR,
+	// for readers who are more familiar with that format. Functions begin
+	// with "define", value names have a "%" prefix, operations other than
+	// registered ones are written in lower case, and block references are
+	// written as "label %b1", as in "br %v1, label %b1, label %b2". Jump
+	// and Branch are both written as "br" and Return as "ret". Phi
+	// candidates put the value first, as in "phi [ %v2, %b0 ], [ %v4, %b1 ]",
+	// the arguments of a call follow its callee in parentheses, as in
+	// "call %v1(%v2, %v3)", and an Invoke is written as
+	// "invoke %v5 to label %b2 unwind label %b3". Verbose comments begin
+	// with ";" rather than "//".
+	//
+	// The names of blocks, values and operations are otherwise the same as
+	// in the default syntax, and so the parser accepts the two syntaxes
+	// mixed together.
+	LLVMSyntax bool
+}
+
+// FprintFunction is like the package-level function of the same name, but
+// uses the receiving configuration.
+func (c PrintConfig) FprintFunction(w io.Writer, f *ossa.Function) error {
+	var buf bytes.Buffer
+	p := newPrinter(&buf, nil, c)
+	p.printFunction("", f)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// FprintModule is like the package-level function of the same name, but
+// uses the receiving configuration.
+func (c PrintConfig) FprintModule(w io.Writer, m *ossa.Module) error {
+	var buf bytes.Buffer
+	p := newPrinter(&buf, m, c)
+	p.printModule()
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// SprintFunction is like the package-level function of the same name, but
+// uses the receiving configuration.
+func (c PrintConfig) SprintFunction(f *ossa.Function) string {
+	var buf strings.Builder
+	c.FprintFunction(&buf, f) // writing to a strings.Builder cannot fail
+	return buf.String()
+}
+
+// SprintModule is like the package-level function of the same name, but
+// uses the receiving configuration.
+func (c PrintConfig) SprintModule(m *ossa.Module) string {
+	var buf strings.Builder
+	c.FprintModule(&buf, m) // writing to a strings.Builder cannot fail
+	return buf.String()
+}
+
+type printer struct {
+	buf    *bytes.Buffer
+	mod    *ossa.Module
+	config PrintConfig
+
+	// valueNames, blockNames and preds are reset for each function.
+	valueNames map[*ossa.Value]string
+	blockNames map[*ossa.BasicBlock]string
+
+	// preds records the predecessors of each block in the current
+	// function, in the order they are owned by the function.
+	preds map[*ossa.BasicBlock][]*ossa.BasicBlock
+}
+
+func newPrinter(buf *bytes.Buffer, mod *ossa.Module, config PrintConfig) *printer {
+	return &printer{
+		buf:    buf,
+		mod:    mod,
+		config: config,
+	}
+}
+
+func (p *printer) printModule() {
+	for _, ref := range p.mod.AppendPoolRefs(nil) {
+		data, _ := p.mod.PoolData(ref)
+		fmt.Fprintf(p.buf, "pool %d = %s\n", ref.Index(), strconv.Quote(data))
+	}
+	names := p.mod.AppendGlobalNames(nil)
+	for _, name := range names {
+		if p.mod.Function(name) == nil {
+			fmt.Fprintf(p.buf, "global %s\n", globalName(name))
+		}
+	}
+	for _, name := range names {
+		f := p.mod.Function(name)
+		if f == nil {
+			continue
+		}
+		p.buf.WriteByte('\n')
+		p.printFunction(globalName(name), f)
+	}
+}
+
+func (p *printer) printFunction(name string, f *ossa.Function) {
+	blocks := f.AppendBlocks(nil)
+	free := p.assignNames(f, blocks)
+	p.preds = make(map[*ossa.BasicBlock][]*ossa.BasicBlock)
+	for _, block := range blocks {
+		for _, succ := range uniqueSuccessors(block) {
+			p.preds[succ] = append(p.preds[succ], block)
+		}
+	}
+
+	if p.config.LLVMSyntax {
+		p.buf.WriteString("define")
+	} else {
+		p.buf.WriteString("func")
+	}
+	if name != "" {
+		p.buf.WriteByte(' ')
+		p.buf.WriteString(name)
+	}
+	p.buf.WriteByte('(')
+	for i, param := range f.Params {
+		if i > 0 {
+			p.buf.WriteString(", ")
+		}
+		p.buf.WriteString(p.operand(param))
+	}
+	p.buf.WriteString(") {\n")
+
+	for _, v := range free {
+		p.buf.WriteString("    ")
+		p.printValue(v, nil)
+		p.buf.WriteByte('\n')
+	}
+	for _, block := range blocks {
+		p.buf.WriteString(p.blockNames[block])
+		p.buf.WriteByte(':')
+		if p.config.Verbose {
+			p.printPredsComment(block, block == f.Entry())
+		}
+		p.buf.WriteByte('\n')
+		for _, v := range block.Instructions {
+			p.buf.WriteString("    ")
+			p.printValue(v, block)
+			p.buf.WriteByte('\n')
+		}
+		if block.Terminator != nil {
+			p.buf.WriteString("    ")
+			p.printTerminator(block)
+			p.buf.WriteByte('\n')
+		}
+	}
+	p.buf.WriteString("}\n")
+}
+
+// assignNames populates the printer's name tables for the given function,
+// returning the values that need to be declared before the first block
+// because they are not parameters or instructions.
+func (p *printer) assignNames(f *ossa.Function, blocks []*ossa.BasicBlock) []*ossa.Value {
+	p.valueNames = make(map[*ossa.Value]string)
+	p.blockNames = make(map[*ossa.BasicBlock]string)
+
+	for _, block := range blocks {
+		p.nameBlock(block)
+	}
+
+	next := 0
+	nameValue := func(v *ossa.Value) {
+		p.valueNames[v] = "v" + strconv.Itoa(next) + nameSuffix(v.Name())
+		next++
+	}
+
+	for _, param := range f.Params {
+		nameValue(param)
+	}
+
+	// Instructions get their names only after the free values, but we need
+	// to know which values are instructions in order to find the free ones.
+	insts := make(ossa.ValueSet)
+	for _, block := range blocks {
+		for _, v := range block.Instructions {
+			insts.Add(v)
+		}
+	}
+	var free []*ossa.Value
+	var visitFree func(v *ossa.Value)
+	visitFree = func(v *ossa.Value) {
+		if v == nil || insts.Has(v) {
+			return
+		}
+		if _, named := p.valueNames[v]; named {
+			return
+		}
+		if p.isModuleGlobal(v) {
+			return
+		}
+		// Operands of a free value must be declared before it.
+		for i := 0; i < v.NumArgs(); i++ {
+			visitFree(v.Arg(i))
+		}
+		nameValue(v)
+		free = append(free, v)
+	}
+	visitOperands := func(v *ossa.Value) {
+		for i := 0; i < v.NumArgs(); i++ {
+			visitFree(v.Arg(i))
+		}
+		if v.Op() == ossa.OpPhi {
+			for i := 0; i < v.NumArgs(); i++ {
+				p.nameBlock(v.PhiCandidate(i).Block)
+			}
+		}
+	}
+	for _, block := range blocks {
+		for _, v := range block.Instructions {
+			visitOperands(v)
+		}
+		if t := block.Terminator; t != nil {
+			for i := 0; i < t.NumArgs(); i++ {
+				arg := t.Arg(i)
+				visitFree(arg.Value)
+				if arg.Block != nil {
+					p.nameBlock(arg.Block)
+				}
+			}
+		}
+	}
+
+	for _, block := range blocks {
+		for _, v := range block.Instructions {
+			nameValue(v)
+		}
+	}
+
+	return free
+}
+
+func (p *printer) nameBlock(block *ossa.BasicBlock) {
+	if _, named := p.blockNames[block]; named {
+		return
+	}
+	p.blockNames[block] = "b" + strconv.Itoa(len(p.blockNames)) + nameSuffix(block.Name)
+}
+
+// nameSuffix returns the suffix to add to a generated label to include the
+// given name, which is either empty or a dot followed by the name. Any
+// characters that are not allowed in identifiers are replaced with
+// underscores, so such names do not survive a round trip unchanged.
+func nameSuffix(name string) string {
+	if name == "" {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteByte('.')
+	for _, r := range name {
+		switch {
+		case r == '_' || r == '.':
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		default:
+			r = '_'
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func (p *printer) isModuleGlobal(v *ossa.Value) bool {
+	if p.mod == nil {
+		return false
+	}
+	_, ok := p.mod.GlobalName(v)
+	return ok
+}
+
+// printPredsComment writes a comment listing the predecessors of the given
+// block, for verbose mode.
+func (p *printer) printPredsComment(block *ossa.BasicBlock, entry bool) {
+	preds := p.preds[block]
+	comment, sep := " // ", ":"
+	if p.config.LLVMSyntax {
+		comment, sep = " ; ", " ="
+	}
+	switch {
+	case len(preds) == 0 && entry:
+		return
+	case len(preds) == 0:
+		p.buf.WriteString(comment + "no predecessors")
+		return
+	}
+	p.buf.WriteString(comment + "preds" + sep)
+	for i, pred := range preds {
+		if i > 0 {
+			p.buf.WriteByte(',')
+		}
+		p.buf.WriteByte(' ')
+		p.buf.WriteString(p.blockRef(pred))
+	}
+}
+
+// printValue writes the given value, which is an instruction in the given
+// block or a free value if the block is nil.
+func (p *printer) printValue(v *ossa.Value, block *ossa.BasicBlock) {
+	p.buf.WriteString(p.operand(v))
+	p.buf.WriteString(" = ")
+	p.buf.WriteString(p.opName(v.Op()))
+	switch {
+	case v.Op() == ossa.OpAuxLiteral:
+		p.buf.WriteByte(' ')
+		p.buf.WriteString(auxString(v.Aux()))
+	case v.Op() == ossa.OpPhi:
+		written := 0
+		for i := 0; i < v.NumArgs(); i++ {
+			c := v.PhiCandidate(i)
+			if p.config.EdgePhis && p.isPred(c.Block, block) {
+				continue // written on the predecessor's terminator instead
+			}
+			switch {
+			case !p.config.LLVMSyntax:
+				fmt.Fprintf(p.buf, " [%s: %s]", p.blockNames[c.Block], p.operand(c.Value))
+			case written == 0:
+				fmt.Fprintf(p.buf, " [ %s, %s ]", p.operand(c.Value), p.blockRef(c.Block))
+			default:
+				fmt.Fprintf(p.buf, ", [ %s, %s ]", p.operand(c.Value), p.blockRef(c.Block))
+			}
+			written++
+		}
+	case v.Op() == ossa.OpCall && p.config.LLVMSyntax:
+		fmt.Fprintf(p.buf, " %s(", p.operand(v.Arg(0)))
+		for i := 1; i < v.NumArgs(); i++ {
+			if i > 1 {
+				p.buf.WriteString(", ")
+			}
+			p.buf.WriteString(p.operand(v.Arg(i)))
+		}
+		p.buf.WriteByte(')')
+	default:
+		for i := 0; i < v.NumArgs(); i++ {
+			if i == 0 {
+				p.buf.WriteByte(' ')
+			} else {
+				p.buf.WriteString(", ")
+			}
+			p.buf.WriteString(p.operand(v.Arg(i)))
+		}
+	}
+}
+
+// printTerminator writes the terminator of the given block.
+func (p *printer) printTerminator(block *ossa.BasicBlock) {
+	t := block.Terminator
+	written := make(ossa.BasicBlockSet)
+	target := func(succ *ossa.BasicBlock) string {
+		name := p.blockNames[succ]
+		if p.config.LLVMSyntax {
+			name = "label " + p.blockRef(succ)
+		}
+		if p.config.EdgePhis && !written.Has(succ) {
+			written.Add(succ)
+			name += p.edgeAssignments(block, succ)
+		}
+		return name
+	}
+	p.buf.WriteString(p.opName(t.Op()))
+	switch {
+	case t.Op() == ossa.OpJump, t.Op() == ossa.OpYield:
+		fmt.Fprintf(p.buf, " %s", target(t.Arg(0).Block))
+	case t.Op() == ossa.OpInvoke && p.config.LLVMSyntax:
+		fmt.Fprintf(
+			p.buf, " %s to %s unwind %s",
+			p.operand(t.Arg(0).Value),
+			target(t.Arg(0).Block),
+			target(t.Arg(1).Block),
+		)
+	case t.Op() == ossa.OpBranch, t.Op() == ossa.OpInvoke:
+		fmt.Fprintf(
+			p.buf, " %s, %s, %s",
+			p.operand(t.Arg(0).Value),
+			target(t.Arg(0).Block),
+			target(t.Arg(1).Block),
+		)
+	case t.Op() == ossa.OpSwitch:
+		fmt.Fprintf(p.buf, " %s, %s", p.operand(t.Arg(0).Value), target(t.Arg(0).Block))
+		if p.config.LLVMSyntax && t.NumArgs() > 1 {
+			p.buf.WriteString(" [")
+			for i := 1; i < t.NumArgs(); i++ {
+				c := t.Arg(i)
+				fmt.Fprintf(p.buf, " %s, %s", p.operand(c.Value), target(c.Block))
+			}
+			p.buf.WriteString(" ]")
+			break
+		}
+		for i := 1; i < t.NumArgs(); i++ {
+			c := t.Arg(i)
+			fmt.Fprintf(p.buf, " [%s: %s]", p.operand(c.Value), target(c.Block))
+		}
+	case t.Op() == ossa.OpReturn && t.NumArgs() == 0 && p.config.LLVMSyntax:
+		p.buf.WriteString(" void")
+	case t.Op() == ossa.OpReturn:
+		for i := 0; i < t.NumArgs(); i++ {
+			if i == 0 {
+				p.buf.WriteByte(' ')
+			} else {
+				p.buf.WriteString(", ")
+			}
+			p.buf.WriteString(p.operand(t.Arg(i).Value))
+		}
+	case t.Op() == ossa.OpResume:
+		fmt.Fprintf(p.buf, " %s", p.operand(t.Arg(0).Value))
+	case t.Op() == ossa.OpAwait:
+		fmt.Fprintf(p.buf, " %s, %s", p.operand(t.Arg(0).Value), target(t.Arg(0).Block))
+	default:
+		// Registered terminators have a list of arguments that each have
+		// a value, a block, or both.
+		for i := 0; i < t.NumArgs(); i++ {
+			arg := t.Arg(i)
+			if arg.Block == nil {
+				fmt.Fprintf(p.buf, " [%s]", p.operand(arg.Value))
+			} else {
+				fmt.Fprintf(p.buf, " [%s: %s]", p.operand(arg.Value), target(arg.Block))
+			}
+		}
+	}
+}
+
+// edgeAssignments returns the parenthesized list of Phi candidates for the
+// edge from one block to another, for EdgePhis mode, or an empty string if
+// there are none.
+func (p *printer) edgeAssignments(from, to *ossa.BasicBlock) string {
+	var assigns []string
+	for _, v := range to.Instructions {
+		if v.Op() != ossa.OpPhi {
+			break
+		}
+		for i := 0; i < v.NumArgs(); i++ {
+			if c := v.PhiCandidate(i); c.Block == from {
+				assigns = append(assigns, p.operand(v)+" = "+p.operand(c.Value))
+			}
+		}
+	}
+	if len(assigns) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(assigns, ", ") + ")"
+}
+
+// isPred returns true if the given block is a predecessor of the other
+// given block in the current function.
+func (p *printer) isPred(pred, block *ossa.BasicBlock) bool {
+	for _, candidate := range p.preds[block] {
+		if candidate == pred {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueSuccessors returns the successors of the given block, without
+// duplicates, in the order they are first targeted by its terminator.
+func uniqueSuccessors(block *ossa.BasicBlock) []*ossa.BasicBlock {
+	if block.Terminator == nil {
+		return nil
+	}
+	var ret []*ossa.BasicBlock
+	seen := make(ossa.BasicBlockSet)
+	for _, succ := range block.Terminator.AppendSuccessors(nil) {
+		if !seen.Has(succ) {
+			seen.Add(succ)
+			ret = append(ret, succ)
+		}
+	}
+	return ret
+}
+
+func (p *printer) operand(v *ossa.Value) string {
+	if v == nil {
+		return "void"
+	}
+	if name, ok := p.valueNames[v]; ok {
+		if p.config.LLVMSyntax {
+			return "%" + name
+		}
+		return name
+	}
+	if p.mod != nil {
+		if name, ok := p.mod.GlobalName(v); ok {
+			return globalName(name)
+		}
+	}
+	// Should never get here for a well-formed function, but we'll produce
+	// something reasonable rather than panicking because the printer is
+	// often used to debug malformed functions.
+	return "?"
+}
+
+// blockRef returns the label of the given block as it is written when
+// referring to the block, rather than when defining it.
+func (p *printer) blockRef(block *ossa.BasicBlock) string {
+	if p.config.LLVMSyntax {
+		return "%" + p.blockNames[block]
+	}
+	return p.blockNames[block]
+}
+
+// opName returns the name of the given operation as written in the selected
+// syntax.
+func (p *printer) opName(op ossa.Op) string {
+	if _, registered := op.Spec(); registered || !p.config.LLVMSyntax {
+		return op.Name()
+	}
+	switch op {
+	case ossa.OpJump, ossa.OpBranch:
+		return "br"
+	case ossa.OpReturn:
+		return "ret"
+	default:
+		return strings.ToLower(op.Name())
+	}
+}
+
+func globalName(name string) string {
+	if isIdentifier(name) {
+		return "@" + name
+	}
+	return "@" + strconv.Quote(name)
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_':
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case (r == '.' || (r >= '0' && r <= '9')) && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// auxString returns a textual representation of an aux value. Canonical
+// constants, strings and constant pool references are written in a form
+// that can be parsed back in, while any other value is written using Go
+// syntax in angle brackets.
+func auxString(aux interface{}) string {
+	switch aux := aux.(type) {
+	case ossa.Const:
+		return aux.String()
+	case string:
+		return strconv.Quote(aux)
+	case ossa.PoolRef:
+		return "pool " + strconv.Itoa(aux.Index())
+	default:
+		return fmt.Sprintf("<%#v>", aux)
+	}
+}
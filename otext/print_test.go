@@ -0,0 +1,319 @@
+package otext
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+)
+
+func TestSprintFunction(t *testing.T) {
+	f := ossa.NewFunction()
+	n := f.AddParam()
+	entry := f.Entry()
+	loopHeader := f.NewBlock()
+	loopBody := f.NewBlock()
+	exit := f.NewBlock()
+
+	counter := ossa.LocalSym()
+	one := ossa.ConstLiteral(ossa.Int64Const(1))
+	lt := ossa.AuxLiteral("lt")
+	add := ossa.AuxLiteral("add")
+
+	entry.Instructions = append(entry.Instructions, ossa.Store(one, counter))
+	entry.Terminator = ossa.Jump(loopHeader)
+	cur := ossa.Load(counter)
+	cond := ossa.Call(lt, cur, n)
+	loopHeader.Instructions = append(loopHeader.Instructions, cur, cond)
+	loopHeader.Terminator = ossa.Branch(cond, loopBody, exit)
+	next := ossa.Call(add, cur, one)
+	loopBody.Instructions = append(loopBody.Instructions, next, ossa.Store(next, counter))
+	loopBody.Terminator = ossa.Jump(loopHeader)
+	exit.Terminator = ossa.Return(cur)
+
+	got := SprintFunction(f)
+	want := `func(v0) {
+    v1 = AuxLiteral 1
+    v2 = LocalSym
+    v3 = AuxLiteral "lt"
+    v4 = AuxLiteral "add"
+b0:
+    v5 = Store v1, v2
+    Jump b1
+b1:
+    v6 = Load v2
+    v7 = Call v3, v6, v0
+    Branch v7, b2, b3
+b2:
+    v8 = Call v4, v6, v1
+    v9 = Store v8, v2
+    Jump b1
+b3:
+    Return v6
+}
+`
+	if got != want {
+		t.Errorf("wrong output\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSprintModule(t *testing.T) {
+	m := ossa.NewModule()
+	counter := m.DeclareGlobal("counter")
+	callee := m.DefineFunction("callee")
+	callee.Entry().Terminator = ossa.Return()
+	caller := m.DefineFunction("caller")
+	entry := caller.Entry()
+	then := caller.NewBlock()
+	x := caller.AddParam()
+	phiIn := ossa.Call(m.Global("callee"))
+	entry.Instructions = append(entry.Instructions, phiIn)
+	entry.Terminator = ossa.Switch(
+		x, then,
+		ossa.BasicBlockValue{Block: then, Value: ossa.ConstLiteral(ossa.BoolConst(true))},
+	)
+	phi := ossa.Phi(ossa.BasicBlockValue{Block: entry, Value: phiIn})
+	then.Instructions = append(then.Instructions, phi, ossa.Store(phi, counter))
+	then.Terminator = ossa.Unreachable
+
+	got := SprintModule(m)
+	want := `global @counter
+
+func @callee() {
+b0:
+    Return
+}
+
+func @caller(v0) {
+    v1 = AuxLiteral true
+b0:
+    v2 = Call @callee
+    Switch v0, b1 [v1: b1]
+b1:
+    v3 = Phi [b0: v2]
+    v4 = Store v3, @counter
+    Unreachable
+}
+`
+	if got != want {
+		t.Errorf("wrong output\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSprintFunctionNames(t *testing.T) {
+	f := ossa.NewFunction()
+	n := f.AddParam()
+	n.SetName("n")
+	loop := f.NewBlock()
+	loop.Name = "loop"
+	counter := ossa.LocalSym()
+	counter.SetName("counter")
+	v := ossa.Load(counter)
+	v.SetName("current value") // not an identifier
+	f.Entry().Instructions = []*ossa.Value{v}
+	f.Entry().Terminator = ossa.Jump(loop)
+	loop.Terminator = ossa.Return(n)
+
+	got := SprintFunction(f)
+	want := `func(v0.n) {
+    v1.counter = LocalSym
+b0:
+    v2.current_value = Load v1.counter
+    Jump b1.loop
+b1.loop:
+    Return v0.n
+}
+`
+	if got != want {
+		t.Fatalf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The names must survive a round trip through the parser, aside from
+	// the replaced characters.
+	f, err := ParseFunction([]byte(got))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := f.Params[0].Name(), "n"; got != want {
+		t.Errorf("wrong parameter name %q; want %q", got, want)
+	}
+	if got, want := f.Entry().Instructions[0].Name(), "current_value"; got != want {
+		t.Errorf("wrong instruction name %q; want %q", got, want)
+	}
+	if got := f.Entry().Name; got != "" {
+		t.Errorf("unnamed block has name %q", got)
+	}
+	if got := SprintFunction(f); got != want {
+		t.Errorf("wrong result after round trip\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	src := `func(v0) {
+    v1 = AuxLiteral 1
+    v2 = AuxLiteral 2
+b0:
+    Branch v0, b1, b2
+b1:
+    Switch v0, b2 [v1: b3]
+b2:
+    v3 = Phi [b0: v1] [b1: v2]
+    Jump b3
+b3:
+    v4 = Phi [b1: v0] [b2: v3] [b4: v1]
+    Return v4
+b4:
+    Return v2
+}
+`
+	f, err := ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The candidate of v4 from b4 remains on the Phi node because b4 is
+	// not actually a predecessor of b3.
+	config := PrintConfig{Verbose: true, EdgePhis: true}
+	got := config.SprintFunction(f)
+	want := `func(v0) {
+    v1 = AuxLiteral 1
+    v2 = AuxLiteral 2
+b0:
+    Branch v0, b1, b2 (v3 = v1)
+b1: // preds: b0
+    Switch v0, b2 (v3 = v2) [v1: b3 (v4 = v0)]
+b2: // preds: b0, b1
+    v3 = Phi
+    Jump b3 (v4 = v3)
+b3: // preds: b1, b2
+    v4 = Phi [b4: v1]
+    Return v4
+b4: // no predecessors
+    Return v2
+}
+`
+	if got != want {
+		t.Fatalf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Parsing the configured output must produce an equivalent function,
+	// although candidates written on edges come after those on the Phi.
+	f, err = ParseFunction([]byte(got))
+	if err != nil {
+		t.Fatalf("unexpected error parsing configured output: %s", err)
+	}
+	got = SprintFunction(f)
+	want = `func(v0) {
+    v1 = AuxLiteral 1
+    v2 = AuxLiteral 2
+b0:
+    Branch v0, b1, b2
+b1:
+    Switch v0, b2 [v1: b3]
+b2:
+    v3 = Phi [b0: v1] [b1: v2]
+    Jump b3
+b3:
+    v4 = Phi [b4: v1] [b1: v0] [b2: v3]
+    Return v4
+b4:
+    Return v2
+}
+`
+	if got != want {
+		t.Errorf("wrong result after round trip\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrintConfigLLVMSyntax(t *testing.T) {
+	src := `global @counter
+
+func @caller(v0) {
+    v1 = AuxLiteral 1
+    v2 = LocalSym
+b0:
+    v3 = Call @callee, v1, v2
+    Switch v0, b1 [v1: b2] [v3: b3]
+b1:
+    v4 = Load @counter
+    Await v4, b2
+b2:
+    v5 = Phi [b0: v3] [b1: v4]
+    v6 = Store v5, @counter
+    Branch v5, b3, b4
+b3:
+    v7 = Phi [b0: v1] [b2: v5]
+    Yield b4
+b4:
+    Return
+}
+
+func @callee(v0, v1) {
+b0:
+    v2 = Call @counter
+    Invoke v2, b1, b2
+b1:
+    Return v2, v0
+b2:
+    v3 = LandingPad
+    Resume v3
+}
+`
+	m, err := ParseModule([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := PrintConfig{LLVMSyntax: true}.SprintModule(m)
+	want := `global @counter
+
+define @caller(%v0) {
+    %v1 = auxliteral 1
+    %v2 = localsym
+b0:
+    %v3 = call @callee(%v1, %v2)
+    switch %v0, label %b1 [ %v1, label %b2 %v3, label %b3 ]
+b1:
+    %v4 = load @counter
+    await %v4, label %b2
+b2:
+    %v5 = phi [ %v3, %b0 ], [ %v4, %b1 ]
+    %v6 = store %v5, @counter
+    br %v5, label %b3, label %b4
+b3:
+    %v7 = phi [ %v1, %b0 ], [ %v5, %b2 ]
+    yield label %b4
+b4:
+    ret void
+}
+
+define @callee(%v0, %v1) {
+b0:
+    %v2 = call @counter()
+    invoke %v2 to label %b1 unwind label %b2
+b1:
+    ret %v2, %v0
+b2:
+    %v3 = landingpad
+    resume %v3
+}
+`
+	if got != want {
+		t.Fatalf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The parser accepts the LLVM syntax in combination with the other
+	// options, and produces the same module.
+	for _, config := range []PrintConfig{
+		{LLVMSyntax: true},
+		{LLVMSyntax: true, Verbose: true, EdgePhis: true},
+	} {
+		printed := config.SprintModule(m)
+		parsed, err := ParseModule([]byte(printed))
+		if err != nil {
+			t.Fatalf("unexpected error parsing %#v output: %s\n%s", config, err, printed)
+		}
+		if got := SprintModule(parsed); got != src {
+			t.Errorf("wrong result after round trip with %#v\ngot:\n%s\nwant:\n%s", config, got, src)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// CanonicalizeBranches rewrites the Branch terminators in the given function
+// so that their conditions are not negations, returning the number of
+// terminators that were changed.
+//
+// The given function reports whether a callee represents logical negation in
+// the frontend's language. A Branch whose condition is a Call to such a callee
+// with exactly one argument is replaced with a Branch on that argument with
+// its targets, and any weights of those targets, swapped. This is repeated for as long as the new condition is
+// itself a negation, so double negations are removed too.
+//
+// The negation calls themselves are left in place even if they are no longer
+// used, for removal by a later dead code elimination.
+func CanonicalizeBranches(f *ossa.Function, isNegation func(callee *ossa.Value) bool) int {
+	count := 0
+	for _, block := range f.AppendBlocks(nil) {
+		t := block.Terminator
+		if t == nil || t.Op() != ossa.OpBranch {
+			continue
+		}
+		cond := t.Arg(0).Value
+		trueTarget, falseTarget := t.Arg(0).Block, t.Arg(1).Block
+		swapped, changed := false, false
+		for cond != nil && cond.Op() == ossa.OpCall && cond.NumArgs() == 2 && isNegation(cond.Arg(0)) {
+			cond = cond.Arg(1)
+			trueTarget, falseTarget = falseTarget, trueTarget
+			swapped = !swapped
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		t.SetArg(0, ossa.BasicBlockValue{Value: cond, Block: trueTarget})
+		t.SetArg(1, ossa.BasicBlockValue{Block: falseTarget})
+		if w := t.Weights(); swapped && w != nil {
+			t.SetWeights(w[1], w[0])
+		}
+		count++
+	}
+	return count
+}
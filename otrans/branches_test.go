@@ -0,0 +1,72 @@
+package otrans
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestCanonicalizeBranches(t *testing.T) {
+	src := `func(a, b) {
+    not = AuxLiteral "not"
+entry:
+    na = Call not, a
+    Branch na, single, other
+single:
+    nb = Call not, b
+    nnb = Call not, nb
+    Branch nnb, done, other
+other:
+    Branch a, done, single
+done:
+    Return
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	blocks := f.AppendBlocks(nil)
+	blocks[0].Terminator.SetWeights(1, 9)
+	blocks[1].Terminator.SetWeights(2, 8)
+
+	isNegation := func(callee *ossa.Value) bool {
+		name, ok := ossa.AuxAs[string](callee)
+		return ok && name == "not"
+	}
+	if got, want := CanonicalizeBranches(f, isNegation), 2; got != want {
+		t.Errorf("wrong number of branches changed %d; want %d", got, want)
+	}
+
+	// The weights follow their targets, so a single negation swaps them
+	// and a double negation leaves them as they were.
+	if got, want := blocks[0].Terminator.Weights(), []float64{9, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong weights for single negation %v; want %v", got, want)
+	}
+	if got, want := blocks[1].Terminator.Weights(), []float64{2, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong weights for double negation %v; want %v", got, want)
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func(v0, v1) {
+    v2 = AuxLiteral "not"
+b0:
+    v3 = Call v2, v0
+    Branch v0, b2, b1
+b1:
+    v4 = Call v2, v1
+    v5 = Call v2, v4
+    Branch v1, b3, b2
+b2:
+    Branch v0, b3, b1
+b3:
+    Return
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
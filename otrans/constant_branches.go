@@ -0,0 +1,181 @@
+package otrans
+
+import (
+	"reflect"
+
+	"github.com/alamatic/ossa"
+)
+
+// FoldConstantBranches rewrites the Branch and Switch terminators in the given
+// function whose outcome can be decided from AuxLiteral operands, returning
+// the number of terminators that were changed.
+//
+// The given function reports the truthiness of an AuxLiteral value used as a
+// Branch condition, with its second return value false if the truthiness is
+// not known. A Branch whose condition has known truthiness is replaced with a
+// Jump to the corresponding target.
+//
+// Switch cases are assumed to be tested in order, with the first matching
+// case selected. If the input of a Switch is an AuxLiteral then any cases
+// whose values are AuxLiterals not equal to it are removed, and if a case
+// is then known to be the first match the Switch is replaced with a Jump to
+// its target. A Switch left with no cases is replaced with a Jump to its
+// default target. AuxLiterals are equal if they are equal canonical
+// constants, or if their aux values are comparable and equal using the ==
+// operator. Floating point zero and negative zero are neither equal nor
+// unequal for this purpose, since a frontend may compare them either way,
+// so a case comparing one with the other is kept. A Switch that keeps some
+// of its cases also keeps their weights.
+//
+// When an edge is removed, the Phi candidates for that edge are removed from
+// the former target. Blocks that become unreachable as a result are left in
+// place, for removal by a later transform.
+func FoldConstantBranches(f *ossa.Function, truthy func(lit *ossa.Value) (result, ok bool)) int {
+	count := 0
+	for _, block := range f.AppendBlocks(nil) {
+		t := block.Terminator
+		if t == nil {
+			continue
+		}
+		before := t.AppendSuccessors(nil)
+		repl := foldConstantTerminator(t, truthy)
+		if repl == nil {
+			continue
+		}
+		repl.SetPos(t.Pos())
+		block.Terminator = repl
+
+		after := ossa.NewBasicBlockSet(repl.AppendSuccessors(nil)...)
+		for _, succ := range before {
+			if !after.Has(succ) {
+				after.Add(succ) // so we only handle each successor once
+				RemovePredecessor(succ, block)
+			}
+		}
+		count++
+	}
+	return count
+}
+
+// foldConstantTerminator returns a replacement for the given terminator if
+// it is a Branch or Switch whose outcome can be at least partially decided,
+// as described for FoldConstantBranches, or nil otherwise.
+func foldConstantTerminator(t *ossa.Terminator, truthy func(lit *ossa.Value) (bool, bool)) *ossa.Terminator {
+	switch t.Op() {
+	case ossa.OpBranch:
+		return foldConstantBranch(t, truthy)
+	case ossa.OpSwitch:
+		return foldConstantSwitch(t)
+	default:
+		return nil
+	}
+}
+
+func foldConstantBranch(t *ossa.Terminator, truthy func(lit *ossa.Value) (bool, bool)) *ossa.Terminator {
+	cond := t.Arg(0).Value
+	if cond == nil || cond.Op() != ossa.OpAuxLiteral {
+		return nil
+	}
+	result, ok := truthy(cond)
+	if !ok {
+		return nil
+	}
+	if result {
+		return ossa.Jump(t.Arg(0).Block)
+	}
+	return ossa.Jump(t.Arg(1).Block)
+}
+
+func foldConstantSwitch(t *ossa.Terminator) *ossa.Terminator {
+	inp := t.Arg(0).Value
+	if inp == nil || inp.Op() != ossa.OpAuxLiteral {
+		return nil
+	}
+	def := t.Arg(0).Block
+
+	var cases []ossa.BasicBlockValue
+	var weights []float64
+	keep := func(i int) {
+		cases = append(cases, t.Arg(i))
+		if w := t.Weights(); w != nil {
+			weights = append(weights, w[i])
+		}
+	}
+	changed := false
+search:
+	for i := 1; i < t.NumArgs(); i++ {
+		c := t.Arg(i)
+		if c.Value == nil || c.Value.Op() != ossa.OpAuxLiteral {
+			keep(i)
+			continue
+		}
+		equal, known := auxLiteralsEqual(inp, c.Value)
+		switch {
+		case !known:
+			keep(i)
+		case !equal:
+			changed = true
+		case len(cases) == 0:
+			// This is the first case that could possibly match, and it
+			// definitely does.
+			return ossa.Jump(c.Block)
+		default:
+			// This case definitely matches if none of the earlier ones do,
+			// so all of the later ones are impossible.
+			keep(i)
+			if i+1 < t.NumArgs() {
+				changed = true
+			}
+			break search
+		}
+	}
+	if len(cases) == 0 {
+		return ossa.Jump(def)
+	}
+	if !changed {
+		return nil
+	}
+	ret := ossa.Switch(inp, def, cases...)
+	if weights != nil {
+		ret.SetWeights(append([]float64{t.Weights()[0]}, weights...)...)
+	}
+	return ret
+}
+
+// auxLiteralsEqual compares the aux values of two AuxLiteral values. The
+// second return value is false if equality cannot be decided.
+func auxLiteralsEqual(a, b *ossa.Value) (equal, known bool) {
+	if a == b {
+		return true, true
+	}
+	ac, aIsConst := a.Const()
+	bc, bIsConst := b.Const()
+	if aIsConst || bIsConst {
+		if !aIsConst || !bIsConst {
+			return false, false
+		}
+		if ac.Equal(bc) {
+			return true, true
+		}
+		af, aIsFloat := ac.Float()
+		bf, bIsFloat := bc.Float()
+		return false, !aIsFloat || !bIsFloat || af.Cmp(bf) != 0
+	}
+	aa, ba := a.Aux(), b.Aux()
+	if aa == nil || ba == nil {
+		return aa == nil && ba == nil, true
+	}
+	at, bt := reflect.TypeOf(aa), reflect.TypeOf(ba)
+	if at != bt || !at.Comparable() {
+		return false, false
+	}
+	// A comparable type can still hold values that are not comparable, such
+	// as a struct with an interface field containing a slice, and then ==
+	// panics.
+	defer func() {
+		if recover() != nil {
+			equal, known = false, false
+		}
+	}()
+	return aa == ba, true
+}
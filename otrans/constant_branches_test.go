@@ -0,0 +1,137 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestFoldConstantBranches(t *testing.T) {
+	src := `func(p) {
+    yes = AuxLiteral true
+    maybe = AuxLiteral "maybe"
+    a = AuxLiteral "a"
+    b = AuxLiteral "b"
+    c = AuxLiteral "c"
+    q = AuxLiteral "q"
+    one = AuxLiteral 1
+    two = AuxLiteral 2
+entry:
+    Branch yes, first, dead
+first:
+    Branch maybe, second, dead
+second:
+    Switch b, dead [a: dead] [p: third] [b: fourth] [c: dead]
+third:
+    Switch q, fourth [a: dead] [b: dead]
+fourth:
+    Return
+dead:
+    x = Phi [entry: one] [first: two]
+    Return x
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	truthy := func(lit *ossa.Value) (bool, bool) {
+		c, ok := lit.Const()
+		if !ok {
+			return false, false
+		}
+		return c.Bool()
+	}
+	if got, want := FoldConstantBranches(f, truthy), 3; got != want {
+		t.Errorf("wrong number of terminators changed %d; want %d", got, want)
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func(v0) {
+    v1 = AuxLiteral "maybe"
+    v2 = AuxLiteral "b"
+    v3 = AuxLiteral 2
+b0:
+    Jump b1
+b1:
+    Branch v1, b2, b5
+b2:
+    Switch v2, b5 [v0: b3] [v2: b4]
+b3:
+    Jump b4
+b4:
+    Return
+b5:
+    v4 = Phi [b1: v3]
+    Return v4
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFoldConstantBranchesSignedZero(t *testing.T) {
+	// Whether zero matches negative zero depends on the frontend, so that
+	// case must be kept, while the case for one can be removed.
+	src := `func() {
+    zero = AuxLiteral 0.0
+    negzero = AuxLiteral -0.0
+    one = AuxLiteral 1.0
+entry:
+    Switch zero, a [one: b] [negzero: b]
+a:
+    Return
+b:
+    Return
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := FoldConstantBranches(f, nil), 1; got != want {
+		t.Errorf("wrong number of terminators changed %d; want %d", got, want)
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func() {
+    v0 = AuxLiteral 0.0
+    v1 = AuxLiteral -0.0
+b0:
+    Switch v0, b1 [v1: b2]
+b1:
+    Return
+b2:
+    Return
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFoldConstantBranchesNonComparable(t *testing.T) {
+	// The aux values have a comparable type but hold values that are not
+	// comparable, so whether the case matches cannot be decided.
+	type wrapper struct {
+		v interface{}
+	}
+	f := ossa.NewFunction()
+	b := ossa.NewFunctionBuilder(f)
+	inp := b.AuxLiteral(wrapper{[]int{1}})
+	c := b.AuxLiteral(wrapper{[]int{1}})
+	def := b.NewBlock()
+	target := b.NewBlock()
+	b.Switch(inp, def, ossa.BasicBlockValue{Value: c, Block: target})
+	b.SetBlock(def)
+	b.Return()
+	b.SetBlock(target)
+	b.Return()
+
+	if got, want := FoldConstantBranches(f, nil), 0; got != want {
+		t.Errorf("wrong number of terminators changed %d; want %d", got, want)
+	}
+}
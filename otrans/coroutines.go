@@ -0,0 +1,273 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+)
+
+// CoroutineFrame is implemented by frontends to describe how a coroutine
+// lowered by LowerCoroutine accesses its frame, which is the memory that
+// holds the coroutine's state while it is suspended.
+//
+// Each method may emit instructions using the given builder, which it must
+// leave pointed at the end of the same block.
+type CoroutineFrame interface {
+	// StateRef returns a reference to the frame's memory for the
+	// coroutine's state number, suitable as the reference operand of Load
+	// and Store instructions.
+	StateRef(b *ossa.Builder) *ossa.Value
+
+	// SlotRef returns a reference to the frame's memory for the spill slot
+	// with the given index, suitable as the reference operand of Load and
+	// Store instructions.
+	SlotRef(b *ossa.Builder, slot int) *ossa.Value
+
+	// Suspend terminates the builder's block to suspend the coroutine in
+	// place of the given Yield or Await terminator, typically by returning
+	// to the coroutine's caller. The state number and spilled values have
+	// already been stored when Suspend is called.
+	Suspend(b *ossa.Builder, t *ossa.Terminator)
+}
+
+// CoroutineLayout describes the frame and states of a coroutine lowered by
+// LowerCoroutine.
+type CoroutineLayout struct {
+	// Slots is the value spilled to each slot of the frame, indexed by slot
+	// number. The frame must have room for all of them.
+	Slots []*ossa.Value
+
+	// States is the block that the lowered function dispatches to for each
+	// state number. State zero starts the coroutine from the beginning, and
+	// each other state resumes it after one of its suspension points.
+	States []*ossa.BasicBlock
+}
+
+// LowerCoroutine rewrites the given function, whose Yield and Await
+// terminators mark the points where it suspends, into a state machine that
+// returns to its caller at each suspension point and can later be called
+// again to resume where it left off. It returns the layout of the resulting
+// frame, or nil without making any changes if the function has no reachable
+// suspension points.
+//
+// The entry block of the lowered function loads the state number from the
+// frame and uses a Switch to dispatch to the block for that state, as
+// recorded in the result's States field. State numbers are AuxLiteral
+// values with int aux values, starting from zero for the original entry
+// code, which is moved to a new block.
+//
+// At each suspension point, the lowered function stores the state number for
+// resuming from there along with all of the values that are live across the
+// suspension, as found by oana.FindSuspensions, into the frame, and then calls
+// the frame's Suspend method to end the block. Each such point is given a new
+// resume block that reloads those values from the frame before jumping to
+// the original resume target. Each spilled value is given its own slot.
+//
+// The function's parameters are never spilled, so each call to the lowered
+// function must pass the same parameter values, or at least any parameters
+// used after a suspension point must be the same. Local symbols are memory
+// belonging to a single call of the lowered function, so the frontend must
+// also ensure that no local symbol is accessed both before and after a
+// suspension point, for example by promoting them with PromoteLocalSyms
+// first.
+//
+// The transform uses PromoteLocalSyms internally to reconstruct SSA form
+// across the new resume paths, so any other local symbols in the function
+// that can be promoted are promoted too. Any Phi nodes it inserts that turn
+// out to be unused are removed.
+func LowerCoroutine(f *ossa.Function, frame CoroutineFrame) *CoroutineLayout {
+	if len(oana.FindSuspensions(f)) == 0 {
+		return nil
+	}
+
+	// The entry block will become the dispatcher, so we move its original
+	// content to a new block first and find the live values afterwards.
+	entry := f.Entry()
+	start := f.NewBlock()
+	start.Instructions, entry.Instructions = entry.Instructions, nil
+	start.Terminator = entry.Terminator
+	start.Name, entry.Name = entry.Name, ""
+	start.Region = entry.Region
+	replacePhiPredecessor(start, entry, start)
+	for _, block := range f.AppendBlocks(nil) {
+		if block != entry && block.Terminator != nil {
+			retargetTerminator(block.Terminator, entry, start)
+		}
+	}
+	entry.Terminator = ossa.Jump(start)
+	suspensions := oana.FindSuspensions(f)
+
+	// We spill every value that is live across any suspension point, other
+	// than parameters, giving each one a slot in the order the values are
+	// defined in reverse postorder.
+	params := make(ossa.ValueSet)
+	for _, param := range f.Params {
+		params.Add(param)
+	}
+	var suspends []*ossa.BasicBlock
+	spilled := make(ossa.ValueSet)
+	blocks := f.AppendBlocks(nil)
+	for _, block := range blocks {
+		live, ok := suspensions[block]
+		if !ok {
+			continue
+		}
+		suspends = append(suspends, block)
+		for v := range live {
+			if !params.Has(v) {
+				spilled.Add(v)
+			}
+		}
+	}
+	ret := &CoroutineLayout{
+		States: []*ossa.BasicBlock{start},
+	}
+	slots := make(map[*ossa.Value]int, len(spilled))
+	syms := make(map[*ossa.Value]*ossa.Value, len(spilled))
+	defBlocks := make(map[*ossa.Value]*ossa.BasicBlock, len(spilled))
+	for _, block := range ossa.AppendBlocksRPO(entry, nil) {
+		for _, inst := range block.Instructions {
+			if spilled.Has(inst) {
+				slots[inst] = len(ret.Slots)
+				syms[inst] = ossa.LocalSym()
+				defBlocks[inst] = block
+				ret.Slots = append(ret.Slots, inst)
+			}
+		}
+	}
+	liveSlots := func(block *ossa.BasicBlock) []*ossa.Value {
+		var live []*ossa.Value
+		for _, v := range ret.Slots {
+			if suspensions[block].Has(v) {
+				live = append(live, v)
+			}
+		}
+		return live
+	}
+
+	// Each suspension point stores its state and live values and then
+	// suspends, while its new resume block reloads the values. Until SSA
+	// form is reconstructed below, each spilled value is represented by
+	// its local symbol.
+	b := ossa.NewFunctionBuilder(f)
+	var cases []ossa.BasicBlockValue
+	for _, block := range suspends {
+		t := block.Terminator
+		state := ossa.AuxLiteral(len(ret.States))
+		live := liveSlots(block)
+		b.SetPos(t.Pos())
+
+		block.Terminator = nil
+		b.SetBlock(block)
+		b.Store(state, frame.StateRef(b))
+		for _, v := range live {
+			b.Store(b.Load(syms[v]), frame.SlotRef(b, slots[v]))
+		}
+		frame.Suspend(b, t)
+
+		resume := b.NewBlock()
+		resume.Region = block.Region
+		b.SetBlock(resume)
+		for _, v := range live {
+			b.Store(b.Load(frame.SlotRef(b, slots[v])), syms[v])
+		}
+		target := t.Arg(0).Block
+		b.Jump(target)
+		for _, inst := range target.Instructions {
+			if inst.Op() != ossa.OpPhi {
+				break
+			}
+			if i := phiCandidateIndex(inst, block); i >= 0 {
+				c := inst.PhiCandidate(i)
+				c.Block = resume
+				inst.SetPhiCandidate(i, c)
+			}
+		}
+
+		cases = append(cases, ossa.BasicBlockValue{Value: state, Block: resume})
+		ret.States = append(ret.States, resume)
+	}
+	b.SetPos(nil)
+
+	entry.Terminator = nil
+	b.SetBlock(entry)
+	b.Switch(b.Load(frame.StateRef(b)), start, cases...)
+
+	// Now we replace each spilled value with its local symbol, storing it
+	// immediately after its definition and loading it again before each
+	// use, and then let PromoteLocalSyms reconstruct SSA form, which will
+	// insert Phi nodes where the resume paths meet the original ones.
+	uses := oana.BuildUses(entry)
+	for _, v := range ret.Slots {
+		sym := syms[v]
+		after := v
+		if v.Op() == ossa.OpPhi {
+			for _, inst := range defBlocks[v].Instructions {
+				if inst.Op() == ossa.OpPhi {
+					after = inst
+				}
+			}
+		}
+		if v == invokedCall(defBlocks[v]) {
+			// Nothing can follow an invoked call in its own block, so
+			// the Store belongs on the edge to its normal target.
+			b.SetInsertAtStart(normalEdgeBlock(f, defBlocks[v]))
+		} else {
+			b.SetInsertAfter(defBlocks[v], after)
+		}
+		b.Store(v, sym)
+
+		for _, u := range uses[v] {
+			switch {
+			case u.Value == nil && u.Terminator().Op() == ossa.OpInvoke:
+				// The Invoke refers to its call rather than using its
+				// result, so it must keep referring to the call itself.
+			case u.Value == nil:
+				u.Replace(appendLoad(u.Block, sym))
+			case u.Value.Op() == ossa.OpPhi:
+				c := u.Value.PhiCandidate(u.Index)
+				c.Value = appendLoad(c.Block, sym)
+				u.Value.SetPhiCandidate(u.Index, c)
+			default:
+				b.SetInsertBefore(u.Block, u.Value)
+				u.Replace(b.Load(sym))
+			}
+		}
+	}
+	existingPhis := make(ossa.ValueSet)
+	for _, block := range f.AppendBlocks(nil) {
+		for _, inst := range block.Instructions {
+			if inst.Op() == ossa.OpPhi {
+				existingPhis.Add(inst)
+			}
+		}
+	}
+	PromoteLocalSyms(f)
+
+	// PromoteLocalSyms places Phi nodes wherever the stores to a symbol
+	// meet, even if nothing loads the symbol there, so we remove the Phi
+	// nodes we caused that turned out to be unused.
+	for changed := true; changed; {
+		changed = false
+		uses := oana.BuildUses(entry)
+		for _, block := range f.AppendBlocks(nil) {
+			insts := block.Instructions[:0]
+			for _, inst := range block.Instructions {
+				if inst.Op() == ossa.OpPhi && !uses.HasUses(inst) && !existingPhis.Has(inst) {
+					changed = true
+					continue
+				}
+				insts = append(insts, inst)
+			}
+			block.Instructions = insts
+		}
+	}
+	return ret
+}
+
+// appendLoad appends a Load of the given reference to the end of the given
+// block, as described for appendInstruction, and returns it.
+func appendLoad(block *ossa.BasicBlock, ref *ossa.Value) *ossa.Value {
+	load := ossa.Load(ref)
+	appendInstruction(block, load)
+	return load
+}
@@ -0,0 +1,199 @@
+package otrans
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/otext"
+)
+
+// testFrame is a CoroutineFrame whose memory is addressed by calling a
+// "field" function with the frame parameter and a field number, where field
+// zero is the state and the slots follow.
+type testFrame struct {
+	frame *ossa.Value
+}
+
+func (fr testFrame) StateRef(b *ossa.Builder) *ossa.Value {
+	return b.Call(b.AuxLiteral("field"), fr.frame, b.AuxLiteral(0))
+}
+
+func (fr testFrame) SlotRef(b *ossa.Builder, slot int) *ossa.Value {
+	return b.Call(b.AuxLiteral("field"), fr.frame, b.AuxLiteral(slot+1))
+}
+
+func (fr testFrame) Suspend(b *ossa.Builder, t *ossa.Terminator) {
+	if t.Op() == ossa.OpAwait {
+		b.Return(t.Arg(0).Value)
+	} else {
+		b.Return()
+	}
+}
+
+func TestLowerCoroutine(t *testing.T) {
+	src := `func(frame, c) {
+    zero = AuxLiteral 0
+entry:
+    x = Call c
+    Jump loop
+loop:
+    i = Phi [entry: zero] [body: next]
+    Await x, body
+body:
+    next = Call c, i
+    Branch next, loop, exit
+exit:
+    Return x
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	layout := LowerCoroutine(f, testFrame{f.Params[0]})
+	if layout == nil {
+		t.Fatalf("function was not lowered")
+	}
+
+	// The value of x is spilled to slot 0 and the Phi node for i to slot
+	// 1. On resuming, b5 reloads both, and the loop head merges the
+	// reloaded x with the one computed on first entry.
+	got := otext.SprintFunction(f)
+	want := `func(v0, v1) {
+    v2 = AuxLiteral "field"
+    v3 = AuxLiteral <0>
+    v4 = AuxLiteral <1>
+    v5 = AuxLiteral 0
+    v6 = AuxLiteral "field"
+    v7 = AuxLiteral <0>
+    v8 = AuxLiteral "field"
+    v9 = AuxLiteral <1>
+    v10 = AuxLiteral "field"
+    v11 = AuxLiteral <2>
+    v12 = AuxLiteral "field"
+    v13 = AuxLiteral <1>
+    v14 = AuxLiteral "field"
+    v15 = AuxLiteral <2>
+b0:
+    v16 = Call v2, v0, v3
+    v17 = Load v16
+    Switch v17, b4 [v4: b5]
+b1:
+    v18 = Phi [b4: v5] [b2: v26]
+    v19 = Phi [b2: v29] [b4: v27]
+    v20 = Call v6, v0, v7
+    v21 = Store v4, v20
+    v22 = Call v8, v0, v9
+    v23 = Store v19, v22
+    v24 = Call v10, v0, v11
+    v25 = Store v18, v24
+    Return v19
+b2:
+    v26 = Call v1, v31
+    Branch v26, b1, b3
+b3:
+    Return v29
+b4:
+    v27 = Call v1
+    Jump b1
+b5:
+    v28 = Call v12, v0, v13
+    v29 = Load v28
+    v30 = Call v14, v0, v15
+    v31 = Load v30
+    Jump b2
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	blocks := f.AppendBlocks(nil)
+	if got, want := layout.States, []*ossa.BasicBlock{blocks[4], blocks[5]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong states %#v; want %#v", got, want)
+	}
+	if got, want := len(layout.Slots), 2; got != want {
+		t.Errorf("wrong number of slots %d; want %d", got, want)
+	} else if layout.Slots[0].Op() != ossa.OpCall || layout.Slots[1].Op() != ossa.OpPhi {
+		t.Errorf("wrong slots %#v", layout.Slots)
+	}
+
+	// A function with no suspension points is left unchanged.
+	f, err = otext.ParseFunction([]byte(`func(frame) {
+entry:
+    Return frame
+}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if layout := LowerCoroutine(f, testFrame{f.Params[0]}); layout != nil {
+		t.Errorf("function without suspension points was lowered")
+	}
+}
+
+func TestLowerCoroutineInvoke(t *testing.T) {
+	// x is the result of an invoked call, so it can only be spilled on
+	// the edge to the call's normal target, which is split to make b6.
+	src := `func(frame, c) {
+entry:
+    x = Call c
+    Invoke x, wait, fail
+wait:
+    Yield after
+after:
+    Return x
+fail:
+    e = LandingPad
+    Resume e
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if layout := LowerCoroutine(f, testFrame{f.Params[0]}); layout == nil {
+		t.Fatalf("function was not lowered")
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func(v0, v1) {
+    v2 = AuxLiteral "field"
+    v3 = AuxLiteral <0>
+    v4 = AuxLiteral <1>
+    v5 = AuxLiteral "field"
+    v6 = AuxLiteral <0>
+    v7 = AuxLiteral "field"
+    v8 = AuxLiteral <1>
+    v9 = AuxLiteral "field"
+    v10 = AuxLiteral <1>
+b0:
+    v11 = Call v2, v0, v3
+    v12 = Load v11
+    Switch v12, b4 [v4: b5]
+b1:
+    v13 = Call v5, v0, v6
+    v14 = Store v4, v13
+    v15 = Call v7, v0, v8
+    v16 = Store v18, v15
+    Return
+b2:
+    Return v20
+b3:
+    v17 = LandingPad
+    Resume v17
+b4:
+    v18 = Call v1
+    Invoke v18, b6, b3
+b5:
+    v19 = Call v9, v0, v10
+    v20 = Load v19
+    Jump b2
+b6:
+    Jump b1
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
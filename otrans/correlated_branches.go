@@ -0,0 +1,84 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+)
+
+// PruneCorrelatedBranches rewrites the given function to remove the
+// provably-dead edges of Branch terminators whose condition was already
+// tested by a dominating Branch, returning the number of terminators that
+// were changed.
+//
+// If the true target of a Branch has no other predecessors, and is not the
+// function's entry block, then the Branch's condition must be true
+// throughout the region dominated by that target, and likewise for the
+// false target. Any Branch in that region that tests the same condition is
+// replaced with a Jump to the corresponding target, and any Phi candidates
+// for the removed edge are removed from the other target.
+//
+// Blocks that become unreachable as a result are left in place, for removal
+// by a later transform.
+func PruneCorrelatedBranches(f *ossa.Function) int {
+	entry := f.Entry()
+	preds := oana.FindPredecessors(entry)
+	dt := oana.FindDominatorTree(entry, preds)
+	blocks := f.AppendBlocks(nil)
+
+	// A fact records that a condition has a known outcome in all blocks that
+	// are dominated by "region".
+	type fact struct {
+		region  *ossa.BasicBlock
+		outcome bool
+	}
+	facts := make(map[*ossa.Value][]fact)
+	for _, block := range blocks {
+		t := block.Terminator
+		if !dt.Has(block) || t == nil || t.Op() != ossa.OpBranch {
+			continue
+		}
+		cond := t.Arg(0).Value
+		trueTarget, falseTarget := t.Arg(0).Block, t.Arg(1).Block
+		if cond == nil || trueTarget == falseTarget {
+			continue
+		}
+		for _, target := range []*ossa.BasicBlock{trueTarget, falseTarget} {
+			// The entry block is also reached by calling the function,
+			// which is an implicit predecessor that FindPredecessors
+			// doesn't report.
+			if target == entry || len(preds[target]) != 1 {
+				continue
+			}
+			facts[cond] = append(facts[cond], fact{
+				region:  target,
+				outcome: target == trueTarget,
+			})
+		}
+	}
+
+	count := 0
+	for _, block := range blocks {
+		t := block.Terminator
+		if !dt.Has(block) || t == nil || t.Op() != ossa.OpBranch {
+			continue
+		}
+		cond := t.Arg(0).Value
+		for _, fc := range facts[cond] {
+			if !dt.Dominates(fc.region, block) {
+				continue
+			}
+			keep, drop := t.Arg(0).Block, t.Arg(1).Block
+			if !fc.outcome {
+				keep, drop = drop, keep
+			}
+			block.Terminator = ossa.Jump(keep)
+			block.Terminator.SetPos(t.Pos())
+			if drop != keep {
+				RemovePredecessor(drop, block)
+			}
+			count++
+			break
+		}
+	}
+	return count
+}
@@ -0,0 +1,106 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestPruneCorrelatedBranches(t *testing.T) {
+	src := `func(c, d) {
+    one = AuxLiteral 1
+    two = AuxLiteral 2
+entry:
+    Branch c, yes, no
+yes:
+    Branch c, done, join
+no:
+    Branch c, done, join
+join:
+    p = Phi [yes: one] [no: two]
+    Branch d, again, done
+again:
+    Branch c, done, join2
+join2:
+    Return p
+done:
+    Return
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The branches in "yes" and "no" are dominated by the edges from entry,
+	// but "again" is not because "join" has two predecessors.
+	if got, want := PruneCorrelatedBranches(f), 2; got != want {
+		t.Errorf("wrong number of branches pruned %d; want %d", got, want)
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func(v0, v1) {
+    v2 = AuxLiteral 2
+b0:
+    Branch v0, b1, b2
+b1:
+    Jump b6
+b2:
+    Jump b3
+b3:
+    v3 = Phi [b2: v2]
+    Branch v1, b4, b6
+b4:
+    Branch v0, b6, b5
+b5:
+    Return v3
+b6:
+    Return
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPruneCorrelatedBranchesEntry(t *testing.T) {
+	// The entry block has only one predecessor in the function, but it
+	// is also reached by calling the function, where v1 may be false, so
+	// only the Branch in b1 can be pruned.
+	src := `func(rand) {
+entry:
+    v1 = Call rand
+    Branch v1, b1, b2
+b1:
+    Branch v1, entry, b3
+b2:
+    Return
+b3:
+    Return
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := PruneCorrelatedBranches(f), 1; got != want {
+		t.Errorf("wrong number of branches pruned %d; want %d", got, want)
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func(v0) {
+b0:
+    v1 = Call v0
+    Branch v1, b1, b2
+b1:
+    Jump b0
+b2:
+    Return
+b3:
+    Return
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,21 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+)
+
+// SplitCriticalEdges inserts an empty block on each critical edge in the
+// given function that is reachable from its entry block, as found by
+// oana.FindCriticalEdges, returning the number of edges split.
+//
+// Each edge is split as described for SplitEdge, so the new blocks are added
+// to the end of the function.
+func SplitCriticalEdges(f *ossa.Function) int {
+	entry := f.Entry()
+	edges := oana.FindCriticalEdges(entry, oana.FindPredecessors(entry), nil)
+	for _, edge := range edges {
+		SplitEdge(f, edge.From, edge.To)
+	}
+	return len(edges)
+}
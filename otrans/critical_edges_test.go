@@ -0,0 +1,58 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestSplitCriticalEdges(t *testing.T) {
+	src := `func(c) {
+    one = AuxLiteral 1
+    two = AuxLiteral 2
+entry:
+    Branch c, left, join
+left:
+    Branch c, join, exit
+join:
+    p = Phi [entry: one] [left: two]
+    Return p
+exit:
+    Return c
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := SplitCriticalEdges(f), 2; got != want {
+		t.Errorf("wrong number of edges split %d; want %d", got, want)
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func(v0) {
+    v1 = AuxLiteral 1
+    v2 = AuxLiteral 2
+b0:
+    Branch v0, b1, b4
+b1:
+    Branch v0, b5, b3
+b2:
+    v3 = Phi [b4: v1] [b5: v2]
+    Return v3
+b3:
+    Return v0
+b4:
+    Jump b2
+b5:
+    Jump b2
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := SplitCriticalEdges(f); got != 0 {
+		t.Errorf("split %d edges on second run; want 0", got)
+	}
+}
@@ -0,0 +1,64 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// DestructSSA rewrites the given function to remove all of its Phi nodes,
+// returning the number removed. This is the inverse of PromoteLocalSyms, and
+// is intended for backends that cannot represent Phi nodes directly.
+//
+// Each Phi node is given a new local symbol and is replaced, in place, by a
+// Load from that symbol. A Store of each candidate to the symbol is then
+// appended to the end of the candidate's predecessor block, after any
+// existing instructions, except that a predecessor ending with an Invoke gets
+// the Store before the invoked call. If the candidate is the invoked call's
+// own result then the edge to the Phi node's block is split instead, and the
+// Store is placed in the new block. Candidates that are undefined values are
+// not stored, leaving the symbol uninitialized on that path.
+//
+// Because each Phi node has its own symbol and every Store writes an SSA
+// value rather than the contents of another symbol, the parallel semantics
+// of Phi nodes are preserved without sequentializing any copies: a Phi node
+// whose candidate is another Phi node in the same block stores the value
+// loaded at the start of that block, so swaps and similar cycles are handled
+// correctly, and uses of a Phi node after its symbol is overwritten refer to
+// the value loaded before that happened. Critical edges need not be split,
+// since a Store on a path that does not lead to the Phi node's block is
+// overwritten before the symbol is next loaded.
+//
+// The Loads and Stores copy the Pos of the Phi node they were made from, and
+// each Load also copies the Phi node's Type and Name.
+func DestructSSA(f *ossa.Function) int {
+	repl := make(map[*ossa.Value]*ossa.Value)
+	for _, block := range f.AppendBlocks(nil) {
+		for i, inst := range block.Instructions {
+			if inst.Op() != ossa.OpPhi {
+				break
+			}
+			sym := ossa.LocalSym()
+			load := ossa.Load(sym)
+			load.SetType(inst.Type())
+			load.SetPos(inst.Pos())
+			load.SetName(inst.Name())
+			block.Instructions[i] = load
+			repl[inst] = load
+
+			for j := 0; j < inst.NumArgs(); j++ {
+				c := inst.PhiCandidate(j)
+				if c.Value == nil || c.Value.Op() == ossa.OpUndef {
+					continue
+				}
+				store := ossa.Store(c.Value, sym)
+				store.SetPos(inst.Pos())
+				pred := c.Block
+				if c.Value == invokedCall(pred) {
+					pred = normalEdgeBlock(f, pred)
+				}
+				appendInstruction(pred, store)
+			}
+		}
+	}
+	replaceUses(f, repl)
+	return len(repl)
+}
@@ -0,0 +1,111 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestDestructSSA(t *testing.T) {
+	// The two Phi nodes swap their values on each iteration, and the
+	// first is also used after the loop, which would be incorrect if the
+	// copies on the back edge were performed one at a time.
+	src := `func(c) {
+    zero = AuxLiteral 0
+    one = AuxLiteral 1
+entry:
+    Jump loop
+loop:
+    a = Phi [entry: zero] [loop: b]
+    b = Phi [entry: one] [loop: a]
+    x = Call c, a, b
+    Branch x, loop, exit
+exit:
+    Return a
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := DestructSSA(f), 2; got != want {
+		t.Errorf("wrong number of Phi nodes removed %d; want %d", got, want)
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func(v0) {
+    v1 = AuxLiteral 0
+    v2 = LocalSym
+    v3 = AuxLiteral 1
+    v4 = LocalSym
+b0:
+    v5 = Store v1, v2
+    v6 = Store v3, v4
+    Jump b1
+b1:
+    v7 = Load v2
+    v8 = Load v4
+    v9 = Call v0, v7, v8
+    v10 = Store v8, v2
+    v11 = Store v7, v4
+    Branch v9, b1, b2
+b2:
+    Return v7
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDestructSSAInvoke(t *testing.T) {
+	// The store for the unwind target must come before the invoked call,
+	// while the store of the call's own result can only happen on the edge
+	// to the normal target, since nothing may follow the call in its block.
+	src := `func(c, d) {
+entry:
+    x = Call c
+    Invoke x, ok, fail
+ok:
+    r = Phi [entry: x]
+    Return r
+fail:
+    e = Phi [entry: d]
+    exc = LandingPad
+    y = Call c, e, exc
+    Unreachable
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := DestructSSA(f), 2; got != want {
+		t.Errorf("wrong number of Phi nodes removed %d; want %d", got, want)
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func(v0, v1) {
+    v2 = LocalSym
+    v3 = LocalSym
+b0:
+    v4 = Store v1, v2
+    v5 = Call v0
+    Invoke v5, b3, b2
+b1:
+    v6 = Load v3
+    Return v6
+b2:
+    v7 = Load v2
+    v8 = LandingPad
+    v9 = Call v0, v7, v8
+    Unreachable
+b3:
+    v10 = Store v5, v3
+    Jump b1
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
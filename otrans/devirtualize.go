@@ -0,0 +1,181 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// CallTargetOracle is the interface implemented by types that can predict
+// the possible callees of indirect calls, usually using frontend-specific
+// knowledge such as a class hierarchy.
+type CallTargetOracle interface {
+	// PossibleTargets returns the callee values that the given Call
+	// instruction is likely to call, or nil if the oracle has no prediction.
+	//
+	// The result need not be exhaustive, since a transformed call always
+	// retains a fallback for any other callee.
+	PossibleTargets(call *ossa.Value) []*ossa.Value
+}
+
+// CallTargetOracleFunc is an implementation of CallTargetOracle that calls a
+// single callback function with the same signature as PossibleTargets.
+type CallTargetOracleFunc func(call *ossa.Value) []*ossa.Value
+
+func (f CallTargetOracleFunc) PossibleTargets(call *ossa.Value) []*ossa.Value {
+	return f(call)
+}
+
+// Devirtualize rewrites the given function to replace indirect calls with
+// direct calls to the targets predicted by the given oracle, returning the
+// number of calls that were rewritten.
+//
+// A call is indirect if its callee is neither a global symbol nor an
+// AuxLiteral. Each indirect call that the oracle has a prediction for is
+// replaced with a Switch on its callee, with one case for each predicted
+// target that calls that target directly, and a default case that makes the
+// original indirect call. The results of the calls are merged by a Phi node
+// in a new block, which also receives the instructions and terminator that
+// followed the original call.
+//
+// If the original call was used by an Invoke terminator then each of the new
+// calls is used by an Invoke with the same unwind target, and the new block
+// that merges their results instead jumps to the original normal target.
+//
+// The speculative direct calls are intended to be inlined or otherwise
+// specialized by subsequent transforms. All of the new blocks belong to the
+// same region as the block that contained the original call.
+func Devirtualize(f *ossa.Function, oracle CallTargetOracle) int {
+	count := 0
+	work := f.AppendBlocks(nil)
+	for len(work) > 0 {
+		block := work[0]
+		work = work[1:]
+		for idx, inst := range block.Instructions {
+			if inst.Op() != ossa.OpCall || !isIndirectCallee(inst.Arg(0)) {
+				continue
+			}
+			targets := uniqueTargets(oracle.PossibleTargets(inst))
+			if len(targets) == 0 {
+				continue
+			}
+			// The rest of the block's instructions move into the join
+			// block, so we'll continue our search there.
+			work = append(work, speculateCall(f, block, idx, targets))
+			count++
+			break
+		}
+	}
+	return count
+}
+
+// speculateCall splits the given block at the Call instruction at the given
+// index, as described for Devirtualize, and returns the new join block.
+func speculateCall(f *ossa.Function, block *ossa.BasicBlock, idx int, targets []*ossa.Value) *ossa.BasicBlock {
+	call := block.Instructions[idx]
+	callee := call.Arg(0)
+	args := make([]*ossa.Value, call.NumArgs()-1)
+	for i := range args {
+		args[i] = call.Arg(i + 1)
+	}
+
+	var unwind *ossa.BasicBlock
+	var callBlocks []*ossa.BasicBlock
+	if call == invokedCall(block) {
+		unwind = block.Terminator.Arg(1).Block
+	}
+
+	join := &ossa.BasicBlock{Region: block.Region}
+	phi := ossa.Phi()
+	phi.SetType(call.Type())
+	newCallBlock := func(target *ossa.Value) *ossa.BasicBlock {
+		callBlock := f.NewBlock()
+		callBlock.Region = block.Region
+		direct := ossa.Call(target, args...)
+		direct.SetType(call.Type())
+		direct.SetPos(call.Pos())
+		callBlock.Instructions = []*ossa.Value{direct}
+		if unwind != nil {
+			callBlock.Terminator = ossa.Invoke(direct, join, unwind)
+		} else {
+			callBlock.Terminator = ossa.Jump(join)
+		}
+		callBlock.Terminator.SetPos(call.Pos())
+		phi.AddPhiCandidate(ossa.BasicBlockValue{Block: callBlock, Value: direct})
+		callBlocks = append(callBlocks, callBlock)
+		return callBlock
+	}
+	cases := make([]ossa.BasicBlockValue, len(targets))
+	for i, target := range targets {
+		cases[i] = ossa.BasicBlockValue{Value: target, Block: newCallBlock(target)}
+	}
+	fallback := newCallBlock(callee)
+	f.AddBlock(join)
+
+	rest := block.Instructions[idx+1:]
+	join.Instructions = make([]*ossa.Value, 0, len(rest)+1)
+	join.Instructions = append(join.Instructions, phi)
+	join.Instructions = append(join.Instructions, rest...)
+	join.Terminator = block.Terminator
+	if unwind != nil {
+		join.Terminator = ossa.Jump(block.Terminator.Arg(0).Block)
+		join.Terminator.SetPos(block.Terminator.Pos())
+		splitPhiPredecessor(unwind, block, callBlocks)
+	}
+	replacePhiPredecessor(join, block, join)
+
+	for i := idx; i < len(block.Instructions); i++ {
+		block.Instructions[i] = nil // don't retain moved instructions
+	}
+	block.Instructions = block.Instructions[:idx]
+	block.Terminator = ossa.Switch(callee, fallback, cases...)
+	block.Terminator.SetPos(call.Pos())
+
+	replaceUses(f, map[*ossa.Value]*ossa.Value{call: phi})
+	return join
+}
+
+// splitPhiPredecessor replaces the candidate for predecessor "from" in each
+// of the Phi nodes at the start of the given block with a candidate for each
+// of the given new predecessors, all with the same value.
+func splitPhiPredecessor(block, from *ossa.BasicBlock, to []*ossa.BasicBlock) {
+	for _, inst := range block.Instructions {
+		if inst.Op() != ossa.OpPhi {
+			break
+		}
+		i := phiCandidateIndex(inst, from)
+		if i < 0 {
+			continue
+		}
+		v := inst.PhiCandidate(i).Value
+		inst.RemovePhiCandidate(i)
+		for _, pred := range to {
+			inst.AddPhiCandidate(ossa.BasicBlockValue{Block: pred, Value: v})
+		}
+	}
+}
+
+func isIndirectCallee(callee *ossa.Value) bool {
+	if callee == nil {
+		return false
+	}
+	switch callee.Op() {
+	case ossa.OpGlobalSym, ossa.OpAuxLiteral:
+		return false
+	default:
+		return true
+	}
+}
+
+// uniqueTargets returns the given targets with any nil or duplicate values
+// removed, preserving the order of the remainder.
+func uniqueTargets(targets []*ossa.Value) []*ossa.Value {
+	seen := make(ossa.ValueSet)
+	ret := targets[:0:0]
+	for _, target := range targets {
+		if target == nil || seen.Has(target) {
+			continue
+		}
+		seen.Add(target)
+		ret = append(ret, target)
+	}
+	return ret
+}
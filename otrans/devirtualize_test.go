@@ -0,0 +1,141 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestDevirtualize(t *testing.T) {
+	src := `
+global @a
+global @b
+global @log
+
+func @f(obj) {
+entry:
+    method = Load obj
+    result = Call method, obj
+    logged = Call @log, result
+    Jump exit
+exit:
+    final = Phi [entry: result]
+    Return final
+}
+`
+	m, err := otext.ParseModule([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := m.Function("f")
+
+	var calls []*ossa.Value
+	oracle := CallTargetOracleFunc(func(call *ossa.Value) []*ossa.Value {
+		calls = append(calls, call)
+		// We include a duplicate to make sure it's ignored.
+		return []*ossa.Value{m.Global("a"), m.Global("b"), m.Global("a")}
+	})
+
+	if got, want := Devirtualize(f, oracle), 1; got != want {
+		t.Errorf("wrong number of calls rewritten %d; want %d", got, want)
+	}
+	if got, want := len(calls), 1; got != want {
+		// The direct calls to @log, @a and @b should not be offered to the
+		// oracle.
+		t.Errorf("oracle was called %d times; want %d", got, want)
+	}
+
+	got := otext.SprintModule(m)
+	want := `global @a
+global @b
+global @log
+
+func @f(v0) {
+b0:
+    v1 = Load v0
+    Switch v1, b4 [@a: b2] [@b: b3]
+b1:
+    v2 = Phi [b5: v6]
+    Return v2
+b2:
+    v3 = Call @a, v0
+    Jump b5
+b3:
+    v4 = Call @b, v0
+    Jump b5
+b4:
+    v5 = Call v1, v0
+    Jump b5
+b5:
+    v6 = Phi [b2: v3] [b3: v4] [b4: v5]
+    v7 = Call @log, v6
+    Jump b1
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDevirtualizeInvoke(t *testing.T) {
+	src := `
+global @a
+
+func @f(obj) {
+entry:
+    method = Load obj
+    result = Call method, obj
+    Invoke result, ok, fail
+ok:
+    Return result
+fail:
+    callee = Phi [entry: method]
+    exc = LandingPad
+    logged = Call @a, callee
+    Resume exc
+}
+`
+	m, err := otext.ParseModule([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := m.Function("f")
+	oracle := CallTargetOracleFunc(func(call *ossa.Value) []*ossa.Value {
+		return []*ossa.Value{m.Global("a")}
+	})
+
+	if got, want := Devirtualize(f, oracle), 1; got != want {
+		t.Errorf("wrong number of calls rewritten %d; want %d", got, want)
+	}
+
+	// The unwind target's Phi node gains a candidate for each new call.
+	got := otext.SprintModule(m)
+	want := `global @a
+
+func @f(v0) {
+b0:
+    v1 = Load v0
+    Switch v1, b4 [@a: b3]
+b1:
+    Return v7
+b2:
+    v2 = Phi [b3: v1] [b4: v1]
+    v3 = LandingPad
+    v4 = Call @a, v2
+    Resume v3
+b3:
+    v5 = Call @a, v0
+    Invoke v5, b5, b2
+b4:
+    v6 = Call v1, v0
+    Invoke v6, b5, b2
+b5:
+    v7 = Phi [b3: v5] [b4: v6]
+    Jump b1
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,3 @@
+// Package otrans is a utility package for ossa that contains transforms,
+// which rewrite functions in-place to simplify or optimize them.
+package otrans
@@ -0,0 +1,82 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// RedirectEdge changes the terminator of block "from" so that any of its
+// targets that refer to block oldSucc instead refer to block newSucc, and
+// updates the Phi nodes of both successors to match. It does nothing if
+// oldSucc is not a successor of from.
+//
+// The Phi nodes of oldSucc lose their candidates for from. Unless from was
+// already a predecessor of newSucc, each Phi node of newSucc gains a
+// candidate for from with the value that would have arrived there through
+// oldSucc: the Phi node's candidate for oldSucc, or, if that is itself one of
+// oldSucc's Phi nodes, that node's candidate for from. This is correct for
+// the common case of bypassing oldSucc to reach one of its own successors. A
+// Phi node with no candidate for oldSucc gets a candidate with no value, which
+// the caller must then set. If from was already a predecessor of newSucc then
+// its existing candidates in newSucc are kept.
+func RedirectEdge(from, oldSucc, newSucc *ossa.BasicBlock) {
+	if oldSucc == newSucc || !hasSuccessor(from, oldSucc) {
+		return
+	}
+	if !hasSuccessor(from, newSucc) {
+		for _, phi := range newSucc.Instructions {
+			if phi.Op() != ossa.OpPhi {
+				break
+			}
+			var v *ossa.Value
+			if i := phiCandidateIndex(phi, oldSucc); i >= 0 {
+				v = phi.PhiCandidate(i).Value
+				if v != nil && v.Op() == ossa.OpPhi && isInstructionOf(oldSucc, v) {
+					if j := phiCandidateIndex(v, from); j >= 0 {
+						v = v.PhiCandidate(j).Value
+					} else {
+						v = nil
+					}
+				}
+			}
+			phi.AddPhiCandidate(ossa.BasicBlockValue{Block: from, Value: v})
+		}
+	}
+	retargetTerminator(from.Terminator, oldSucc, newSucc)
+	RemovePredecessor(oldSucc, from)
+}
+
+// SplitEdge inserts a new empty block on the edge from one block to another,
+// returning the new block.
+//
+// The new block ends with a Jump to the original target and is added to the
+// end of the given function, which must own both blocks. It belongs to the
+// same region as the target. The source block's terminator is retargeted to
+// the new block, keeping any weights, and any Phi candidates in the target for
+// the source block instead refer to the new block. If the terminator has
+// multiple arguments with the same target then they all share the new block.
+func SplitEdge(f *ossa.Function, from, to *ossa.BasicBlock) *ossa.BasicBlock {
+	block := f.NewBlock()
+	block.Region = to.Region
+	block.Terminator = ossa.Jump(to)
+	block.Terminator.SetPos(from.Terminator.Pos())
+	retargetTerminator(from.Terminator, to, block)
+	replacePhiPredecessor(block, from, block)
+	return block
+}
+
+// RemovePredecessor removes from the Phi nodes at the start of the given
+// block any candidates for the given predecessor. It must be called whenever
+// all of the edges from that predecessor to the block are removed, such as
+// when a Branch is replaced with a Jump to only one of its targets.
+func RemovePredecessor(block, pred *ossa.BasicBlock) {
+	for _, inst := range block.Instructions {
+		if inst.Op() != ossa.OpPhi {
+			break
+		}
+		for i := inst.NumArgs() - 1; i >= 0; i-- {
+			if inst.PhiCandidate(i).Block == pred {
+				inst.RemovePhiCandidate(i)
+			}
+		}
+	}
+}
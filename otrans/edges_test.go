@@ -0,0 +1,59 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestRedirectEdge(t *testing.T) {
+	src := `func(c) {
+    one = AuxLiteral 1
+    two = AuxLiteral 2
+entry:
+    Branch c, mid, other
+other:
+    Jump mid
+mid:
+    m = Phi [entry: one] [other: two]
+    Jump exit
+exit:
+    p = Phi [mid: m]
+    q = Phi [mid: c]
+    Return p, q
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	blocks := f.AppendBlocks(nil)
+	entry, other, mid, exit := blocks[0], blocks[1], blocks[2], blocks[3]
+
+	// The entry block bypasses mid, so the Phi nodes in exit receive the
+	// values that would have arrived through mid.
+	RedirectEdge(entry, mid, exit)
+	// There's no edge from other to exit, so this does nothing.
+	RedirectEdge(other, exit, mid)
+
+	got := otext.SprintFunction(f)
+	want := `func(v0) {
+    v1 = AuxLiteral 2
+    v2 = AuxLiteral 1
+b0:
+    Branch v0, b3, b1
+b1:
+    Jump b2
+b2:
+    v3 = Phi [b1: v1]
+    Jump b3
+b3:
+    v4 = Phi [b2: v3] [b0: v2]
+    v5 = Phi [b2: v0] [b0: v0]
+    Return v4, v5
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+)
+
+// ConvertHeapToStack replaces the allocation calls in the given function
+// whose objects do not escape with allocations in the function's activation,
+// returning the number of calls replaced.
+//
+// Allocation calls and their escapes are found using oana.FindAllocations
+// with the given module and registry. For each call to be replaced, the
+// given stackAlloc function is called with a builder pointed immediately
+// before it, and must emit and return a value referring to memory that
+// remains valid until the function returns and is initialized as the
+// allocator would have initialized it. This is usually a frontend-defined
+// StackAlloc operation registered with ossa.RegisterOp. The uses of the call
+// are then replaced with that value and the call is removed.
+//
+// An allocation is not replaced if its block is part of a cycle in the
+// control flow graph, because each iteration would then consume more of the
+// stack, and an object from an earlier iteration might still be in use. If
+// the replaced call was used by an Invoke terminator then the Invoke is
+// replaced with a Jump to its normal target, since the stack allocation
+// cannot raise an exception. Unless it is also the normal target, the unwind
+// target loses its Phi candidates for that block, and is left in place even
+// if it becomes unreachable.
+func ConvertHeapToStack(f *ossa.Function, m *ossa.Module, r *oana.IntrinsicRegistry, stackAlloc func(b *ossa.Builder, call *ossa.Value) *ossa.Value) int {
+	allocs := oana.FindAllocations(m, f.Entry(), r)
+	b := ossa.NewFunctionBuilder(f)
+	repl := make(map[*ossa.Value]*ossa.Value)
+	for _, block := range f.AppendBlocks(nil) {
+		var calls []*ossa.Value
+		for _, inst := range block.Instructions {
+			if _, isAlloc := allocs[inst]; isAlloc && !allocs.Escapes(inst) {
+				calls = append(calls, inst)
+			}
+		}
+		if len(calls) == 0 || inCycle(block) {
+			continue
+		}
+		for _, call := range calls {
+			if call == invokedCall(block) {
+				t := block.Terminator
+				block.Terminator = ossa.Jump(t.Arg(0).Block)
+				block.Terminator.SetPos(t.Pos())
+				if unwind := t.Arg(1).Block; unwind != t.Arg(0).Block {
+					RemovePredecessor(unwind, block)
+				}
+			}
+			b.SetPos(call.Pos())
+			b.SetInsertBefore(block, call)
+			repl[call] = stackAlloc(b, call)
+			removeInstruction(block, call)
+		}
+	}
+	b.SetPos(nil)
+	replaceUses(f, repl)
+	return len(repl)
+}
+
+// inCycle returns true if the given block can reach itself by following the
+// edges of the control flow graph.
+func inCycle(block *ossa.BasicBlock) bool {
+	seen := make(ossa.BasicBlockSet)
+	todo := block.Terminator.AppendSuccessors(nil)
+	for len(todo) > 0 {
+		next := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if next == block {
+			return true
+		}
+		if seen.Has(next) || next.Terminator == nil {
+			continue
+		}
+		seen.Add(next)
+		todo = next.Terminator.AppendSuccessors(todo)
+	}
+	return false
+}
+
+// removeInstruction removes the given instruction from the given block,
+// which must contain it.
+func removeInstruction(block *ossa.BasicBlock, inst *ossa.Value) {
+	insts := block.Instructions
+	for i, other := range insts {
+		if other == inst {
+			copy(insts[i:], insts[i+1:])
+			insts[len(insts)-1] = nil
+			block.Instructions = insts[:len(insts)-1]
+			return
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestConvertHeapToStack(t *testing.T) {
+	src := `func(p, c) {
+    alloc = AuxLiteral "alloc"
+    use = AuxLiteral "use"
+entry:
+    size = AuxLiteral 8
+    local = Call alloc, size
+    s1 = Store size, local
+    val = Load local
+    passed = Call alloc, size
+    r = Call use, passed
+    Jump loop
+loop:
+    looped = Call alloc, size
+    s2 = Store val, looped
+    Branch p, loop, other
+other:
+    x = Call c
+    Invoke x, invoked, fail
+invoked:
+    inv = Call alloc, size
+    Invoke inv, done, fail
+done:
+    s3 = Store val, inv
+    Return
+fail:
+    from = Phi [other: x] [invoked: size]
+    e = LandingPad
+    Resume e
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// passed escapes, looped is allocated in a loop, and the Invoke of inv
+	// becomes a Jump because the stack allocation cannot fail.
+	r := oana.NewIntrinsicRegistry()
+	r.RegisterAux("alloc", &oana.Intrinsic{Name: "alloc", Effects: oana.IntrinsicAllocates | oana.IntrinsicMayFail})
+	stackAlloc := func(b *ossa.Builder, call *ossa.Value) *ossa.Value {
+		return b.Call(b.AuxLiteral("stack"), call.Arg(1))
+	}
+
+	if got, want := ConvertHeapToStack(f, nil, r, stackAlloc), 2; got != want {
+		t.Errorf("wrong number of allocations converted %d; want %d", got, want)
+	}
+	got := otext.SprintFunction(f)
+	want := `func(v0, v1) {
+    v2 = AuxLiteral "stack"
+    v3 = AuxLiteral "alloc"
+    v4 = AuxLiteral "use"
+    v5 = AuxLiteral "stack"
+b0:
+    v6 = AuxLiteral 8
+    v7 = Call v2, v6
+    v8 = Store v6, v7
+    v9 = Load v7
+    v10 = Call v3, v6
+    v11 = Call v4, v10
+    Jump b1
+b1:
+    v12 = Call v3, v6
+    v13 = Store v9, v12
+    Branch v0, b1, b2
+b2:
+    v14 = Call v1
+    Invoke v14, b3, b5
+b3:
+    v15 = Call v5, v6
+    Jump b4
+b4:
+    v16 = Store v9, v15
+    Return
+b5:
+    v17 = Phi [b2: v14]
+    v18 = LandingPad
+    Resume v18
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestConvertHeapToStackInvokeSameTargets(t *testing.T) {
+	// When the Invoke's normal and unwind targets are the same block, the
+	// edge to it remains and so must the Phi candidates for it.
+	src := `func() {
+    alloc = AuxLiteral "alloc"
+entry:
+    size = AuxLiteral 8
+    inv = Call alloc, size
+    Invoke inv, both, both
+both:
+    from = Phi [entry: size]
+    Return from
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	r := oana.NewIntrinsicRegistry()
+	r.RegisterAux("alloc", &oana.Intrinsic{Name: "alloc", Effects: oana.IntrinsicAllocates | oana.IntrinsicMayFail})
+	stackAlloc := func(b *ossa.Builder, call *ossa.Value) *ossa.Value {
+		return b.Call(b.AuxLiteral("stack"), call.Arg(1))
+	}
+
+	if got, want := ConvertHeapToStack(f, nil, r, stackAlloc), 1; got != want {
+		t.Errorf("wrong number of allocations converted %d; want %d", got, want)
+	}
+	got := otext.SprintFunction(f)
+	want := `func() {
+    v0 = AuxLiteral "stack"
+b0:
+    v1 = AuxLiteral 8
+    v2 = Call v0, v1
+    Jump b1
+b1:
+    v3 = Phi [b0: v1]
+    Return v3
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,189 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+)
+
+// IfConvert replaces conditional branches around cheap blocks with Select
+// instructions, returning the number of branches it replaced.
+//
+// A branch is converted if each of its targets is either an arm that jumps
+// to a common join block, or is that join block itself. An arm must have the
+// branching block as its only predecessor, must have no Phi nodes, and must
+// belong to the same region as the branching block. The arms' instructions
+// are moved into the branching block so that they run unconditionally, and
+// each Phi node in the join block then selects between its candidates using
+// the branch condition.
+//
+// Because the arms' instructions are executed speculatively, they must all
+// be free of side-effects and must not read memory. Operations are
+// classified using Op.HasSideEffects and Op.ReadsMemory, and the given
+// function, if not nil, can report that other instructions, such as calls to
+// known-pure functions, are also safe to execute speculatively. The total
+// cost of the instructions in the arms, as estimated by the given model, must
+// also be no greater than the given limit. An arm whose NoSpeculate field is
+// set is never converted.
+//
+// Converted arms are removed from the function. The branching block then
+// ends with a Jump to the join block, so SimplifyCFG can often merge the two
+// and expose further opportunities for conversion.
+func IfConvert(f *ossa.Function, model oana.CostModel, limit float64, pure func(v *ossa.Value) bool) int {
+	ic := &ifConverter{
+		f:     f,
+		model: model,
+		limit: limit,
+		pure:  pure,
+	}
+	count := 0
+	for ic.convertOnce() {
+		count++
+	}
+	return count
+}
+
+type ifConverter struct {
+	f     *ossa.Function
+	model oana.CostModel
+	limit float64
+	pure  func(v *ossa.Value) bool
+	preds map[*ossa.BasicBlock][]*ossa.BasicBlock
+}
+
+// convertOnce converts at most one branch, since each conversion invalidates
+// the predecessors table, and returns true if it did.
+func (ic *ifConverter) convertOnce() bool {
+	ic.preds = functionPredecessors(ic.f)
+	for _, block := range ic.f.AppendBlocks(nil) {
+		if ic.convert(block) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ic *ifConverter) convert(head *ossa.BasicBlock) bool {
+	t := head.Terminator
+	if t == nil || t.Op() != ossa.OpBranch {
+		return false
+	}
+	cond := t.Arg(0).Value
+	trueTarget, falseTarget := t.Arg(0).Block, t.Arg(1).Block
+	if cond == nil || trueTarget == falseTarget {
+		return false
+	}
+
+	// trueFrom and falseFrom are the predecessors of the join block that
+	// control arrives from when the condition is true and false
+	// respectively, which is the head block itself if there's no arm.
+	var join, trueFrom, falseFrom *ossa.BasicBlock
+	var arms []*ossa.BasicBlock
+	trueJoin, falseJoin := ic.armJoin(head, trueTarget), ic.armJoin(head, falseTarget)
+	switch {
+	case trueJoin != nil && trueJoin == falseJoin:
+		join, trueFrom, falseFrom = trueJoin, trueTarget, falseTarget
+		arms = []*ossa.BasicBlock{trueTarget, falseTarget}
+	case trueJoin != nil && trueJoin == falseTarget:
+		join, trueFrom, falseFrom = falseTarget, trueTarget, head
+		arms = []*ossa.BasicBlock{trueTarget}
+	case falseJoin != nil && falseJoin == trueTarget:
+		join, trueFrom, falseFrom = trueTarget, head, falseTarget
+		arms = []*ossa.BasicBlock{falseTarget}
+	default:
+		return false
+	}
+	if join == head {
+		return false
+	}
+
+	var cost float64
+	for _, arm := range arms {
+		for _, inst := range arm.Instructions {
+			if !ic.isPure(inst) {
+				return false
+			}
+			cost += ic.model.ValueCost(inst)
+		}
+	}
+	if cost > ic.limit {
+		return false
+	}
+
+	// Every Phi node in the join block must have a candidate for each side
+	// of the branch, or else we'd have nothing to select between.
+	for _, inst := range join.Instructions {
+		if inst.Op() != ossa.OpPhi {
+			break
+		}
+		if phiCandidateIndex(inst, trueFrom) < 0 || phiCandidateIndex(inst, falseFrom) < 0 {
+			return false
+		}
+	}
+
+	insts := head.Instructions
+	for _, arm := range arms {
+		insts = append(insts, arm.Instructions...)
+	}
+	for _, inst := range join.Instructions {
+		if inst.Op() != ossa.OpPhi {
+			break
+		}
+		ti, fi := phiCandidateIndex(inst, trueFrom), phiCandidateIndex(inst, falseFrom)
+		v := inst.PhiCandidate(ti).Value
+		if other := inst.PhiCandidate(fi).Value; other != v {
+			v = ossa.Select(cond, v, other)
+			v.SetType(inst.Type())
+			v.SetPos(t.Pos())
+			insts = append(insts, v)
+		}
+		if ti > fi {
+			ti, fi = fi, ti
+		}
+		inst.SetPhiCandidate(ti, ossa.BasicBlockValue{Block: head, Value: v})
+		inst.RemovePhiCandidate(fi)
+	}
+	head.Instructions = insts
+	head.Terminator = ossa.Jump(join)
+	head.Terminator.SetPos(t.Pos())
+
+	for _, arm := range arms {
+		arm.Instructions = nil
+		arm.Terminator = nil
+		ic.f.RemoveBlock(arm)
+	}
+	return true
+}
+
+// armJoin returns the block that the given arm of a branch from the given
+// head block jumps to, or nil if the arm is not suitable for conversion.
+func (ic *ifConverter) armJoin(head, arm *ossa.BasicBlock) *ossa.BasicBlock {
+	if arm == head || arm == ic.f.Entry() || arm.NoSpeculate || !arm.SameRegion(head) || hasPhis(arm) {
+		return nil
+	}
+	if preds := ic.preds[arm]; len(preds) != 1 || preds[0] != head {
+		return nil
+	}
+	t := arm.Terminator
+	if t == nil || t.Op() != ossa.OpJump || t.Arg(0).Block == arm {
+		return nil
+	}
+	return t.Arg(0).Block
+}
+
+func (ic *ifConverter) isPure(v *ossa.Value) bool {
+	if op := v.Op(); !op.HasSideEffects() && !op.ReadsMemory() {
+		return true
+	}
+	return ic.pure != nil && ic.pure(v)
+}
+
+// phiCandidateIndex returns the index of the given Phi node's candidate for
+// the given predecessor, or -1 if it has none.
+func phiCandidateIndex(phi *ossa.Value, pred *ossa.BasicBlock) int {
+	for i := 0; i < phi.NumArgs(); i++ {
+		if phi.PhiCandidate(i).Block == pred {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,171 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestIfConvert(t *testing.T) {
+	model := &oana.OpCostModel{
+		Weights: map[ossa.Op]float64{
+			ossa.OpCall: 1,
+		},
+	}
+	pure := func(v *ossa.Value) bool {
+		if v.Op() != ossa.OpCall {
+			return false
+		}
+		name, ok := ossa.AuxAs[string](v.Arg(0))
+		return ok && name == "add"
+	}
+
+	t.Run("convert", func(t *testing.T) {
+		src := `func(c, d, a) {
+    add = AuxLiteral "add"
+    one = AuxLiteral 1
+    two = AuxLiteral 2
+entry:
+    Branch c, yes, no
+yes:
+    x = Call add, a, one
+    Jump join
+no:
+    y = Call add, a, two
+    Jump join
+join:
+    p = Phi [yes: x] [no: y]
+    q = Phi [no: one] [yes: one]
+    Branch d, join2, more
+more:
+    z = Call add, p, q
+    Jump join2
+join2:
+    r = Phi [join: p] [more: z]
+    Return r
+}
+`
+		f, err := otext.ParseFunction([]byte(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := IfConvert(f, model, 2, pure), 2; got != want {
+			t.Errorf("wrong number of branches converted %d; want %d", got, want)
+		}
+
+		got := otext.SprintFunction(f)
+		want := `func(v0, v1, v2) {
+    v3 = AuxLiteral "add"
+    v4 = AuxLiteral 1
+    v5 = AuxLiteral 2
+b0:
+    v6 = Call v3, v2, v4
+    v7 = Call v3, v2, v5
+    v8 = Select v0, v6, v7
+    Jump b1
+b1:
+    v9 = Phi [b0: v8]
+    v10 = Phi [b0: v4]
+    v11 = Call v3, v9, v10
+    v12 = Select v1, v9, v11
+    Jump b2
+b2:
+    v13 = Phi [b1: v12]
+    Return v13
+}
+`
+		if got != want {
+			t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	src := `func(c, a) {
+    add = AuxLiteral "add"
+    one = AuxLiteral 1
+entry:
+    Branch c, yes, join
+yes:
+    x = Call add, a, one
+    y = Call add, x, one
+    Jump join
+join:
+    p = Phi [entry: a] [yes: y]
+    Return p
+}
+`
+	tests := map[string]struct {
+		limit float64
+		setup func(f *ossa.Function)
+	}{
+		"too expensive": {
+			limit: 1,
+		},
+		"impure": {
+			limit: 2,
+			setup: func(f *ossa.Function) {
+				yes := f.AppendBlocks(nil)[1]
+				yes.Instructions[0].SetArg(0, ossa.AuxLiteral("print"))
+			},
+		},
+		"no speculate": {
+			limit: 2,
+			setup: func(f *ossa.Function) {
+				f.AppendBlocks(nil)[1].NoSpeculate = true
+			},
+		},
+		"different region": {
+			limit: 2,
+			setup: func(f *ossa.Function) {
+				f.AppendBlocks(nil)[1].Region = "other"
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := otext.ParseFunction([]byte(src))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if test.setup != nil {
+				test.setup(f)
+			}
+			want := otext.SprintFunction(f)
+			if got := IfConvert(f, model, test.limit, pure); got != 0 {
+				t.Errorf("converted %d branches; want none", got)
+			}
+			if got := otext.SprintFunction(f); got != want {
+				t.Errorf("function was modified\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+
+	t.Run("triangle", func(t *testing.T) {
+		f, err := otext.ParseFunction([]byte(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := IfConvert(f, model, 2, pure), 1; got != want {
+			t.Errorf("wrong number of branches converted %d; want %d", got, want)
+		}
+
+		got := otext.SprintFunction(f)
+		want := `func(v0, v1) {
+    v2 = AuxLiteral "add"
+    v3 = AuxLiteral 1
+b0:
+    v4 = Call v2, v1, v3
+    v5 = Call v2, v4, v3
+    v6 = Select v0, v5, v1
+    Jump b1
+b1:
+    v7 = Phi [b0: v6]
+    Return v7
+}
+`
+		if got != want {
+			t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
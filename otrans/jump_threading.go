@@ -0,0 +1,168 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+)
+
+// ThreadJumps rewrites the given function so that predecessors of certain
+// blocks jump directly to the successor those blocks would choose for them,
+// returning the number of edges that were threaded.
+//
+// A block is considered if it contains only Phi nodes and ends with a Branch
+// or Switch whose condition or input is one of those Phi nodes. For each
+// predecessor whose candidate for that Phi node is an AuxLiteral that decides
+// the outcome, as described for FoldConstantBranches, the predecessor's edge
+// is moved to the chosen successor. The Phi nodes of the successor gain a
+// candidate for the predecessor, using the predecessor's own candidate where
+// the value came from a Phi node of the bypassed block, and the bypassed
+// block's Phi nodes lose their candidates for the predecessor.
+//
+// This is the shape frontends typically produce when lowering short-circuit
+// boolean operators, where a join block merges the outcomes of the operands
+// only to branch on the result.
+//
+// Because the bypassed block's Phi nodes are no longer reached from the
+// predecessor, a block is considered only if its Phi nodes are used nowhere
+// except its own terminator and the Phi candidates of its successors for
+// edges from it. An edge is also not threaded if the predecessor already
+// has an edge to the chosen successor and that successor has Phi nodes,
+// since each Phi node can have only one candidate per predecessor.
+//
+// Blocks that become unreachable as a result are left in place, for removal
+// by a later transform.
+func ThreadJumps(f *ossa.Function, truthy func(lit *ossa.Value) (result, ok bool)) int {
+	uses := oana.BuildUses(f.Entry())
+	count := 0
+	for _, block := range f.AppendBlocks(nil) {
+		t := block.Terminator
+		if t == nil || (t.Op() != ossa.OpBranch && t.Op() != ossa.OpSwitch) {
+			continue
+		}
+		cond := t.Arg(0).Value
+		if !threadable(block, cond, uses) {
+			continue
+		}
+
+		for _, pred := range functionPredecessors(f)[block] {
+			if pred == block {
+				continue
+			}
+			i := phiCandidateIndex(cond, pred)
+			if i < 0 {
+				continue
+			}
+			lit := cond.PhiCandidate(i).Value
+			if lit == nil || lit.Op() != ossa.OpAuxLiteral {
+				continue
+			}
+			folded := foldConstantTerminator(withInput(t, lit), truthy)
+			if folded == nil || folded.Op() != ossa.OpJump {
+				continue
+			}
+			target := folded.Arg(0).Block
+			if target == block || (hasPhis(target) && hasSuccessor(pred, target)) {
+				continue
+			}
+			if !addThreadedCandidates(target, block, pred, uses) {
+				continue
+			}
+			retargetTerminator(pred.Terminator, block, target)
+			RemovePredecessor(block, pred)
+			count++
+		}
+	}
+	return count
+}
+
+// threadable returns true if the given block contains only Phi nodes,
+// including the given condition, and those Phi nodes are used only by the
+// block's terminator and by Phi candidates for edges from the block.
+func threadable(block *ossa.BasicBlock, cond *ossa.Value, uses oana.UsesTable) bool {
+	if cond == nil || !isInstructionOf(block, cond) {
+		return false
+	}
+	for _, inst := range block.Instructions {
+		if inst.Op() != ossa.OpPhi {
+			return false
+		}
+		for _, u := range uses[inst] {
+			switch {
+			case u.Value == nil && u.Block == block:
+				// Used by the block's own terminator.
+			case u.Value != nil && u.Value.Op() == ossa.OpPhi && u.Value.PhiCandidate(u.Index).Block == block:
+				// Used as a candidate for an edge from the block.
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// addThreadedCandidates adds a candidate for pred to each Phi node in
+// target, with the value that arrives there from pred through block. It
+// returns false without making any changes if some Phi node lacks the
+// candidates needed to find that value.
+func addThreadedCandidates(target, block, pred *ossa.BasicBlock, uses oana.UsesTable) bool {
+	var phis []*ossa.Value
+	var vals []*ossa.Value
+	for _, phi := range target.Instructions {
+		if phi.Op() != ossa.OpPhi {
+			break
+		}
+		j := phiCandidateIndex(phi, block)
+		if j < 0 {
+			return false
+		}
+		v := phi.PhiCandidate(j).Value
+		if v != nil && v.Op() == ossa.OpPhi && isInstructionOf(block, v) {
+			k := phiCandidateIndex(v, pred)
+			if k < 0 {
+				return false
+			}
+			v = v.PhiCandidate(k).Value
+		}
+		phis = append(phis, phi)
+		vals = append(vals, v)
+	}
+	for i, phi := range phis {
+		phi.AddPhiCandidate(ossa.BasicBlockValue{Block: pred, Value: vals[i]})
+		if vals[i] != nil {
+			// Keep the table accurate for the blocks we've yet to visit.
+			uses[vals[i]] = append(uses[vals[i]], oana.Use{Block: target, Value: phi, Index: phi.NumArgs() - 1})
+		}
+	}
+	return true
+}
+
+// withInput returns a copy of the given Branch or Switch terminator with its
+// condition or input replaced by the given value.
+func withInput(t *ossa.Terminator, inp *ossa.Value) *ossa.Terminator {
+	if t.Op() == ossa.OpBranch {
+		return ossa.Branch(inp, t.Arg(0).Block, t.Arg(1).Block)
+	}
+	cases := make([]ossa.BasicBlockValue, t.NumSwitchCases())
+	for i := range cases {
+		cases[i] = t.SwitchCase(i)
+	}
+	return ossa.Switch(inp, t.SwitchDefault(), cases...)
+}
+
+func isInstructionOf(block *ossa.BasicBlock, v *ossa.Value) bool {
+	for _, inst := range block.Instructions {
+		if inst == v {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSuccessor(block, succ *ossa.BasicBlock) bool {
+	for _, s := range block.Terminator.AppendSuccessors(nil) {
+		if s == succ {
+			return true
+		}
+	}
+	return false
+}
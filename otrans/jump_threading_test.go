@@ -0,0 +1,92 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestThreadJumps(t *testing.T) {
+	// This is a typical lowering of "if a || b", where the join block
+	// merges the outcomes of the two operands only to branch on the
+	// result. The edge from entry can go directly to the "then" block.
+	src := `func(a, b) {
+    yes = AuxLiteral true
+entry:
+    Branch a, join, rhs
+rhs:
+    x = Call b
+    Jump join
+join:
+    p = Phi [entry: yes] [rhs: x]
+    Branch p, then, else
+then:
+    r = Phi [join: p]
+    Return r
+else:
+    Return a
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	truthy := func(lit *ossa.Value) (bool, bool) {
+		c, ok := lit.Const()
+		if !ok {
+			return false, false
+		}
+		return c.Bool()
+	}
+	if got, want := ThreadJumps(f, truthy), 1; got != want {
+		t.Errorf("wrong number of edges threaded %d; want %d", got, want)
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func(v0, v1) {
+    v2 = AuxLiteral true
+b0:
+    Branch v0, b3, b1
+b1:
+    v3 = Call v1
+    Jump b2
+b2:
+    v4 = Phi [b1: v3]
+    Branch v4, b3, b4
+b3:
+    v5 = Phi [b2: v4] [b0: v2]
+    Return v5
+b4:
+    Return v0
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A Phi node used outside of its block prevents threading.
+	src = `func(a, b) {
+    yes = AuxLiteral true
+entry:
+    Branch a, join, rhs
+rhs:
+    Jump join
+join:
+    p = Phi [entry: yes] [rhs: b]
+    Branch p, then, else
+then:
+    Return p
+else:
+    Return a
+}
+`
+	f, err = otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := ThreadJumps(f, truthy); got != 0 {
+		t.Errorf("threaded %d edges; want 0", got)
+	}
+}
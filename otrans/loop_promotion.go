@@ -0,0 +1,298 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+)
+
+// PromoteLoopMemory rewrites the loops of the given function so that memory
+// accessed only through a single loop-invariant reference is held in SSA
+// values while the loop runs, returning the number of references promoted.
+// This is the memory counterpart of hoisting loop-invariant instructions.
+//
+// A reference is promoted in a loop if it is defined outside of the loop,
+// every Load and Store in the loop that may access its memory does so
+// through the reference itself, and the loop contains no other instructions
+// that read or write memory, such as calls. References are compared using
+// the given alias analysis, or oana.BasicAliasAnalysis if it is nil. The
+// memory is loaded once in the loop's preheader, Loads in the loop are
+// replaced with the value most recently stored or loaded, with Phi nodes
+// where different values meet, and, if the loop stored to the memory, the
+// final value is stored once in each of the loop's exit blocks.
+//
+// Because the memory is then read and written on paths where the loop may
+// not have accessed it, a reference is promoted only if the loop always
+// accesses it before exiting, or if it is a symbol whose memory is always
+// valid to read and no access is in a block whose NoSpeculate field is set.
+// The loop must always store to the reference before exiting for the
+// stores to be added unless it is a local symbol, since otherwise the added
+// stores could be observed by others who share the memory. Loops with no
+// exits, and loops whose head is the function's entry block, are left
+// unchanged.
+//
+// A preheader is a block outside of the loop whose only successor is the
+// loop's head, and which is the head's only predecessor outside of the loop.
+// An exit block is dedicated if all of its predecessors are in the loop.
+// When promoting a reference, PromoteLoopMemory creates a preheader if
+// necessary, and splits any exiting edges that lead to exit blocks that are
+// not dedicated, as described for SplitEdge.
+func PromoteLoopMemory(f *ossa.Function, aa oana.AliasAnalysis) int {
+	if aa == nil {
+		aa = oana.BasicAliasAnalysis{}
+	}
+	count := 0
+	for {
+		n := promoteLoopMemoryOnce(f, aa)
+		if n == 0 {
+			return count
+		}
+		count += n
+	}
+}
+
+// promoteLoopMemoryOnce promotes the references of at most one loop, since
+// each promotion invalidates the loop information, and returns the number
+// of references it promoted. Inner loops are considered before the loops
+// that contain them.
+func promoteLoopMemoryOnce(f *ossa.Function, aa oana.AliasAnalysis) int {
+	entry := f.Entry()
+	preds := oana.FindPredecessors(entry)
+	loops := oana.FindLoopInfo(entry, preds)
+	dt := oana.FindDominatorTree(entry, preds)
+	for i := len(loops.Loops) - 1; i >= 0; i-- {
+		loop := loops.Loops[i]
+		if loop.Head == entry {
+			continue
+		}
+		refs := promotableRefs(loop, dt, aa)
+		if len(refs) == 0 {
+			continue
+		}
+		ph := loopPreheader(f, loop)
+		dedicateLoopExits(f, loop)
+		for _, ref := range refs {
+			promoteLoopRef(f, loop, ph, ref)
+		}
+		return len(refs)
+	}
+	return 0
+}
+
+// loopRef describes the accesses to a reference within a loop.
+type loopRef struct {
+	ref *ossa.Value
+
+	// hasStore is true if the loop stores to the reference at all.
+	hasStore bool
+
+	// mustLoad and mustStore are true if the loop always accesses, or always
+	// stores to, the reference before exiting.
+	mustLoad, mustStore bool
+
+	// noSpeculate is true if any access is in a NoSpeculate block.
+	noSpeculate bool
+}
+
+// promotableRefs returns the references that can be promoted in the given
+// loop, as described for PromoteLoopMemory, in the order of their first
+// access.
+func promotableRefs(loop *oana.Loop, dt *oana.DominatorTree, aa oana.AliasAnalysis) []*ossa.Value {
+	exiting := make(ossa.BasicBlockSet)
+	for _, edge := range loop.AppendExitingEdges(nil) {
+		exiting.Add(edge.From)
+	}
+	if len(exiting) == 0 {
+		return nil
+	}
+	// alwaysRuns returns true if the given block runs before the loop exits,
+	// however it exits.
+	alwaysRuns := func(block *ossa.BasicBlock) bool {
+		for from := range exiting {
+			if !dt.Dominates(block, from) {
+				return false
+			}
+		}
+		return true
+	}
+
+	defined := make(ossa.ValueSet)
+	for _, block := range loop.Blocks {
+		for _, inst := range block.Instructions {
+			defined.Add(inst)
+		}
+	}
+
+	var order []*loopRef
+	byRef := make(map[*ossa.Value]*loopRef)
+	for _, block := range loop.Blocks {
+		for _, inst := range block.Instructions {
+			var ref *ossa.Value
+			switch op := inst.Op(); {
+			case op == ossa.OpLoad:
+				ref = inst.Arg(0)
+			case op == ossa.OpStore:
+				ref = inst.Arg(1)
+			case op.ReadsMemory() || op.WritesMemory():
+				return nil
+			default:
+				continue
+			}
+			r := byRef[ref]
+			if r == nil {
+				r = &loopRef{ref: ref}
+				byRef[ref] = r
+				order = append(order, r)
+			}
+			always := alwaysRuns(block)
+			r.mustLoad = r.mustLoad || always
+			if inst.Op() == ossa.OpStore {
+				r.hasStore = true
+				r.mustStore = r.mustStore || always
+			}
+			r.noSpeculate = r.noSpeculate || block.NoSpeculate
+		}
+	}
+
+	var ret []*ossa.Value
+	for _, r := range order {
+		if r.ref == nil || defined.Has(r.ref) {
+			continue
+		}
+		isSym := r.ref.Op() == ossa.OpLocalSym || r.ref.Op() == ossa.OpGlobalSym
+		if !r.mustLoad && !(isSym && !r.noSpeculate) {
+			continue
+		}
+		if r.hasStore && !r.mustStore && r.ref.Op() != ossa.OpLocalSym {
+			continue
+		}
+		clobbered := false
+		for _, other := range order {
+			if other != r && aa.MayAlias(r.ref, other.ref) != oana.NoAlias {
+				clobbered = true
+				break
+			}
+		}
+		if !clobbered {
+			ret = append(ret, r.ref)
+		}
+	}
+	return ret
+}
+
+// loopPreheader returns the preheader of the given loop, creating one if the
+// loop doesn't already have one.
+func loopPreheader(f *ossa.Function, loop *oana.Loop) *ossa.BasicBlock {
+	var outside []*ossa.BasicBlock
+	for _, pred := range functionPredecessors(f)[loop.Head] {
+		if !loop.Contains(pred) {
+			outside = append(outside, pred)
+		}
+	}
+	if len(outside) == 1 {
+		if t := outside[0].Terminator; t.Op() == ossa.OpJump {
+			return outside[0]
+		}
+	}
+
+	ph := f.NewBlock()
+	ph.Region = loop.Head.Region
+	ph.Terminator = ossa.Jump(loop.Head)
+	for _, pred := range outside {
+		retargetTerminator(pred.Terminator, loop.Head, ph)
+	}
+	for _, phi := range loop.Head.Instructions {
+		if phi.Op() != ossa.OpPhi {
+			break
+		}
+		cands := takePhiCandidates(phi, loop.Body, false)
+		v := cands[0].Value
+		if !sameCandidateValues(cands) {
+			v = ossa.Phi(cands...)
+			ph.Instructions = append(ph.Instructions, v)
+		}
+		phi.AddPhiCandidate(ossa.BasicBlockValue{Block: ph, Value: v})
+	}
+	return ph
+}
+
+// dedicateLoopExits splits each edge leaving the given loop whose target has
+// predecessors outside of the loop.
+func dedicateLoopExits(f *ossa.Function, loop *oana.Loop) {
+	preds := functionPredecessors(f)
+	seen := make(map[oana.Edge]bool)
+	for _, edge := range loop.AppendExitingEdges(nil) {
+		if seen[edge] {
+			continue
+		}
+		seen[edge] = true
+		for _, pred := range preds[edge.To] {
+			if !loop.Contains(pred) {
+				SplitEdge(f, edge.From, edge.To)
+				break
+			}
+		}
+	}
+}
+
+// promoteLoopRef promotes the given reference in the given loop, which must
+// have the given preheader and only dedicated exit blocks.
+func promoteLoopRef(f *ossa.Function, loop *oana.Loop, ph *ossa.BasicBlock, ref *ossa.Value) {
+	init := ossa.Load(ref)
+	appendInstruction(ph, init)
+
+	// The value of the memory at the end of each block of the loop is the
+	// value of its last Store, or else the value at the start of the block.
+	defs := map[*ossa.BasicBlock]*ossa.Value{ph: init}
+	hasStore := false
+	for _, block := range loop.Blocks {
+		for _, inst := range block.Instructions {
+			if inst.Op() == ossa.OpStore && inst.Arg(1) == ref {
+				defs[block] = inst.Arg(0)
+				hasStore = true
+			}
+		}
+	}
+	created := make(map[*ossa.Value]*ossa.BasicBlock)
+	r := newSSARepairer(functionPredecessors(f), created, defs)
+
+	// valueAtStart may insert Phi nodes into the blocks of the loop, so the
+	// accesses are removed only once all of the Loads have been replaced.
+	repl := make(map[*ossa.Value]*ossa.Value)
+	remove := make(ossa.ValueSet)
+	for _, block := range loop.Blocks {
+		var cur *ossa.Value
+		for _, inst := range append([]*ossa.Value(nil), block.Instructions...) {
+			switch {
+			case inst.Op() == ossa.OpLoad && inst.Arg(0) == ref:
+				if cur == nil {
+					cur = r.valueAtStart(block)
+				}
+				repl[inst] = cur
+				remove.Add(inst)
+			case inst.Op() == ossa.OpStore && inst.Arg(1) == ref:
+				cur = inst.Arg(0)
+				remove.Add(inst)
+			}
+		}
+	}
+	for _, block := range loop.Blocks {
+		insts := block.Instructions[:0]
+		for _, inst := range block.Instructions {
+			if !remove.Has(inst) {
+				insts = append(insts, inst)
+			}
+		}
+		for i := len(insts); i < len(block.Instructions); i++ {
+			block.Instructions[i] = nil
+		}
+		block.Instructions = insts
+	}
+
+	if hasStore {
+		for _, exit := range loop.AppendExits(nil) {
+			insertAfterPhis(exit, ossa.Store(r.valueAtStart(exit), ref))
+		}
+	}
+	replaceUses(f, repl)
+	removeTrivialPhis(f, created)
+}
@@ -0,0 +1,213 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestPromoteLoopMemory(t *testing.T) {
+	tests := map[string]struct {
+		src      string
+		want     string
+		promoted int
+	}{
+		"simple": {
+			`func(c) {
+    x = LocalSym
+    one = AuxLiteral 1
+    two = AuxLiteral 2
+entry:
+    s0 = Store one, x
+    Jump head
+head:
+    cur = Load x
+    next = Select c, cur, two
+    s1 = Store next, x
+    Branch c, head, exit
+exit:
+    r = Load x
+    Return r
+}
+`,
+			`func(v0) {
+    v1 = AuxLiteral 1
+    v2 = LocalSym
+    v3 = AuxLiteral 2
+b0:
+    v4 = Store v1, v2
+    v5 = Load v2
+    Jump b1
+b1:
+    v6 = Phi [b0: v5] [b1: v7]
+    v7 = Select v0, v6, v3
+    Branch v0, b1, b2
+b2:
+    v8 = Store v7, v2
+    v9 = Load v2
+    Return v9
+}
+`,
+			1,
+		},
+		"preheader and exits": {
+			`func(c, d) {
+    x = LocalSym
+    g = GlobalSym
+    one = AuxLiteral 1
+entry:
+    Branch c, a, b
+a:
+    Jump head
+b:
+    Branch d, head, exit
+head:
+    cur = Load x
+    old = Load g
+    Branch cur, body, exit
+body:
+    s0 = Store old, x
+    Branch d, head, exit
+exit:
+    r = Load x
+    Return r
+}
+`,
+			`func(v0, v1) {
+    v2 = LocalSym
+    v3 = GlobalSym
+b0:
+    Branch v0, b1, b2
+b1:
+    Jump b6
+b2:
+    Branch v1, b6, b5
+b3:
+    v4 = Phi [b4: v7] [b6: v6]
+    Branch v4, b4, b7
+b4:
+    Branch v1, b3, b8
+b5:
+    v5 = Load v2
+    Return v5
+b6:
+    v6 = Load v2
+    v7 = Load v3
+    Jump b3
+b7:
+    v8 = Store v4, v2
+    Jump b5
+b8:
+    v9 = Store v7, v2
+    Jump b5
+}
+`,
+			2,
+		},
+		"call": {
+			`func(c, f) {
+    x = LocalSym
+entry:
+    Jump head
+head:
+    cur = Load x
+    r = Call f
+    s0 = Store r, x
+    Branch c, head, exit
+exit:
+    Return
+}
+`,
+			`func(v0, v1) {
+    v2 = LocalSym
+b0:
+    Jump b1
+b1:
+    v3 = Load v2
+    v4 = Call v1
+    v5 = Store v4, v2
+    Branch v0, b1, b2
+b2:
+    Return
+}
+`,
+			0,
+		},
+		"may alias": {
+			`func(c, p) {
+    g = GlobalSym
+entry:
+    Jump head
+head:
+    cur = Load g
+    s0 = Store cur, p
+    Branch c, head, exit
+exit:
+    Return
+}
+`,
+			`func(v0, v1) {
+    v2 = GlobalSym
+b0:
+    Jump b1
+b1:
+    v3 = Load v2
+    v4 = Store v3, v1
+    Branch v0, b1, b2
+b2:
+    Return
+}
+`,
+			0,
+		},
+		"conditional global store": {
+			`func(c, d) {
+    g = GlobalSym
+    one = AuxLiteral 1
+entry:
+    Jump head
+head:
+    Branch d, body, latch
+body:
+    s0 = Store one, g
+    Jump latch
+latch:
+    Branch c, head, exit
+exit:
+    Return
+}
+`,
+			`func(v0, v1) {
+    v2 = AuxLiteral 1
+    v3 = GlobalSym
+b0:
+    Jump b1
+b1:
+    Branch v1, b2, b3
+b2:
+    v4 = Store v2, v3
+    Jump b3
+b3:
+    Branch v0, b1, b4
+b4:
+    Return
+}
+`,
+			0,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := otext.ParseFunction([]byte(test.src))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := PromoteLoopMemory(f, nil), test.promoted; got != want {
+				t.Errorf("wrong number of references promoted %d; want %d", got, want)
+			}
+			if got, want := otext.SprintFunction(f), test.want; got != want {
+				t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package otrans
+
+import (
+	"sort"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+)
+
+// PromoteLocalSyms rewrites the given function to replace Load and Store
+// operations on local symbols with direct use of the stored values, inserting
+// Phi nodes where different stored values meet. This is the transform
+// commonly known as "mem2reg".
+//
+// A local symbol is promoted only if it is used exclusively as the reference
+// operand of Load and Store instructions in blocks reachable from the entry
+// block. Symbols used in any other way, such as being passed to a Call, are
+// left unchanged because their memory might be accessed indirectly.
+//
+// A Load that is not preceded by a Store on every path from the entry block
+// is replaced with an undefined value, or produces a Phi candidate of an
+// undefined value, since the memory was not initialized on that path.
+//
+// The result is the set of local symbols that were promoted, none of which
+// are used in the function after the transform is complete.
+func PromoteLocalSyms(f *ossa.Function) ossa.ValueSet {
+	entry := f.Entry()
+	preds := oana.FindPredecessors(entry)
+	doms := oana.FindDominators(entry, preds)
+	idoms := oana.FindImmediateDominators(doms)
+	dfs := oana.FindDominanceFrontiers(preds, idoms)
+	order := blockOrder(f)
+	blocks := f.AppendBlocks(nil)
+
+	// First we'll find which symbols can be promoted, and which blocks
+	// store to each of them.
+	var syms []*ossa.Value
+	promotable := make(map[*ossa.Value]bool)
+	defBlocks := make(map[*ossa.Value][]*ossa.BasicBlock)
+	consider := func(sym *ossa.Value, ok bool) {
+		if _, seen := promotable[sym]; !seen {
+			syms = append(syms, sym)
+			promotable[sym] = ok
+			return
+		}
+		promotable[sym] = promotable[sym] && ok
+	}
+	for _, block := range blocks {
+		_, reachable := doms[block]
+		for _, inst := range block.Instructions {
+			for i := 0; i < inst.NumArgs(); i++ {
+				arg := inst.Arg(i)
+				if arg == nil || arg.Op() != ossa.OpLocalSym {
+					continue
+				}
+				isRef := (inst.Op() == ossa.OpLoad && i == 0) || (inst.Op() == ossa.OpStore && i == 1)
+				consider(arg, reachable && isRef)
+				if inst.Op() == ossa.OpStore && i == 1 {
+					defBlocks[arg] = append(defBlocks[arg], block)
+				}
+			}
+		}
+		if t := block.Terminator; t != nil {
+			for i := 0; i < t.NumArgs(); i++ {
+				if arg := t.Arg(i).Value; arg != nil && arg.Op() == ossa.OpLocalSym {
+					consider(arg, false)
+				}
+			}
+		}
+	}
+
+	// Now we'll decide where each symbol needs Phi nodes, using the iterated
+	// dominance frontier of the blocks that store to it.
+	phis := make(map[*ossa.BasicBlock][]*ossa.Value)
+	phiSyms := make(map[*ossa.Value]*ossa.Value)
+	ret := make(ossa.ValueSet)
+	for _, sym := range syms {
+		if !promotable[sym] {
+			continue
+		}
+		phiBlocks := make(ossa.BasicBlockSet)
+		work := append([]*ossa.BasicBlock(nil), defBlocks[sym]...)
+		for len(work) > 0 {
+			block := work[len(work)-1]
+			work = work[:len(work)-1]
+			for frontier := range dfs[block] {
+				if !phiBlocks.Has(frontier) {
+					phiBlocks.Add(frontier)
+					work = append(work, frontier)
+				}
+			}
+		}
+		if phiBlocks.Has(entry) {
+			// The entry block has an implied predecessor with no
+			// corresponding block, so we can't represent a Phi node there.
+			continue
+		}
+		for _, block := range sortedBlocks(phiBlocks, order) {
+			cands := make([]ossa.BasicBlockValue, 0, len(preds[block]))
+			for _, pred := range sortedBlocks(preds[block], order) {
+				cands = append(cands, ossa.BasicBlockValue{Block: pred})
+			}
+			phi := ossa.Phi(cands...)
+			insertPhi(block, phi)
+			phis[block] = append(phis[block], phi)
+			phiSyms[phi] = sym
+		}
+		ret.Add(sym)
+	}
+	if len(ret) == 0 {
+		return ret
+	}
+
+	// Finally we'll walk the dominator tree, tracking the current value of
+	// each symbol and replacing the loads and stores.
+	children := make(map[*ossa.BasicBlock][]*ossa.BasicBlock)
+	for _, block := range blocks {
+		if idom := idoms[block]; idom != nil {
+			children[idom] = append(children[idom], block)
+		}
+	}
+	repl := make(map[*ossa.Value]*ossa.Value)
+	current := make(map[*ossa.Value]*ossa.Value, len(ret))
+	undef := ossa.Undef()
+	for sym := range ret {
+		current[sym] = undef
+	}
+	var rename func(block *ossa.BasicBlock)
+	rename = func(block *ossa.BasicBlock) {
+		saved := make(map[*ossa.Value]*ossa.Value)
+		set := func(sym, val *ossa.Value) {
+			if _, exists := saved[sym]; !exists {
+				saved[sym] = current[sym]
+			}
+			current[sym] = val
+		}
+
+		for _, phi := range phis[block] {
+			set(phiSyms[phi], phi)
+		}
+		insts := block.Instructions[:0]
+		for _, inst := range block.Instructions {
+			switch {
+			case inst.Op() == ossa.OpLoad && ret.Has(inst.Arg(0)):
+				repl[inst] = current[inst.Arg(0)]
+			case inst.Op() == ossa.OpStore && ret.Has(inst.Arg(1)):
+				val := inst.Arg(0)
+				if r, exists := repl[val]; exists {
+					val = r
+				}
+				set(inst.Arg(1), val)
+			default:
+				insts = append(insts, inst)
+			}
+		}
+		for i := len(insts); i < len(block.Instructions); i++ {
+			block.Instructions[i] = nil // don't retain removed instructions
+		}
+		block.Instructions = insts
+
+		seen := make(ossa.BasicBlockSet)
+		block.AddSuccessors(basicBlockAdderFunc(func(succ *ossa.BasicBlock) {
+			if seen.Has(succ) {
+				return
+			}
+			seen.Add(succ)
+			for _, phi := range phis[succ] {
+				for i := 0; i < phi.NumArgs(); i++ {
+					if phi.PhiCandidate(i).Block == block {
+						phi.SetArg(i, current[phiSyms[phi]])
+					}
+				}
+			}
+		}))
+
+		for _, child := range children[block] {
+			rename(child)
+		}
+
+		for sym, val := range saved {
+			current[sym] = val
+		}
+	}
+	rename(entry)
+
+	replaceUses(f, repl)
+	return ret
+}
+
+func sortedBlocks(set ossa.BasicBlockSet, order map[*ossa.BasicBlock]int) []*ossa.BasicBlock {
+	ret := set.AppendBlocks(nil)
+	sort.Slice(ret, func(i, j int) bool {
+		return order[ret[i]] < order[ret[j]]
+	})
+	return ret
+}
+
+// basicBlockAdderFunc is a bit of a cheat to let us use functions that take
+// basicBlockAdderFuncs as a mapping function over whatever blocks are
+// added.
+type basicBlockAdderFunc func(block *ossa.BasicBlock)
+
+func (f basicBlockAdderFunc) Add(block *ossa.BasicBlock) {
+	f(block)
+}
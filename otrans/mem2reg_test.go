@@ -0,0 +1,125 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestPromoteLocalSyms(t *testing.T) {
+	tests := map[string]struct {
+		src      string
+		want     string
+		promoted int
+	}{
+		"diamond": {
+			`func(cond) {
+    x = LocalSym
+    one = AuxLiteral 1
+    two = AuxLiteral 2
+    escaped = LocalSym
+entry:
+    s1 = Store one, x
+    c = Call cond, escaped
+    Branch cond, then, join
+then:
+    s2 = Store two, x
+    Jump join
+join:
+    l = Load x
+    Return l
+}
+`,
+			`func(v0) {
+    v1 = LocalSym
+    v2 = AuxLiteral 1
+    v3 = AuxLiteral 2
+b0:
+    v4 = Call v0, v1
+    Branch v0, b1, b2
+b1:
+    Jump b2
+b2:
+    v5 = Phi [b0: v2] [b1: v3]
+    Return v5
+}
+`,
+			1,
+		},
+		"loop": {
+			`func(limit) {
+    i = LocalSym
+    zero = AuxLiteral 0
+    lt = AuxLiteral "lt"
+    inc = AuxLiteral "inc"
+entry:
+    s1 = Store zero, i
+    Jump header
+header:
+    cur = Load i
+    cond = Call lt, cur, limit
+    Branch cond, body, exit
+body:
+    cur2 = Load i
+    next = Call inc, cur2
+    s2 = Store next, i
+    Jump header
+exit:
+    result = Load i
+    Return result
+}
+`,
+			`func(v0) {
+    v1 = AuxLiteral 0
+    v2 = AuxLiteral "lt"
+    v3 = AuxLiteral "inc"
+b0:
+    Jump b1
+b1:
+    v4 = Phi [b0: v1] [b2: v6]
+    v5 = Call v2, v4, v0
+    Branch v5, b2, b3
+b2:
+    v6 = Call v3, v4
+    Jump b1
+b3:
+    Return v4
+}
+`,
+			1,
+		},
+		"uninitialized": {
+			`func() {
+    x = LocalSym
+entry:
+    l = Load x
+    Return l
+}
+`,
+			`func() {
+    v0 = Undef
+b0:
+    Return v0
+}
+`,
+			1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := otext.ParseFunction([]byte(test.src))
+			if err != nil {
+				t.Fatalf("invalid source: %s", err)
+			}
+			promoted := PromoteLocalSyms(f)
+			if got, want := len(promoted), test.promoted; got != want {
+				t.Errorf("wrong number of promoted symbols %d; want %d", got, want)
+			}
+			got := otext.SprintFunction(f)
+			if got != test.want {
+				t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
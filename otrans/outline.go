@@ -0,0 +1,353 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// OutlineRegion moves a single-entry, single-exit region of the given
+// function into a new function defined in the given module with the given
+// name, and replaces the region with a call to the new function. It returns
+// the new function, or nil if the region cannot be outlined or a function is
+// already defined with the given name, in which case neither the function
+// nor the module is changed.
+//
+// The region is made of the blocks reachable from the given entry block
+// without passing through the given exit block, which is not itself part of
+// the region. The region can be outlined only if all of the following hold:
+//
+//   - The entry block is not the function's entry block, and is the only
+//     block in the region with predecessors outside of it.
+//   - Every edge that leaves the region goes to the exit block, and there is
+//     at least one such edge.
+//   - Every terminator in the region is a Jump, Branch, Switch, Invoke or
+//     Unreachable, since control must leave the new function by returning
+//     to its caller.
+//   - Neither the entry block nor the exit block begins with a LandingPad,
+//     since an unwind edge cannot cross the function boundary.
+//   - At most one value defined in the region is used outside of it, since
+//     a Call has only one result.
+//
+// Values used in the region that are defined outside of it, including the
+// function's arguments and any local symbols, become the parameters of the
+// new function, in the order of their first use in the region, followed by
+// any merged Phi nodes described below. Other operands, such as literals and
+// global symbols, are shared by both functions.
+//
+// The region's blocks move to the new function, whose entry block jumps to
+// the region's entry. In the original function, the region's entry is
+// replaced by a new block that calls the new function and then jumps to the
+// exit block. Where a Phi node in the region's entry has different
+// candidates for its predecessors outside the region, those candidates move
+// to a Phi node in the new block whose result is passed to the new
+// function. Likewise, where a Phi node in the exit block has different
+// candidates for its predecessors in the region, those candidates move to a
+// Phi node in a new block of the new function that returns its result, and
+// that result counts as the region's one value used outside of it.
+func OutlineRegion(m *ossa.Module, f *ossa.Function, entry, exit *ossa.BasicBlock, name string) *ossa.Function {
+	if m.Function(name) != nil {
+		return nil
+	}
+	r := findOutlineRegion(f, functionPredecessors(f), entry, exit)
+	if r == nil {
+		return nil
+	}
+	return r.outline(m, f, name)
+}
+
+// outlineRegion is a region of a function that OutlineRegion has found to be
+// suitable for outlining.
+type outlineRegion struct {
+	entry, exit *ossa.BasicBlock
+
+	// blocks are the blocks of the region in the function's block order,
+	// and set is the same blocks as a set.
+	blocks []*ossa.BasicBlock
+	set    ossa.BasicBlockSet
+
+	// preds are the predecessors of the entry block outside of the region,
+	// and exiting are the blocks of the region that have the exit block as
+	// a successor.
+	preds, exiting []*ossa.BasicBlock
+
+	// liveIns are the values defined outside of the region that are used
+	// within it, except for any candidates of the entry block's Phi nodes
+	// that outline merges into a new Phi node.
+	liveIns []*ossa.Value
+
+	// liveOut is the value defined in the region that is used outside of it,
+	// or nil if there is none or if the exit block has a Phi node that
+	// needs a Phi node in the new function.
+	liveOut *ossa.Value
+}
+
+// findOutlineRegion returns the region with the given entry and exit blocks,
+// or nil if that region cannot be outlined as described for OutlineRegion.
+// The given predecessors must be the result of functionPredecessors for the
+// function.
+func findOutlineRegion(f *ossa.Function, preds map[*ossa.BasicBlock][]*ossa.BasicBlock, entry, exit *ossa.BasicBlock) *outlineRegion {
+	if entry == f.Entry() || entry == exit || startsWithLandingPad(entry) || startsWithLandingPad(exit) {
+		return nil
+	}
+	set := make(ossa.BasicBlockSet)
+	todo := []*ossa.BasicBlock{entry}
+	for len(todo) > 0 {
+		block := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if block == exit || set.Has(block) {
+			continue
+		}
+		set.Add(block)
+		t := block.Terminator
+		if t == nil {
+			return nil
+		}
+		switch t.Op() {
+		case ossa.OpJump, ossa.OpBranch, ossa.OpSwitch, ossa.OpInvoke, ossa.OpUnreachable:
+		default:
+			return nil
+		}
+		todo = t.AppendSuccessors(todo)
+	}
+
+	r := &outlineRegion{
+		entry: entry,
+		exit:  exit,
+		set:   set,
+	}
+	for _, pred := range preds[entry] {
+		if !set.Has(pred) {
+			r.preds = append(r.preds, pred)
+		}
+	}
+	for _, pred := range preds[exit] {
+		if set.Has(pred) {
+			r.exiting = append(r.exiting, pred)
+		}
+	}
+	if len(r.preds) == 0 || len(r.exiting) == 0 {
+		return nil
+	}
+
+	insts := make(ossa.ValueSet)
+	defs := make(ossa.ValueSet)
+	for _, block := range f.AppendBlocks(nil) {
+		for _, inst := range block.Instructions {
+			insts.Add(inst)
+			if set.Has(block) {
+				defs.Add(inst)
+			}
+		}
+		if !set.Has(block) {
+			continue
+		}
+		r.blocks = append(r.blocks, block)
+		if block != entry {
+			for _, pred := range preds[block] {
+				if !set.Has(pred) {
+					return nil
+				}
+			}
+		}
+	}
+
+	// Values flowing into and out of the region are found by visiting the
+	// operands of every instruction and terminator in the function.
+	seenIn := make(ossa.ValueSet)
+	addLiveIn := func(v *ossa.Value) {
+		if !defs.Has(v) && !seenIn.Has(v) && (insts.Has(v) || v.Op() == ossa.OpArgument || v.Op() == ossa.OpLocalSym) {
+			seenIn.Add(v)
+			r.liveIns = append(r.liveIns, v)
+		}
+	}
+	var outs []*ossa.Value
+	mergedOuts := 0
+	for _, block := range f.AppendBlocks(nil) {
+		inRegion := set.Has(block)
+		visit := func(v *ossa.Value) (*ossa.Value, bool) {
+			switch {
+			case inRegion:
+				addLiveIn(v)
+			case defs.Has(v) && (len(outs) == 0 || outs[0] != v):
+				outs = append(outs, v)
+			}
+			return v, true
+		}
+		for _, inst := range block.Instructions {
+			if inst.Op() != ossa.OpPhi || (block != entry && block != exit) {
+				ossa.WalkOperands(inst, visit)
+				continue
+			}
+			// Candidates for edges that cross the region's boundary are
+			// moved by outline, so they are visited only if they will
+			// remain as they are.
+			var crossing []ossa.BasicBlockValue
+			for i := 0; i < inst.NumArgs(); i++ {
+				c := inst.PhiCandidate(i)
+				if set.Has(c.Block) != inRegion {
+					if inRegion && defs.Has(c.Value) {
+						// A value from the region that flows back into
+						// its entry from outside can't be passed in.
+						return nil
+					}
+					crossing = append(crossing, c)
+				} else if c.Value != nil {
+					visit(c.Value)
+				}
+			}
+			switch {
+			case len(crossing) == 0:
+			case !sameCandidateValues(crossing):
+				if block == exit {
+					// The candidates move to a Phi node in the new
+					// function, so any from outside the region must be
+					// passed in.
+					mergedOuts++
+					for _, c := range crossing {
+						if c.Value != nil {
+							addLiveIn(c.Value)
+						}
+					}
+				}
+			case crossing[0].Value != nil:
+				visit(crossing[0].Value)
+			}
+		}
+		if block.Terminator != nil {
+			ossa.WalkTerminatorOperands(block.Terminator, visit)
+		}
+	}
+	switch {
+	case len(outs)+mergedOuts > 1:
+		return nil
+	case len(outs) == 1:
+		r.liveOut = outs[0]
+	}
+	return r
+}
+
+// outline moves the receiving region from the given function into a new
+// function, as described for OutlineRegion.
+func (r *outlineRegion) outline(m *ossa.Module, f *ossa.Function, name string) *ossa.Function {
+	callee := m.DefineFunction(name)
+	calleeEntry := callee.Entry()
+	calleeEntry.Region = r.entry.Region
+	calleeEntry.Terminator = ossa.Jump(r.entry)
+	caller := f.NewBlock()
+	caller.Region = r.entry.Region
+	ret := ossa.NewBasicBlock()
+	ret.Region = r.entry.Region
+	args := append([]*ossa.Value(nil), r.liveIns...)
+
+	// The entry's Phi nodes lose their candidates for predecessors outside
+	// the region, which become a single candidate for the new function's
+	// entry block. Where those candidates differ, they are merged by a Phi
+	// node in the calling block and the merged value is passed as an extra
+	// argument.
+	for _, phi := range r.entry.Instructions {
+		if phi.Op() != ossa.OpPhi {
+			break
+		}
+		cands := takePhiCandidates(phi, r.set, false)
+		var in *ossa.Value
+		switch {
+		case len(cands) == 0:
+		case !sameCandidateValues(cands):
+			in = ossa.Phi(cands...)
+			caller.Instructions = append(caller.Instructions, in)
+			args = append(args, in)
+		default:
+			in = cands[0].Value
+		}
+		phi.AddPhiCandidate(ossa.BasicBlockValue{Block: calleeEntry, Value: in})
+	}
+	for _, pred := range r.preds {
+		retargetTerminator(pred.Terminator, r.entry, caller)
+	}
+	call := ossa.Call(m.Global(name), args...)
+	caller.Instructions = append(caller.Instructions, call)
+	caller.Terminator = ossa.Jump(r.exit)
+
+	// The exit's Phi nodes lose their candidates for predecessors in the
+	// region, which become a single candidate for the calling block. Where
+	// those candidates differ, they are merged by a Phi node in the new
+	// function's return block, and the call's result is used instead.
+	result := r.liveOut
+	for _, phi := range r.exit.Instructions {
+		if phi.Op() != ossa.OpPhi {
+			break
+		}
+		cands := takePhiCandidates(phi, r.set, true)
+		var out *ossa.Value
+		switch {
+		case len(cands) == 0:
+		case !sameCandidateValues(cands):
+			result = ossa.Phi(cands...)
+			ret.Instructions = append(ret.Instructions, result)
+			out = call
+		default:
+			out = cands[0].Value
+		}
+		phi.AddPhiCandidate(ossa.BasicBlockValue{Block: caller, Value: out})
+	}
+	for _, block := range r.exiting {
+		retargetTerminator(block.Terminator, r.exit, ret)
+	}
+	if result != nil {
+		ret.Terminator = ossa.Return(result)
+	} else {
+		ret.Terminator = ossa.Return()
+	}
+
+	for _, block := range r.blocks {
+		f.RemoveBlock(block)
+		callee.AddBlock(block)
+	}
+	callee.AddBlock(ret)
+	params := make(map[*ossa.Value]*ossa.Value, len(args))
+	for _, arg := range args {
+		params[arg] = callee.AddParam()
+	}
+	replaceUses(callee, params)
+	if r.liveOut != nil {
+		replaceUses(f, map[*ossa.Value]*ossa.Value{r.liveOut: call})
+	}
+	return callee
+}
+
+// takePhiCandidates removes from the given Phi node the candidates whose
+// blocks are in the given set, if in is true, or are not in the set
+// otherwise, returning the removed candidates in their original order.
+func takePhiCandidates(phi *ossa.Value, set ossa.BasicBlockSet, in bool) []ossa.BasicBlockValue {
+	var ret []ossa.BasicBlockValue
+	for i := 0; i < phi.NumArgs(); {
+		if c := phi.PhiCandidate(i); set.Has(c.Block) == in {
+			ret = append(ret, c)
+			phi.RemovePhiCandidate(i)
+			continue
+		}
+		i++
+	}
+	return ret
+}
+
+// sameCandidateValues returns true if all of the given Phi candidates have
+// the same value.
+func sameCandidateValues(cands []ossa.BasicBlockValue) bool {
+	for _, c := range cands[1:] {
+		if c.Value != cands[0].Value {
+			return false
+		}
+	}
+	return true
+}
+
+// startsWithLandingPad returns true if the first instruction of the given
+// block after any Phi nodes is a LandingPad.
+func startsWithLandingPad(block *ossa.BasicBlock) bool {
+	for _, inst := range block.Instructions {
+		if inst.Op() != ossa.OpPhi {
+			return inst.Op() == ossa.OpLandingPad
+		}
+	}
+	return false
+}
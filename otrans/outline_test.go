@@ -0,0 +1,203 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestOutlineRegion(t *testing.T) {
+	src := `global @f
+global @g
+
+func @main(p, q) {
+    one = AuxLiteral 1
+entry:
+    x = Call @f, p
+    Branch q, head, other
+other:
+    Jump head
+head:
+    h = Phi [entry: x] [other: p]
+    y = Call @g, h, q
+    Branch y, left, right
+left:
+    l = Call @g, y, x
+    Jump join
+right:
+    Jump join
+join:
+    j = Phi [left: l] [right: one]
+    Return j
+}
+`
+	m, err := otext.ParseModule([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := m.Function("main")
+	blocks := f.AppendBlocks(nil)
+	if got := OutlineRegion(m, f, blocks[2], blocks[5], "main.outlined"); got == nil {
+		t.Fatalf("region was not outlined")
+	}
+
+	got := otext.SprintModule(m)
+	want := `global @f
+global @g
+
+func @main(v0, v1) {
+b0:
+    v2 = Call @f, v0
+    Branch v1, b3, b1
+b1:
+    Jump b3
+b2:
+    v3 = Phi [b3: v5]
+    Return v3
+b3:
+    v4 = Phi [b0: v2] [b1: v0]
+    v5 = Call @main.outlined, v1, v2, v4
+    Jump b2
+}
+
+func @main.outlined(v0, v1, v2) {
+    v3 = AuxLiteral 1
+b0:
+    Jump b1
+b1:
+    v4 = Phi [b0: v2]
+    v5 = Call @g, v4, v0
+    Branch v5, b2, b3
+b2:
+    v6 = Call @g, v5, v1
+    Jump b4
+b3:
+    Jump b4
+b4:
+    v7 = Phi [b2: v6] [b3: v3]
+    Return v7
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestOutlineRegionMergedExitPhi(t *testing.T) {
+	// The exit's Phi node has different candidates from the region, so
+	// they move to the new function, which must then take the one that is
+	// defined outside of the region as a parameter.
+	src := `global @f
+
+func @main(p, q) {
+entry:
+    x = Call @f, p
+    Jump head
+head:
+    Branch q, left, right
+left:
+    y = Call @f, q
+    Jump join
+right:
+    Jump join
+join:
+    j = Phi [left: y] [right: x]
+    Return j
+}
+`
+	m, err := otext.ParseModule([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := m.Function("main")
+	blocks := f.AppendBlocks(nil)
+	if got := OutlineRegion(m, f, blocks[1], blocks[4], "main.outlined"); got == nil {
+		t.Fatalf("region was not outlined")
+	}
+
+	got := otext.SprintModule(m)
+	want := `global @f
+
+func @main(v0, v1) {
+b0:
+    v2 = Call @f, v0
+    Jump b2
+b1:
+    v3 = Phi [b2: v4]
+    Return v3
+b2:
+    v4 = Call @main.outlined, v1, v2
+    Jump b1
+}
+
+func @main.outlined(v0, v1) {
+b0:
+    Jump b1
+b1:
+    Branch v0, b2, b3
+b2:
+    v2 = Call @f, v0
+    Jump b4
+b3:
+    Jump b4
+b4:
+    v3 = Phi [b2: v2] [b3: v1]
+    Return v3
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestOutlineRegionRejected(t *testing.T) {
+	src := `global @f
+
+func @main(p) {
+entry:
+    x = Call @f, p
+    Branch x, first, second
+first:
+    y = Call @f, x
+    z = Call @f, y
+    Jump second
+second:
+    s = Phi [entry: p] [first: y]
+    u = Phi [entry: p] [first: z]
+    w = Call @f, s, u
+    Branch w, loop, done
+loop:
+    Jump first
+done:
+    Return w
+}
+`
+	tests := map[string]struct {
+		entry, exit int
+		name        string
+	}{
+		"function entry":       {0, 2, "main.outlined"},
+		"region returns":       {2, 1, "main.outlined"},
+		"two values used":      {1, 2, "main.outlined"},
+		"side entrance":        {1, 4, "main.outlined"},
+		"exit is region entry": {1, 1, "main.outlined"},
+		"name already defined": {3, 1, "main"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, err := otext.ParseModule([]byte(src))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			f := m.Function("main")
+			blocks := f.AppendBlocks(nil)
+			want := otext.SprintModule(m)
+			if got := OutlineRegion(m, f, blocks[test.entry], blocks[test.exit], test.name); got != nil {
+				t.Errorf("region was outlined")
+			}
+			if got := otext.SprintModule(m); got != want {
+				t.Errorf("module was changed\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// ApplyExecutionCounts sets the weights of the Branch and Switch terminators
+// in the given function from the given estimates of how many times each
+// block ran, such as those produced by oana.Profile.ExecutionCounts,
+// returning the number of terminators whose weights it set. The resulting
+// weights then guide analyses such as oana.FindBlockFrequencies.
+//
+// The count of a block is shared among its predecessors in proportion to
+// their own counts, and the weight of each edge is the share belonging to
+// the block it leaves. This is exact for blocks that have only one
+// predecessor. Terminators whose edges would all have zero weight, usually
+// because the profile collected no samples in or around them, keep any
+// weights they already had.
+func ApplyExecutionCounts(f *ossa.Function, counts map[*ossa.BasicBlock]float64) int {
+	preds := functionPredecessors(f)
+	predCounts := make(map[*ossa.BasicBlock]float64, len(preds))
+	for block, blockPreds := range preds {
+		for _, pred := range blockPreds {
+			predCounts[block] += counts[pred]
+		}
+	}
+
+	count := 0
+	for _, block := range f.AppendBlocks(nil) {
+		t := block.Terminator
+		if t == nil || (t.Op() != ossa.OpBranch && t.Op() != ossa.OpSwitch) {
+			continue
+		}
+		weights := make([]float64, t.NumArgs())
+		var total float64
+		for i := range weights {
+			succ := t.Arg(i).Block
+			if predCounts[succ] > 0 {
+				weights[i] = counts[succ] * counts[block] / predCounts[succ]
+			}
+			total += weights[i]
+		}
+		if total == 0 {
+			continue
+		}
+		t.SetWeights(weights...)
+		count++
+	}
+	return count
+}
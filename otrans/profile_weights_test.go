@@ -0,0 +1,62 @@
+package otrans
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestApplyExecutionCounts(t *testing.T) {
+	src := `func(c, d) {
+entry:
+    Branch c, left, right
+left:
+    Jump join
+right:
+    Branch d, join, exit
+join:
+    Switch c, exit [c: other]
+other:
+    Jump exit
+exit:
+    Return
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	blocks := f.AppendBlocks(nil)
+	entry, left, right, join := blocks[0], blocks[1], blocks[2], blocks[3]
+
+	// join's count of 8 is shared between left, which ran 6 times, and
+	// right, which ran 2 times. The profile has no samples for either of
+	// the Switch's targets, so it keeps its original weights.
+	join.Terminator.SetWeights(1, 2)
+	counts := map[*ossa.BasicBlock]float64{
+		entry: 8,
+		left:  6,
+		right: 2,
+		join:  8,
+	}
+	if got, want := ApplyExecutionCounts(f, counts), 2; got != want {
+		t.Errorf("wrong number of terminators updated %d; want %d", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		block *ossa.BasicBlock
+		want  []float64
+	}{
+		{"entry", entry, []float64{6, 2}},
+		{"right", right, []float64{2, 0}},
+		{"join", join, []float64{1, 2}},
+	}
+	for _, test := range tests {
+		if got := test.block.Terminator.Weights(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("wrong weights for %s %v; want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// SimplifyCFG rewrites the control flow graph of the given function to remove
+// some common redundancies, returning true if it made any changes.
+//
+// It repeatedly applies the following simplifications until none of them
+// apply:
+//
+//   - A Branch whose two targets are the same block is replaced with a Jump.
+//   - A block whose only predecessor ends with a Jump to it is merged into
+//     that predecessor.
+//   - A block with no instructions that ends with a Jump is removed, and its
+//     predecessors instead target its successor directly.
+//
+// Blocks are never merged or removed if doing so would combine blocks that
+// belong to different regions, as described for BasicBlock.Region, and
+// blocks are never merged if only one of them has NoSpeculate set. An empty
+// block is also kept if removing it would give a Phi node in its successor
+// two candidates for the same predecessor.
+//
+// A block that absorbs its successor keeps its own Name and metadata, takes
+// the successor's Name if it had none, and gains any of the successor's
+// metadata entries whose keys it doesn't already have, as described for
+// BasicBlock.MergeMeta.
+func SimplifyCFG(f *ossa.Function) bool {
+	changed := false
+	for simplifyCFGOnce(f) {
+		changed = true
+	}
+	return changed
+}
+
+// simplifyCFGOnce makes at most one change to the graph, since each change
+// invalidates the predecessors table, and returns true if it did.
+func simplifyCFGOnce(f *ossa.Function) bool {
+	entry := f.Entry()
+	blocks := f.AppendBlocks(nil)
+	preds := functionPredecessors(f)
+
+	for _, block := range blocks {
+		t := block.Terminator
+		if t == nil || t.Op() != ossa.OpBranch || t.Arg(0).Block != t.Arg(1).Block {
+			continue
+		}
+		block.Terminator = ossa.Jump(t.Arg(0).Block)
+		block.Terminator.SetPos(t.Pos())
+		return true
+	}
+
+	for _, block := range blocks {
+		t := block.Terminator
+		if t == nil || t.Op() != ossa.OpJump {
+			continue
+		}
+		succ := t.Arg(0).Block
+		if succ == block || succ == entry || len(preds[succ]) != 1 || !block.SameRegion(succ) || block.NoSpeculate != succ.NoSpeculate {
+			continue
+		}
+		mergeBlocks(f, block, succ)
+		return true
+	}
+
+	for _, block := range blocks {
+		t := block.Terminator
+		if block == entry || len(block.Instructions) != 0 || t == nil || t.Op() != ossa.OpJump {
+			continue
+		}
+		succ := t.Arg(0).Block
+		if succ == block || !block.SameRegion(succ) {
+			continue
+		}
+		if !removeForwardingBlock(f, block, succ, preds) {
+			continue
+		}
+		return true
+	}
+
+	return false
+}
+
+// mergeBlocks appends the instructions and terminator of the given successor
+// block to the given block, and removes the successor from the function. The
+// successor must have the given block as its only predecessor.
+func mergeBlocks(f *ossa.Function, block, succ *ossa.BasicBlock) {
+	// The successor's Phi nodes each have only one candidate, because it
+	// has only one predecessor.
+	repl := make(map[*ossa.Value]*ossa.Value)
+	insts := block.Instructions
+	for _, inst := range succ.Instructions {
+		if inst.Op() == ossa.OpPhi {
+			repl[inst] = inst.Arg(0)
+			continue
+		}
+		insts = append(insts, inst)
+	}
+	block.Instructions = insts
+	block.Terminator = succ.Terminator
+	replacePhiPredecessor(block, succ, block)
+	if block.Name == "" {
+		block.Name = succ.Name
+	}
+	block.MergeMeta(succ)
+
+	succ.Instructions = nil
+	succ.Terminator = nil
+	f.RemoveBlock(succ)
+	replaceUses(f, repl)
+}
+
+// removeForwardingBlock redirects the predecessors of the given empty block
+// to its successor and removes it from the function, returning true. If
+// that would be invalid due to Phi nodes in the successor then it makes no
+// changes and returns false.
+func removeForwardingBlock(f *ossa.Function, block, succ *ossa.BasicBlock, preds map[*ossa.BasicBlock][]*ossa.BasicBlock) bool {
+	blockPreds := preds[block]
+	if len(blockPreds) == 0 {
+		return false // unreachable blocks are not our concern
+	}
+	if hasPhis(succ) {
+		for _, pred := range blockPreds {
+			for _, other := range preds[succ] {
+				if pred == other {
+					return false
+				}
+			}
+		}
+		for _, inst := range succ.Instructions {
+			if inst.Op() != ossa.OpPhi {
+				break
+			}
+			for i := 0; i < inst.NumArgs(); i++ {
+				c := inst.PhiCandidate(i)
+				if c.Block != block {
+					continue
+				}
+				c.Block = blockPreds[0]
+				inst.SetPhiCandidate(i, c)
+				for _, pred := range blockPreds[1:] {
+					c.Block = pred
+					inst.AddPhiCandidate(c)
+				}
+				break
+			}
+		}
+	}
+
+	for _, pred := range blockPreds {
+		retargetTerminator(pred.Terminator, block, succ)
+	}
+	block.Terminator = nil
+	f.RemoveBlock(block)
+	return true
+}
+
+func hasPhis(block *ossa.BasicBlock) bool {
+	return len(block.Instructions) != 0 && block.Instructions[0].Op() == ossa.OpPhi
+}
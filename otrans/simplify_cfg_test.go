@@ -0,0 +1,174 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestSimplifyCFG(t *testing.T) {
+	t.Run("simplify", func(t *testing.T) {
+		src := `func(c) {
+    one = AuxLiteral 1
+    two = AuxLiteral 2
+entry:
+    x = Call c
+    Jump next
+next:
+    y = Call c, x
+    Branch y, mid, mid
+mid:
+    Branch c, fwd, other
+fwd:
+    Jump join
+other:
+    z = Call c
+    Jump join
+join:
+    p = Phi [fwd: one] [other: two]
+    Return p
+}
+`
+		f, err := otext.ParseFunction([]byte(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !SimplifyCFG(f) {
+			t.Errorf("no changes made")
+		}
+
+		got := otext.SprintFunction(f)
+		want := `func(v0) {
+    v1 = AuxLiteral 1
+    v2 = AuxLiteral 2
+b0:
+    v3 = Call v0
+    v4 = Call v0, v3
+    Branch v0, b2, b1
+b1:
+    v5 = Call v0
+    Jump b2
+b2:
+    v6 = Phi [b0: v1] [b1: v2]
+    Return v6
+}
+`
+		if got != want {
+			t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+	t.Run("phi conflict", func(t *testing.T) {
+		// Removing "fwd" would give the Phi node two candidates for the
+		// entry block, so it must be kept.
+		src := `func(c) {
+    one = AuxLiteral 1
+    two = AuxLiteral 2
+entry:
+    Branch c, fwd, join
+fwd:
+    Jump join
+join:
+    p = Phi [fwd: one] [entry: two]
+    Return p
+}
+`
+		f, err := otext.ParseFunction([]byte(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		want := otext.SprintFunction(f)
+		if SimplifyCFG(f) {
+			t.Errorf("unexpected changes made")
+		}
+		if got := otext.SprintFunction(f); got != want {
+			t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+	t.Run("regions", func(t *testing.T) {
+		src := `func(c) {
+entry:
+    x = Call c
+    Jump next
+next:
+    Jump last
+last:
+    Return x
+}
+`
+		f, err := otext.ParseFunction([]byte(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		blocks := f.AppendBlocks(nil)
+		blocks[1].Region = "inner"
+		want := otext.SprintFunction(f)
+		if SimplifyCFG(f) {
+			t.Errorf("unexpected changes made")
+		}
+		if got := otext.SprintFunction(f); got != want {
+			t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+	t.Run("merged properties", func(t *testing.T) {
+		src := `func(c) {
+entry:
+    x = Call c
+    Jump b1.next
+b1.next:
+    Return x
+}
+`
+		f, err := otext.ParseFunction([]byte(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		blocks := f.AppendBlocks(nil)
+		blocks[0].SetMeta("kept", 1)
+		blocks[1].SetMeta("kept", 2)
+		blocks[1].SetMeta("moved", 3)
+		if !SimplifyCFG(f) {
+			t.Fatalf("no changes made")
+		}
+		if got := f.BlockCount(); got != 1 {
+			t.Fatalf("wrong number of blocks %d; want 1", got)
+		}
+		block := f.Entry()
+		if got, want := block.Name, "next"; got != want {
+			t.Errorf("wrong name %q; want %q", got, want)
+		}
+		if got := block.Meta("kept"); got != 1 {
+			t.Errorf("wrong value for existing metadata %v; want 1", got)
+		}
+		if got := block.Meta("moved"); got != 3 {
+			t.Errorf("wrong value for merged metadata %v; want 3", got)
+		}
+	})
+	t.Run("no speculate", func(t *testing.T) {
+		// Merging "next" into the entry block would either lose its
+		// NoSpeculate flag or impose it on the entry block's call.
+		src := `func(c) {
+entry:
+    x = Call c
+    Jump next
+next:
+    y = Call c, x
+    Return y
+}
+`
+		f, err := otext.ParseFunction([]byte(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		f.AppendBlocks(nil)[1].NoSpeculate = true
+		want := otext.SprintFunction(f)
+		if SimplifyCFG(f) {
+			t.Errorf("unexpected changes made")
+		}
+		if got := otext.SprintFunction(f); got != want {
+			t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+		}
+		if !f.AppendBlocks(nil)[1].NoSpeculate {
+			t.Errorf("NoSpeculate flag was lost")
+		}
+	})
+}
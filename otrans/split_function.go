@@ -0,0 +1,133 @@
+package otrans
+
+import (
+	"fmt"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+)
+
+// SplitFunction outlines regions of the function defined with the given
+// name in the given module into new functions, until the function's static
+// cost as estimated by the given model is no greater than the given limit or
+// no further region can be outlined. It returns the new functions in the
+// order they were created, or nil if no function is defined with the given
+// name.
+//
+// This is intended for machine-generated code whose functions are too large
+// for the time and memory budgets of later analyses. Each step outlines the
+// costliest single-entry, single-exit region whose cost is no greater than
+// the limit and is greater than the cost of the Call and Jump that replace
+// it, as described for OutlineRegion, so that each new function is also
+// within the limit apart from its added entry and return blocks. Regions
+// are considered in the function's block order, and the first of any with
+// equal costs is chosen.
+//
+// The new functions are named after the original with a suffix of ".split"
+// and a number, skipping any names that are already declared in the module.
+func SplitFunction(m *ossa.Module, name string, model oana.CostModel, limit float64) []*ossa.Function {
+	f := m.Function(name)
+	if f == nil {
+		return nil
+	}
+	var ret []*ossa.Function
+	next := 1
+	for {
+		costs := oana.EstimateCost(f, model)
+		if costs.Static() <= limit {
+			return ret
+		}
+		r := findSplitRegion(f, costs, model, limit)
+		if r == nil {
+			return ret
+		}
+		var newName string
+		for newName == "" || m.Global(newName) != nil {
+			newName = fmt.Sprintf("%s.split%d", name, next)
+			next++
+		}
+		ret = append(ret, r.outline(m, f, newName))
+	}
+}
+
+// findSplitRegion returns the region of the given function that
+// SplitFunction should outline next, or nil if there is none.
+func findSplitRegion(f *ossa.Function, costs oana.CostTable, model oana.CostModel, limit float64) *outlineRegion {
+	preds := functionPredecessors(f)
+	var best *outlineRegion
+	var bestCost float64
+	for _, entry := range f.AppendBlocks(nil)[1:] {
+		for _, exit := range nearbyBlocks(entry, costs, limit) {
+			cost, ok := regionCost(entry, exit, costs, limit)
+			if !ok || cost <= bestCost {
+				continue
+			}
+			r := findOutlineRegion(f, preds, entry, exit)
+			if r == nil {
+				continue
+			}
+			call := ossa.Call(ossa.GlobalSym(), r.liveIns...)
+			if cost <= model.ValueCost(call)+model.TerminatorCost(ossa.Jump(exit)) {
+				continue
+			}
+			best, bestCost = r, cost
+		}
+	}
+	return best
+}
+
+// nearbyBlocks returns the blocks that could be the exit of a region with the
+// given entry block whose cost, according to the given table, is no greater
+// than the given limit.
+//
+// The blocks are found by a breadth-first search from the entry, which stops
+// once the blocks it has visited cost more than the limit. Until the search
+// finds a region's exit, every block it has visited must belong to that
+// region, so the exit of any region within the limit is found before then.
+func nearbyBlocks(entry *ossa.BasicBlock, costs oana.CostTable, limit float64) []*ossa.BasicBlock {
+	var ret []*ossa.BasicBlock
+	seen := make(ossa.BasicBlockSet)
+	seen.Add(entry)
+	queue := []*ossa.BasicBlock{entry}
+	var cost float64
+	for len(queue) > 0 {
+		block := queue[0]
+		queue = queue[1:]
+		if cost += costs[block]; cost > limit || block.Terminator == nil {
+			break
+		}
+		for _, succ := range block.Terminator.AppendSuccessors(nil) {
+			if !seen.Has(succ) {
+				seen.Add(succ)
+				ret = append(ret, succ)
+				queue = append(queue, succ)
+			}
+		}
+	}
+	return ret
+}
+
+// regionCost returns the total cost of the blocks reachable from the given
+// entry block without passing through the given exit block, according to
+// the given table. It returns false if that cost is greater than the given
+// limit.
+func regionCost(entry, exit *ossa.BasicBlock, costs oana.CostTable, limit float64) (float64, bool) {
+	seen := make(ossa.BasicBlockSet)
+	todo := []*ossa.BasicBlock{entry}
+	var cost float64
+	for len(todo) > 0 {
+		block := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if block == exit || seen.Has(block) {
+			continue
+		}
+		seen.Add(block)
+		if cost += costs[block]; cost > limit {
+			return cost, false
+		}
+		if block.Terminator != nil {
+			todo = block.Terminator.AppendSuccessors(todo)
+		}
+	}
+	return cost, true
+}
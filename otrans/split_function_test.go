@@ -0,0 +1,120 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestSplitFunction(t *testing.T) {
+	src := `global @f
+
+func @main(p) {
+entry:
+    a = Call @f, p
+    Branch a, left, right
+left:
+    b = Call @f, a
+    c = Call @f, b
+    Branch c, inner, join
+inner:
+    d = Call @f, c
+    e = Call @f, d
+    Jump join
+right:
+    g = Call @f, a
+    Jump join
+join:
+    j = Phi [left: c] [inner: e] [right: g]
+    k = Call @f, j
+    Return k
+}
+`
+	model := &oana.OpCostModel{
+		Weights: map[ossa.Op]float64{ossa.OpCall: 1},
+	}
+
+	t.Run("within limit", func(t *testing.T) {
+		m, err := otext.ParseModule([]byte(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		want := otext.SprintModule(m)
+		if got := SplitFunction(m, "main", model, 8); len(got) != 0 {
+			t.Errorf("split into %d functions; want none", len(got))
+		}
+		if got := otext.SprintModule(m); got != want {
+			t.Errorf("module was changed\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+	t.Run("not defined", func(t *testing.T) {
+		m, err := otext.ParseModule([]byte(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		want := otext.SprintModule(m)
+		for _, name := range []string{"f", "missing"} {
+			if got := SplitFunction(m, name, model, 4); len(got) != 0 {
+				t.Errorf("split %q into %d functions; want none", name, len(got))
+			}
+		}
+		if got := otext.SprintModule(m); got != want {
+			t.Errorf("module was changed\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+	t.Run("over limit", func(t *testing.T) {
+		m, err := otext.ParseModule([]byte(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		// The left arm and its inner block are outlined first. The cost
+		// is then still over the limit, but outlining the right arm would
+		// not reduce it.
+		m.DeclareGlobal("main.split1")
+		got := SplitFunction(m, "main", model, 4)
+		if len(got) != 1 || got[0] != m.Function("main.split2") {
+			t.Fatalf("wrong new functions %v", got)
+		}
+
+		want := `global @f
+global @main.split1
+
+func @main(v0) {
+b0:
+    v1 = Call @f, v0
+    Branch v1, b3, b1
+b1:
+    v2 = Call @f, v1
+    Jump b2
+b2:
+    v3 = Phi [b1: v2] [b3: v5]
+    v4 = Call @f, v3
+    Return v4
+b3:
+    v5 = Call @main.split2, v1
+    Jump b2
+}
+
+func @main.split2(v0) {
+b0:
+    Jump b1
+b1:
+    v1 = Call @f, v0
+    v2 = Call @f, v1
+    Branch v2, b2, b3
+b2:
+    v3 = Call @f, v2
+    v4 = Call @f, v3
+    Jump b3
+b3:
+    v5 = Phi [b1: v2] [b2: v4]
+    Return v5
+}
+`
+		if got := otext.SprintModule(m); got != want {
+			t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
@@ -0,0 +1,294 @@
+package otrans
+
+import (
+	"sort"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+)
+
+// FormSuperblocks duplicates blocks of the given function so that its most
+// frequently executed paths become superblocks, returning the number of
+// blocks it duplicated.
+//
+// A superblock is a trace of blocks that can be entered only at its first
+// block, which allows later passes to treat it much like a single large
+// block. Traces are selected using the frequencies estimated by
+// oana.FindBlockFrequencies, and so are guided by the terminator weights
+// that a frontend can set from profile data. Starting from the most
+// frequent block not yet in any trace, each trace grows by following the
+// most likely edge out of its last block for as long as that edge is also
+// the most frequent edge into its target. A trace never grows into a block
+// that is already in another trace, that is the head of a loop, or that
+// starts with a LandingPad.
+//
+// Any edge into a block of a trace other than from the previous block of
+// the same trace is a side entrance. The part of the trace starting at its
+// first side entrance is copied, and all of the side entrances are redirected
+// to the copies, a transformation known as tail duplication. The copies
+// belong to the same regions as the originals. Values defined in the
+// duplicated blocks that are used elsewhere are merged using new Phi nodes
+// where paths through the originals and the copies meet.
+//
+// A trace is left as it is if the total cost of the blocks that would be
+// copied, as estimated by the given model, is greater than the given limit.
+func FormSuperblocks(f *ossa.Function, model oana.CostModel, limit float64) int {
+	entry := f.Entry()
+	if entry == nil {
+		return 0
+	}
+	preds := oana.FindPredecessors(entry)
+	freqs := oana.FindBlockFrequencies(entry, preds)
+	loops := oana.FindLoopInfo(entry, preds)
+	costs := oana.EstimateCost(f, model)
+
+	count := 0
+	for _, trace := range selectTraces(f, freqs, loops) {
+		count += duplicateTail(f, trace, costs, limit)
+	}
+	return count
+}
+
+// selectTraces returns the traces of the given function that have more than
+// one block, as described for FormSuperblocks, in the order of the frequency
+// of their first blocks.
+func selectTraces(f *ossa.Function, freqs oana.BlockFrequencies, loops *oana.LoopInfo) [][]*ossa.BasicBlock {
+	allPreds := functionPredecessors(f)
+	seeds := ossa.AppendBlocksRPO(f.Entry(), nil)
+	sort.SliceStable(seeds, func(i, j int) bool {
+		return freqs[seeds[i]] > freqs[seeds[j]]
+	})
+
+	var ret [][]*ossa.BasicBlock
+	inTrace := make(ossa.BasicBlockSet)
+	for _, seed := range seeds {
+		if inTrace.Has(seed) {
+			continue
+		}
+		trace := []*ossa.BasicBlock{seed}
+		inTrace.Add(seed)
+		for cur := seed; ; {
+			succ := likelySuccessor(cur, freqs)
+			if succ == nil || inTrace.Has(succ) || startsWithLandingPad(succ) {
+				break
+			}
+			if loop := loops.Loop(succ); loop != nil && loop.Head == succ {
+				break
+			}
+			if !isLikelyPredecessor(cur, succ, allPreds[succ], freqs) {
+				break
+			}
+			trace = append(trace, succ)
+			inTrace.Add(succ)
+			cur = succ
+		}
+		if len(trace) > 1 {
+			ret = append(ret, trace)
+		}
+	}
+	return ret
+}
+
+// likelySuccessor returns the successor of the given block with the most
+// frequent edge from it, or nil if it has no edges that are ever taken.
+func likelySuccessor(block *ossa.BasicBlock, freqs oana.BlockFrequencies) *ossa.BasicBlock {
+	if block.Terminator == nil {
+		return nil
+	}
+	var ret *ossa.BasicBlock
+	var best float64
+	for _, succ := range block.Terminator.AppendSuccessors(nil) {
+		if freq := freqs.Edge(block, succ); freq > best {
+			ret, best = succ, freq
+		}
+	}
+	return ret
+}
+
+// isLikelyPredecessor returns true if no edge from any of the given
+// predecessors of block succ is more frequent than the edge from block pred.
+func isLikelyPredecessor(pred, succ *ossa.BasicBlock, preds []*ossa.BasicBlock, freqs oana.BlockFrequencies) bool {
+	freq := freqs.Edge(pred, succ)
+	for _, other := range preds {
+		if freqs.Edge(other, succ) > freq {
+			return false
+		}
+	}
+	return true
+}
+
+// duplicateTail removes the side entrances of the given trace by tail
+// duplication, as described for FormSuperblocks, and returns the number of
+// blocks it copied.
+func duplicateTail(f *ossa.Function, trace []*ossa.BasicBlock, costs oana.CostTable, limit float64) int {
+	preds := functionPredecessors(f)
+	start := 0
+	for i := 1; i < len(trace) && start == 0; i++ {
+		for _, pred := range preds[trace[i]] {
+			if pred != trace[i-1] {
+				start = i
+				break
+			}
+		}
+	}
+	if start == 0 {
+		return 0
+	}
+	tail := trace[start:]
+	var cost float64
+	for _, block := range tail {
+		cost += costs[block]
+	}
+	if cost > limit {
+		return 0
+	}
+
+	// tracePred maps each block of the tail to the block before it in the
+	// trace, which is the only predecessor it will keep.
+	tracePred := make(map[*ossa.BasicBlock]*ossa.BasicBlock, len(tail))
+	copyOf := make(map[*ossa.BasicBlock]*ossa.BasicBlock, len(tail))
+	copies := make(ossa.BasicBlockSet, len(tail))
+	clones := make(map[*ossa.Value]*ossa.Value)
+	for i, block := range tail {
+		tracePred[block] = trace[start+i-1]
+		c := f.NewBlock()
+		c.Name = block.Name
+		c.Region = block.Region
+		c.NoSpeculate = block.NoSpeculate
+		c.MergeMeta(block)
+		for _, inst := range block.Instructions {
+			clone := inst.Clone()
+			clones[inst] = clone
+			c.Instructions = append(c.Instructions, clone)
+		}
+		copyOf[block] = c
+		copies.Add(c)
+	}
+
+	// The copies initially use the same operands as the originals, which
+	// are then corrected by repairSSA below, except that their terminators
+	// must refer to the copies of any blocks in the tail.
+	for _, block := range tail {
+		t := block.Terminator.Clone()
+		for i := 0; i < t.NumArgs(); i++ {
+			if arg := t.Arg(i); copyOf[arg.Block] != nil {
+				arg.Block = copyOf[arg.Block]
+				t.SetArg(i, arg)
+			}
+		}
+		copyOf[block].Terminator = t
+	}
+
+	// Each side entrance now goes to the copy instead, and so the Phi
+	// candidates for it move from the original to the copy. The copy of
+	// the first block of the tail has only side entrances, while each later
+	// copy is also entered from the copy of the block before it.
+	for _, block := range tail {
+		c := copyOf[block]
+		for i, inst := range block.Instructions {
+			if inst.Op() != ossa.OpPhi {
+				break
+			}
+			clone := c.Instructions[i]
+			for j := clone.NumArgs() - 1; j >= 0; j-- {
+				clone.RemovePhiCandidate(j)
+			}
+			for j := 0; j < inst.NumArgs(); j++ {
+				cand := inst.PhiCandidate(j)
+				if cand.Block != tracePred[block] {
+					clone.AddPhiCandidate(cand)
+				}
+				if predCopy := copyOf[cand.Block]; predCopy != nil {
+					clone.AddPhiCandidate(ossa.BasicBlockValue{Block: predCopy, Value: cand.Value})
+				}
+			}
+			for j := inst.NumArgs() - 1; j >= 0; j-- {
+				if inst.PhiCandidate(j).Block != tracePred[block] {
+					inst.RemovePhiCandidate(j)
+				}
+			}
+		}
+	}
+	for _, block := range f.AppendBlocks(nil) {
+		if t := block.Terminator; t != nil && !copies.Has(block) {
+			for i := 0; i < t.NumArgs(); i++ {
+				arg := t.Arg(i)
+				if c := copyOf[arg.Block]; c != nil && tracePred[arg.Block] != block {
+					arg.Block = c
+					t.SetArg(i, arg)
+				}
+			}
+		}
+	}
+
+	// The successors of the copies outside of the tail gain new
+	// predecessors, with the same Phi candidates as the originals.
+	for _, block := range tail {
+		c := copyOf[block]
+		seen := make(ossa.BasicBlockSet)
+		for _, succ := range c.Terminator.AppendSuccessors(nil) {
+			if seen.Has(succ) || copies.Has(succ) {
+				continue
+			}
+			seen.Add(succ)
+			for _, inst := range succ.Instructions {
+				if inst.Op() != ossa.OpPhi {
+					break
+				}
+				if j := phiCandidateIndex(inst, block); j >= 0 {
+					inst.AddPhiCandidate(ossa.BasicBlockValue{Block: c, Value: inst.PhiCandidate(j).Value})
+				}
+			}
+		}
+	}
+
+	repairSSA(f, tail, copyOf, clones)
+	return len(tail)
+}
+
+// repairSSA restores the SSA property after duplicateTail has copied the
+// given tail, so that each use of a value defined in the tail, or of its
+// clone, refers to whichever of the two reaches it, or to a new Phi node
+// that merges them.
+func repairSSA(f *ossa.Function, tail []*ossa.BasicBlock, copyOf map[*ossa.BasicBlock]*ossa.BasicBlock, clones map[*ossa.Value]*ossa.Value) {
+	preds := functionPredecessors(f)
+	uses := oana.BuildUses(f.Entry())
+	position := make(map[*ossa.Value]int)
+	for _, block := range f.AppendBlocks(nil) {
+		for i, inst := range block.Instructions {
+			position[inst] = i
+		}
+	}
+
+	created := make(map[*ossa.Value]*ossa.BasicBlock)
+	for _, block := range tail {
+		for _, inst := range block.Instructions {
+			clone := clones[inst]
+			r := newSSARepairer(preds, created, map[*ossa.BasicBlock]*ossa.Value{
+				block:         inst,
+				copyOf[block]: clone,
+			})
+			for _, use := range append(uses[inst], uses[clone]...) {
+				var v *ossa.Value
+				switch {
+				case use.Value != nil && use.Value.Op() == ossa.OpPhi:
+					v = r.valueAtEnd(use.Value.PhiCandidate(use.Index).Block)
+				case use.Value != nil:
+					if def := r.defs[use.Block]; def != nil && position[def] < position[use.Value] {
+						v = def
+					} else {
+						v = r.valueAtStart(use.Block)
+					}
+				default:
+					if def := r.defs[use.Block]; def != nil {
+						v = def
+					} else {
+						v = r.valueAtStart(use.Block)
+					}
+				}
+				use.Replace(v)
+			}
+		}
+	}
+	removeTrivialPhis(f, created)
+}
@@ -0,0 +1,108 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestFormSuperblocks(t *testing.T) {
+	model := &oana.OpCostModel{
+		Weights: map[ossa.Op]float64{
+			ossa.OpCall: 1,
+		},
+	}
+	const src = `func(c, d) {
+    f = AuxLiteral "f"
+    g = AuxLiteral "g"
+    h = AuxLiteral "h"
+entry:
+    Branch c, hot, cold
+hot:
+    a = Call f
+    Jump join
+cold:
+    b = Call g
+    Jump join
+join:
+    p = Phi [hot: a] [cold: b]
+    x = Call h, p
+    Branch d, next, other
+next:
+    y = Call h, x
+    Jump exit
+other:
+    Jump exit
+exit:
+    Return x
+}
+`
+	parse := func(t *testing.T) *ossa.Function {
+		f, err := otext.ParseFunction([]byte(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		f.Entry().Terminator.SetWeights(9, 1)
+		return f
+	}
+
+	t.Run("duplicate", func(t *testing.T) {
+		f := parse(t)
+		if got, want := FormSuperblocks(f, model, 10), 3; got != want {
+			t.Errorf("wrong number of blocks duplicated %d; want %d", got, want)
+		}
+
+		got := otext.SprintFunction(f)
+		want := `func(v0, v1) {
+    v2 = AuxLiteral "f"
+    v3 = AuxLiteral "g"
+    v4 = AuxLiteral "h"
+b0:
+    Branch v0, b1, b2
+b1:
+    v5 = Call v2
+    Jump b3
+b2:
+    v6 = Call v3
+    Jump b7
+b3:
+    v7 = Phi [b1: v5]
+    v8 = Call v4, v7
+    Branch v1, b4, b5
+b4:
+    v9 = Call v4, v8
+    Jump b6
+b5:
+    v10 = Phi [b3: v8] [b7: v12]
+    Jump b9
+b6:
+    Return v8
+b7:
+    v11 = Phi [b2: v6]
+    v12 = Call v4, v11
+    Branch v1, b8, b5
+b8:
+    v13 = Call v4, v12
+    Jump b9
+b9:
+    v14 = Phi [b5: v10] [b8: v12]
+    Return v14
+}
+`
+		if got != want {
+			t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+	t.Run("too costly", func(t *testing.T) {
+		f := parse(t)
+		want := otext.SprintFunction(f)
+		if got, want := FormSuperblocks(f, model, 1), 0; got != want {
+			t.Errorf("wrong number of blocks duplicated %d; want %d", got, want)
+		}
+		if got := otext.SprintFunction(f); got != want {
+			t.Errorf("function was modified\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
@@ -0,0 +1,226 @@
+package otrans
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/alamatic/ossa"
+)
+
+// SwitchLowering is implemented by frontends to describe how LowerSwitches
+// operates on the inputs of Switch terminators, since the meaning of
+// operations on values is defined by the frontend.
+//
+// Each method may emit instructions using the given builder, which it must
+// leave pointed at the end of the same block. The operands are either the
+// input of a Switch or the value of one of its cases.
+type SwitchLowering interface {
+	// Less returns a value that is true, when used as the condition of a
+	// Branch, if x is less than y.
+	Less(b *ossa.Builder, x, y *ossa.Value) *ossa.Value
+
+	// Equal returns a value that is true, when used as the condition of a
+	// Branch, if x is equal to y.
+	Equal(b *ossa.Builder, x, y *ossa.Value) *ossa.Value
+
+	// Sub returns the result of subtracting y from x.
+	Sub(b *ossa.Builder, x, y *ossa.Value) *ossa.Value
+}
+
+// minJumpTableCases is the smallest number of distinct cases that
+// LowerSwitches will dispatch through a table.
+const minJumpTableCases = 4
+
+// LowerSwitches rewrites the Switch terminators in the given function whose
+// cases are all integer constants, for backends that have no general switch
+// instruction, returning the number of terminators that were changed.
+//
+// A Switch is dense if it has at least four distinct cases and they fill at
+// least half of the range between the smallest and largest of them. A dense
+// Switch is converted to table form: a Switch whose input is the original
+// input minus the smallest case, as calculated by the given SwitchLowering,
+// and whose cases are the integers from zero up to the size of the range, in
+// order, with the default target for any integers that didn't have a case.
+// A backend can implement a Switch in table form with a bounds check and an
+// indexed jump. Switches that are already in table form are left unchanged.
+//
+// Any other Switch is converted to a balanced tree of Branch terminators in
+// new blocks, each of which compares the input to the median of the cases
+// that remain possible using Less, until one case remains and is tested using
+// Equal.
+//
+// Cases are assumed to be tested in order, as described for
+// FoldConstantBranches, so only the first of any cases with equal values is
+// kept. The Phi nodes of the Switch's targets are updated to have the same
+// candidate for each of their new predecessors. All of the new blocks belong
+// to the same region as the block that contained the Switch, and the new
+// terminators and any instructions emitted by the SwitchLowering have the
+// Switch's source position. Any weights of the Switch are discarded.
+func LowerSwitches(f *ossa.Function, ops SwitchLowering) int {
+	b := ossa.NewFunctionBuilder(f)
+	count := 0
+	for _, block := range f.AppendBlocks(nil) {
+		t := block.Terminator
+		if t == nil || t.Op() != ossa.OpSwitch || t.SwitchInput() == nil {
+			continue
+		}
+		cases, ok := intSwitchCases(t)
+		if !ok || inTableForm(t) {
+			continue
+		}
+		succs := t.AppendSuccessors(nil)
+		block.Terminator = nil
+		b.SetPos(t.Pos())
+		b.SetBlock(block)
+		newBlocks := []*ossa.BasicBlock{block}
+		if isDenseSwitch(cases) {
+			lowerSwitchTable(b, t, cases, ops)
+		} else {
+			newBlocks = lowerSwitchTree(b, t, cases, ops, newBlocks)
+		}
+		b.SetPos(nil)
+
+		// Each of the original targets now has some subset of the new
+		// blocks as its predecessors instead of the original block.
+		newPreds := make(map[*ossa.BasicBlock][]*ossa.BasicBlock)
+		for _, pred := range newBlocks {
+			seen := make(ossa.BasicBlockSet)
+			for _, succ := range pred.Terminator.AppendSuccessors(nil) {
+				if !seen.Has(succ) {
+					seen.Add(succ)
+					newPreds[succ] = append(newPreds[succ], pred)
+				}
+			}
+		}
+		seen := make(ossa.BasicBlockSet)
+		for _, succ := range succs {
+			if seen.Has(succ) {
+				continue
+			}
+			seen.Add(succ)
+			switch preds := newPreds[succ]; {
+			case len(preds) == 1 && preds[0] == block:
+				// The original edge remains as it was.
+			case len(preds) == 0:
+				RemovePredecessor(succ, block)
+			default:
+				splitPhiPredecessor(succ, block, preds)
+			}
+		}
+		count++
+	}
+	return count
+}
+
+// switchCase is a Switch case whose value is an integer constant.
+type switchCase struct {
+	val    *big.Int
+	lit    *ossa.Value
+	target *ossa.BasicBlock
+}
+
+// intSwitchCases returns the cases of the given Switch terminator in
+// ascending order of their values, excluding any that can never be selected
+// because an earlier case has the same value. It returns false if any case
+// value is not an integer constant.
+func intSwitchCases(t *ossa.Terminator) ([]switchCase, bool) {
+	var ret []switchCase
+	seen := make(map[string]bool)
+	for i := 0; i < t.NumSwitchCases(); i++ {
+		c := t.SwitchCase(i)
+		if c.Value == nil {
+			return nil, false
+		}
+		k, ok := c.Value.Const()
+		if !ok {
+			return nil, false
+		}
+		val, ok := k.Int()
+		if !ok {
+			return nil, false
+		}
+		if seen[val.String()] {
+			continue
+		}
+		seen[val.String()] = true
+		ret = append(ret, switchCase{val: val, lit: c.Value, target: c.Block})
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].val.Cmp(ret[j].val) < 0
+	})
+	return ret, true
+}
+
+// inTableForm returns true if the cases of the given Switch terminator are
+// the integers from zero upwards, in order.
+func inTableForm(t *ossa.Terminator) bool {
+	for i := 0; i < t.NumSwitchCases(); i++ {
+		k, _ := t.SwitchCase(i).Value.Const()
+		val, _ := k.Int()
+		if !val.IsInt64() || val.Int64() != int64(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// isDenseSwitch returns true if the given sorted cases are dense enough to
+// be dispatched through a table, as described for LowerSwitches.
+func isDenseSwitch(cases []switchCase) bool {
+	if len(cases) < minJumpTableCases {
+		return false
+	}
+	size := new(big.Int).Sub(cases[len(cases)-1].val, cases[0].val)
+	size.Add(size, big.NewInt(1))
+	return size.Cmp(big.NewInt(int64(len(cases)*2))) <= 0
+}
+
+// lowerSwitchTable terminates the builder's block with the table form of
+// the given Switch terminator, whose sorted cases are given.
+func lowerSwitchTable(b *ossa.Builder, t *ossa.Terminator, cases []switchCase, ops SwitchLowering) {
+	min := cases[0]
+	idx := t.SwitchInput()
+	if min.val.Sign() != 0 {
+		idx = ops.Sub(b, idx, min.lit)
+	}
+	size := new(big.Int).Sub(cases[len(cases)-1].val, min.val).Int64() + 1
+	table := make([]ossa.BasicBlockValue, size)
+	for i := range table {
+		table[i] = ossa.BasicBlockValue{
+			Value: ossa.ConstLiteral(ossa.Int64Const(int64(i))),
+			Block: t.SwitchDefault(),
+		}
+	}
+	for _, c := range cases {
+		table[new(big.Int).Sub(c.val, min.val).Int64()].Block = c.target
+	}
+	b.Switch(idx, t.SwitchDefault(), table...)
+}
+
+// lowerSwitchTree terminates the builder's block with a tree of Branch
+// terminators that selects between the given sorted cases of the given
+// Switch terminator, appending the new blocks to the given slice and
+// returning the result.
+func lowerSwitchTree(b *ossa.Builder, t *ossa.Terminator, cases []switchCase, ops SwitchLowering, blocks []*ossa.BasicBlock) []*ossa.BasicBlock {
+	inp := t.SwitchInput()
+	region := b.Block().Region
+	switch len(cases) {
+	case 0:
+		b.Jump(t.SwitchDefault())
+	case 1:
+		b.Branch(ops.Equal(b, inp, cases[0].lit), cases[0].target, t.SwitchDefault())
+	default:
+		mid := len(cases) / 2
+		lower := b.NewBlock()
+		upper := b.NewBlock()
+		lower.Region = region
+		upper.Region = region
+		b.Branch(ops.Less(b, inp, cases[mid].lit), lower, upper)
+		blocks = append(blocks, lower, upper)
+		b.SetBlock(lower)
+		blocks = lowerSwitchTree(b, t, cases[:mid], ops, blocks)
+		b.SetBlock(upper)
+		blocks = lowerSwitchTree(b, t, cases[mid:], ops, blocks)
+	}
+	return blocks
+}
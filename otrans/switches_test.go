@@ -0,0 +1,142 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/otext"
+)
+
+type testSwitchLowering struct{}
+
+func (testSwitchLowering) Less(b *ossa.Builder, x, y *ossa.Value) *ossa.Value {
+	return b.Call(b.AuxLiteral("lt"), x, y)
+}
+
+func (testSwitchLowering) Equal(b *ossa.Builder, x, y *ossa.Value) *ossa.Value {
+	return b.Call(b.AuxLiteral("eq"), x, y)
+}
+
+func (testSwitchLowering) Sub(b *ossa.Builder, x, y *ossa.Value) *ossa.Value {
+	return b.Call(b.AuxLiteral("sub"), x, y)
+}
+
+func TestLowerSwitchesTable(t *testing.T) {
+	// The cases fill four of the six slots between 3 and 8, and the second
+	// case for 5 can never be selected.
+	src := `func(x) {
+    three = AuxLiteral 3
+    four = AuxLiteral 4
+    five = AuxLiteral 5
+    eight = AuxLiteral 8
+entry:
+    Switch x, def [five: a] [three: b] [eight: a] [four: b] [five: def]
+a:
+    Return
+b:
+    Return
+def:
+    p = Phi [entry: x]
+    Return p
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := LowerSwitches(f, testSwitchLowering{}), 1; got != want {
+		t.Errorf("wrong number of switches lowered %d; want %d", got, want)
+	}
+	if got, want := LowerSwitches(f, testSwitchLowering{}), 0; got != want {
+		t.Errorf("lowered %d switches already in table form; want %d", got, want)
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func(v0) {
+    v1 = AuxLiteral "sub"
+    v2 = AuxLiteral 3
+    v3 = AuxLiteral 0
+    v4 = AuxLiteral 1
+    v5 = AuxLiteral 2
+    v6 = AuxLiteral 3
+    v7 = AuxLiteral 4
+    v8 = AuxLiteral 5
+b0:
+    v9 = Call v1, v0, v2
+    Switch v9, b3 [v3: b2] [v4: b2] [v5: b1] [v6: b3] [v7: b3] [v8: b1]
+b1:
+    Return
+b2:
+    Return
+b3:
+    v10 = Phi [b0: v0]
+    Return v10
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLowerSwitchesTree(t *testing.T) {
+	src := `func(x) {
+    one = AuxLiteral 1
+    ten = AuxLiteral 10
+    hundred = AuxLiteral 100
+entry:
+    Switch x, def [hundred: a] [one: b] [ten: a]
+a:
+    Return
+b:
+    Return
+def:
+    p = Phi [entry: x]
+    Return p
+}
+`
+	f, err := otext.ParseFunction([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := LowerSwitches(f, testSwitchLowering{}), 1; got != want {
+		t.Errorf("wrong number of switches lowered %d; want %d", got, want)
+	}
+
+	got := otext.SprintFunction(f)
+	want := `func(v0) {
+    v1 = AuxLiteral "lt"
+    v2 = AuxLiteral 10
+    v3 = AuxLiteral "eq"
+    v4 = AuxLiteral 1
+    v5 = AuxLiteral "lt"
+    v6 = AuxLiteral 100
+    v7 = AuxLiteral "eq"
+    v8 = AuxLiteral "eq"
+b0:
+    v9 = Call v1, v0, v2
+    Branch v9, b4, b5
+b1:
+    Return
+b2:
+    Return
+b3:
+    v10 = Phi [b4: v0] [b6: v0] [b7: v0]
+    Return v10
+b4:
+    v11 = Call v3, v0, v4
+    Branch v11, b2, b3
+b5:
+    v12 = Call v5, v0, v6
+    Branch v12, b6, b7
+b6:
+    v13 = Call v7, v0, v2
+    Branch v13, b1, b3
+b7:
+    v14 = Call v8, v0, v6
+    Branch v14, b1, b3
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
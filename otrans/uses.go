@@ -0,0 +1,277 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+)
+
+// replaceUses rewrites the operands of all of the instructions and
+// terminators in the given function, replacing each value that is a key in
+// the given map with its corresponding value.
+//
+// Replacements are followed transitively, so if a maps to b and b maps to c
+// then uses of a are replaced with c.
+func replaceUses(f *ossa.Function, repl map[*ossa.Value]*ossa.Value) {
+	if len(repl) == 0 {
+		return
+	}
+	resolve := func(v *ossa.Value) *ossa.Value {
+		for {
+			next, exists := repl[v]
+			if !exists || next == v {
+				return v
+			}
+			v = next
+		}
+	}
+	replace := func(operand *ossa.Value) (*ossa.Value, bool) {
+		return resolve(operand), true
+	}
+	ossa.WalkValues(f, ossa.VisitorFuncs{
+		Value: func(v *ossa.Value) bool {
+			return ossa.WalkOperands(v, replace)
+		},
+		Terminator: func(t *ossa.Terminator) bool {
+			return ossa.WalkTerminatorOperands(t, replace)
+		},
+	})
+}
+
+// blockOrder returns a map from each block in the given function to its
+// position in the function's block order, for use in sorting blocks
+// deterministically.
+func blockOrder(f *ossa.Function) map[*ossa.BasicBlock]int {
+	blocks := f.AppendBlocks(nil)
+	ret := make(map[*ossa.BasicBlock]int, len(blocks))
+	for i, block := range blocks {
+		ret[block] = i
+	}
+	return ret
+}
+
+// insertPhi inserts the given Phi node at the start of the given block, after
+// any Phi nodes already present.
+func insertPhi(block *ossa.BasicBlock, phi *ossa.Value) {
+	insertAfterPhis(block, phi)
+}
+
+// insertAfterPhis inserts the given instruction into the given block,
+// immediately after any Phi nodes at its start.
+func insertAfterPhis(block *ossa.BasicBlock, v *ossa.Value) {
+	idx := 0
+	for idx < len(block.Instructions) && block.Instructions[idx].Op() == ossa.OpPhi {
+		idx++
+	}
+	insts := append(block.Instructions, nil)
+	copy(insts[idx+1:], insts[idx:])
+	insts[idx] = v
+	block.Instructions = insts
+}
+
+// invokedCall returns the Call instruction used by the Invoke terminator of
+// the given block, or nil if the block doesn't end with an Invoke.
+func invokedCall(block *ossa.BasicBlock) *ossa.Value {
+	if t := block.Terminator; t != nil && t.Op() == ossa.OpInvoke {
+		return t.Arg(0).Value
+	}
+	return nil
+}
+
+// appendInstruction appends the given instruction to the end of the given
+// block, before its terminator. If the block ends with an Invoke then the
+// instruction is instead inserted before the invoked call, which is always the
+// block's last instruction, so that it runs even if the call unwinds. The
+// instruction therefore must not use the call's result; use
+// normalEdgeBlock to find a place for such instructions.
+func appendInstruction(block *ossa.BasicBlock, v *ossa.Value) {
+	call := invokedCall(block)
+	if call == nil {
+		block.Instructions = append(block.Instructions, v)
+		return
+	}
+	block.Instructions = append(block.Instructions, call)
+	block.Instructions[len(block.Instructions)-2] = v
+}
+
+// normalEdgeBlock returns a block whose instructions run only after the call
+// invoked by the given block's terminator has returned normally, and before
+// execution reaches the Invoke's normal target, splitting that edge as
+// described for SplitEdge. The given block must end with an Invoke whose
+// normal and unwind targets differ, as they must whenever the call's result
+// is used.
+func normalEdgeBlock(f *ossa.Function, block *ossa.BasicBlock) *ossa.BasicBlock {
+	return SplitEdge(f, block, block.Terminator.Arg(0).Block)
+}
+
+// replacePhiPredecessor updates the Phi nodes in the successors of the given
+// block so that any candidates for predecessor "from" instead refer to
+// predecessor "to".
+func replacePhiPredecessor(block, from, to *ossa.BasicBlock) {
+	if block.Terminator == nil {
+		return
+	}
+	seen := make(ossa.BasicBlockSet)
+	block.AddSuccessors(basicBlockAdderFunc(func(succ *ossa.BasicBlock) {
+		if seen.Has(succ) {
+			return
+		}
+		seen.Add(succ)
+		for _, inst := range succ.Instructions {
+			if inst.Op() != ossa.OpPhi {
+				break
+			}
+			for i := 0; i < inst.NumArgs(); i++ {
+				if c := inst.PhiCandidate(i); c.Block == from {
+					c.Block = to
+					inst.SetPhiCandidate(i, c)
+				}
+			}
+		}
+	}))
+}
+
+// functionPredecessors returns the predecessors of each block in the given
+// function, including any that are not reachable from the entry block, in
+// the function's block order. Unlike oana.FindPredecessors, this finds all
+// of the terminators that refer to each block, which is what transforms
+// must update when they remove a block.
+func functionPredecessors(f *ossa.Function) map[*ossa.BasicBlock][]*ossa.BasicBlock {
+	ret := make(map[*ossa.BasicBlock][]*ossa.BasicBlock)
+	for _, block := range f.AppendBlocks(nil) {
+		if block.Terminator == nil {
+			continue
+		}
+		seen := make(ossa.BasicBlockSet)
+		block.AddSuccessors(basicBlockAdderFunc(func(succ *ossa.BasicBlock) {
+			if seen.Has(succ) {
+				return
+			}
+			seen.Add(succ)
+			ret[succ] = append(ret[succ], block)
+		}))
+	}
+	return ret
+}
+
+// retargetTerminator changes any targets of the given terminator that refer
+// to block "from" to instead refer to block "to".
+func retargetTerminator(t *ossa.Terminator, from, to *ossa.BasicBlock) {
+	for i := 0; i < t.NumArgs(); i++ {
+		if arg := t.Arg(i); arg.Block == from {
+			arg.Block = to
+			t.SetArg(i, arg)
+		}
+	}
+}
+
+// ssaRepairer finds the value of a variable that reaches each block, given
+// the value of the variable at the end of some of the blocks that define it,
+// inserting Phi nodes where the definitions meet. It is used to restore the
+// SSA property after a transform introduces new definitions of a value.
+type ssaRepairer struct {
+	preds    map[*ossa.BasicBlock][]*ossa.BasicBlock
+	defs     map[*ossa.BasicBlock]*ossa.Value
+	atStart  map[*ossa.BasicBlock]*ossa.Value
+	visiting ossa.BasicBlockSet
+	undef    *ossa.Value
+
+	// created records each Phi node inserted by the repairer along with the
+	// block that contains it.
+	created map[*ossa.Value]*ossa.BasicBlock
+}
+
+// newSSARepairer returns a repairer for a variable whose value at the end of
+// each block that defines it is given in defs. The predecessors must be those
+// returned by functionPredecessors for the function being repaired. Each Phi
+// node the repairer inserts is recorded in the created map, which is usually
+// later passed to removeTrivialPhis.
+func newSSARepairer(preds map[*ossa.BasicBlock][]*ossa.BasicBlock, created map[*ossa.Value]*ossa.BasicBlock, defs map[*ossa.BasicBlock]*ossa.Value) *ssaRepairer {
+	return &ssaRepairer{
+		preds:    preds,
+		defs:     defs,
+		atStart:  make(map[*ossa.BasicBlock]*ossa.Value),
+		visiting: make(ossa.BasicBlockSet),
+		created:  created,
+	}
+}
+
+// valueAtEnd returns the value that reaches the end of the given block.
+func (r *ssaRepairer) valueAtEnd(block *ossa.BasicBlock) *ossa.Value {
+	if def := r.defs[block]; def != nil {
+		return def
+	}
+	return r.valueAtStart(block)
+}
+
+// valueAtStart returns the value that reaches the start of the given block,
+// inserting a Phi node into it if different values reach it from different
+// predecessors. The result is Undef for blocks that are reachable only
+// through paths that never pass through a definition.
+func (r *ssaRepairer) valueAtStart(block *ossa.BasicBlock) *ossa.Value {
+	if v := r.atStart[block]; v != nil {
+		return v
+	}
+	preds := r.preds[block]
+	if len(preds) == 0 || r.visiting.Has(block) {
+		if r.undef == nil {
+			r.undef = ossa.Undef()
+		}
+		return r.undef
+	}
+	if len(preds) == 1 {
+		r.visiting.Add(block)
+		v := r.valueAtEnd(preds[0])
+		r.visiting.Remove(block)
+		r.atStart[block] = v
+		return v
+	}
+	phi := ossa.Phi()
+	insertPhi(block, phi)
+	r.created[phi] = block
+	r.atStart[block] = phi
+	for _, pred := range preds {
+		phi.AddPhiCandidate(ossa.BasicBlockValue{Block: pred, Value: r.valueAtEnd(pred)})
+	}
+	return phi
+}
+
+// removeTrivialPhis removes each of the given Phi nodes whose candidates
+// all have the same value, other than the Phi node itself, replacing its
+// uses with that value. The map gives the block containing each Phi node.
+func removeTrivialPhis(f *ossa.Function, phis map[*ossa.Value]*ossa.BasicBlock) {
+	repl := make(map[*ossa.Value]*ossa.Value)
+	resolve := func(v *ossa.Value) *ossa.Value {
+		for repl[v] != nil {
+			v = repl[v]
+		}
+		return v
+	}
+	for changed := true; changed; {
+		changed = false
+		for phi := range phis {
+			if repl[phi] != nil {
+				continue
+			}
+			var same *ossa.Value
+			trivial := true
+			for i := 0; i < phi.NumArgs(); i++ {
+				v := resolve(phi.PhiCandidate(i).Value)
+				if v == phi || v == same {
+					continue
+				}
+				if same != nil {
+					trivial = false
+					break
+				}
+				same = v
+			}
+			if trivial && same != nil {
+				repl[phi] = same
+				changed = true
+			}
+		}
+	}
+	replaceUses(f, repl)
+	for phi := range repl {
+		removeInstruction(phis[phi], phi)
+	}
+}
@@ -0,0 +1,124 @@
+package otrans
+
+import (
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/oana"
+)
+
+// LocalValueNumbering removes instructions that are equivalent to an earlier
+// instruction in the same block, replacing their uses with that earlier
+// instruction. It returns the number of instructions removed.
+//
+// Only instructions without side-effects that do not read memory can be
+// removed. Operations are classified using Op.HasSideEffects and
+// Op.ReadsMemory, and the given function, if not nil, can report that other
+// instructions, such as calls to known-pure functions, are also pure. Calls
+// used by Invoke terminators are never removed.
+//
+// Instructions are compared using Value.Equivalent, after replacing any
+// operands that were themselves removed.
+func LocalValueNumbering(f *ossa.Function, pure func(v *ossa.Value) bool) int {
+	vn := newValueNumbering(pure)
+	for _, block := range f.AppendBlocks(nil) {
+		vn.visitBlock(block)
+		vn.table = make(map[uint64][]*ossa.Value)
+	}
+	vn.finish(f)
+	return vn.count
+}
+
+// GlobalValueNumbering is like LocalValueNumbering except that an
+// instruction is removed if it is equivalent to any instruction in a block
+// that dominates it, rather than only in the same block.
+func GlobalValueNumbering(f *ossa.Function, pure func(v *ossa.Value) bool) int {
+	entry := f.Entry()
+	dt := oana.FindDominatorTree(entry, oana.FindPredecessors(entry))
+	vn := newValueNumbering(pure)
+
+	var visit func(block *ossa.BasicBlock)
+	visit = func(block *ossa.BasicBlock) {
+		added := vn.visitBlock(block)
+		for _, child := range dt.AppendChildren(block, nil) {
+			visit(child)
+		}
+		// The instructions from this block are not available in our
+		// siblings, which our parent will visit next.
+		for _, v := range added {
+			h := v.StructuralHash()
+			bucket := vn.table[h]
+			vn.table[h] = bucket[:len(bucket)-1]
+		}
+	}
+	visit(entry)
+
+	vn.finish(f)
+	return vn.count
+}
+
+type valueNumbering struct {
+	pure  func(v *ossa.Value) bool
+	table map[uint64][]*ossa.Value
+	repl  map[*ossa.Value]*ossa.Value
+	count int
+}
+
+func newValueNumbering(pure func(v *ossa.Value) bool) *valueNumbering {
+	return &valueNumbering{
+		pure:  pure,
+		table: make(map[uint64][]*ossa.Value),
+		repl:  make(map[*ossa.Value]*ossa.Value),
+	}
+}
+
+// visitBlock removes any instructions in the given block that are equivalent
+// to one already in the table, and adds the others to the table, returning
+// the ones that were added in the order they were added.
+func (vn *valueNumbering) visitBlock(block *ossa.BasicBlock) []*ossa.Value {
+	var added []*ossa.Value
+	insts := block.Instructions[:0]
+	for _, inst := range block.Instructions {
+		for i := 0; i < inst.NumArgs(); i++ {
+			if r, exists := vn.repl[inst.Arg(i)]; exists {
+				inst.SetArg(i, r)
+			}
+		}
+		if !vn.isPure(inst) || inst == invokedCall(block) {
+			insts = append(insts, inst)
+			continue
+		}
+		h := inst.StructuralHash()
+		var existing *ossa.Value
+		for _, candidate := range vn.table[h] {
+			if candidate.Equivalent(inst) {
+				existing = candidate
+				break
+			}
+		}
+		if existing != nil {
+			vn.repl[inst] = existing
+			vn.count++
+			continue
+		}
+		vn.table[h] = append(vn.table[h], inst)
+		added = append(added, inst)
+		insts = append(insts, inst)
+	}
+	for i := len(insts); i < len(block.Instructions); i++ {
+		block.Instructions[i] = nil // don't retain removed instructions
+	}
+	block.Instructions = insts
+	return added
+}
+
+func (vn *valueNumbering) isPure(v *ossa.Value) bool {
+	if op := v.Op(); !op.HasSideEffects() && !op.ReadsMemory() {
+		return true
+	}
+	return vn.pure != nil && vn.pure(v)
+}
+
+// finish replaces any remaining uses of the removed instructions, such as
+// Phi candidates from later blocks and terminator operands.
+func (vn *valueNumbering) finish(f *ossa.Function) {
+	replaceUses(f, vn.repl)
+}
@@ -0,0 +1,111 @@
+package otrans
+
+import (
+	"testing"
+
+	"github.com/alamatic/ossa"
+	"github.com/alamatic/ossa/otext"
+)
+
+func TestValueNumbering(t *testing.T) {
+	src := `func(p) {
+    add = AuxLiteral "add"
+    print = AuxLiteral "print"
+entry:
+    one = AuxLiteral 1
+    x = Call add, p, one
+    one2 = AuxLiteral 1
+    y = Call add, p, one2
+    z = Call print, y
+    w = Call print, y
+    Branch p, left, right
+left:
+    x2 = Call add, p, one
+    Return x2
+right:
+    x3 = Call add, p, one
+    Jump join
+join:
+    phi1 = Phi [right: x3]
+    phi2 = Phi [right: x]
+    Return phi2
+}
+`
+	pure := func(v *ossa.Value) bool {
+		if v.Op() != ossa.OpCall {
+			return false
+		}
+		name, ok := ossa.AuxAs[string](v.Arg(0))
+		return ok && name == "add"
+	}
+
+	tests := map[string]struct {
+		fn      func(f *ossa.Function, pure func(v *ossa.Value) bool) int
+		want    string
+		removed int
+	}{
+		"local": {
+			LocalValueNumbering,
+			`func(v0) {
+    v1 = AuxLiteral "add"
+    v2 = AuxLiteral "print"
+b0:
+    v3 = AuxLiteral 1
+    v4 = Call v1, v0, v3
+    v5 = Call v2, v4
+    v6 = Call v2, v4
+    Branch v0, b1, b2
+b1:
+    v7 = Call v1, v0, v3
+    Return v7
+b2:
+    v8 = Call v1, v0, v3
+    Jump b3
+b3:
+    v9 = Phi [b2: v8]
+    v10 = Phi [b2: v4]
+    Return v10
+}
+`,
+			2,
+		},
+		"global": {
+			GlobalValueNumbering,
+			`func(v0) {
+    v1 = AuxLiteral "add"
+    v2 = AuxLiteral "print"
+b0:
+    v3 = AuxLiteral 1
+    v4 = Call v1, v0, v3
+    v5 = Call v2, v4
+    v6 = Call v2, v4
+    Branch v0, b1, b2
+b1:
+    Return v4
+b2:
+    Jump b3
+b3:
+    v7 = Phi [b2: v4]
+    Return v7
+}
+`,
+			5,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := otext.ParseFunction([]byte(src))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := test.fn(f, pure), test.removed; got != want {
+				t.Errorf("wrong number of instructions removed %d; want %d", got, want)
+			}
+			got := otext.SprintFunction(f)
+			if got != test.want {
+				t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package ossa
+
+// Pos is the interface implemented by frontend-defined source positions,
+// which can be attached to instructions and terminators so that diagnostics
+// and debug information can refer back to the source code they came from.
+//
+// Positions are opaque to ossa, which only carries them along. A frontend
+// can represent them in whatever way suits it, such as a single offset or a
+// span between two line and column pairs, and recover its own concrete type
+// using a type assertion. The String method is used when a position must be
+// presented to a user without knowing its concrete type.
+type Pos interface {
+	String() string
+}
+
+// Pos returns the source position of the receiver, or nil if it has none.
+func (v *Value) Pos() Pos {
+	return v.pos
+}
+
+// SetPos changes the source position of the receiver. A nil position removes
+// any existing position.
+func (v *Value) SetPos(pos Pos) {
+	v.pos = pos
+}
+
+// Pos returns the source position of the receiver, or nil if it has none.
+func (t *Terminator) Pos() Pos {
+	return t.pos
+}
+
+// SetPos changes the source position of the receiver. A nil position removes
+// any existing position.
+//
+// The Unreachable terminator is shared and so cannot have a position, so
+// SetPos panics if given it with a non-nil position.
+func (t *Terminator) SetPos(pos Pos) {
+	if t == Unreachable {
+		if pos == nil {
+			return
+		}
+		panic("can't modify the Unreachable terminator")
+	}
+	t.pos = pos
+}
@@ -0,0 +1,109 @@
+package ossa
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+)
+
+// Equivalent returns true if the receiver and the given other value perform
+// the same operation with the same operands and equal aux values, and so
+// would produce the same result if their operation has no side-effects.
+//
+// Operands are compared by identity, so this is a shallow comparison. Aux
+// values are equal if they are equal canonical constants, or if they are of
+// the same comparable type and equal using the == operator. Aux values that
+// cannot be compared with == are not equal to anything.
+//
+// Values whose identity is significant, such as symbols, arguments and
+// landing pads, are equivalent only to themselves. Phi nodes are equivalent
+// only if they have the same candidates in the same order. Values must also
+// have equal types, as reported by TypesEqual.
+func (v *Value) Equivalent(other *Value) bool {
+	if v == other {
+		return true
+	}
+	if v == nil || other == nil || v.op != other.op || len(v.args) != len(other.args) {
+		return false
+	}
+	if !TypesEqual(v.typ, other.typ) {
+		return false
+	}
+	switch v.op {
+	case OpGlobalSym, OpLocalSym, OpArgument, OpLandingPad:
+		return false
+	case OpPhi:
+		for i := 0; i < v.NumArgs(); i++ {
+			if v.PhiCandidate(i) != other.PhiCandidate(i) {
+				return false
+			}
+		}
+		return true
+	}
+	for i, arg := range v.args {
+		if other.args[i] != arg {
+			return false
+		}
+	}
+	return auxEqual(v.aux, other.aux)
+}
+
+// StructuralHash returns a hash of the operation, operands and aux value of
+// the receiver, such that any two values that are Equivalent have the same
+// hash. It can be used to find equivalent values efficiently.
+//
+// The hash of a value depends on the identities of its operands, and so it
+// is meaningful only within a single process.
+func (v *Value) StructuralHash() uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%d", v.op, len(v.args))
+	switch v.op {
+	case OpGlobalSym, OpLocalSym, OpArgument, OpLandingPad:
+		fmt.Fprintf(h, "/%p", v)
+		return h.Sum64()
+	case OpPhi:
+		for i := 0; i < v.NumArgs(); i++ {
+			c := v.PhiCandidate(i)
+			fmt.Fprintf(h, "/%p:%p", c.Block, c.Value)
+		}
+		return h.Sum64()
+	}
+	for _, arg := range v.args {
+		fmt.Fprintf(h, "/%p", arg)
+	}
+	switch aux := v.aux.(type) {
+	case nil:
+	case Const:
+		fmt.Fprintf(h, "/%s", aux)
+	default:
+		// Values that are not comparable are never equal to anything, so
+		// it doesn't matter what we hash for them.
+		if reflect.TypeOf(aux).Comparable() {
+			fmt.Fprintf(h, "/%T:%#v", aux, aux)
+		}
+	}
+	return h.Sum64()
+}
+
+func auxEqual(a, b interface{}) (equal bool) {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if ac, ok := a.(Const); ok {
+		bc, ok := b.(Const)
+		return ok && ac.Equal(bc)
+	}
+	at := reflect.TypeOf(a)
+	if at != reflect.TypeOf(b) || !at.Comparable() {
+		return false
+	}
+	// A comparable type can still hold values that are not comparable, such
+	// as a struct with an interface field containing a slice, and then ==
+	// panics. Those are treated like values of non-comparable types.
+	defer func() {
+		if recover() != nil {
+			equal = false
+		}
+	}()
+	return a == b
+}
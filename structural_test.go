@@ -0,0 +1,34 @@
+package ossa
+
+import (
+	"testing"
+)
+
+func TestValueEquivalentAux(t *testing.T) {
+	type wrapper struct {
+		v interface{}
+	}
+	tests := map[string]struct {
+		a, b interface{}
+		want bool
+	}{
+		"equal strings":        {"a", "a", true},
+		"different strings":    {"a", "b", false},
+		"different types":      {int32(1), int64(1), false},
+		"equal constants":      {Int64Const(1), Int64Const(1), true},
+		"slices":               {[]int{1}, []int{1}, false},
+		"equal wrappers":       {wrapper{1}, wrapper{1}, true},
+		"wrappers with slices": {wrapper{[]int{1}}, wrapper{[]int{1}}, false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a, b := AuxLiteral(test.a), AuxLiteral(test.b)
+			if got := a.Equivalent(b); got != test.want {
+				t.Errorf("Equivalent = %t; want %t", got, test.want)
+			}
+			if test.want && a.StructuralHash() != b.StructuralHash() {
+				t.Errorf("equivalent values have different hashes")
+			}
+		})
+	}
+}
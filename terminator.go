@@ -14,6 +14,17 @@ type Terminator struct {
 	// fields of struct BasicBlockValue, depending on the needs of the op.
 	args []BasicBlockValue
 
+	// pos is the frontend-defined source position of the terminator, if
+	// any.
+	pos Pos
+
+	// meta is the terminator's metadata, as described by MetaHolder.
+	meta metadata
+
+	// weights is the relative weight of each of the terminator's
+	// arguments, if set using SetWeights.
+	weights []float64
+
 	// For ops that use two or fewer args, this can be used as the backing
 	// array for args, avoiding another allocation. The size 3 is chosen
 	// to make just enough room for call instructions that are representing
@@ -64,13 +75,31 @@ func Switch(inp *Value, defTarget *BasicBlock, cases ...BasicBlockValue) *Termin
 }
 
 // Return constructs a terminator that exits the current function with the
-// given return value. This terminator produces no successors.
-func Return(ret *Value) *Terminator {
+// given return values, of which there may be any number including zero. This
+// terminator produces no successors.
+//
+// Return values must not be nil. However, Return previously took exactly one
+// value, which was nil for a function that returns nothing, so for
+// compatibility with callers written for that form a single nil value is
+// treated the same as no values at all. Return panics if given nil along
+// with other values.
+func Return(vals ...*Value) *Terminator {
+	if len(vals) == 1 && vals[0] == nil {
+		vals = nil
+	}
+	for _, v := range vals {
+		if v == nil {
+			panic("Return with nil value")
+		}
+	}
 	t := &Terminator{
 		op: OpReturn,
 	}
-	t.argsBuf[0].Value = ret
-	t.args = t.argsBuf[:1]
+	aa := t.bufForArgs(len(vals))
+	for _, v := range vals {
+		aa = append(aa, BasicBlockValue{Value: v})
+	}
+	t.args = aa
 	return t
 }
 
@@ -78,6 +107,8 @@ func Return(ret *Value) *Terminator {
 // Yield indicates that the routine wishes to yield control to another routine.
 // The exact behavior of a yield is ultimately decided by the language runtime;
 // for languages that don't use coroutines, do not generate Yield terminators.
+// Package otrans provides LowerCoroutine for runtimes that implement
+// coroutines as state machines.
 //
 // The given basic block is the point where execution will continue after the
 // coroutine is resumed.
@@ -107,6 +138,45 @@ func Await(event *Value, resume *BasicBlock) *Terminator {
 	return t
 }
 
+// Invoke constructs a terminator that represents a call that may unwind,
+// for languages with exceptions. The given call must be a Call instruction
+// and must be the last instruction of the block that the terminator ends, so
+// that the call is the only thing that can unwind and every other instruction
+// in the block runs before it on both paths.
+//
+// If the call returns normally then execution continues at the normal
+// target, where the call's result is available. If the call instead raises an
+// exception then execution continues at the unwind target, where the call
+// has no result and a LandingPad instruction can obtain the exception.
+// Transforms that need to place an instruction after the call, such as a use
+// of its result, must place it on the edge to the normal target instead.
+//
+// Calls that aren't used with Invoke are assumed to either return normally or
+// unwind out of the function entirely, without visiting any of its blocks.
+func Invoke(call *Value, normal, unwind *BasicBlock) *Terminator {
+	t := &Terminator{
+		op: OpInvoke,
+	}
+	t.argsBuf[0].Value = call
+	t.argsBuf[0].Block = normal
+	t.argsBuf[1].Block = unwind // argsBuf[1].Value is unused
+	t.args = t.argsBuf[:2]
+	return t
+}
+
+// Resume constructs a terminator that exits the current function by
+// continuing to unwind with the given exception value, which was typically
+// obtained from a LandingPad instruction. This terminator produces no
+// successors.
+func Resume(exc *Value) *Terminator {
+	t := &Terminator{
+		op: OpResume,
+	}
+	t.argsBuf[0].Value = exc
+	t.args = t.argsBuf[:1]
+	return t
+}
+
 // Unreachable is a special terminator that has no behavior and no successors.
 // This should be used only in situations where the language frontend can
 // guarantee control can never reach a certain point (or it would be undefined
@@ -119,6 +189,168 @@ func Await(event *Value, resume *BasicBlock) *Terminator {
 // Although this is a variable, callers are forbidden from assigning to it.
 var Unreachable *Terminator
 
+// Op returns the operation of the receiving terminator.
+func (t *Terminator) Op() Op {
+	return t.op
+}
+
+// NumArgs returns the number of arguments of the receiving terminator.
+func (t *Terminator) NumArgs() int {
+	return len(t.args)
+}
+
+// Arg returns the argument at the given index, which must be less than the
+// result of NumArgs. The meaning of each argument, and which of its fields
+// are populated, depends on the terminator's operation:
+//
+//   - OpJump and OpYield have a single argument whose Block is the target.
+//   - OpBranch has two arguments. The first has the condition as its Value
+//     and the true target as its Block, while the second has only the false
+//     target as its Block.
+//   - OpSwitch has the input value and default target as its first argument,
+//     followed by one argument for each case.
+//   - OpReturn has one argument for each return value, with that value as
+//     its Value.
+//   - OpAwait has a single argument with the event as its Value and the resume
+//     block as its Block.
+//   - OpInvoke has two arguments. The first has the call as its Value and the
+//     normal target as its Block, while the second has only the unwind target
+//     as its Block.
+//   - OpResume has a single argument whose Value is the exception.
+//   - OpUnreachable has no arguments.
+//   - Registered operations have arguments as described for OpSpec.Arity.
+func (t *Terminator) Arg(i int) BasicBlockValue {
+	return t.args[i]
+}
+
+// SetArg replaces the argument at the given index, which must be less than
+// the result of NumArgs. The caller must respect the argument layout of the
+// terminator's operation, as described for Arg.
+func (t *Terminator) SetArg(i int, arg BasicBlockValue) {
+	if t == Unreachable {
+		panic("can't modify the Unreachable terminator")
+	}
+	t.args[i] = arg
+}
+
+// Clone returns a new terminator with the same operation, arguments, source
+// position, metadata and weights as the receiver, except that the clone of
+// the shared Unreachable terminator is Unreachable itself.
+//
+// Transforms that duplicate blocks use Clone to copy their terminators, and
+// must then replace any arguments that refer to duplicated blocks or
+// instructions.
+func (t *Terminator) Clone() *Terminator {
+	if t == Unreachable {
+		return t
+	}
+	ret := &Terminator{
+		op:      t.op,
+		pos:     t.pos,
+		meta:    t.meta.copy(),
+		weights: append([]float64(nil), t.weights...),
+	}
+	ret.args = append(ret.bufForArgs(len(t.args)), t.args...)
+	return ret
+}
+
+// SwitchInput returns the input value of a terminator constructed by Switch.
+//
+// SwitchInput panics if the receiver is not a Switch terminator.
+func (t *Terminator) SwitchInput() *Value {
+	t.assertSwitch("SwitchInput")
+	return t.args[0].Value
+}
+
+// SwitchDefault returns the default target of a terminator constructed by
+// Switch, which is selected when none of the cases match.
+//
+// SwitchDefault panics if the receiver is not a Switch terminator.
+func (t *Terminator) SwitchDefault() *BasicBlock {
+	t.assertSwitch("SwitchDefault")
+	return t.args[0].Block
+}
+
+// NumSwitchCases returns the number of cases of a terminator constructed by
+// Switch, not including its default target.
+//
+// NumSwitchCases panics if the receiver is not a Switch terminator.
+func (t *Terminator) NumSwitchCases() int {
+	t.assertSwitch("NumSwitchCases")
+	return len(t.args) - 1
+}
+
+// SwitchCase returns the case at the given index of a terminator constructed
+// by Switch, with the value to compare the input against as its Value and the
+// target to select if they are equal as its Block. The index must be less
+// than the result of NumSwitchCases.
+//
+// SwitchCase panics if the receiver is not a Switch terminator.
+func (t *Terminator) SwitchCase(i int) BasicBlockValue {
+	t.assertSwitch("SwitchCase")
+	return t.args[i+1]
+}
+
+func (t *Terminator) assertSwitch(method string) {
+	if t.op != OpSwitch {
+		panic(method + " on non-Switch terminator")
+	}
+}
+
+// AppendReturnValues appends to the given slice the values returned by the
+// receiving terminator, in order, and returns the resulting slice. Nothing is
+// appended for terminators other than OpReturn.
+func (t *Terminator) AppendReturnValues(to []*Value) []*Value {
+	if t.op != OpReturn {
+		return to
+	}
+	for _, arg := range t.args {
+		to = append(to, arg.Value)
+	}
+	return to
+}
+
+// Weights returns the relative weights of the outgoing edges of the receiving
+// terminator, with one weight for each of its arguments, or nil if the
+// terminator has no weights.
+//
+// The caller must not modify the returned slice.
+func (t *Terminator) Weights() []float64 {
+	return t.weights
+}
+
+// SetWeights sets the relative weights of the outgoing edges of the receiving
+// terminator, which indicate how likely each edge is to be taken compared to
+// the others. Calling SetWeights with no weights removes any existing weights.
+//
+// Only OpBranch and OpSwitch terminators can have weights, and there must be
+// exactly one non-negative weight for each argument: the true and then the
+// false target of a branch, or the default target and then each of the cases
+// of a switch. SetWeights panics if these requirements are not met.
+//
+// Weights belong to the terminator rather than to its target blocks, so
+// replacing an argument using SetArg keeps the weight at the same index.
+func (t *Terminator) SetWeights(weights ...float64) {
+	if len(weights) == 0 {
+		if t != Unreachable {
+			t.weights = nil
+		}
+		return
+	}
+	if t.op != OpBranch && t.op != OpSwitch {
+		panic(fmt.Sprintf("can't set weights on %s terminator", t.op))
+	}
+	if len(weights) != len(t.args) {
+		panic(fmt.Sprintf("%s terminator with %d arguments can't have %d weights", t.op, len(t.args), len(weights)))
+	}
+	for _, w := range weights {
+		if w < 0 {
+			panic("terminator weights must not be negative")
+		}
+	}
+	t.weights = append([]float64(nil), weights...)
+}
+
 // AppendSuccessors appends to the given slice any successors for the recieving
 // terminator. Pass a nil slice to force this function to allocate a new backing
 // array and return it, or pre-allocate a buffer in the caller.
@@ -136,23 +368,33 @@ func (t *Terminator) AppendSuccessors(to []*BasicBlock) []*BasicBlock {
 // AddSuccessors adds to the given set any successors for the receiving
 // terminator, in-place.
 func (t *Terminator) AddSuccessors(to BasicBlockAdder) {
-	// This switch must cover all of the ops that are considered to be
-	// terminator operations by op.Terminator.
+	// This switch must cover all of the built-in ops that are considered to
+	// be terminator operations by op.Terminator.
 	switch t.op {
 	case OpJump:
 		to.Add(t.args[0].Block)
-	case OpBranch:
+	case OpBranch, OpInvoke:
 		to.Add(t.args[0].Block)
 		to.Add(t.args[1].Block)
 	case OpSwitch:
 		for _, arg := range t.args {
 			to.Add(arg.Block)
 		}
-	case OpReturn, OpUnreachable:
+	case OpReturn, OpResume, OpUnreachable:
 		return // no successors
 	case OpYield, OpAwait:
 		to.Add(t.args[0].Block)
 	default:
+		if _, registered := t.op.Spec(); registered && t.op.Terminator() {
+			// The successors of a registered terminator are whichever
+			// blocks its arguments refer to.
+			for _, arg := range t.args {
+				if arg.Block != nil {
+					to.Add(arg.Block)
+				}
+			}
+			return
+		}
 		if t.op.Terminator() {
 			// Indicates we're missing a case above
 			panic(fmt.Sprintf("AppendSuccessors is missing a case for %s", t.op))
@@ -0,0 +1,135 @@
+package ossa
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReturnValues(t *testing.T) {
+	a := AuxLiteral(1)
+	b := AuxLiteral(2)
+	tests := map[string][]*Value{
+		"none": nil,
+		"one":  {a},
+		"two":  {a, b},
+	}
+	for name, vals := range tests {
+		t.Run(name, func(t *testing.T) {
+			ret := Return(vals...)
+			if got, want := ret.NumArgs(), len(vals); got != want {
+				t.Errorf("wrong number of arguments %d; want %d", got, want)
+			}
+			if got := ret.AppendReturnValues(nil); !reflect.DeepEqual(got, vals) {
+				t.Errorf("wrong return values %v; want %v", got, vals)
+			}
+			if got := ret.AppendSuccessors(nil); len(got) != 0 {
+				t.Errorf("Return has successors")
+			}
+		})
+	}
+
+	if got := Jump(&BasicBlock{}).AppendReturnValues(nil); got != nil {
+		t.Errorf("Jump has return values %v", got)
+	}
+
+	// A lone nil is the void return from when Return took a single value.
+	if got := Return(nil).NumArgs(); got != 0 {
+		t.Errorf("Return(nil) has %d arguments; want 0", got)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Return with nil among other values did not panic")
+			}
+		}()
+		Return(a, nil)
+	}()
+}
+
+func TestSwitchCases(t *testing.T) {
+	def := &BasicBlock{}
+	target := &BasicBlock{}
+	inp := AuxLiteral(0)
+	one := AuxLiteral(1)
+	sw := Switch(inp, def, BasicBlockValue{Value: one, Block: target})
+
+	if got := sw.SwitchInput(); got != inp {
+		t.Errorf("wrong input")
+	}
+	if got := sw.SwitchDefault(); got != def {
+		t.Errorf("wrong default target")
+	}
+	if got, want := sw.NumSwitchCases(), 1; got != want {
+		t.Fatalf("wrong number of cases %d; want %d", got, want)
+	}
+	if got, want := sw.SwitchCase(0), (BasicBlockValue{Value: one, Block: target}); got != want {
+		t.Errorf("wrong case %#v; want %#v", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("SwitchInput on a Jump did not panic")
+		}
+	}()
+	Jump(def).SwitchInput()
+}
+
+func TestTerminatorArgs(t *testing.T) {
+	a, b := NewBasicBlock(), NewBasicBlock()
+	cond := Argument()
+
+	tests := map[string]struct {
+		t    *Terminator
+		op   Op
+		args []BasicBlockValue
+	}{
+		"Jump":        {Jump(a), OpJump, []BasicBlockValue{{Block: a}}},
+		"Branch":      {Branch(cond, a, b), OpBranch, []BasicBlockValue{{Value: cond, Block: a}, {Block: b}}},
+		"Yield":       {Yield(b), OpYield, []BasicBlockValue{{Block: b}}},
+		"Await":       {Await(cond, a), OpAwait, []BasicBlockValue{{Value: cond, Block: a}}},
+		"Resume":      {Resume(cond), OpResume, []BasicBlockValue{{Value: cond}}},
+		"Unreachable": {Unreachable, OpUnreachable, nil},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.t.Op(); got != test.op {
+				t.Errorf("wrong op %s; want %s", got, test.op)
+			}
+			var got []BasicBlockValue
+			for i := 0; i < test.t.NumArgs(); i++ {
+				got = append(got, test.t.Arg(i))
+			}
+			if !reflect.DeepEqual(got, test.args) {
+				t.Errorf("wrong arguments %v; want %v", got, test.args)
+			}
+		})
+	}
+}
+
+func TestTerminatorClone(t *testing.T) {
+	a, b := NewBasicBlock(), NewBasicBlock()
+	cond := Argument()
+	br := Branch(cond, a, b)
+	br.SetWeights(3, 1)
+	br.SetMeta("hint", 1)
+	clone := br.Clone()
+	if clone == br {
+		t.Fatalf("clone is the original terminator")
+	}
+	if got, want := clone.AppendSuccessors(nil), []*BasicBlock{a, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong successors %v; want %v", got, want)
+	}
+	if got, want := clone.Weights(), []float64{3, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong weights %v; want %v", got, want)
+	}
+	clone.SetArg(0, BasicBlockValue{Value: cond, Block: b})
+	clone.SetWeights(1, 1)
+	clone.SetMeta("hint", 2)
+	if br.Arg(0).Block != a || br.Weights()[0] != 3 || br.Meta("hint") != 1 {
+		t.Errorf("changing the clone changed the original")
+	}
+
+	if Unreachable.Clone() != Unreachable {
+		t.Errorf("clone of Unreachable is a different terminator")
+	}
+}
@@ -0,0 +1,126 @@
+package ossa
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Type is the interface implemented by frontend-defined types that can be
+// attached to values.
+//
+// Types are opaque to ossa, which never interprets them except to compare
+// them using Equal. A frontend or backend that needs more information, such
+// as the width of a value in bits, should use a type assertion to recover
+// its own concrete type.
+type Type interface {
+	// Equal returns true if the receiver and the given other type are the
+	// same type. The other type might have been defined by a different
+	// frontend, in which case Equal should return false.
+	Equal(other Type) bool
+}
+
+// TypesEqual returns true if the two given types are equal. Two nil types
+// are equal, but a nil type is not equal to any other type.
+func TypesEqual(a, b Type) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(b)
+}
+
+// Type returns the type of the receiver, or nil if it has no type.
+//
+// Values do not have types unless a frontend assigns them using SetType, and
+// transforms that construct new values do not generally assign types to
+// them, so callers must be prepared to handle values with no type.
+func (v *Value) Type() Type {
+	return v.typ
+}
+
+// SetType changes the type of the receiver. A nil type removes any existing
+// type.
+func (v *Value) SetType(t Type) {
+	v.typ = t
+}
+
+// TypeChecker is the interface implemented by frontend-defined type rules,
+// which can check that the types of the values in a function are consistent
+// with each other. A TypeChecker can be used with CheckTypes.
+type TypeChecker interface {
+	// CheckValue returns an error if the given instruction's type is not
+	// consistent with its operation and the types of its operands.
+	CheckValue(v *Value) error
+
+	// CheckTerminator returns an error if the types of the given
+	// terminator's operands are not suitable for its operation.
+	CheckTerminator(t *Terminator) error
+}
+
+// CheckTypes calls the given checker for each instruction and terminator in
+// the given function, in the order visited by WalkValues, and returns the
+// first error it reports, or nil if there were no errors.
+//
+// Values that are not instructions, such as literals and parameters, are not
+// checked directly, but the checker can check them as operands of the
+// instructions that use them.
+func CheckTypes(f *Function, checker TypeChecker) error {
+	var err error
+	WalkValues(f, VisitorFuncs{
+		Value: func(v *Value) bool {
+			err = checker.CheckValue(v)
+			return err == nil
+		},
+		Terminator: func(t *Terminator) bool {
+			err = checker.CheckTerminator(t)
+			return err == nil
+		},
+	})
+	return err
+}
+
+// AuxTypeChecker returns a TypeChecker that reports an error for any
+// AuxLiteral, whether an instruction or an operand of an instruction or
+// terminator, whose auxiliary value is not of type T, as determined by
+// AuxAs.
+//
+// A frontend whose AuxLiteral values all have a single type can use this
+// with CheckTypes to ensure that code consuming those values with AuxAs will
+// not find any of another type. A frontend with other type rules can call
+// CheckTypes once for each checker.
+func AuxTypeChecker[T any]() TypeChecker {
+	return auxTypeChecker[T]{}
+}
+
+type auxTypeChecker[T any] struct{}
+
+func (c auxTypeChecker[T]) CheckValue(v *Value) error {
+	if err := c.check(v); err != nil {
+		return err
+	}
+	var err error
+	WalkOperands(v, func(operand *Value) (*Value, bool) {
+		err = c.check(operand)
+		return operand, err == nil
+	})
+	return err
+}
+
+func (c auxTypeChecker[T]) CheckTerminator(t *Terminator) error {
+	var err error
+	WalkTerminatorOperands(t, func(operand *Value) (*Value, bool) {
+		err = c.check(operand)
+		return operand, err == nil
+	})
+	return err
+}
+
+func (auxTypeChecker[T]) check(v *Value) error {
+	if v.Op() != OpAuxLiteral {
+		return nil
+	}
+	if _, ok := AuxAs[T](v); !ok {
+		want := reflect.TypeOf((*T)(nil)).Elem()
+		return fmt.Errorf("AuxLiteral has aux value of type %T; want %s", v.Aux(), want)
+	}
+	return nil
+}
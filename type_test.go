@@ -0,0 +1,117 @@
+package ossa
+
+import (
+	"fmt"
+	"testing"
+)
+
+type testIntType int
+
+func (t testIntType) Equal(other Type) bool {
+	o, ok := other.(testIntType)
+	return ok && o == t
+}
+
+type testTypeChecker struct{}
+
+func (testTypeChecker) CheckValue(v *Value) error {
+	if v.Op() != OpCall {
+		return nil
+	}
+	for i := 1; i < v.NumArgs(); i++ {
+		if !TypesEqual(v.Arg(i).Type(), v.Type()) {
+			return fmt.Errorf("operand %d has wrong type", i)
+		}
+	}
+	return nil
+}
+
+func (testTypeChecker) CheckTerminator(t *Terminator) error {
+	if t.Op() == OpBranch && !TypesEqual(t.Arg(0).Value.Type(), testIntType(1)) {
+		return fmt.Errorf("branch condition must be int1")
+	}
+	return nil
+}
+
+func TestTypes(t *testing.T) {
+	f := NewFunction()
+	p := f.AddParam()
+	p.SetType(testIntType(32))
+	b := NewFunctionBuilder(f)
+	add := b.AuxLiteral("add")
+	lt := b.AuxLiteral("lt")
+
+	sum := b.Call(add, p, p)
+	sum.SetType(testIntType(32))
+	cond := b.Call(lt, sum, p)
+	cond.SetType(testIntType(1))
+	exit := b.NewBlock()
+	b.Branch(cond, exit, exit)
+	b.SetBlock(exit)
+	b.Return(sum)
+
+	t.Run("equivalence", func(t *testing.T) {
+		other := Call(add, p, p)
+		if other.Equivalent(sum) {
+			t.Errorf("untyped value is equivalent to typed value")
+		}
+		other.SetType(testIntType(64))
+		if other.Equivalent(sum) {
+			t.Errorf("values with different types are equivalent")
+		}
+		other.SetType(testIntType(32))
+		if !other.Equivalent(sum) {
+			t.Errorf("values with equal types are not equivalent")
+		}
+	})
+
+	t.Run("CheckTypes", func(t *testing.T) {
+		// The comparison is deliberately ill-typed, because its operands
+		// have 32-bit type but its result is 1-bit.
+		err := CheckTypes(f, testTypeChecker{})
+		if err == nil {
+			t.Fatalf("no error for ill-typed comparison")
+		}
+		if got, want := err.Error(), "operand 1 has wrong type"; got != want {
+			t.Errorf("wrong error %q; want %q", got, want)
+		}
+
+		cond.SetType(testIntType(32))
+		err = CheckTypes(f, testTypeChecker{})
+		if err == nil {
+			t.Fatalf("no error for ill-typed branch")
+		}
+		if got, want := err.Error(), "branch condition must be int1"; got != want {
+			t.Errorf("wrong error %q; want %q", got, want)
+		}
+	})
+	t.Run("AuxTypeChecker", func(t *testing.T) {
+		if err := CheckTypes(f, AuxTypeChecker[string]()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// An AuxLiteral of another type is reported both as an operand of a
+		// terminator and as an operand of an instruction.
+		b.SetBlock(exit)
+		exit.Terminator = nil
+		num := AuxLiteralOf[int64](2)
+		b.Switch(num, exit)
+		err := CheckTypes(f, AuxTypeChecker[string]())
+		if err == nil {
+			t.Fatalf("no error for int64 aux value")
+		}
+		if got, want := err.Error(), "AuxLiteral has aux value of type int64; want string"; got != want {
+			t.Errorf("wrong error %q; want %q", got, want)
+		}
+
+		exit.Terminator = nil
+		b.Return(b.Call(num))
+		err = CheckTypes(f, AuxTypeChecker[string]())
+		if err == nil {
+			t.Fatalf("no error for int64 callee")
+		}
+		if got, want := err.Error(), "AuxLiteral has aux value of type int64; want string"; got != want {
+			t.Errorf("wrong error %q; want %q", got, want)
+		}
+	})
+}
@@ -12,6 +12,18 @@ type Value struct {
 	// aux is an auxillary native Go value
 	aux interface{}
 
+	// typ is the frontend-defined type of the value, if any.
+	typ Type
+
+	// pos is the frontend-defined source position of the value, if any.
+	pos Pos
+
+	// name is an optional name for the value, used only for debugging.
+	name string
+
+	// meta is the value's metadata, as described by MetaHolder.
+	meta metadata
+
 	// For ops that use three or fewer args, this can be used as the backing
 	// array for args, avoiding another allocation. The size 3 is chosen
 	// to make just enough room for call instructions that are representing
@@ -26,6 +38,146 @@ func (v *Value) Op() Op {
 	return v.op
 }
 
+// NumArgs returns the number of argument values of the receiver. The meaning
+// of each argument depends on the value's operation.
+//
+// For OpPhi values, the arguments are the candidate values, without their
+// associated basic blocks. Use PhiCandidate to obtain both together.
+func (v *Value) NumArgs() int {
+	if v.op == OpPhi {
+		return len(v.args) / 2
+	}
+	return len(v.args)
+}
+
+// Arg returns the argument value at the given index, which must be less than
+// the result of NumArgs.
+func (v *Value) Arg(i int) *Value {
+	if v.op == OpPhi {
+		return v.args[i*2+1]
+	}
+	return v.args[i]
+}
+
+// SetArg replaces the argument value at the given index, which must be less
+// than the result of NumArgs.
+//
+// For OpPhi values this replaces only the value of the candidate, leaving its
+// basic block unchanged.
+func (v *Value) SetArg(i int, arg *Value) {
+	if v.op == OpPhi {
+		v.args[i*2+1] = arg
+		return
+	}
+	v.args[i] = arg
+}
+
+// PhiCandidate returns the candidate at the given index for a value
+// constructed by Phi. The index must be less than the result of NumArgs.
+//
+// PhiCandidate panics if the receiver is not a Phi value.
+func (v *Value) PhiCandidate(i int) BasicBlockValue {
+	if v.op != OpPhi {
+		panic("PhiCandidate on non-Phi value")
+	}
+	return BasicBlockValue{
+		Block: v.args[i*2].aux.(*BasicBlock),
+		Value: v.args[i*2+1],
+	}
+}
+
+// AddPhiCandidate appends a new candidate to a value constructed by Phi.
+//
+// AddPhiCandidate panics if the receiver is not a Phi value.
+func (v *Value) AddPhiCandidate(c BasicBlockValue) {
+	if v.op != OpPhi {
+		panic("AddPhiCandidate on non-Phi value")
+	}
+	v.args = append(v.args, bbvsAsArgs([]BasicBlockValue{c})...)
+}
+
+// SetPhiCandidate replaces both the basic block and the value of the
+// candidate at the given index for a value constructed by Phi. The index
+// must be less than the result of NumArgs.
+//
+// SetPhiCandidate panics if the receiver is not a Phi value.
+func (v *Value) SetPhiCandidate(i int, c BasicBlockValue) {
+	if v.op != OpPhi {
+		panic("SetPhiCandidate on non-Phi value")
+	}
+	v.args[i*2] = &Value{
+		op:  opBasicBlock,
+		aux: c.Block,
+	}
+	v.args[i*2+1] = c.Value
+}
+
+// RemovePhiCandidate removes the candidate at the given index from a value
+// constructed by Phi, shifting any later candidates down to fill the gap.
+// The index must be less than the result of NumArgs.
+//
+// RemovePhiCandidate panics if the receiver is not a Phi value.
+func (v *Value) RemovePhiCandidate(i int) {
+	if v.op != OpPhi {
+		panic("RemovePhiCandidate on non-Phi value")
+	}
+	n := copy(v.args[i*2:], v.args[i*2+2:])
+	v.args[i*2+n] = nil
+	v.args[i*2+n+1] = nil
+	v.args = v.args[:i*2+n]
+}
+
+// Aux returns the auxiliary native Go value of the receiver, or nil if it
+// has none. Only OpAuxLiteral values have auxiliary values.
+func (v *Value) Aux() interface{} {
+	return v.aux
+}
+
+// Name returns the name of the receiver, or an empty string if it has no
+// name.
+//
+// Names are used only to make the IR easier to read when debugging, such as
+// in the output of the otext printer. They need not be unique, and they do
+// not affect the meaning of a value.
+func (v *Value) Name() string {
+	return v.name
+}
+
+// SetName changes the name of the receiver. An empty string removes any
+// existing name.
+func (v *Value) SetName(name string) {
+	v.name = name
+}
+
+// Clone returns a new value with the same operation, arguments, aux value,
+// type, source position, name and metadata as the receiver. For Phi nodes
+// the candidates are copied, so the clone's candidates can be changed
+// without affecting the receiver's.
+//
+// The clone is not added to any block. Transforms that duplicate code use
+// Clone to copy instructions, and must then replace any arguments that
+// refer to other duplicated instructions.
+func (v *Value) Clone() *Value {
+	ret := &Value{
+		op:   v.op,
+		aux:  v.aux,
+		typ:  v.typ,
+		pos:  v.pos,
+		name: v.name,
+		meta: v.meta.copy(),
+	}
+	ret.args = append(ret.bufForArgs(len(v.args)), v.args...)
+	if v.op == OpPhi {
+		for i := 0; i < len(ret.args); i += 2 {
+			ret.args[i] = &Value{
+				op:  opBasicBlock,
+				aux: v.args[i].aux,
+			}
+		}
+	}
+	return ret
+}
+
 // AuxLiteral constructs a new Value with OpAuxLiteral.
 func AuxLiteral(v interface{}) *Value {
 	return &Value{
@@ -34,6 +186,30 @@ func AuxLiteral(v interface{}) *Value {
 	}
 }
 
+// AuxAs returns the auxiliary value of the given value if it is of type T.
+// The second return value is false if the value has no auxiliary value or
+// if it is of some other type.
+//
+// This is intended to replace unchecked type assertions in code that
+// consumes AuxLiteral values produced by a frontend, e.g.:
+//
+//	if name, ok := ossa.AuxAs[string](v); ok {
+//	    // ...
+//	}
+func AuxAs[T any](v *Value) (T, bool) {
+	ret, ok := v.aux.(T)
+	return ret, ok
+}
+
+// AuxLiteralOf is a type-safe variant of AuxLiteral, which can be used to
+// make the intended aux type explicit at the call site, e.g. to force an
+// untyped constant to a specific type:
+//
+//	ossa.AuxLiteralOf[int64](1)
+func AuxLiteralOf[T any](v T) *Value {
+	return AuxLiteral(v)
+}
+
 // GlobalSym constructs a new global symbol. A global symbol's value pointer
 // its identity; it contains no further data.
 func GlobalSym() *Value {
@@ -58,6 +234,32 @@ func Argument() *Value {
 	}
 }
 
+// Undef constructs a new undefined value, which represents an arbitrary value
+// that a transform can choose freely. Each use of an undefined value may
+// observe a different value, so two uses of the same undefined value need not
+// be equal.
+//
+// Transforms use undefined values as placeholders where no particular value
+// is required, such as for a Load from memory that was never written.
+func Undef() *Value {
+	return &Value{
+		op: OpUndef,
+	}
+}
+
+// Poison constructs a new poison value, which represents the result of an
+// operation whose behavior is undefined. Unlike an undefined value, a poison
+// value must not be observed at all: any operation that depends on it also
+// produces poison, and a program whose effects depend on it is invalid.
+//
+// Transforms can therefore replace a poison value with any value, including
+// an undefined value, but not the other way around.
+func Poison() *Value {
+	return &Value{
+		op: OpPoison,
+	}
+}
+
 // Phi constructs a Phi node, representing the join of various possible source
 // values at the entry into a basic block.
 func Phi(candidates ...BasicBlockValue) *Value {
@@ -85,7 +287,7 @@ func Store(val *Value, ref *Value) *Value {
 		op: OpStore,
 	}
 	v.args = v.argsBuf[:2]
-	v.args[0] = v
+	v.args[0] = val
 	v.args[1] = ref
 	return v
 }
@@ -110,6 +312,37 @@ func Call(callee *Value, args ...*Value) *Value {
 	return v
 }
 
+// Select constructs a Select instruction value, which produces ifTrue if the
+// given condition is true and ifFalse otherwise, without any control flow.
+//
+// Unlike a Branch, both of the possible results must already have been
+// computed before the Select is evaluated.
+func Select(cond, ifTrue, ifFalse *Value) *Value {
+	v := &Value{
+		op: OpSelect,
+	}
+	v.args = v.argsBuf[:3]
+	v.args[0] = cond
+	v.args[1] = ifTrue
+	v.args[2] = ifFalse
+	return v
+}
+
+// LandingPad constructs a LandingPad instruction value, which produces the
+// language-defined exception value that caused control to leave a block
+// through the unwind edge of an Invoke terminator.
+//
+// A LandingPad must only be reached after passing through an unwind edge, with
+// no other Invoke terminator in between. It's typically the first instruction,
+// after any Phi nodes, of the block that an Invoke unwinds to. Each LandingPad
+// is a distinct value, even if it has the same position in the control flow
+// as another.
+func LandingPad() *Value {
+	return &Value{
+		op: OpLandingPad,
+	}
+}
+
 // bufForArgs returns a zero-length value slice with at least the given capacity
 // that can be used as the arguments for the receiving value.
 //
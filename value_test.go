@@ -0,0 +1,129 @@
+package ossa
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestValueArgs(t *testing.T) {
+	ref := LocalSym()
+	val := AuxLiteral(1)
+	callee := GlobalSym()
+	cond := Argument()
+
+	tests := map[string]struct {
+		v    *Value
+		op   Op
+		args []*Value
+	}{
+		"Load":   {Load(ref), OpLoad, []*Value{ref}},
+		"Store":  {Store(val, ref), OpStore, []*Value{val, ref}},
+		"Call":   {Call(callee, val, ref), OpCall, []*Value{callee, val, ref}},
+		"Select": {Select(cond, val, ref), OpSelect, []*Value{cond, val, ref}},
+		"Symbol": {LocalSym(), OpLocalSym, nil},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.v.Op(); got != test.op {
+				t.Errorf("wrong op %s; want %s", got, test.op)
+			}
+			var got []*Value
+			for i := 0; i < test.v.NumArgs(); i++ {
+				got = append(got, test.v.Arg(i))
+			}
+			if !reflect.DeepEqual(got, test.args) {
+				t.Errorf("wrong arguments %v; want %v", got, test.args)
+			}
+		})
+	}
+}
+
+func TestValuePhiCandidates(t *testing.T) {
+	a, b := NewBasicBlock(), NewBasicBlock()
+	one, two := AuxLiteral(1), AuxLiteral(2)
+	phi := Phi(BasicBlockValue{Block: a, Value: one}, BasicBlockValue{Block: b, Value: two})
+
+	if got, want := phi.NumArgs(), 2; got != want {
+		t.Fatalf("wrong number of candidates %d; want %d", got, want)
+	}
+	if got := phi.Arg(1); got != two {
+		t.Errorf("wrong second argument")
+	}
+	if got, want := phi.PhiCandidate(0), (BasicBlockValue{Block: a, Value: one}); got != want {
+		t.Errorf("wrong first candidate %v; want %v", got, want)
+	}
+
+	t.Run("non-Phi", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("PhiCandidate did not panic")
+			}
+		}()
+		Load(one).PhiCandidate(0)
+	})
+}
+
+func TestValueAux(t *testing.T) {
+	if got := AuxLiteral("hello").Aux(); got != "hello" {
+		t.Errorf("wrong aux value %#v", got)
+	}
+	if got := Load(LocalSym()).Aux(); got != nil {
+		t.Errorf("Load has aux value %#v", got)
+	}
+}
+
+func TestValueAuxAs(t *testing.T) {
+	v := AuxLiteralOf[int64](1)
+	if got, ok := v.Aux().(int64); !ok || got != 1 {
+		t.Errorf("wrong aux value %#v", v.Aux())
+	}
+	if got, ok := AuxAs[int64](v); !ok || got != 1 {
+		t.Errorf("AuxAs[int64] returned %#v, %v; want 1, true", got, ok)
+	}
+	if got, ok := AuxAs[int](v); ok || got != 0 {
+		t.Errorf("AuxAs[int] returned %#v, %v; want 0, false", got, ok)
+	}
+	if got, ok := AuxAs[fmt.Stringer](AuxLiteral(OpCall)); !ok || got != OpCall {
+		t.Errorf("AuxAs for interface returned %#v, %v", got, ok)
+	}
+	if _, ok := AuxAs[string](Load(LocalSym())); ok {
+		t.Errorf("AuxAs succeeded for value with no aux value")
+	}
+}
+
+func TestValueClone(t *testing.T) {
+	callee := GlobalSym()
+	x := Argument()
+	call := Call(callee, x)
+	call.SetType(testIntType(32))
+	call.SetName("result")
+	call.SetMeta("hint", 1)
+	clone := call.Clone()
+	if clone == call {
+		t.Fatalf("clone is the original value")
+	}
+	if !clone.Equivalent(call) {
+		t.Errorf("clone is not equivalent to the original")
+	}
+	if got, want := clone.Name(), "result"; got != want {
+		t.Errorf("wrong name %q; want %q", got, want)
+	}
+	if got, want := clone.Meta("hint"), 1; got != want {
+		t.Errorf("wrong metadata %#v; want %#v", got, want)
+	}
+	clone.SetArg(1, callee)
+	clone.SetMeta("hint", 2)
+	if call.Arg(1) != x || call.Meta("hint") != 1 {
+		t.Errorf("changing the clone changed the original")
+	}
+
+	a, b := NewBasicBlock(), NewBasicBlock()
+	phi := Phi(BasicBlockValue{Block: a, Value: x})
+	phiClone := phi.Clone()
+	phiClone.SetPhiCandidate(0, BasicBlockValue{Block: b, Value: callee})
+	phiClone.AddPhiCandidate(BasicBlockValue{Block: a, Value: x})
+	if got, want := phi.PhiCandidate(0), (BasicBlockValue{Block: a, Value: x}); got != want || phi.NumArgs() != 1 {
+		t.Errorf("changing the clone changed the original candidate to %v", got)
+	}
+}
@@ -0,0 +1,187 @@
+package ossa
+
+// Visitor is the interface implemented by types that can be passed to
+// WalkValues to visit the contents of a function.
+//
+// Each method returns true to continue the walk, or false to stop it
+// immediately. VisitorFuncs is a convenient implementation for visitors that
+// are interested in only some of the events.
+type Visitor interface {
+	// EnterBlock is called for each block before visiting its contents.
+	EnterBlock(block *BasicBlock) bool
+
+	// VisitValue is called for each instruction in the current block, in
+	// order.
+	VisitValue(v *Value) bool
+
+	// VisitTerminator is called for the current block's terminator, if
+	// it has one.
+	VisitTerminator(t *Terminator) bool
+
+	// LeaveBlock is called for each block after visiting its contents.
+	LeaveBlock(block *BasicBlock) bool
+}
+
+// VisitorFuncs is an implementation of Visitor that calls a separate
+// function for each event. A nil function is treated as one that always
+// continues the walk.
+type VisitorFuncs struct {
+	Enter      func(block *BasicBlock) bool
+	Value      func(v *Value) bool
+	Terminator func(t *Terminator) bool
+	Leave      func(block *BasicBlock) bool
+}
+
+var _ Visitor = VisitorFuncs{}
+
+func (f VisitorFuncs) EnterBlock(block *BasicBlock) bool {
+	return f.Enter == nil || f.Enter(block)
+}
+
+func (f VisitorFuncs) VisitValue(v *Value) bool {
+	return f.Value == nil || f.Value(v)
+}
+
+func (f VisitorFuncs) VisitTerminator(t *Terminator) bool {
+	return f.Terminator == nil || f.Terminator(t)
+}
+
+func (f VisitorFuncs) LeaveBlock(block *BasicBlock) bool {
+	return f.Leave == nil || f.Leave(block)
+}
+
+// WalkValues visits each of the blocks owned by the given function in the
+// order returned by AppendBlocks, calling the given visitor for each block,
+// instruction and terminator. It returns false if the visitor stopped the
+// walk early.
+//
+// The visitor must not add or remove blocks from the function during the
+// walk, but it may modify the contents of the block it is visiting.
+func WalkValues(f *Function, visitor Visitor) bool {
+	for _, block := range f.blocks {
+		if !walkBlock(block, visitor) {
+			return false
+		}
+	}
+	return true
+}
+
+// WalkValuesRPO is like WalkValues, except that it visits only the blocks
+// reachable from the given start block, in reverse postorder.
+func WalkValuesRPO(start *BasicBlock, visitor Visitor) bool {
+	for _, block := range AppendBlocksRPO(start, nil) {
+		if !walkBlock(block, visitor) {
+			return false
+		}
+	}
+	return true
+}
+
+func walkBlock(block *BasicBlock, visitor Visitor) bool {
+	if !visitor.EnterBlock(block) {
+		return false
+	}
+	for _, v := range block.Instructions {
+		if !visitor.VisitValue(v) {
+			return false
+		}
+	}
+	if block.Terminator != nil && !visitor.VisitTerminator(block.Terminator) {
+		return false
+	}
+	return visitor.LeaveBlock(block)
+}
+
+// WalkBlocksRPO calls the given function for each block reachable from the
+// given start block, in reverse postorder, until the function returns
+// false. It returns false if the walk was stopped early.
+//
+// Reverse postorder visits each block before any of its successors, except
+// where the successor is reached by a loop's back edge.
+func WalkBlocksRPO(start *BasicBlock, fn func(block *BasicBlock) bool) bool {
+	for _, block := range AppendBlocksRPO(start, nil) {
+		if !fn(block) {
+			return false
+		}
+	}
+	return true
+}
+
+// AppendBlocksRPO appends to the given slice all of the blocks reachable from
+// the given start block, in reverse postorder, and returns the new slice.
+//
+// The ordering is deterministic for a particular graph, since it depends
+// only on the order of the successors of each terminator.
+func AppendBlocksRPO(start *BasicBlock, to []*BasicBlock) []*BasicBlock {
+	type frame struct {
+		block *BasicBlock
+		succs []*BasicBlock
+	}
+	base := len(to)
+	visited := NewBasicBlockSet(start)
+	stack := []frame{{start, start.Terminator.AppendSuccessors(nil)}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if len(top.succs) > 0 {
+			succ := top.succs[0]
+			top.succs = top.succs[1:]
+			if !visited.Has(succ) {
+				visited.Add(succ)
+				stack = append(stack, frame{succ, succ.Terminator.AppendSuccessors(nil)})
+			}
+			continue
+		}
+		to = append(to, top.block)
+		stack = stack[:len(stack)-1]
+	}
+	post := to[base:]
+	for i, j := 0, len(post)-1; i < j; i, j = i+1, j-1 {
+		post[i], post[j] = post[j], post[i]
+	}
+	return to
+}
+
+// WalkOperands calls the given function for each non-nil operand of the
+// given value, in order, until the function returns false for its second
+// result. It returns false if the walk was stopped early.
+//
+// The first result of the function is the operand to use in place of the
+// given one, which allows the walk to also rewrite operands. The function
+// should return the operand it was given to leave it unchanged. For Phi
+// nodes, the operands are the values of the candidates.
+func WalkOperands(v *Value, fn func(operand *Value) (repl *Value, cont bool)) bool {
+	for i := 0; i < v.NumArgs(); i++ {
+		arg := v.Arg(i)
+		if arg == nil {
+			continue
+		}
+		repl, cont := fn(arg)
+		if repl != arg {
+			v.SetArg(i, repl)
+		}
+		if !cont {
+			return false
+		}
+	}
+	return true
+}
+
+// WalkTerminatorOperands is like WalkOperands but visits the value operands
+// of a terminator.
+func WalkTerminatorOperands(t *Terminator, fn func(operand *Value) (repl *Value, cont bool)) bool {
+	for i := 0; i < t.NumArgs(); i++ {
+		arg := t.Arg(i)
+		if arg.Value == nil {
+			continue
+		}
+		repl, cont := fn(arg.Value)
+		if repl != arg.Value {
+			arg.Value = repl
+			t.SetArg(i, arg)
+		}
+		if !cont {
+			return false
+		}
+	}
+	return true
+}
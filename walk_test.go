@@ -0,0 +1,98 @@
+package ossa
+
+import (
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	f := NewFunction()
+	p := f.AddParam()
+	b := NewFunctionBuilder(f)
+	entry := f.Entry()
+	exit := b.NewBlock()
+	loop := b.NewBlock() // added after exit, but comes before it in RPO
+
+	x := b.Load(p)
+	b.Jump(loop)
+
+	b.SetBlock(loop)
+	y := b.Load(x)
+	b.Branch(y, loop, exit)
+
+	b.SetBlock(exit)
+	b.Return(y)
+
+	t.Run("WalkBlocksRPO", func(t *testing.T) {
+		var got []*BasicBlock
+		WalkBlocksRPO(entry, func(block *BasicBlock) bool {
+			got = append(got, block)
+			return true
+		})
+		want := []*BasicBlock{entry, loop, exit}
+		if len(got) != len(want) {
+			t.Fatalf("visited %d blocks; want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("wrong block at position %d", i)
+			}
+		}
+	})
+
+	t.Run("WalkValues", func(t *testing.T) {
+		var events []string
+		completed := WalkValues(f, VisitorFuncs{
+			Enter: func(block *BasicBlock) bool {
+				events = append(events, "enter")
+				return true
+			},
+			Value: func(v *Value) bool {
+				events = append(events, "value")
+				return true
+			},
+			Terminator: func(t *Terminator) bool {
+				events = append(events, t.Op().String())
+				return t.Op() != OpBranch // stop at the loop block's terminator
+			},
+			Leave: func(block *BasicBlock) bool {
+				events = append(events, "leave")
+				return true
+			},
+		})
+		if completed {
+			t.Errorf("walk was not stopped")
+		}
+		want := []string{
+			"enter", "value", "OpJump", "leave", // entry
+			"enter", "OpReturn", "leave", // exit
+			"enter", "value", "OpBranch", // loop
+		}
+		if len(events) != len(want) {
+			t.Fatalf("wrong events %#v; want %#v", events, want)
+		}
+		for i := range want {
+			if events[i] != want[i] {
+				t.Fatalf("wrong events %#v; want %#v", events, want)
+			}
+		}
+	})
+
+	t.Run("WalkOperands", func(t *testing.T) {
+		z := AuxLiteral(nil)
+		WalkOperands(y, func(operand *Value) (*Value, bool) {
+			return z, true
+		})
+		if y.Arg(0) != z {
+			t.Errorf("operand was not replaced")
+		}
+		WalkTerminatorOperands(exit.Terminator, func(operand *Value) (*Value, bool) {
+			if operand != y {
+				t.Errorf("wrong terminator operand")
+			}
+			return operand, false
+		})
+		if exit.Terminator.Arg(0).Value != y {
+			t.Errorf("terminator operand was changed")
+		}
+	})
+}